@@ -1,5 +1,8 @@
 #!/bin/sh
 
+#apply pending schema migrations before the new functions are deployed
+go run ./cmd/migrate -dir db/migrations up || exit 1
+
 aws cloudformation package --template-file template.yaml --s3-bucket fmt-api-deploy --output-template-file packaged-template.yaml
 
-aws cloudformation deploy --template-file packaged-template.yaml --stack-name fmt-api-stack --capabilities CAPABILITY_IAM
\ No newline at end of file
+aws cloudformation deploy --template-file packaged-template.yaml --stack-name fmt-api-stack --capabilities CAPABILITY_IAM
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/webhooks"
+)
+
+const usage = `usage: dispatcher [-once] [-interval duration] [-dead n] [-requeue id]
+
+Delivers the outbox events to the consumers and sends the webhook deliveries,
+the same work done by the dispatcher lambda. Without flags it polls the outbox until interrupted.
+
+The database connection uses the FMT_DBUSER, FMT_DBPASS, FMT_DBHOST and FMT_DBNAME
+environment variables, the same ones used by the lambda functions.
+`
+
+func main() {
+	once := flag.Bool("once", false, "deliver the events due and exit")
+	interval := flag.Duration("interval", 5*time.Second, "polling interval")
+	dead := flag.Int("dead", 0, "list the last n dead events and exit")
+	requeue := flag.String("requeue", "", "move the dead event back to pending and exit")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	store := db.NewMySQLStore()
+	d := outbox.NewDispatcher(store)
+	sender := webhooks.NewSender(webhooks.NewRepository(store))
+	err := d.Register(trips.NewNotifier(trips.NewRepository(store)))
+	if err == nil {
+		err = d.Register(webhooks.NewFanout(webhooks.NewRepository(store)))
+	}
+	if err == nil {
+		err = run(d, sender, *once, *interval, *dead, *requeue)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dispatcher: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(d *outbox.Dispatcher, sender *webhooks.Sender, once bool, interval time.Duration, dead int, requeue string) error {
+	if requeue != "" {
+		return d.Requeue(requeue)
+	}
+	if dead > 0 {
+		entries, err := d.Dead(dead)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	for {
+		result, err := d.Drain(ctx)
+		if result.Delivered+result.Retried+result.Dead > 0 {
+			fmt.Printf("delivered %d, retried %d, dead %d\n", result.Delivered, result.Retried, result.Dead)
+		}
+		if err == nil {
+			result, err = sender.Drain(ctx)
+			if result.Delivered+result.Retried+result.Dead > 0 {
+				fmt.Printf("webhooks delivered %d, retried %d, failed %d\n", result.Delivered, result.Retried, result.Dead)
+			}
+		}
+		if err != nil || once {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
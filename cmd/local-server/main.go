@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/routers"
+)
+
+const usage = `usage: local-server [-addr address] [-template file]
+
+Serves the API routes declared in the SAM template with the routers of the lambda
+functions, without SAM or Docker. The requests are converted into API Gateway proxy
+requests and handled one at a time, like a single lambda container.
+
+The database connection uses the FMT_DBUSER, FMT_DBPASS, FMT_DBHOST and FMT_DBNAME
+environment variables and the identity provider uses FMT_AUTH_PROVIDER, the same ones
+used by the lambda functions. FMT_AUTH_PROVIDER=local signs the tokens without Cognito.
+`
+
+func main() {
+	addr := flag.String("addr", ":3000", "address the server listens on")
+	template := flag.String("template", "template.yaml", "SAM template with the function routes")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "local-server: ", log.LstdFlags)
+	err := run(*addr, *template, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(addr, template string, logger *log.Logger) error {
+	f, err := os.Open(template)
+	if err != nil {
+		return err
+	}
+	templateRoutes, err := routers.ParseTemplate(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	routes := newRoutes(templateRoutes)
+
+	provider, providerErr := auth.NewProviderFromEnv()
+	if providerErr != nil {
+		logger.Print("identity provider not configured: " + providerErr.Error())
+	}
+	s, err := newServer(routes, routers.Functions(db.NewMySQLStore(), provider, providerErr), logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("%d routes listening on %s", len(routes), addr)
+	return http.ListenAndServe(addr, s)
+}
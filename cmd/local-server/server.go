@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/routers"
+	"github.com/google/uuid"
+)
+
+//route is a template route with the segments of its path
+type route struct {
+	routers.Route
+	segments []string
+}
+
+//newRoutes splits the paths of the template routes
+func newRoutes(templateRoutes []routers.Route) []route {
+	routes := make([]route, len(templateRoutes))
+	for i, rt := range templateRoutes {
+		routes[i] = route{Route: rt, segments: splitPath(rt.Path)}
+	}
+	return routes
+}
+
+//splitPath returns the segments of the path without the empty ones
+func splitPath(path string) []string {
+	segments := []string{}
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+//match returns the path parameters of the request segments when they fit the route path. The score prefers
+//the literal segments over the parameters from the first segment on, like the API Gateway.
+func (rt route) match(segments []string) (map[string]string, int, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, 0, false
+	}
+	params := map[string]string{}
+	score := 0
+	for i, s := range rt.segments {
+		score <<= 1
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			params[s[1:len(s)-1]] = segments[i]
+			continue
+		}
+		if s != segments[i] {
+			return nil, 0, false
+		}
+		score |= 1
+	}
+	return params, score, true
+}
+
+//server runs the function routers of the template routes like the API Gateway
+type server struct {
+	//mu serializes the requests, the routers keep the audit context in a package variable like a lambda container
+	mu      sync.Mutex
+	routes  []route
+	routers map[string]routers.Router
+	logger  *log.Logger
+}
+
+//newServer checks every route has the router of its function
+func newServer(routes []route, handlers map[string]routers.Router, logger *log.Logger) (*server, error) {
+	for _, rt := range routes {
+		if _, ok := handlers[rt.Function]; !ok {
+			return nil, errors.New("no router for " + rt.Function + " " + rt.Method + " " + rt.Path)
+		}
+	}
+	return &server{routes: routes, routers: handlers, logger: logger}, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := s.serve(w, r)
+	s.logger.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), status, time.Since(start).Round(time.Millisecond))
+}
+
+func (s *server) serve(w http.ResponseWriter, r *http.Request) int {
+	segments := []string{}
+	for _, escaped := range splitPath(r.URL.EscapedPath()) {
+		segment, err := url.PathUnescape(escaped)
+		if err != nil {
+			return writeMessage(w, http.StatusBadRequest, "Invalid path")
+		}
+		segments = append(segments, segment)
+	}
+
+	//the routes of the most specific path, one for each method
+	var matched []route
+	var params map[string]string
+	best := -1
+	for _, rt := range s.routes {
+		p, score, ok := rt.match(segments)
+		if !ok || score < best {
+			continue
+		}
+		if score > best {
+			matched, params, best = nil, p, score
+		}
+		if len(matched) == 0 || matched[0].Path == rt.Path {
+			matched = append(matched, rt)
+		}
+	}
+	if len(matched) == 0 {
+		return writeMessage(w, http.StatusNotFound, "Not Found")
+	}
+
+	methods := []string{}
+	for _, rt := range matched {
+		methods = append(methods, rt.Method)
+		if rt.Method != r.Method && rt.Method != "ANY" {
+			continue
+		}
+		req, err := proxyRequest(r, rt, params)
+		if err != nil {
+			return writeMessage(w, http.StatusBadRequest, err.Error())
+		}
+		return s.invoke(w, rt, req)
+	}
+
+	//the preflight requests are answered by the API Gateway CORS configuration
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(append(methods, http.MethodOptions), ","))
+		w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+		w.WriteHeader(http.StatusOK)
+		return http.StatusOK
+	}
+	return writeMessage(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+}
+
+//invoke runs the router of the route function and writes the proxy response
+func (s *server) invoke(w http.ResponseWriter, rt route, req events.APIGatewayProxyRequest) int {
+	s.mu.Lock()
+	response, err := s.routers[rt.Function](req)
+	s.mu.Unlock()
+	if err != nil {
+		//the API Gateway hides the lambda errors
+		s.logger.Printf("%s %s: %s", rt.Function, req.RequestContext.RequestID, err.Error())
+		return writeMessage(w, http.StatusBadGateway, "Internal server error")
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			s.logger.Printf("%s %s: invalid base64 body", rt.Function, req.RequestContext.RequestID)
+			return writeMessage(w, http.StatusBadGateway, "Internal server error")
+		}
+	}
+	for k, values := range response.MultiValueHeaders {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	for k, v := range response.Headers {
+		w.Header().Set(k, v)
+	}
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+	return status
+}
+
+//proxyRequest converts the http request into the API Gateway proxy request of the route
+func proxyRequest(r *http.Request, rt route, params map[string]string) (events.APIGatewayProxyRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Resource:                        rt.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         map[string]string{},
+		MultiValueHeaders:               map[string][]string{},
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: map[string][]string{},
+		PathParameters:                  params,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:    uuid.New().String(),
+			Stage:        "local",
+			ResourcePath: rt.Path,
+			HTTPMethod:   r.Method,
+			Path:         r.URL.Path,
+		},
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.RequestContext.Identity.SourceIP = host
+	}
+
+	//the single value maps keep the last value, like the API Gateway
+	for k, values := range r.Header {
+		req.Headers[k] = values[len(values)-1]
+		req.MultiValueHeaders[k] = values
+	}
+	req.Headers["Host"] = r.Host
+	for k, values := range r.URL.Query() {
+		req.QueryStringParameters[k] = values[len(values)-1]
+		req.MultiValueQueryStringParameters[k] = values
+	}
+
+	if utf8.Valid(body) {
+		req.Body = string(body)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.IsBase64Encoded = true
+	}
+	return req, nil
+}
+
+//writeMessage writes the API Gateway error responses
+func writeMessage(w http.ResponseWriter, status int, message string) int {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+	return status
+}
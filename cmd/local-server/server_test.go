@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/routers"
+	"github.com/stretchr/testify/assert"
+)
+
+func templateRoutes(t *testing.T) []route {
+	f, err := os.Open("../../template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	routes, err := routers.ParseTemplate(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRoutes(routes)
+}
+
+func TestTemplateRoutes(t *testing.T) {
+	routes := templateRoutes(t)
+
+	//every function with Api events has its router
+	_, err := newServer(routes, routers.Functions(db.NewMemoryStore(), nil, nil), log.New(ioutil.Discard, "", 0))
+	assert.Nil(t, err)
+
+	found := false
+	for _, rt := range routes {
+		assert.NotEqual(t, "DispatcherFunction", rt.Function)
+		if rt.Path == "/trips/{id}/itineraries/{itinerary_id}" && rt.Method == "GET" {
+			found = true
+			assert.Equal(t, "TripsFunction", rt.Function)
+			assert.Equal(t, []string{"trips", "{id}", "itineraries", "{itinerary_id}"}, rt.segments)
+		}
+	}
+	assert.True(t, found)
+
+	_, err = newServer(routes, map[string]routers.Router{}, log.New(ioutil.Discard, "", 0))
+	assert.NotNil(t, err)
+}
+
+func TestServer(t *testing.T) {
+	requests := []events.APIGatewayProxyRequest{}
+	routes := newRoutes([]routers.Route{
+		{Function: "A", Path: "/a/{id}", Method: "GET"},
+		{Function: "A", Path: "/a/{id}", Method: "PATCH"},
+		{Function: "A", Path: "/a/latest", Method: "GET"},
+	})
+	s, err := newServer(routes, map[string]routers.Router{
+		"A": func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			requests = append(requests, req)
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"ETag": `"1"`},
+				Body:       `{"resource":"` + req.Resource + `"}`,
+			}, nil
+		},
+	}, log.New(ioutil.Discard, "", 0))
+	if !assert.Nil(t, err) {
+		return
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	req, _ := http.NewRequest("PATCH", server.URL+"/a/caf%C3%A9?lang=pt&tag=x&tag=y", strings.NewReader(`{"title":"new"}`))
+	req.Header.Set("Authorization", "token")
+	req.Header.Set("If-Match", `"1"`)
+	response, err := http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, `"1"`, response.Header.Get("ETag"))
+		response.Body.Close()
+	}
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, "/a/{id}", requests[0].Resource)
+		assert.Equal(t, "PATCH", requests[0].HTTPMethod)
+		assert.Equal(t, map[string]string{"id": "café"}, requests[0].PathParameters)
+		assert.Equal(t, "pt", requests[0].QueryStringParameters["lang"])
+		assert.Equal(t, []string{"x", "y"}, requests[0].MultiValueQueryStringParameters["tag"])
+		assert.Equal(t, "token", requests[0].Headers["Authorization"])
+		assert.Equal(t, `"1"`, requests[0].Headers["If-Match"])
+		assert.Equal(t, `{"title":"new"}`, requests[0].Body)
+		assert.NotEmpty(t, requests[0].RequestContext.RequestID)
+	}
+
+	//the literal segments win over the path parameters
+	response, err = http.Get(server.URL + "/a/latest")
+	if assert.Nil(t, err) {
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		assert.JSONEq(t, `{"resource":"/a/latest"}`, string(body))
+	}
+
+	response, err = http.Post(server.URL+"/a/latest", "application/json", nil)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+		response.Body.Close()
+	}
+
+	response, err = http.Get(server.URL + "/b")
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		response.Body.Close()
+	}
+
+	req, _ = http.NewRequest("OPTIONS", server.URL+"/a/1", nil)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization,If-Match")
+	response, err = http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "GET,PATCH,OPTIONS", response.Header.Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization,If-Match", response.Header.Get("Access-Control-Allow-Headers"))
+		response.Body.Close()
+	}
+	assert.Len(t, requests, 2)
+}
+
+func TestServerRouters(t *testing.T) {
+	s, err := newServer(templateRoutes(t), routers.Functions(db.NewMemoryStore(), nil, nil), log.New(ioutil.Discard, "", 0))
+	if !assert.Nil(t, err) {
+		return
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	token := apitest.Token("test_admin", "Admin")
+
+	req, _ := http.NewRequest("POST", server.URL+"/categories", strings.NewReader(`{"title": {"pt": "Transporte"}}`))
+	req.Header.Set("Authorization", token)
+	response, err := http.DefaultClient.Do(req)
+	if !assert.Nil(t, err) {
+		return
+	}
+	category := struct {
+		ID string `json:"id"`
+	}{}
+	json.NewDecoder(response.Body).Decode(&category)
+	response.Body.Close()
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+
+	req, _ = http.NewRequest("GET", server.URL+"/categories/"+category.ID, nil)
+	req.Header.Set("Authorization", token)
+	response, err = http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, `"0"`, response.Header.Get("ETag"))
+		response.Body.Close()
+	}
+
+	req, _ = http.NewRequest("GET", server.URL+"/categories/"+category.ID, nil)
+	response, err = http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+		response.Body.Close()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+)
+
+const usage = `usage: migrate [-dir path] <command> [args]
+
+commands:
+  up [n]         apply all (or the next n) pending migrations
+  down [n]       revert the last (or the last n) applied migrations
+  status         list the migrations and when they were applied
+  create <name>  write empty up and down scripts for a new migration
+
+The database connection uses the FMT_DBUSER, FMT_DBPASS, FMT_DBHOST and FMT_DBNAME
+environment variables, the same ones used by the lambda functions.
+`
+
+func main() {
+	dir := flag.String("dir", "db/migrations", "migrations directory")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*dir, flag.Arg(0), flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(dir, command string, args []string) error {
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("create expects the migration name")
+		}
+		up, down, err := db.CreateMigration(dir, args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Println("created " + up)
+		fmt.Println("created " + down)
+		return nil
+	}
+
+	steps := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of steps %q", args[0])
+		}
+		steps = n
+	}
+
+	migrations, err := db.LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	session := conn.NewSession(nil)
+	defer session.Close()
+
+	switch command {
+	case "up":
+		done, err := db.MigrateUp(session, migrations, steps)
+		for _, m := range done {
+			fmt.Printf("applied  %d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		done, err := db.MigrateDown(session, migrations, steps)
+		for _, m := range done {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("no migration to revert")
+		}
+		return err
+	case "status":
+		status, err := db.MigrationStatus(session, migrations)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.Applied {
+				applied = m.AppliedDate.Format(time.RFC3339)
+			}
+			fmt.Printf("%-25s %d_%s\n", applied, m.Version, m.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+)
+
+const usage = `usage: purge [-retention duration]
+
+Removes the trips, itineraries and events soft deleted before the retention window,
+the same work done by the purge lambda. The children of the purged records are removed
+by the database cascades.
+
+The retention defaults to the FMT_PURGE_RETENTION environment variable or 30 days.
+The database connection uses the FMT_DBUSER, FMT_DBPASS, FMT_DBHOST and FMT_DBNAME
+environment variables, the same ones used by the lambda functions.
+`
+
+func main() {
+	retention := flag.Duration("retention", db.PurgeRetention(), "time the soft deleted records are kept")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	purged, err := db.PurgeAll(db.NewMySQLStore(), time.Now().Add(-*retention))
+	tables := []string{}
+	for table := range purged {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("%s: purged %d\n", table, purged[table])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "purge: "+err.Error())
+		os.Exit(1)
+	}
+}
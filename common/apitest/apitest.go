@@ -0,0 +1,75 @@
+package apitest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/feedmytrip/api/common"
+)
+
+const (
+	//Issuer defines the iss claim of the test tokens
+	Issuer = "https://apitest.feedmytrip.local"
+	//Audience defines the client_id claim of the test tokens
+	Audience = "apitest"
+	//KeyID defines the kid header of the test tokens
+	KeyID = "apitest"
+)
+
+var key *rsa.PrivateKey
+
+//init makes common.GetTokenUser trust the tokens signed by this package, it's only imported by the tests
+func init() {
+	var err error
+	key, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	common.SetTokenVerifier(&common.TokenVerifier{
+		Keys:     common.NewStaticKeySet(map[string]*rsa.PublicKey{KeyID: &key.PublicKey}),
+		Issuer:   Issuer,
+		Audience: Audience,
+		TokenUse: []string{"access"},
+	})
+}
+
+//Token returns an access token for the user and groups to call the handlers in tests without AWS Cognito
+func Token(userID string, groups ...string) string {
+	return Sign(KeyID, map[string]interface{}{
+		"sub":            userID,
+		"cognito:groups": groups,
+		"iss":            Issuer,
+		"client_id":      Audience,
+		"token_use":      "access",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+}
+
+//Key returns the private key signing the test tokens, it lets the local identity provider issue tokens accepted by the handlers
+func Key() *rsa.PrivateKey {
+	return key
+}
+
+//Sign returns a RS256 token with the claims signed by the test key
+func Sign(kid string, claims map[string]interface{}) string {
+	return SignWith(key, kid, claims)
+}
+
+//SignWith returns a RS256 token with the claims signed by the private key
+func SignWith(privateKey *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": kid,
+	})
+	payload, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -4,14 +4,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/gbrlsnchs/jwt"
 )
 
 var (
@@ -63,7 +61,6 @@ func ParseRequestFilters(request events.APIGatewayProxyRequest) (string, map[str
 
 //TokenUser represents user information form the cognito token in Authorization header
 type TokenUser struct {
-	*jwt.JWT
 	UserID       string   `json:"sub"`
 	Groups       []string `json:"cognito:groups"`
 	LanguageCode string   `json:"custom:language_code"`
@@ -71,34 +68,50 @@ type TokenUser struct {
 
 //IsAdmin verify if the user is in the Admin group
 func (t *TokenUser) IsAdmin() bool {
-	return strings.Contains(strings.Join(t.Groups, ","), "Admin")
+	return inAdminGroup(t.Groups)
 }
 
-//GetTokenUser return the userID and Groups from the request access token
-func GetTokenUser(request events.APIGatewayProxyRequest) *TokenUser {
-	tokenUser := &TokenUser{}
-	jwtPayload, _, _ := jwt.Parse(request.Headers["Authorization"])
-	jwt.Unmarshal(jwtPayload, tokenUser)
-	return tokenUser
+//GetTokenUser verifies the request access token and return the userID and Groups, invalid tokens return a *TokenError
+func GetTokenUser(request events.APIGatewayProxyRequest) (*TokenUser, error) {
+	return currentTokenVerifier().Verify(request.Headers["Authorization"])
 }
 
 //IsTokenUserAdmin check if token user is from the Admin group
 func IsTokenUserAdmin(request events.APIGatewayProxyRequest) bool {
-	user := GetTokenUser(request)
-	if len(user.Groups) <= 0 {
+	user, err := GetTokenUser(request)
+	if err != nil || len(user.Groups) <= 0 {
 		return false
 	}
-	return strings.Contains(strings.Join(user.Groups, ","), "Admin")
+	return inAdminGroup(user.Groups)
+}
+
+//inAdminGroup compares the whole group names, groups like SuperAdmin aren't admins
+func inAdminGroup(groups []string) bool {
+	for _, g := range groups {
+		if g == "Admin" {
+			return true
+		}
+	}
+	return false
 }
 
 type apiErr struct {
-	APIError string `json:"error"`
+	APIError string      `json:"error"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+//detailedError represents errors with structured attributes to include in the api error response
+type detailedError interface {
+	Detail() interface{}
 }
 
 //APIError generates an api error message response with the defines error and status code
 func APIError(statusCode int, err error) (events.APIGatewayProxyResponse, error) {
 	e := apiErr{}
 	e.APIError = err.Error()
+	if d, ok := err.(detailedError); ok {
+		e.Details = d.Detail()
+	}
 	jsonBytes, err := json.Marshal(e)
 	return events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+//ErrPreconditionFailed is returned when the If-Match header doesn't match the current version of the resource
+var ErrPreconditionFailed = errors.New("the resource was changed, load it again and retry with the new ETag")
+
+//ETag returns the entity tag of a resource version
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+//Header returns the request header ignoring the name case, API Gateway keeps the case sent by the client
+func Header(request events.APIGatewayProxyRequest, name string) string {
+	if v, ok := request.Headers[name]; ok {
+		return v
+	}
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+//IfMatch returns the resource version required by the If-Match header, -1 when the request accepts any version.
+//A list of tags matches when any of them is the version returned by current, which is only loaded for lists.
+//Tags that aren't a version of the resource return ErrPreconditionFailed.
+func IfMatch(request events.APIGatewayProxyRequest, current func() (int64, error)) (int64, error) {
+	header := strings.TrimSpace(Header(request, "If-Match"))
+	if header == "" || header == "*" {
+		return -1, nil
+	}
+	if !strings.Contains(header, ",") {
+		version, ok := tagVersion(header)
+		if !ok {
+			return 0, ErrPreconditionFailed
+		}
+		return version, nil
+	}
+
+	//the update still checks the returned version, so a concurrent change fails the precondition
+	version, err := current()
+	if err != nil {
+		return 0, ErrPreconditionFailed
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if v, ok := tagVersion(tag); ok && v == version {
+			return version, nil
+		}
+	}
+	return 0, ErrPreconditionFailed
+}
+
+//tagVersion parses the version of a strong entity tag, the weak tags never match the If-Match header
+func tagVersion(tag string) (int64, bool) {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+//NotModified checks if the If-None-Match header of the request has the entity tag, the weak tags are also compared
+func NotModified(request events.APIGatewayProxyRequest, etag string) bool {
+	header := strings.TrimSpace(Header(request, "If-None-Match"))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+//APIVersionedResponse generates the api response of a resource version with the ETag header
+func APIVersionedResponse(object interface{}, version int64, statuscode int) (events.APIGatewayProxyResponse, error) {
+	response, err := APIResponse(object, statuscode)
+	if response.StatusCode == statuscode {
+		response.Headers["ETag"] = ETag(version)
+	}
+	return response, err
+}
+
+//APIConditionalResponse generates the GET response of a resource version, the requests informing the current
+//ETag in the If-None-Match header receive a 304 without body
+func APIConditionalResponse(request events.APIGatewayProxyRequest, object interface{}, version int64) (events.APIGatewayProxyResponse, error) {
+	etag := ETag(version)
+	if !NotModified(request, etag) {
+		return APIVersionedResponse(object, version, http.StatusOK)
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusNotModified,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin":      "*",
+			"Access-Control-Allow-Credentials": "true",
+			"ETag":                             etag,
+		},
+	}, nil
+}
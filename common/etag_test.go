@@ -0,0 +1,61 @@
+package common
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int64
+		err     error
+	}{
+		{"", -1, nil},
+		{"*", -1, nil},
+		{`"3"`, 3, nil},
+		{` "3" `, 3, nil},
+		{`W/"3"`, 0, ErrPreconditionFailed},
+		{`"3", "4"`, 4, nil},
+		{`"3",W/"4"`, 0, ErrPreconditionFailed},
+		{`"3", "5"`, 0, ErrPreconditionFailed},
+		{`"abc"`, 0, ErrPreconditionFailed},
+		{`"-1"`, 0, ErrPreconditionFailed},
+		{`3`, 0, ErrPreconditionFailed},
+	}
+	//the current version is 4
+	current := func() (int64, error) { return 4, nil }
+	for _, test := range tests {
+		request := events.APIGatewayProxyRequest{Headers: map[string]string{"if-match": test.header}}
+		version, err := IfMatch(request, current)
+		assert.Equal(t, test.err, err, test.header)
+		assert.Equal(t, test.version, version, test.header)
+	}
+}
+
+func TestAPIConditionalResponse(t *testing.T) {
+	object := map[string]string{"id": "1"}
+
+	response, err := APIConditionalResponse(events.APIGatewayProxyRequest{}, object, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, `"2"`, response.Headers["ETag"])
+	assert.NotEmpty(t, response.Body)
+
+	for _, header := range []string{`"2"`, `W/"2"`, `"1", "2"`, "*"} {
+		request := events.APIGatewayProxyRequest{Headers: map[string]string{"If-None-Match": header}}
+		response, err = APIConditionalResponse(request, object, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotModified, response.StatusCode, header)
+		assert.Equal(t, `"2"`, response.Headers["ETag"])
+		assert.Empty(t, response.Body)
+	}
+
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"If-None-Match": `"1"`}}
+	response, err = APIConditionalResponse(request, object, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
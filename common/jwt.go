@@ -0,0 +1,426 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cognitoRegion     = "us-east-1"
+	cognitoUserPoolID = "us-east-1_0JwI28hrb"
+	cognitoClientID   = "2i1vka74ub2c6b3i5l6o3aaio"
+	//keySetTTL defines how long the loaded keys are used before the JWKS document is loaded again
+	keySetTTL = time.Hour
+	//keySetMinRefresh limits how often an unknown key id loads the JWKS document again
+	keySetMinRefresh = time.Minute
+	tokenLeeway      = 30 * time.Second
+)
+
+const (
+	//LocalIssuer defines the default iss claim of the tokens issued by the local identity provider
+	LocalIssuer = "feedmytrip-local"
+	//LocalAudience defines the default audience of the tokens issued by the local identity provider
+	LocalAudience = "feedmytrip"
+	//LocalKeyID defines the kid header of the tokens issued by the local identity provider
+	LocalKeyID = "local"
+)
+
+//CognitoSettings returns the AWS Cognito region, user pool and app client from the FMT_COGNITO_REGION, FMT_COGNITO_USER_POOL_ID and FMT_COGNITO_CLIENT_ID environment variables
+func CognitoSettings() (region, userPoolID, clientID string) {
+	return envOrDefault("FMT_COGNITO_REGION", cognitoRegion),
+		envOrDefault("FMT_COGNITO_USER_POOL_ID", cognitoUserPoolID),
+		envOrDefault("FMT_COGNITO_CLIENT_ID", cognitoClientID)
+}
+
+//LoadRSAPrivateKey reads a PEM encoded PKCS#1 or PKCS#8 RSA private key
+func LoadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM file %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't a RSA private key", path)
+	}
+	return key, nil
+}
+
+//TokenError is returned when the request access token is missing or can't be trusted, the handlers answer it with 401
+type TokenError struct {
+	Reason string `json:"reason"`
+}
+
+func (e *TokenError) Error() string {
+	return "invalid access token: " + e.Reason
+}
+
+//IsTokenError check if the error was caused by a missing or invalid access token
+func IsTokenError(err error) bool {
+	_, ok := err.(*TokenError)
+	return ok
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+//KeySet keeps in memory the public keys of a JWKS document indexed by key id
+type KeySet struct {
+	mu      sync.Mutex
+	load    func() ([]byte, error)
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+	now     func() time.Time
+}
+
+//NewURLKeySet returns a KeySet loading the JWKS document from the url
+func NewURLKeySet(url string) *KeySet {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return &KeySet{
+		now: time.Now,
+		load: func() ([]byte, error) {
+			resp, err := client.Get(url)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("jwks %s returned status %d", url, resp.StatusCode)
+			}
+			return ioutil.ReadAll(resp.Body)
+		},
+	}
+}
+
+//NewFileKeySet returns a KeySet loading the JWKS document from a local file
+func NewFileKeySet(path string) *KeySet {
+	return &KeySet{
+		now: time.Now,
+		load: func() ([]byte, error) {
+			return ioutil.ReadFile(path)
+		},
+	}
+}
+
+//NewPrivateKeySet returns a KeySet with the public part of the PEM private key used by the local identity provider
+func NewPrivateKeySet(path, kid string) *KeySet {
+	keys := &KeySet{now: time.Now}
+	keys.load = func() ([]byte, error) {
+		key, err := LoadRSAPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys.keys = map[string]*rsa.PublicKey{kid: &key.PublicKey}
+		return nil, nil
+	}
+	return keys
+}
+
+//NewStaticKeySet returns a KeySet with fixed keys, it's never reloaded
+func NewStaticKeySet(keys map[string]*rsa.PublicKey) *KeySet {
+	return &KeySet{
+		now:     time.Now,
+		keys:    keys,
+		fetched: time.Now(),
+		load: func() ([]byte, error) {
+			return nil, nil
+		},
+	}
+}
+
+//Key returns the public key with the key id, the JWKS document is loaded again when the cache expires or the key id is unknown to support keys rotation
+func (k *KeySet) Key(kid string) (*rsa.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	age := k.now().Sub(k.fetched)
+	key, ok := k.keys[kid]
+	if ok && age < keySetTTL {
+		return key, nil
+	}
+	if k.keys == nil || age >= keySetTTL || (!ok && age >= keySetMinRefresh) {
+		err := k.refresh()
+		if err != nil && !ok {
+			return nil, err
+		}
+		if err == nil {
+			key, ok = k.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+func (k *KeySet) refresh() error {
+	data, err := k.load()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		k.fetched = k.now()
+		return nil
+	}
+
+	doc := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return fmt.Errorf("invalid jwks document: %s", err.Error())
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range doc.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return err
+		}
+		keys[jwk.Kid] = key
+	}
+	k.keys = keys
+	k.fetched = k.now()
+	return nil
+}
+
+func (jwk jsonWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus of key %q", jwk.Kid)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 {
+		return nil, fmt.Errorf("invalid exponent of key %q", jwk.Kid)
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+//TokenVerifier checks the signature and claims of the access tokens
+type TokenVerifier struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+	//TokenUse lists the accepted token_use claims, Cognito issues "id" and "access" tokens
+	TokenUse []string
+	now      func() time.Time
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ClientID  string          `json:"client_id"`
+	TokenUse  string          `json:"token_use"`
+	ExpiresAt int64           `json:"exp"`
+}
+
+//NewTokenVerifierFromEnv returns a verifier configured by the FMT_JWKS_URL or FMT_JWKS_FILE, FMT_JWT_ISSUER, FMT_JWT_AUDIENCE and FMT_JWT_TOKEN_USE environment variables.
+//By default it trusts the Cognito user pool from CognitoSettings, or the key in FMT_LOCAL_AUTH_KEY when FMT_AUTH_PROVIDER is local
+func NewTokenVerifierFromEnv() *TokenVerifier {
+	region, userPoolID, clientID := CognitoSettings()
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+	audience := clientID
+	if os.Getenv("FMT_AUTH_PROVIDER") == "local" {
+		issuer = LocalIssuer
+		audience = LocalAudience
+	}
+	issuer = envOrDefault("FMT_JWT_ISSUER", issuer)
+
+	keys := NewURLKeySet(envOrDefault("FMT_JWKS_URL", issuer+"/.well-known/jwks.json"))
+	if path := os.Getenv("FMT_JWKS_FILE"); path != "" {
+		keys = NewFileKeySet(path)
+	} else if os.Getenv("FMT_AUTH_PROVIDER") == "local" {
+		keys = NewPrivateKeySet(os.Getenv("FMT_LOCAL_AUTH_KEY"), LocalKeyID)
+	}
+	return &TokenVerifier{
+		Keys:     keys,
+		Issuer:   issuer,
+		Audience: envOrDefault("FMT_JWT_AUDIENCE", audience),
+		TokenUse: strings.Split(envOrDefault("FMT_JWT_TOKEN_USE", "id,access"), ","),
+	}
+}
+
+var (
+	verifierMu    sync.Mutex
+	tokenVerifier *TokenVerifier
+)
+
+//SetTokenVerifier replaces the verifier used by GetTokenUser
+func SetTokenVerifier(v *TokenVerifier) {
+	verifierMu.Lock()
+	defer verifierMu.Unlock()
+	tokenVerifier = v
+}
+
+func currentTokenVerifier() *TokenVerifier {
+	verifierMu.Lock()
+	defer verifierMu.Unlock()
+	if tokenVerifier == nil {
+		tokenVerifier = NewTokenVerifierFromEnv()
+	}
+	return tokenVerifier
+}
+
+//Verify checks the token signature, expiration, issuer, audience and token use and returns its user
+func (v *TokenVerifier) Verify(token string) (*TokenUser, error) {
+	token = strings.TrimSpace(token)
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	if token == "" {
+		return nil, &TokenError{Reason: "missing token"}
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &TokenError{Reason: "malformed token"}
+	}
+
+	header := tokenHeader{}
+	err := decodeTokenPart(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, &TokenError{Reason: fmt.Sprintf("unsupported algorithm %q", header.Alg)}
+	}
+
+	key, err := v.Keys.Key(header.Kid)
+	if err != nil {
+		return nil, &TokenError{Reason: err.Error()}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &TokenError{Reason: "malformed signature"}
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return nil, &TokenError{Reason: "invalid signature"}
+	}
+
+	claims := tokenClaims{}
+	err = decodeTokenPart(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+	err = v.validate(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &TokenUser{}
+	err = decodeTokenPart(parts[1], user)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserID == "" {
+		return nil, &TokenError{Reason: "missing subject"}
+	}
+	return user, nil
+}
+
+func (v *TokenVerifier) validate(claims tokenClaims) error {
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	if claims.ExpiresAt == 0 || now().Add(-tokenLeeway).Unix() >= claims.ExpiresAt {
+		return &TokenError{Reason: "token expired"}
+	}
+	if claims.Issuer != v.Issuer {
+		return &TokenError{Reason: fmt.Sprintf("unexpected issuer %q", claims.Issuer)}
+	}
+	if v.Audience != "" && !claims.hasAudience(v.Audience) {
+		return &TokenError{Reason: "unexpected audience"}
+	}
+	if len(v.TokenUse) > 0 && !contains(v.TokenUse, claims.TokenUse) {
+		return &TokenError{Reason: fmt.Sprintf("unexpected token_use %q", claims.TokenUse)}
+	}
+	return nil
+}
+
+//hasAudience checks the aud claim, a string or a list, of id tokens and the client_id claim of Cognito access tokens
+func (c tokenClaims) hasAudience(audience string) bool {
+	if c.ClientID == audience {
+		return true
+	}
+	if len(c.Audience) == 0 {
+		return false
+	}
+	single := ""
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == audience
+	}
+	list := []string{}
+	if json.Unmarshal(c.Audience, &list) == nil {
+		return contains(list, audience)
+	}
+	return false
+}
+
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return &TokenError{Reason: "malformed token"}
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return &TokenError{Reason: "malformed token"}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func envOrDefault(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
@@ -0,0 +1,199 @@
+package common
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testAudience = "client"
+)
+
+var (
+	testKeysOnce sync.Once
+	testKeys     []*rsa.PrivateKey
+)
+
+func testKey(i int) *rsa.PrivateKey {
+	testKeysOnce.Do(func() {
+		for n := 0; n < 2; n++ {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				panic(err)
+			}
+			testKeys = append(testKeys, key)
+		}
+	})
+	return testKeys[i]
+}
+
+func signTestToken(key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testClaims(changes map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub":            "user",
+		"cognito:groups": []string{"Admin"},
+		"iss":            testIssuer,
+		"client_id":      testAudience,
+		"token_use":      "access",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func testJWKS(kids ...string) []byte {
+	keys := []map[string]string{}
+	for i, kid := range kids {
+		pub := testKey(i).PublicKey
+		keys = append(keys, map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return data
+}
+
+func TestTokenVerifierClaims(t *testing.T) {
+	verifier := &TokenVerifier{
+		Keys:     NewStaticKeySet(map[string]*rsa.PublicKey{"k1": &testKey(0).PublicKey}),
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		TokenUse: []string{"id", "access"},
+	}
+	header := map[string]interface{}{"alg": "RS256", "kid": "k1"}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid access token", signTestToken(testKey(0), header, testClaims(nil)), true},
+		{"bearer prefix", "Bearer " + signTestToken(testKey(0), header, testClaims(nil)), true},
+		{"id token audience", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"client_id": nil, "aud": testAudience, "token_use": "id"})), true},
+		{"audience list", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"client_id": nil, "aud": []string{"other", testAudience}})), true},
+		{"missing token", "", false},
+		{"malformed token", "abc.def", false},
+		{"expired", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), false},
+		{"missing exp", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"exp": nil})), false},
+		{"wrong issuer", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"iss": "https://evil.test"})), false},
+		{"wrong audience", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"client_id": "other"})), false},
+		{"refresh token use", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"token_use": "refresh"})), false},
+		{"missing subject", signTestToken(testKey(0), header, testClaims(map[string]interface{}{"sub": nil})), false},
+		{"forged signature", signTestToken(testKey(1), header, testClaims(nil)), false},
+		{"unknown key id", signTestToken(testKey(0), map[string]interface{}{"alg": "RS256", "kid": "k9"}, testClaims(nil)), false},
+		{"none algorithm", signTestToken(testKey(0), map[string]interface{}{"alg": "none", "kid": "k1"}, testClaims(nil)), false},
+	}
+
+	for _, test := range tests {
+		user, err := verifier.Verify(test.token)
+		if test.valid {
+			if assert.Nil(t, err, test.name) {
+				assert.Equal(t, "user", user.UserID, test.name)
+			}
+			continue
+		}
+		assert.Nil(t, user, test.name)
+		assert.True(t, IsTokenError(err), test.name)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	mu := sync.Mutex{}
+	requests := 0
+	document := testJWKS("k1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		w.Write(document)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	keys := NewURLKeySet(server.URL)
+	keys.now = func() time.Time { return now }
+
+	_, err := keys.Key("k1")
+	assert.Nil(t, err)
+	_, err = keys.Key("k1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+
+	mu.Lock()
+	document = testJWKS("k1", "k2")
+	mu.Unlock()
+
+	_, err = keys.Key("k2")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, requests)
+
+	now = now.Add(keySetMinRefresh)
+	key, err := keys.Key("k2")
+	assert.Nil(t, err)
+	assert.Equal(t, testKey(1).PublicKey.N, key.N)
+	assert.Equal(t, 2, requests)
+
+	now = now.Add(keySetTTL)
+	server.Close()
+	_, err = keys.Key("k1")
+	assert.Nil(t, err, "cached keys are used while the JWKS url is unavailable")
+}
+
+func TestGetTokenUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(path, testJWKS("k1"), 0600))
+
+	SetTokenVerifier(&TokenVerifier{
+		Keys:     NewFileKeySet(path),
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		TokenUse: []string{"access"},
+	})
+	defer SetTokenVerifier(nil)
+
+	token := signTestToken(testKey(0), map[string]interface{}{"alg": "RS256", "kid": "k1"}, testClaims(nil))
+	user, err := GetTokenUser(events.APIGatewayProxyRequest{Headers: map[string]string{"Authorization": token}})
+	assert.Nil(t, err)
+	assert.True(t, user.IsAdmin())
+	assert.False(t, (&TokenUser{Groups: []string{"SuperAdmin"}}).IsAdmin())
+	assert.False(t, (&TokenUser{Groups: []string{"User", "Administrators"}}).IsAdmin())
+	assert.True(t, (&TokenUser{Groups: []string{"User", "Admin"}}).IsAdmin())
+
+	_, err = GetTokenUser(events.APIGatewayProxyRequest{})
+	assert.True(t, IsTokenError(err))
+	assert.False(t, IsTokenUserAdmin(events.APIGatewayProxyRequest{}))
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/google/uuid"
+)
+
+const (
+	//AuditInsert defines the audit entries of the created records, Before is empty
+	AuditInsert = "insert"
+	//AuditUpdate defines the audit entries of the changed records, Before and After keep the changed columns only
+	AuditUpdate = "update"
+	//AuditDelete defines the audit entries of the removed records, After is empty
+	AuditDelete = "delete"
+)
+
+//auditIgnoredTables defines the tables without history, they are written by the system or keep credentials
+var auditIgnoredTables = map[string]bool{
+	TableAuditLog:         true,
+	TableOutbox:           true,
+	TableWebhookDelivery:  true,
+	TableUserCredential:   true,
+	TableUserRefreshToken: true,
+}
+
+//auditHiddenColumns defines the columns left out of the audit log, the secrets and the version incremented by every update
+var auditHiddenColumns = map[string]bool{
+	"token_hash": true,
+	"secret":     true,
+	"version":    true,
+}
+
+//AuditEntry represents a change of a record in the audit log. The values are keyed by column and the translations
+//by field and language, like "title.en". ParentID keeps the record owning the changed one, like the itinerary of an
+//itinerary event, so the history of a record includes the changes of its children.
+type AuditEntry struct {
+	ID          string    `json:"id" db:"id"`
+	TableName   string    `json:"table_name" db:"table_name"`
+	RecordID    string    `json:"record_id" db:"record_id"`
+	ParentID    string    `json:"parent_id" db:"parent_id"`
+	Action      string    `json:"action" db:"action"`
+	OldValues   string    `json:"-" db:"old_values"`
+	NewValues   string    `json:"-" db:"new_values"`
+	Actor       string    `json:"actor" db:"actor"`
+	RequestID   string    `json:"request_id" db:"request_id"`
+	CreatedDate time.Time `json:"created_date" db:"created_date"`
+}
+
+//MarshalJSON writes the old and new values as the before and after objects
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	type entry AuditEntry
+	return json.Marshal(struct {
+		entry
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+	}{entry(e), rawValues(e.OldValues), rawValues(e.NewValues)})
+}
+
+func rawValues(values string) json.RawMessage {
+	if values == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(values)
+}
+
+//AuditContext identifies the author of the changes recorded in the audit log
+type AuditContext struct {
+	Actor     string
+	RequestID string
+}
+
+var audit = struct {
+	sync.RWMutex
+	context AuditContext
+}{}
+
+//SetAuditContext defines the actor and request id recorded with the next changes.
+//The lambdas handle one request at a time, so the routers set it before handling each request.
+func SetAuditContext(actor, requestID string) {
+	audit.Lock()
+	defer audit.Unlock()
+	audit.context = AuditContext{Actor: actor, RequestID: requestID}
+}
+
+func currentAuditContext() AuditContext {
+	audit.RLock()
+	defer audit.RUnlock()
+	return audit.context
+}
+
+//History returns the changes of the record and of its children, the most recent first
+func History(store Store, recordID string) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	for _, column := range []string{"record_id", "parent_id"} {
+		changes := []AuditEntry{}
+		err := store.LoadAll(TableAuditLog, map[string]string{column: recordID, "count": "none"}, &changes)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, changes...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedDate.After(entries[j].CreatedDate)
+	})
+	return entries, nil
+}
+
+//auditParentColumn returns the column referencing the parent record of the table
+func auditParentColumn(table string) string {
+	for _, cascades := range memoryCascades {
+		for _, c := range cascades {
+			if c.table == table {
+				return c.column
+			}
+		}
+	}
+	return ""
+}
+
+//auditValues returns the audited columns of the record, including the persisted translations
+func auditValues(v reflect.Value) map[string]interface{} {
+	values := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if column := field.Tag.Get("db"); column != "" {
+			if !auditHiddenColumns[column] {
+				values[column] = normalizeValue(v.Field(i))
+			}
+			continue
+		}
+		if field.Type.Name() != "Translation" || field.Tag.Get("persist") == "" {
+			continue
+		}
+		translation := v.Field(i)
+		for j := 0; j < translation.NumField(); j++ {
+			f := translation.Type().Field(j)
+			if f.Tag.Get("db") != "" && f.Tag.Get("lock") == "" {
+				values[field.Tag.Get("alias")+"."+f.Tag.Get("db")] = normalizeValue(translation.Field(j))
+			}
+		}
+	}
+	return values
+}
+
+//auditDiff returns the values that changed between before and after
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	oldValues := map[string]interface{}{}
+	newValues := map[string]interface{}{}
+	for column, value := range after {
+		a, _ := json.Marshal(before[column])
+		b, _ := json.Marshal(value)
+		if string(a) != string(b) {
+			oldValues[column] = before[column]
+			newValues[column] = value
+		}
+	}
+	return oldValues, newValues
+}
+
+//auditChange writes the audit entry of the change with the current audit context, the parent is read from the
+//values of the record
+func auditChange(w Writer, table, id, action string, before, after map[string]interface{}) error {
+	values := after
+	if values == nil {
+		values = before
+	}
+	return writeAuditEntry(w, table, id, action, values, before, after)
+}
+
+//auditUpdate writes the audit entry of the columns changed from before to after, nothing is written without changes
+func auditUpdate(w Writer, table, id string, before, after reflect.Value) error {
+	record := auditValues(after)
+	oldValues, newValues := auditDiff(auditValues(before), record)
+	if len(newValues) == 0 {
+		return nil
+	}
+	return writeAuditEntry(w, table, id, AuditUpdate, record, oldValues, newValues)
+}
+
+func writeAuditEntry(w Writer, table, id, action string, record, before, after map[string]interface{}) error {
+	ctx := currentAuditContext()
+	entry := AuditEntry{
+		ID:          uuid.New().String(),
+		TableName:   table,
+		RecordID:    id,
+		Action:      action,
+		Actor:       ctx.Actor,
+		RequestID:   ctx.RequestID,
+		CreatedDate: time.Now(),
+	}
+	if parent := auditParentColumn(table); parent != "" {
+		entry.ParentID, _ = record[parent].(string)
+	}
+	if before != nil {
+		b, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		entry.OldValues = string(b)
+	}
+	if after != nil {
+		b, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		entry.NewValues = string(b)
+	}
+	return w.Insert(TableAuditLog, entry)
+}
+
+//loadAuditRecord loads the columns of the object template and the persisted translations of the record
+func loadAuditRecord(tx *dbr.Tx, table, id string, object interface{}) (reflect.Value, error) {
+	record := reflect.New(reflect.TypeOf(object)).Elem()
+	err := tx.Select(getTagFromInterface(object, "db", "")...).From(table).Where(dbr.Eq("id", id)).LoadOne(record.Addr().Interface())
+	if err != nil {
+		return record, err
+	}
+
+	t := record.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Name() != "Translation" || field.Tag.Get("persist") == "" {
+			continue
+		}
+		err := tx.Select(getTagFromInterface(record.Field(i).Interface(), "db", "")...).From(TableTranslation).Where(dbr.And(
+			dbr.Eq("parent_id", id),
+			dbr.Eq("field", field.Tag.Get("alias")),
+		)).LoadOne(record.Field(i).Addr().Interface())
+		if err != nil && err != dbr.ErrNotFound {
+			return record, err
+		}
+	}
+	return record, nil
+}
+
+//loadAuditRows loads the columns and translations of the records before they are removed from the database
+func loadAuditRows(tx *dbr.Tx, table string, ids ...string) (map[string]map[string]interface{}, error) {
+	records := map[string]map[string]interface{}{}
+	rows, err := tx.Select("*").From(table).Where("id IN ?", ids).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		raw := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		values := map[string]interface{}{}
+		for i, column := range columns {
+			if auditHiddenColumns[column] {
+				continue
+			}
+			values[column] = nil
+			if raw[i].Valid {
+				values[column] = raw[i].String
+			}
+		}
+		id, _ := values["id"].(string)
+		records[id] = values
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	translations := []struct {
+		ParentID string `db:"parent_id"`
+		Field    string `db:"field"`
+		PT       string `db:"pt"`
+		ES       string `db:"es"`
+		EN       string `db:"en"`
+	}{}
+	_, err = tx.Select("parent_id", "field", "pt", "es", "en").From(TableTranslation).Where("parent_id IN ?", ids).Load(&translations)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		if values, ok := records[t.ParentID]; ok {
+			values[t.Field+".pt"] = t.PT
+			values[t.Field+".es"] = t.ES
+			values[t.Field+".en"] = t.EN
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/feedmytrip/api/resources/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+type auditTestObject struct {
+	ID       string             `json:"id" db:"id" lock:"true"`
+	TripID   string             `json:"trip_id" db:"trip_id" lock:"true"`
+	Secret   string             `json:"secret" db:"secret"`
+	Duration int                `json:"duration" db:"duration"`
+	Title    shared.Translation `json:"title" table:"translation" alias:"title" on:"title.parent_id = trip_itinerary.id" embedded:"true" persist:"true"`
+}
+
+func loadAuditValues(t *testing.T, values string) map[string]interface{} {
+	if values == "" {
+		return nil
+	}
+	result := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(values), &result))
+	return result
+}
+
+func TestAuditLog(t *testing.T) {
+	defer SetAuditContext("", "")
+	s := NewMemoryStore()
+
+	SetAuditContext("user-1", "request-1")
+	err := s.Transaction(func(tx Writer) error {
+		return tx.Insert(TableTripItinerary, auditTestObject{ID: "i", TripID: "t", Secret: "s", Duration: 1, Title: shared.Translation{EN: "Day"}})
+	})
+	assert.Nil(t, err)
+
+	SetAuditContext("user-2", "request-2")
+	err = s.Transaction(func(tx Writer) error {
+		return tx.Update(TableTripItinerary, "i", auditTestObject{}, map[string]interface{}{"duration": 2, "title.en": "First day", "title.pt": ""})
+	})
+	assert.Nil(t, err)
+
+	//changes without different values aren't recorded
+	err = s.Transaction(func(tx Writer) error {
+		return tx.Update(TableTripItinerary, "i", auditTestObject{}, map[string]interface{}{"duration": 2})
+	})
+	assert.Nil(t, err)
+
+	//changes discarded by the transaction aren't recorded
+	err = s.Transaction(func(tx Writer) error {
+		err := tx.Update(TableTripItinerary, "i", auditTestObject{}, map[string]interface{}{"duration": 3})
+		if err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+
+	SetAuditContext("user-3", "request-3")
+	err = s.Transaction(func(tx Writer) error {
+		return tx.Delete(TableTripItinerary, "i")
+	})
+	assert.Nil(t, err)
+
+	entries, err := History(s, "i")
+	assert.Nil(t, err)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+
+	deleted, updated, inserted := entries[0], entries[1], entries[2]
+	assert.Equal(t, AuditInsert, inserted.Action)
+	assert.Equal(t, "user-1", inserted.Actor)
+	assert.Equal(t, "request-1", inserted.RequestID)
+	assert.Equal(t, "t", inserted.ParentID)
+	assert.Nil(t, loadAuditValues(t, inserted.OldValues))
+	after := loadAuditValues(t, inserted.NewValues)
+	assert.Equal(t, "Day", after["title.en"])
+	assert.NotContains(t, after, "secret")
+
+	assert.Equal(t, AuditUpdate, updated.Action)
+	assert.Equal(t, "user-2", updated.Actor)
+	assert.Equal(t, map[string]interface{}{"duration": 1.0, "title.en": "Day"}, loadAuditValues(t, updated.OldValues))
+	assert.Equal(t, map[string]interface{}{"duration": 2.0, "title.en": "First day"}, loadAuditValues(t, updated.NewValues))
+
+	assert.Equal(t, AuditDelete, deleted.Action)
+	assert.Equal(t, "request-3", deleted.RequestID)
+	assert.Equal(t, "First day", loadAuditValues(t, deleted.OldValues)["title.en"])
+	assert.Nil(t, loadAuditValues(t, deleted.NewValues))
+
+	//the history of the parent includes the changes of the children
+	entries, err = History(s, "t")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+
+	body, err := json.Marshal(updated)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"before":{"duration":1,"title.en":"Day"}`)
+	assert.Contains(t, string(body), `"after":{"duration":2,"title.en":"First day"}`)
+}
+
+func TestAuditIgnoredTables(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		return tx.Insert(TableOutbox, auditTestObject{ID: "o"})
+	})
+	assert.Nil(t, err)
+
+	entries := []AuditEntry{}
+	assert.Nil(t, s.LoadAll(TableAuditLog, map[string]string{}, &entries))
+	assert.Empty(t, entries)
+}
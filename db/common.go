@@ -1,13 +1,10 @@
 package db
 
 import (
-	"database/sql"
 	"fmt"
 	"reflect"
 	"strconv"
-	"strings"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/gocraft/dbr"
 	"github.com/gocraft/dbr/dialect"
 )
@@ -43,19 +40,36 @@ const (
 	TableUser = "user"
 	//TableTranslation defines the translation entities database table
 	TableTranslation = "translation"
+	//TableUserCredential defines the local identity provider accounts database table
+	TableUserCredential = "user_credential"
+	//TableUserRefreshToken defines the local identity provider refresh tokens database table
+	TableUserRefreshToken = "user_refresh_token"
+	//TableOutbox defines the domain events waiting to be dispatched database table
+	TableOutbox = "outbox"
+	//TableWebhookSubscription defines the webhook subscriptions entities database table
+	TableWebhookSubscription = "webhook_subscription"
+	//TableWebhookDelivery defines the webhook deliveries entities database table
+	TableWebhookDelivery = "webhook_delivery"
+	//TableAuditLog defines the records changes history database table
+	TableAuditLog = "audit_log"
 )
 
-type dbResult struct {
-	Metadata metadata                 `json:"metadata"`
-	Data     []map[string]interface{} `json:"data"`
-	Errors   []error                  `json:"errors"`
+//ListResult is the response of the selects, the records of the page are in data
+type ListResult struct {
+	Metadata ListMetadata `json:"metadata"`
+	Data     interface{}  `json:"data"`
+	Errors   []error      `json:"errors"`
 }
 
-type metadata struct {
+//ListMetadata describes the page of the select, the totals are -1 with count=none
+type ListMetadata struct {
 	Page          int    `json:"page"`
 	Total         int    `json:"total" db:"total"`
 	TotalFiltered int    `json:"total_filtered" db:"total_filtered"`
+	Count         string `json:"count"`
 	RecorsPerPage int    `json:"records_per_page"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+	PrevCursor    string `json:"prev_cursor,omitempty"`
 	Source        string `json:"source"`
 }
 
@@ -81,84 +95,41 @@ func getTagFromInterface(object interface{}, tag, alias string) []string {
 	return results
 }
 
-func checkFiltersInParams(params map[string]string) bool {
-	if _, ok := params["filter"]; ok {
-		return true
-	}
-	for k := range params {
-		if k != "page" && k != "results" {
-			return true
-		}
-	}
-	return false
-}
-
-func loadTableMetadata(session *dbr.Session, table string, params map[string]string, meta objectMetadata) (metadata, error) {
-	var m metadata
-	_, err := session.Select("count(id) total").From(table).Load(&m)
-	m.TotalFiltered = m.Total
-
-	if checkFiltersInParams(params) {
-		stmt := session.Select("count(" + table + ".id) total_filtered").From(table)
-		if len(meta.joins) > 0 {
-			for _, j := range meta.joins {
-				if j.alias != "" {
-					stmt.LeftJoin(dbr.I(j.table).As(j.alias), j.on)
-				} else {
-					stmt.LeftJoin(j.table, j.on)
-				}
-			}
-		}
-
-		//TODO: extract this filter code to a function
-		var filterOr dbr.Builder
-		hasFilter := false
-		if val, ok := params["filter"]; ok {
-			whereFilters := []dbr.Builder{}
-			for _, f := range meta.filters {
-				whereFilters = append(whereFilters, dbr.Expr("LOWER("+f+") like LOWER('%"+val+"%')"))
-			}
-			filterOr = dbr.Or(whereFilters...)
-			hasFilter = true
+func loadTableMetadata(session *dbr.Session, table string, params map[string]string, meta objectMetadata, where dbr.Builder, count string) (ListMetadata, error) {
+	var m ListMetadata
+	var err error
+	m.Count = count
+
+	switch count {
+	case CountNone:
+		m.Total = -1
+		m.TotalFiltered = -1
+	case CountEstimate:
+		m.Total, err = estimateTotal(session, table)
+		m.TotalFiltered = m.Total
+		if err == nil && where != nil {
+			m.TotalFiltered, err = estimateFiltered(session, countStatement(session, table, meta, where))
 		}
+	default:
+		_, err = session.Select("count(id) total").From(table).Load(&m)
+		m.TotalFiltered = m.Total
 
-		otherFilters := []dbr.Builder{}
-		for k, v := range params {
-			if k != "filter" && k != "page" && k != "results" && k != "id" && k != "order" && k != "sort" {
-				column := table + "." + k
-				filter := dbr.Eq(column, v)
-				if v == "is_not_null" {
-					filter = dbr.And(
-						dbr.Neq(column, nil),
-						dbr.Neq(column, ""),
-					)
-				} else if v == "is_null" {
-					filter = dbr.Or(
-						dbr.Eq(column, nil),
-						dbr.Eq(column, ""),
-					)
-				}
-				otherFilters = append(otherFilters, filter)
+		if where != nil {
+			fm := ListMetadata{}
+			_, err := countStatement(session, table, meta, where).Load(&fm)
+			if err != nil {
+				fmt.Println(err.Error())
+				return m, err
 			}
-		}
 
-		if hasFilter && len(otherFilters) == 0 {
-			stmt.Where(filterOr)
-		} else if !hasFilter && len(otherFilters) > 0 {
-			stmt.Where(dbr.And(otherFilters...))
-		} else if hasFilter && len(otherFilters) > 0 {
-			stmt.Where(dbr.And(filterOr, dbr.And(otherFilters...)))
-		}
-
-		fm := metadata{}
-		_, err := stmt.Load(&fm)
-		if err != nil {
-			fmt.Println(err.Error())
-			return m, err
+			m.TotalFiltered = fm.TotalFiltered
 		}
-
-		m.TotalFiltered = fm.TotalFiltered
 	}
+	m.setRequestParams(table, params)
+	return m, err
+}
+
+func (m *ListMetadata) setRequestParams(table string, params map[string]string) {
 	m.Source = table
 	m.RecorsPerPage = recorsPerPage
 	if val, ok := params["results"]; ok {
@@ -170,10 +141,38 @@ func loadTableMetadata(session *dbr.Session, table string, params map[string]str
 		i, _ := strconv.Atoi(val)
 		m.Page = i
 	}
-	return m, err
 }
 
-func loadGeneric(sess *dbr.Session, table string, params map[string]string, object interface{}, meta objectMetadata, ids []string) ([]map[string]interface{}, error) {
+func countStatement(session *dbr.Session, table string, meta objectMetadata, where dbr.Builder) *dbr.SelectStmt {
+	stmt := session.Select("count(" + table + ".id) total_filtered").From(table)
+	if len(meta.joins) > 0 {
+		for _, j := range meta.joins {
+			if j.alias != "" {
+				stmt.LeftJoin(dbr.I(j.table).As(j.alias), j.on)
+			} else {
+				stmt.LeftJoin(j.table, j.on)
+			}
+		}
+	}
+	stmt.Where(where)
+	return stmt
+}
+
+//loadGeneric load the records into a new slice of the object type
+func loadGeneric(sess *dbr.Session, table string, params map[string]string, object interface{}, meta objectMetadata, ids []string) (reflect.Value, error) {
+	where, err := buildWhereFilters(params, meta)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	where, err = withoutDeleted(table, params, where)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	p, err := parsePagination(table, params, meta)
+	if err != nil {
+		return reflect.Value{}, err
+	}
 
 	stmt := sess.Select(meta.columns...).From(table)
 	if len(meta.joins) > 0 {
@@ -193,44 +192,8 @@ func loadGeneric(sess *dbr.Session, table string, params map[string]string, obje
 		stmt.Where(dbr.Eq(table+".id", val))
 	}
 
-	//TODO: extract this filter code to a function
-	var filterOr dbr.Builder
-	hasFilter := false
-	if val, ok := params["filter"]; ok {
-		whereFilters := []dbr.Builder{}
-		for _, f := range meta.filters {
-			whereFilters = append(whereFilters, dbr.Expr("LOWER("+f+") like LOWER('%"+val+"%')"))
-		}
-		filterOr = dbr.Or(whereFilters...)
-		hasFilter = true
-	}
-
-	otherFilters := []dbr.Builder{}
-	for k, v := range params {
-		if k != "filter" && k != "page" && k != "results" && k != "id" && k != "order" && k != "sort" {
-			column := table + "." + k
-			filter := dbr.Eq(column, v)
-			if v == "is_not_null" {
-				filter = dbr.And(
-					dbr.Neq(column, nil),
-					dbr.Neq(column, ""),
-				)
-			} else if v == "is_null" {
-				filter = dbr.Or(
-					dbr.Eq(column, nil),
-					dbr.Eq(column, ""),
-				)
-			}
-			otherFilters = append(otherFilters, filter)
-		}
-	}
-
-	if hasFilter && len(otherFilters) == 0 {
-		stmt.Where(filterOr)
-	} else if !hasFilter && len(otherFilters) > 0 {
-		stmt.Where(dbr.And(otherFilters...))
-	} else if hasFilter && len(otherFilters) > 0 {
-		stmt.Where(dbr.And(filterOr, dbr.And(otherFilters...)))
+	if where != nil {
+		stmt.Where(where)
 	}
 
 	if len(ids) > 0 {
@@ -241,137 +204,57 @@ func loadGeneric(sess *dbr.Session, table string, params map[string]string, obje
 		stmt.Where(table+".id IN ?", idsInterface...)
 	}
 
-	if col, ok := params["sort"]; ok {
-		column := table + "." + col
-		if val, ok := params["order"]; ok {
-			if val == "asc" {
-				stmt.OrderAsc(column)
-			} else {
-				stmt.OrderDesc(column)
-			}
-		} else {
-			stmt.OrderDesc(column)
-		}
-	}
+	p.apply(stmt)
 
-	if val, ok := params["page"]; ok {
-		page, err := strconv.ParseUint(val, 10, 64)
-		if err != nil {
-			page = 1
-		}
-		rpp := recorsPerPage
-		if val, ok := params["results"]; ok {
-			i, err := strconv.Atoi(val)
-			if err != nil {
-				rpp = recorsPerPage
-			} else {
-				rpp = i
-			}
-		}
-		rppUint64, _ := strconv.ParseUint(strconv.Itoa(rpp), 10, 64)
-		stmt.Paginate(page, rppUint64)
-	}
+	objectType := reflect.TypeOf(object)
+	results := reflect.MakeSlice(reflect.SliceOf(objectType), 0, 0)
 
 	rows, err := stmt.Rows()
 	if err != nil {
-		return nil, err
+		return results, err
 	}
-
-	tt, err := rows.ColumnTypes()
-	if err != nil {
-		return nil, err
-	}
-
-	types := make([]reflect.Type, len(tt))
-	for i, tp := range tt {
-		st := tp.ScanType()
-		if st == nil {
-			//TODO: log -> fmt.Printf("scantype is null for column %q", tp.Name())
-			continue
-		}
-		types[i] = st
-	}
-
-	result := make([]interface{}, len(meta.fields))
-	for i := range result {
-		result[i] = reflect.New(types[i]).Interface()
-	}
-
-	results := []map[string]interface{}{}
+	defer rows.Close()
 
 	for rows.Next() {
-		err := rows.Scan(result...)
-		if err != nil {
-			return nil, err
+		record := reflect.New(objectType).Elem()
+		dest := make([]interface{}, len(meta.columns))
+		for i, target := range meta.targets {
+			dest[i] = fieldScanner{column: meta.columns[i], field: record.FieldByIndex(target)}
 		}
 
-		if getResultValue(result[0], types[0].String()) == nil {
-			return results, nil
+		err := rows.Scan(dest...)
+		if err != nil {
+			return results, err
 		}
-
-		mapJSON := make(map[string]interface{})
-		for i := 0; i < len(meta.fields); i++ {
-			f := meta.fields[i]
-			if strings.Contains(f, ".") {
-				parentField := f[0:strings.Index(f, ".")]
-				embeddedResult := make(map[string]interface{})
-				index := i
-				for index < len(meta.fields) && strings.Contains(meta.fields[index], ".") && parentField == meta.fields[index][0:strings.Index(meta.fields[index], ".")] {
-					key := meta.fields[index][strings.Index(meta.fields[index], ".")+1:]
-					embeddedResult[key] = getResultValue(result[index], types[index].String())
-					if embeddedResult[key] == nil {
-						fmt.Println("Field: " + key)
-					}
-					index++
-				}
-				mapJSON[parentField] = embeddedResult
-				i = index - 1
-			} else {
-				mapJSON[f] = getResultValue(result[i], types[i].String())
-				if mapJSON[f] == nil {
-					fmt.Println("Field: " + f)
-				}
-			}
+		//aggregated queries without matches return a single row of nulls
+		first := record.FieldByIndex(meta.targets[0])
+		if meta.aggregation && reflect.DeepEqual(first.Interface(), reflect.Zero(first.Type()).Interface()) {
+			break
 		}
-		results = append(results, mapJSON)
+		results = reflect.Append(results, record)
 	}
-	rows.Close()
-
-	return results, err
-}
-
-func getResultValue(result interface{}, interfaceType string) interface{} {
-	switch interfaceType {
-	case "sql.RawBytes":
-		return string(*result.(*sql.RawBytes))
-	case "int8":
-		return *result.(*int8)
-	case "int16":
-		return *result.(*int16)
-	case "int32":
-		return *result.(*int32)
-	case "int64":
-		return *result.(*int64)
-	case "mysql.NullTime":
-		nt := *result.(*mysql.NullTime)
-		return nt.Time
-	default:
-		fmt.Println("Type: " + interfaceType)
-		return nil
+	if err := rows.Err(); err != nil {
+		return results, err
 	}
+	p.reorder(results)
+
+	return results, nil
 }
 
 type objectMetadata struct {
 	columns        []string
+	targets        [][]int
 	groupByColumns []string
-	fields         []string
 	filters        []string
+	filterable     map[string]filterColumn
 	joins          []joinConfig
 	aggregation    bool
 }
 
 func parseObjectTagsRecursively(alias, table string, object interface{}) objectMetadata {
-	data := objectMetadata{}
+	data := objectMetadata{
+		filterable: map[string]filterColumn{},
+	}
 
 	t := reflect.TypeOf(object)
 	v := reflect.ValueOf(object)
@@ -389,10 +272,16 @@ func parseObjectTagsRecursively(alias, table string, object interface{}) objectM
 			}
 			embeddedObjectMetadata := parseObjectTagsRecursively(field.Tag.Get("alias"), field.Tag.Get("table"), v.Field(i).Interface())
 			data.columns = append(data.columns, embeddedObjectMetadata.columns...)
+			for _, target := range embeddedObjectMetadata.targets {
+				data.targets = append(data.targets, append([]int{i}, target...))
+			}
 			data.groupByColumns = append(data.groupByColumns, embeddedObjectMetadata.groupByColumns...)
-			data.fields = append(data.fields, embeddedObjectMetadata.fields...)
 			data.joins = append(data.joins, embeddedObjectMetadata.joins...)
 			data.filters = append(data.filters, embeddedObjectMetadata.filters...)
+			for k, v := range embeddedObjectMetadata.filterable {
+				v.index = append([]int{i}, v.index...)
+				data.filterable[k] = v
+			}
 			data.aggregation = embeddedObjectMetadata.aggregation
 		} else {
 			if field.Tag.Get("db") != "" {
@@ -401,20 +290,21 @@ func parseObjectTagsRecursively(alias, table string, object interface{}) objectM
 					col = alias + "." + field.Tag.Get("db")
 				}
 				data.columns = append(data.columns, col)
+				data.targets = append(data.targets, []int{i})
 				data.groupByColumns = append(data.groupByColumns, col)
 				if field.Tag.Get("filter") != "" {
 					data.filters = append(data.filters, col)
 				}
-			}
-			if field.Tag.Get("json") != "" && field.Tag.Get("embedded") == "" {
-				val := field.Tag.Get("json")
-				if alias != "" {
-					val = alias + "." + val
+				filterable := filterColumn{column: col, kind: field.Type, index: []int{i}}
+				if alias == "" {
+					data.filterable[field.Tag.Get("db")] = filterable
+				} else if field.Tag.Get("filter") != "" {
+					data.filterable[alias+"."+field.Tag.Get("db")] = filterable
 				}
-				data.fields = append(data.fields, val)
 			}
 			if field.Tag.Get("aggr") != "" {
 				data.columns = append(data.columns, field.Tag.Get("aggr"))
+				data.targets = append(data.targets, []int{i})
 				data.aggregation = true
 			}
 			if field.Tag.Get("table") != "" {
@@ -0,0 +1,334 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocraft/dbr"
+)
+
+const (
+	//FilterEq matches records where the column is equal to the value (default operator)
+	FilterEq = "eq"
+	//FilterNe matches records where the column is different from the value
+	FilterNe = "ne"
+	//FilterGt matches records where the column is greater than the value
+	FilterGt = "gt"
+	//FilterGte matches records where the column is greater than or equal to the value
+	FilterGte = "gte"
+	//FilterLt matches records where the column is lower than the value
+	FilterLt = "lt"
+	//FilterLte matches records where the column is lower than or equal to the value
+	FilterLte = "lte"
+	//FilterBetween matches records where the column is inside the two comma separated values
+	FilterBetween = "between"
+	//FilterIn matches records where the column is one of the comma separated values
+	FilterIn = "in"
+	//FilterContains matches records where the text column contains the value
+	FilterContains = "contains"
+	//FilterStartsWith matches records where the text column starts with the value
+	FilterStartsWith = "starts_with"
+	//FilterIsNull matches records where the column is null or empty
+	FilterIsNull = "is_null"
+	//FilterIsNotNull matches records where the column is not null and not empty
+	FilterIsNotNull = "is_not_null"
+)
+
+//reservedParams are querystring parameters that controls the query and are never used as filters
+var reservedParams = map[string]bool{
+	"filter":  true,
+	"page":    true,
+	"results": true,
+	"id":      true,
+	"order":   true,
+	"sort":    true,
+	"cursor":  true,
+	"count":   true,
+	//include_deleted=true keeps the soft deleted records in the results
+	"include_deleted": true,
+}
+
+//FilterError represents an invalid filter in the request querystring
+type FilterError struct {
+	Param    string `json:"param"`
+	Operator string `json:"operator,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e *FilterError) Error() string {
+	if e.Operator != "" {
+		return fmt.Sprintf("invalid filter %s[%s]: %s", e.Param, e.Operator, e.Message)
+	}
+	return fmt.Sprintf("invalid filter %s: %s", e.Param, e.Message)
+}
+
+//Detail returns the attributes used in the api error response
+func (e *FilterError) Detail() interface{} {
+	return e
+}
+
+//IsFilterError check if the error was caused by an invalid request filter
+func IsFilterError(err error) bool {
+	_, ok := err.(*FilterError)
+	return ok
+}
+
+//FilterColumns returns the columns of the object records accepted by the filters and by the sort param with
+//their types, the columns of the embedded objects are named alias.column
+func FilterColumns(object interface{}) map[string]reflect.Type {
+	columns := map[string]reflect.Type{}
+	for name, col := range parseObjectTagsRecursively("", "", object).filterable {
+		columns[name] = col.kind
+	}
+	return columns
+}
+
+//IsReservedParam check if the querystring param controls the query instead of filtering a column
+func IsReservedParam(param string) bool {
+	return reservedParams[param]
+}
+
+//IsSearchable check if the object has the text columns searched by the filter param
+func IsSearchable(object interface{}) bool {
+	return len(parseObjectTagsRecursively("", "", object).filters) > 0
+}
+
+type filterColumn struct {
+	column string
+	kind   reflect.Type
+	index  []int
+}
+
+type filterCondition struct {
+	param    string
+	column   string
+	operator string
+	values   []interface{}
+}
+
+//parseFilterKey split a querystring key like "created_date[gte]" into the param and the operator
+func parseFilterKey(key string) (string, string, error) {
+	open := strings.Index(key, "[")
+	if open < 0 {
+		return key, "", nil
+	}
+	if !strings.HasSuffix(key, "]") || open == 0 {
+		return key, "", &FilterError{Param: key, Message: "malformed filter key"}
+	}
+	return key[:open], key[open+1 : len(key)-1], nil
+}
+
+func parseRequestFilters(params map[string]string, meta objectMetadata) ([]filterCondition, error) {
+	conditions := []filterCondition{}
+	for k, v := range params {
+		if reservedParams[k] {
+			continue
+		}
+
+		param, operator, err := parseFilterKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		col, ok := meta.filterable[param]
+		if !ok {
+			return nil, &FilterError{Param: param, Operator: operator, Message: "unknown filter column"}
+		}
+
+		if operator == "" {
+			operator = FilterEq
+			if v == FilterIsNull || v == FilterIsNotNull {
+				operator = v
+			}
+		}
+
+		values, err := parseFilterValues(operator, v, col.kind)
+		if err != nil {
+			return nil, &FilterError{Param: param, Operator: operator, Message: err.Error()}
+		}
+
+		conditions = append(conditions, filterCondition{
+			param:    param,
+			column:   col.column,
+			operator: operator,
+			values:   values,
+		})
+	}
+	return conditions, nil
+}
+
+func parseFilterValues(operator, value string, kind reflect.Type) ([]interface{}, error) {
+	switch operator {
+	case FilterIsNull, FilterIsNotNull:
+		return []interface{}{}, nil
+	case FilterEq, FilterNe, FilterGt, FilterGte, FilterLt, FilterLte:
+		v, err := parseFilterValue(value, kind)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	case FilterBetween:
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("between expects two comma separated values")
+		}
+		return parseFilterList(parts, kind)
+	case FilterIn:
+		if value == "" {
+			return nil, fmt.Errorf("in expects at least one value")
+		}
+		return parseFilterList(strings.Split(value, ","), kind)
+	case FilterContains, FilterStartsWith:
+		if kind.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s is only valid for text columns", operator)
+		}
+		return []interface{}{value}, nil
+	}
+	return nil, fmt.Errorf("unknown filter operator")
+}
+
+func parseFilterList(parts []string, kind reflect.Type) ([]interface{}, error) {
+	values := make([]interface{}, len(parts))
+	for i, p := range parts {
+		v, err := parseFilterValue(strings.TrimSpace(p), kind)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+var filterTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseFilterValue(value string, kind reflect.Type) (interface{}, error) {
+	if kind == reflect.TypeOf(time.Time{}) || kind == reflect.TypeOf(dbr.NullTime{}) {
+		for _, layout := range filterTimeLayouts {
+			t, err := time.Parse(layout, value)
+			if err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date %q", value)
+	}
+
+	switch kind.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q", value)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", value)
+		}
+		return i, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		return f, nil
+	}
+	return value, nil
+}
+
+//escapeLike protects the LIKE wildcards present in the user value
+func escapeLike(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(value)
+}
+
+func (c filterCondition) builder() dbr.Builder {
+	switch c.operator {
+	case FilterNe:
+		return dbr.Neq(c.column, c.values[0])
+	case FilterGt:
+		return dbr.Gt(c.column, c.values[0])
+	case FilterGte:
+		return dbr.Gte(c.column, c.values[0])
+	case FilterLt:
+		return dbr.Lt(c.column, c.values[0])
+	case FilterLte:
+		return dbr.Lte(c.column, c.values[0])
+	case FilterBetween:
+		return dbr.And(
+			dbr.Gte(c.column, c.values[0]),
+			dbr.Lte(c.column, c.values[1]),
+		)
+	case FilterIn:
+		return dbr.Eq(c.column, c.values)
+	case FilterContains:
+		return dbr.Expr("LOWER("+c.column+") LIKE LOWER(?)", "%"+escapeLike(c.values[0].(string))+"%")
+	case FilterStartsWith:
+		return dbr.Expr("LOWER("+c.column+") LIKE LOWER(?)", escapeLike(c.values[0].(string))+"%")
+	case FilterIsNull:
+		return dbr.Or(
+			dbr.Eq(c.column, nil),
+			dbr.Eq(c.column, ""),
+		)
+	case FilterIsNotNull:
+		return dbr.And(
+			dbr.Neq(c.column, nil),
+			dbr.Neq(c.column, ""),
+		)
+	}
+	return dbr.Eq(c.column, c.values[0])
+}
+
+//buildWhereFilters parse the request params into a single parameterised where condition
+func buildWhereFilters(params map[string]string, meta objectMetadata) (dbr.Builder, error) {
+	conditions, err := parseRequestFilters(params, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []dbr.Builder{}
+	if val, ok := params["filter"]; ok && len(meta.filters) > 0 {
+		search := []dbr.Builder{}
+		for _, f := range meta.filters {
+			search = append(search, dbr.Expr("LOWER("+f+") LIKE LOWER(?)", "%"+escapeLike(val)+"%"))
+		}
+		where = append(where, dbr.Or(search...))
+	}
+	for _, c := range conditions {
+		where = append(where, c.builder())
+	}
+
+	if len(where) == 0 {
+		return nil, nil
+	}
+	return dbr.And(where...), nil
+}
+
+//parseSortParams validate the sort and order params against the object columns
+func parseSortParams(params map[string]string, meta objectMetadata) (string, bool, error) {
+	col, ok := params["sort"]
+	if !ok {
+		return "", false, nil
+	}
+	c, ok := meta.filterable[col]
+	if !ok {
+		return "", false, &FilterError{Param: "sort", Message: "unknown sort column " + col}
+	}
+	asc := false
+	if val, ok := params["order"]; ok {
+		switch val {
+		case "asc":
+			asc = true
+		case "desc":
+		default:
+			return "", false, &FilterError{Param: "order", Message: "order must be asc or desc"}
+		}
+	}
+	return c.column, asc, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/feedmytrip/api/resources/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+type filterTestObject struct {
+	ID          string             `json:"id" db:"id"`
+	Active      bool               `json:"active" db:"active"`
+	BeginOffset float64            `json:"begin_offset" db:"begin_offset"`
+	Duration    int                `json:"duration" db:"duration"`
+	Title       shared.Translation `json:"title" table:"translation" alias:"title" on:"title.parent_id = test.id" embedded:"true"`
+	CreatedDate time.Time          `json:"created_date" db:"created_date"`
+}
+
+func TestParseRequestFilters(t *testing.T) {
+	meta := parseObjectTagsRecursively("", "test", filterTestObject{})
+
+	cases := []struct {
+		name     string
+		params   map[string]string
+		operator string
+		column   string
+		values   int
+		err      bool
+	}{
+		{"default eq", map[string]string{"active": "true"}, FilterEq, "test.active", 1, false},
+		{"legacy is_null", map[string]string{"duration": "is_null"}, FilterIsNull, "test.duration", 0, false},
+		{"date range", map[string]string{"created_date[between]": "2019-01-01,2019-02-01"}, FilterBetween, "test.created_date", 2, false},
+		{"multi value", map[string]string{"duration[in]": "1,2,3"}, FilterIn, "test.duration", 3, false},
+		{"translation contains", map[string]string{"title.en[contains]": "paris"}, FilterContains, "title.en", 1, false},
+		{"number gt", map[string]string{"begin_offset[gt]": "86400"}, FilterGt, "test.begin_offset", 1, false},
+		{"reserved params", map[string]string{"page": "1", "sort": "id"}, "", "", 0, false},
+		{"unknown column", map[string]string{"password": "x"}, "", "", 0, true},
+		{"injection column", map[string]string{"id) or (1=1": "x"}, "", "", 0, true},
+		{"unknown operator", map[string]string{"duration[like]": "1"}, "", "", 0, true},
+		{"not filterable translation", map[string]string{"title.id": "1"}, "", "", 0, true},
+		{"invalid number", map[string]string{"duration[gt]": "one"}, "", "", 0, true},
+		{"invalid date", map[string]string{"created_date[gte]": "yesterday"}, "", "", 0, true},
+		{"between single value", map[string]string{"duration[between]": "1"}, "", "", 0, true},
+		{"contains on number", map[string]string{"duration[contains]": "1"}, "", "", 0, true},
+		{"malformed key", map[string]string{"duration[gt": "1"}, "", "", 0, true},
+	}
+
+	for _, c := range cases {
+		conditions, err := parseRequestFilters(c.params, meta)
+		if c.err {
+			assert.True(t, IsFilterError(err), c.name)
+			continue
+		}
+		assert.Nil(t, err, c.name)
+		if c.operator == "" {
+			assert.Empty(t, conditions, c.name)
+			continue
+		}
+		if assert.Len(t, conditions, 1, c.name) {
+			assert.Equal(t, c.operator, conditions[0].operator, c.name)
+			assert.Equal(t, c.column, conditions[0].column, c.name)
+			assert.Len(t, conditions[0].values, c.values, c.name)
+		}
+	}
+}
+
+func TestParseSortParams(t *testing.T) {
+	meta := parseObjectTagsRecursively("", "test", filterTestObject{})
+
+	column, asc, err := parseSortParams(map[string]string{"sort": "begin_offset", "order": "asc"}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, "test.begin_offset", column)
+	assert.True(t, asc)
+
+	_, _, err = parseSortParams(map[string]string{"sort": "id; drop table user"}, meta)
+	assert.True(t, IsFilterError(err))
+
+	_, _, err = parseSortParams(map[string]string{"sort": "id", "order": "sideways"}, meta)
+	assert.True(t, IsFilterError(err))
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\%\_off\\`, escapeLike(`100%_off\`))
+}
@@ -0,0 +1,596 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryCascade struct {
+	table  string
+	column string
+}
+
+//memoryCascades reproduces the ON DELETE CASCADE foreign keys of the database schema
+var memoryCascades = map[string][]memoryCascade{
+	TableTrip: {
+		{table: TableTripParticipant, column: "trip_id"},
+		{table: TableTripItinerary, column: "trip_id"},
+		{table: TableTripInvite, column: "trip_id"},
+	},
+	TableTripItinerary: {
+		{table: TableTripItineraryEvent, column: "itinerary_id"},
+	},
+	TableEvent: {
+		{table: TableEventSchedule, column: "event_id"},
+	},
+	TableHighlight: {
+		{table: TableHighlightImage, column: "highlight_id"},
+	},
+	TableUserCredential: {
+		{table: TableUserRefreshToken, column: "user_id"},
+	},
+	TableWebhookSubscription: {
+		{table: TableWebhookDelivery, column: "subscription_id"},
+	},
+}
+
+//MemoryStore implements the Store keeping the records in memory, it runs the api without a database.
+//Persisted translations are kept inside the records, joined attributes like created_user are left empty.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tables map[string][]reflect.Value
+}
+
+//NewMemoryStore returns an empty in memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tables: map[string][]reflect.Value{},
+	}
+}
+
+//Select load the records matching the request params with the pagination metadata
+func (s *MemoryStore) Select(table string, params map[string]string, object interface{}) (interface{}, error) {
+	results, m, err := s.selectRecords(table, params, object)
+	if err != nil {
+		return nil, err
+	}
+	return ListResult{Metadata: m, Data: results.Interface()}, nil
+}
+
+//QueryOne load one record with the same type of object
+func (s *MemoryStore) QueryOne(table, id string, object interface{}) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.tables[table] {
+		if recordID(record) == id && !(SoftDeleteTables[table] && isSoftDeleted(record)) {
+			return convertRecord(record, reflect.TypeOf(object)).Interface(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+//LoadOne load one record into dest, a pointer to a struct
+func (s *MemoryStore) LoadOne(table, id string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to a struct")
+	}
+
+	result, err := s.QueryOne(table, id, v.Elem().Interface())
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(reflect.ValueOf(result))
+	return nil
+}
+
+//LoadAll load the records matching the request params into dest, a pointer to a slice of structs
+func (s *MemoryStore) LoadAll(table string, params map[string]string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice || v.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to a slice of structs")
+	}
+
+	results, _, err := s.selectRecords(table, params, reflect.Zero(v.Elem().Type().Elem()).Interface())
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(results)
+	return nil
+}
+
+//Transaction runs fn over a copy of the tables and keeps the changes only if fn succeeds
+func (s *MemoryStore) Transaction(fn func(tx Writer) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := &memoryWriter{tables: map[string][]reflect.Value{}}
+	for table, records := range s.tables {
+		w.tables[table] = append([]reflect.Value{}, records...)
+	}
+
+	err := fn(w)
+	if err != nil {
+		return err
+	}
+	s.tables = w.tables
+	return nil
+}
+
+func (s *MemoryStore) selectRecords(table string, params map[string]string, object interface{}) (reflect.Value, ListMetadata, error) {
+	meta := parseObjectTagsRecursively("", table, object)
+	objectType := reflect.TypeOf(object)
+	results := reflect.MakeSlice(reflect.SliceOf(objectType), 0, 0)
+
+	conditions, err := parseRequestFilters(params, meta)
+	if err != nil {
+		return results, ListMetadata{}, err
+	}
+	p, err := parsePagination(table, params, meta)
+	if err != nil {
+		return results, ListMetadata{}, err
+	}
+	count, err := parseCountMode(params)
+	if err != nil {
+		return results, ListMetadata{}, err
+	}
+	includeDeleted, err := parseIncludeDeleted(params)
+	if err != nil {
+		return results, ListMetadata{}, err
+	}
+
+	s.mu.RLock()
+	records := []reflect.Value{}
+	total := len(s.tables[table])
+	for _, r := range s.tables[table] {
+		if SoftDeleteTables[table] && !includeDeleted && isSoftDeleted(r) {
+			continue
+		}
+		record := convertRecord(r, objectType)
+		if val, ok := params["id"]; ok && recordID(record) != val {
+			continue
+		}
+		if val, ok := params["filter"]; ok && !matchSearch(record, val, meta) {
+			continue
+		}
+		if matchConditions(record, conditions, meta) {
+			records = append(records, record)
+		}
+	}
+	s.mu.RUnlock()
+
+	m := ListMetadata{Count: count, Total: total, TotalFiltered: len(records)}
+	if count == CountNone {
+		m.Total = -1
+		m.TotalFiltered = -1
+	}
+	m.setRequestParams(table, params)
+
+	records = p.memoryPage(records)
+	for _, r := range records {
+		results = reflect.Append(results, r)
+	}
+	p.reorder(results)
+	results, m.NextCursor, m.PrevCursor = p.cursors(results)
+	return results, m, nil
+}
+
+//memoryPage sort and slice the records the same way apply does in the select statement
+func (p pagination) memoryPage(records []reflect.Value) []reflect.Value {
+	if p.sortIndex == nil {
+		if p.mode == pageModeOffset {
+			return offsetPage(records, p.page, p.perPage)
+		}
+		return records
+	}
+
+	asc := p.asc
+	if p.backward() {
+		asc = !asc
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		c := compareRecords(records[i], records[j], p.sortIndex, p.idIndex)
+		if asc {
+			return c < 0
+		}
+		return c > 0
+	})
+
+	switch p.mode {
+	case pageModeOffset:
+		return offsetPage(records, p.page, p.perPage)
+	case pageModeCursor:
+		if p.cursor != nil {
+			after := []reflect.Value{}
+			for _, r := range records {
+				c, _ := compareValues(normalizeValue(r.FieldByIndex(p.sortIndex)), p.cursorKey)
+				if c == 0 {
+					c = strings.Compare(recordID(r), p.cursor.ID)
+				}
+				if (asc && c > 0) || (!asc && c < 0) {
+					after = append(after, r)
+				}
+			}
+			records = after
+		}
+		if uint64(len(records)) > p.perPage+1 {
+			records = records[:p.perPage+1]
+		}
+	}
+	return records
+}
+
+func offsetPage(records []reflect.Value, page, perPage uint64) []reflect.Value {
+	start := (page - 1) * perPage
+	if start >= uint64(len(records)) {
+		return []reflect.Value{}
+	}
+	end := start + perPage
+	if end > uint64(len(records)) {
+		end = uint64(len(records))
+	}
+	return records[start:end]
+}
+
+type memoryWriter struct {
+	tables map[string][]reflect.Value
+}
+
+func (w *memoryWriter) Insert(table string, object interface{}) error {
+	v := reflect.ValueOf(object)
+	if v.Kind() != reflect.Struct {
+		return errors.New("object must be a struct")
+	}
+	id := recordID(v)
+	for _, r := range w.tables[table] {
+		if recordID(r) == id {
+			return fmt.Errorf("duplicate entry '%s' for key 'PRIMARY'", id)
+		}
+	}
+
+	record := reflect.New(v.Type()).Elem()
+	record.Set(v)
+	w.tables[table] = append(w.tables[table], record)
+
+	if auditIgnoredTables[table] {
+		return nil
+	}
+	return auditChange(w, table, id, AuditInsert, nil, auditValues(record))
+}
+
+func (w *memoryWriter) Update(table, id string, object interface{}, values map[string]interface{}) error {
+	for i, r := range w.tables[table] {
+		if recordID(r) != id {
+			continue
+		}
+
+		record := reflect.New(r.Type()).Elem()
+		record.Set(r)
+		err := applyValues(record, object, values)
+		if err != nil {
+			return err
+		}
+		bumpVersion(table, record)
+		w.tables[table][i] = record
+
+		if !auditIgnoredTables[table] {
+			err = auditUpdate(w, table, id, r, record)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//applyValues sets the values updatable by the object template in the record, including the persisted translations
+func applyValues(record reflect.Value, object interface{}, values map[string]interface{}) error {
+	err := setRecordValues(record, parseObjectFieldsToUpdatableMap("", object, values))
+	if err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(object)
+	v := reflect.ValueOf(object)
+	for f := 0; f < v.NumField(); f++ {
+		alias := t.Field(f).Tag.Get("alias")
+		if v.Field(f).Type().Name() != "Translation" || t.Field(f).Tag.Get("persist") == "" {
+			continue
+		}
+		translation, ok := fieldByTag(record, "alias", alias)
+		if !ok {
+			continue
+		}
+		err := setRecordValues(translation, parseObjectFieldsToUpdatableMap(alias, v.Field(f).Interface(), values))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *memoryWriter) Delete(table string, ids ...string) error {
+	if !auditIgnoredTables[table] {
+		deleted := map[string]bool{}
+		for _, id := range ids {
+			deleted[id] = true
+		}
+		for _, r := range w.tables[table] {
+			if deleted[recordID(r)] {
+				err := auditChange(w, table, recordID(r), AuditDelete, auditValues(r), nil)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return w.remove(table, ids...)
+}
+
+//remove deletes the records and the children of the cascades, the children aren't recorded in the audit log
+//like the ON DELETE CASCADE of the database
+func (w *memoryWriter) remove(table string, ids ...string) error {
+	deleted := map[string]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	kept := []reflect.Value{}
+	for _, r := range w.tables[table] {
+		if !deleted[recordID(r)] {
+			kept = append(kept, r)
+		}
+	}
+	w.tables[table] = kept
+
+	for _, c := range memoryCascades[table] {
+		children := []string{}
+		for _, r := range w.tables[c.table] {
+			f, ok := fieldByTag(r, "db", c.column)
+			if ok && deleted[f.String()] {
+				children = append(children, recordID(r))
+			}
+		}
+		if len(children) > 0 {
+			err := w.remove(c.table, children...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setRecordValues(record reflect.Value, values map[string]interface{}) error {
+	for column, value := range values {
+		f, ok := fieldByTag(record, "db", column)
+		if !ok {
+			continue
+		}
+		err := fieldScanner{column: column, field: f}.Scan(value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//fieldByTag returns the first field of the struct with the tag value
+func fieldByTag(v reflect.Value, tag, value string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(tag) == value {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func recordID(v reflect.Value) string {
+	f, ok := fieldByTag(v, "db", "id")
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+//convertRecord copies a stored record into a new value of the requested type matching the db and alias tags
+func convertRecord(record reflect.Value, t reflect.Type) reflect.Value {
+	result := reflect.New(t).Elem()
+	if record.Type() == t {
+		result.Set(record)
+		return result
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		var src reflect.Value
+		ok := false
+		if tag := field.Tag.Get("db"); tag != "" {
+			src, ok = fieldByTag(record, "db", tag)
+		} else if tag := field.Tag.Get("alias"); tag != "" {
+			src, ok = fieldByTag(record, "alias", tag)
+		}
+		if ok && src.Type().AssignableTo(field.Type) {
+			result.Field(i).Set(src)
+		}
+	}
+	return result
+}
+
+func matchSearch(record reflect.Value, search string, meta objectMetadata) bool {
+	if len(meta.filters) == 0 {
+		return true
+	}
+	search = strings.ToLower(search)
+	for _, col := range meta.filterable {
+		for _, f := range meta.filters {
+			if col.column != f {
+				continue
+			}
+			value := strings.ToLower(fmt.Sprint(normalizeValue(record.FieldByIndex(col.index))))
+			if strings.Contains(value, search) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchConditions(record reflect.Value, conditions []filterCondition, meta objectMetadata) bool {
+	for _, c := range conditions {
+		value := normalizeValue(record.FieldByIndex(meta.filterable[c.param].index))
+		if !c.match(value) {
+			return false
+		}
+	}
+	return true
+}
+
+//match evaluates the condition the same way the database evaluates the builder
+func (c filterCondition) match(value interface{}) bool {
+	switch c.operator {
+	case FilterIsNull:
+		return isNullValue(value)
+	case FilterIsNotNull:
+		return !isNullValue(value)
+	case FilterContains:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(c.values[0].(string)))
+	case FilterStartsWith:
+		return strings.HasPrefix(strings.ToLower(fmt.Sprint(value)), strings.ToLower(c.values[0].(string)))
+	case FilterIn:
+		for _, v := range c.values {
+			if cmp, ok := compareValues(value, v); ok && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	case FilterBetween:
+		low, ok := compareValues(value, c.values[0])
+		high, ok2 := compareValues(value, c.values[1])
+		return ok && ok2 && low >= 0 && high <= 0
+	}
+
+	cmp, ok := compareValues(value, c.values[0])
+	if !ok {
+		return false
+	}
+	switch c.operator {
+	case FilterNe:
+		return cmp != 0
+	case FilterGt:
+		return cmp > 0
+	case FilterGte:
+		return cmp >= 0
+	case FilterLt:
+		return cmp < 0
+	case FilterLte:
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+func isNullValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	return reflect.DeepEqual(value, reflect.Zero(v.Type()).Interface())
+}
+
+//normalizeValue converts a field into the types returned by parseFilterValue
+func normalizeValue(v reflect.Value) interface{} {
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return nil
+		}
+		return value
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+//compareValues returns -1, 0 or 1 comparing a to b and false when they can't be compared
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	case int64, float64:
+		fx := toFloat(x)
+		var fy float64
+		switch y := b.(type) {
+		case int64, float64:
+			fy = toFloat(y)
+		default:
+			return 0, false
+		}
+		switch {
+		case fx < fy:
+			return -1, true
+		case fx > fy:
+			return 1, true
+		}
+		return 0, true
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+func toFloat(v interface{}) float64 {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case float64:
+		return x
+	}
+	return 0
+}
+
+func compareRecords(a, b reflect.Value, sortIndex, idIndex []int) int {
+	c, _ := compareValues(normalizeValue(a.FieldByIndex(sortIndex)), normalizeValue(b.FieldByIndex(sortIndex)))
+	if c == 0 && idIndex != nil {
+		c = strings.Compare(recordID(a), recordID(b))
+	}
+	return c
+}
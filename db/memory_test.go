@@ -0,0 +1,122 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/feedmytrip/api/resources/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func memoryTestStore(t *testing.T, count int) *MemoryStore {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		for i := 0; i < count; i++ {
+			id := string(rune('a' + i))
+			err := tx.Insert("test", filterTestObject{
+				ID:          id,
+				Active:      i%2 == 0,
+				Duration:    count - i,
+				Title:       shared.Translation{ParentID: id, Field: "title", EN: "Title " + id},
+				CreatedDate: time.Date(2019, 8, 1+i, 0, 0, 0, 0, time.UTC),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	return s
+}
+
+func TestMemoryStoreFilters(t *testing.T) {
+	s := memoryTestStore(t, 5)
+
+	records := []filterTestObject{}
+	err := s.LoadAll("test", map[string]string{"active": "true", "duration[gte]": "2"}, &records)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "a", records[0].ID)
+		assert.Equal(t, "c", records[1].ID)
+	}
+
+	err = s.LoadAll("test", map[string]string{"title.en[contains]": "title d"}, &records)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	err = s.LoadAll("test", map[string]string{"created_date[between]": "2019-08-02,2019-08-03", "sort": "duration"}, &records)
+	assert.Nil(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "b", records[0].ID)
+	}
+
+	err = s.LoadAll("test", map[string]string{"unknown": "x"}, &records)
+	assert.True(t, IsFilterError(err))
+}
+
+func TestMemoryStoreCursorPagination(t *testing.T) {
+	s := memoryTestStore(t, 5)
+
+	ids := []string{}
+	params := map[string]string{"results": "2", "sort": "duration", "order": "desc", "cursor": ""}
+	for {
+		result, err := s.Select("test", params, filterTestObject{})
+		assert.Nil(t, err)
+		r := result.(ListResult)
+		for _, record := range r.Data.([]filterTestObject) {
+			ids = append(ids, record.ID)
+		}
+		if r.Metadata.NextCursor == "" {
+			break
+		}
+		params["cursor"] = r.Metadata.NextCursor
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+
+	result, err := s.Select("test", map[string]string{"page": "3", "results": "2", "count": "none"}, filterTestObject{})
+	assert.Nil(t, err)
+	r := result.(ListResult)
+	assert.Len(t, r.Data, 1)
+	assert.Equal(t, -1, r.Metadata.Total)
+}
+
+func TestMemoryStoreTransaction(t *testing.T) {
+	s := memoryTestStore(t, 2)
+
+	err := s.Transaction(func(tx Writer) error {
+		err := tx.Update("test", "a", filterTestObject{}, map[string]interface{}{"duration": 10, "title.en": "Changed"})
+		if err != nil {
+			return err
+		}
+		return tx.Insert("test", filterTestObject{ID: "b"})
+	})
+	assert.NotNil(t, err)
+
+	record := filterTestObject{}
+	assert.Nil(t, s.LoadOne("test", "a", &record))
+	assert.Equal(t, 2, record.Duration)
+
+	err = s.Transaction(func(tx Writer) error {
+		return tx.Update("test", "a", filterTestObject{}, map[string]interface{}{"duration": 10, "title.en": "Changed"})
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, s.LoadOne("test", "a", &record))
+	assert.Equal(t, 10, record.Duration)
+
+	err = s.Transaction(func(tx Writer) error {
+		err := tx.Delete("test", "a")
+		if err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, s.LoadOne("test", "a", &record))
+
+	assert.Nil(t, s.Transaction(func(tx Writer) error {
+		return tx.Delete("test", "a")
+	}))
+	assert.NotNil(t, s.LoadOne("test", "a", &record))
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocraft/dbr"
+)
+
+const (
+	//TableSchemaMigrations defines the applied schema migrations database table
+	TableSchemaMigrations = "schema_migrations"
+
+	migrationVersionLayout = "20060102150405"
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+//Migration represents a versioned change in the database schema
+type Migration struct {
+	Version     int64     `json:"version" db:"version"`
+	Name        string    `json:"name" db:"name"`
+	Up          string    `json:"-"`
+	Down        string    `json:"-"`
+	Applied     bool      `json:"applied"`
+	AppliedDate time.Time `json:"applied_date" db:"applied_date"`
+}
+
+//LoadMigrations read every <version>_<name>.up.sql and .down.sql file in the directory sorted by version
+func LoadMigrations(dir string) ([]Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, f := range files {
+		match := migrationFileName.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+//CreateMigration writes empty up and down scripts for a new migration and returns their paths
+func CreateMigration(dir, name string, now time.Time) (string, string, error) {
+	name = strings.Trim(strings.ToLower(regexp.MustCompile(`[^A-Za-z0-9]+`).ReplaceAllString(name, "_")), "_")
+	if name == "" {
+		return "", "", errors.New("invalid migration name")
+	}
+
+	base := filepath.Join(dir, now.UTC().Format(migrationVersionLayout)+"_"+name)
+	up := base + ".up.sql"
+	down := base + ".down.sql"
+	for _, path := range []string{up, down} {
+		if _, err := os.Stat(path); err == nil {
+			return "", "", fmt.Errorf("migration %s already exists", path)
+		}
+	}
+
+	err := ioutil.WriteFile(up, []byte("-- "+name+" up migration\n"), 0644)
+	if err != nil {
+		return "", "", err
+	}
+	err = ioutil.WriteFile(down, []byte("-- "+name+" down migration\n"), 0644)
+	if err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+func createMigrationsTable(session *dbr.Session) error {
+	_, err := session.Exec("CREATE TABLE IF NOT EXISTS `" + TableSchemaMigrations + "` (" +
+		"`version` bigint NOT NULL, " +
+		"`name` varchar(255) NOT NULL, " +
+		"`applied_date` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, " +
+		"PRIMARY KEY (`version`))")
+	return err
+}
+
+//MigrationStatus returns the migrations flagging the ones already applied to the database
+func MigrationStatus(session *dbr.Session, migrations []Migration) ([]Migration, error) {
+	err := createMigrationsTable(session)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	_, err = session.Select("version", "name", "applied_date").From(TableSchemaMigrations).Load(&applied)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int64]int{}
+	status := make([]Migration, len(migrations))
+	for i, m := range migrations {
+		known[m.Version] = i
+		status[i] = m
+	}
+
+	for _, a := range applied {
+		i, ok := known[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d_%s is applied to the database but missing in the migrations directory", a.Version, a.Name)
+		}
+		status[i].Applied = true
+		status[i].AppliedDate = a.AppliedDate
+	}
+	return status, nil
+}
+
+//MigrateUp applies the pending migrations in version order, steps <= 0 applies all of them
+func MigrateUp(session *dbr.Session, migrations []Migration, steps int) ([]Migration, error) {
+	status, err := MigrationStatus(session, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range status {
+		if m.Applied {
+			continue
+		}
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		err := runMigration(session, m, m.Up, func(tx *dbr.Tx) error {
+			_, err := tx.InsertInto(TableSchemaMigrations).
+				Columns("version", "name").
+				Values(m.Version, m.Name).
+				Exec()
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+//MigrateDown reverts the last applied migrations, steps <= 0 reverts only the last one
+func MigrateDown(session *dbr.Session, migrations []Migration, steps int) ([]Migration, error) {
+	status, err := MigrationStatus(session, migrations)
+	if err != nil {
+		return nil, err
+	}
+	if steps <= 0 {
+		steps = 1
+	}
+
+	done := []Migration{}
+	for i := len(status) - 1; i >= 0 && len(done) < steps; i-- {
+		m := status[i]
+		if !m.Applied {
+			continue
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return done, fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+		err := runMigration(session, m, m.Down, func(tx *dbr.Tx) error {
+			_, err := tx.DeleteFrom(TableSchemaMigrations).Where(dbr.Eq("version", m.Version)).Exec()
+			return err
+		})
+		if err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+//runMigration executes the script statements and records the change. MySQL commits DDL statements implicitly so
+//a migration failing halfway keeps the statements already executed, the schema must be fixed by hand before running it again
+func runMigration(session *dbr.Session, m Migration, script string, record func(tx *dbr.Tx) error) error {
+	tx, err := session.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	for _, stmt := range splitStatements(script) {
+		_, err := tx.Exec(stmt)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %s", m.Version, m.Name, err.Error())
+		}
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+//splitStatements breaks a sql script into single statements ignoring comments and semicolons inside quotes
+func splitStatements(script string) []string {
+	statements := []string{}
+	var current strings.Builder
+	var quote rune
+	lineComment := false
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+				current.WriteRune(c)
+			}
+			continue
+		case quote != 0:
+			current.WriteRune(c)
+			if c == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			lineComment = true
+		case c == '#':
+			lineComment = true
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == ';':
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
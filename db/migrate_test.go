@@ -0,0 +1,63 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment; with a semicolon
+CREATE TABLE ` + "`a;b`" + ` (id int);
+# another comment
+INSERT INTO t VALUES ('x;y', "it\"s;");
+
+UPDATE t SET v = 1`
+
+	statements := splitStatements(script)
+	if assert.Len(t, statements, 3) {
+		assert.Equal(t, "CREATE TABLE `a;b` (id int)", statements[0])
+		assert.Equal(t, `INSERT INTO t VALUES ('x;y', "it\"s;")`, statements[1])
+		assert.Equal(t, "UPDATE t SET v = 1", statements[2])
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	up, down, err := CreateMigration(dir, "Add Event Coordinates", time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "20190802000000_add_event_coordinates.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "20190802000000_add_event_coordinates.down.sql"), down)
+
+	ioutil.WriteFile(filepath.Join(dir, "20190801000000_baseline.up.sql"), []byte("CREATE TABLE a (id int);"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644)
+
+	migrations, err := LoadMigrations(dir)
+	assert.Nil(t, err)
+	if assert.Len(t, migrations, 2) {
+		assert.Equal(t, int64(20190801000000), migrations[0].Version)
+		assert.Equal(t, "baseline", migrations[0].Name)
+		assert.Equal(t, "", migrations[0].Down)
+		assert.Equal(t, "add_event_coordinates", migrations[1].Name)
+	}
+
+	_, _, err = CreateMigration(dir, "add event coordinates", time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC))
+	assert.NotNil(t, err)
+}
+
+func TestRepositoryMigrations(t *testing.T) {
+	migrations, err := LoadMigrations("migrations")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, migrations)
+	for _, m := range migrations {
+		assert.NotEmpty(t, splitStatements(m.Up), m.Name)
+		assert.NotEmpty(t, splitStatements(m.Down), m.Name)
+	}
+}
@@ -0,0 +1,15 @@
+-- Drops every baseline table, children first because of the foreign keys.
+
+DROP TABLE IF EXISTS `trip_itinerary_event`;
+DROP TABLE IF EXISTS `event_schedule`;
+DROP TABLE IF EXISTS `trip_invite`;
+DROP TABLE IF EXISTS `trip_itinerary`;
+DROP TABLE IF EXISTS `trip_participant`;
+DROP TABLE IF EXISTS `category`;
+DROP TABLE IF EXISTS `event`;
+DROP TABLE IF EXISTS `location`;
+DROP TABLE IF EXISTS `highlight_image`;
+DROP TABLE IF EXISTS `highlight`;
+DROP TABLE IF EXISTS `translation`;
+DROP TABLE IF EXISTS `trip`;
+DROP TABLE IF EXISTS `user`;
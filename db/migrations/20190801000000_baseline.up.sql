@@ -1,12 +1,10 @@
--- ****************** SqlDBM: MySQL ******************;
--- ***************************************************;
-
--- DROP TABLE user, translation, highlight, location, event_schedule, event, category, trip_itinerary_event, trip_participant, trip_itinerary, trip_invite, trip;
-
+-- Baseline schema captured from the original SqlDBM dump (db/schema.sql).
+-- Every table uses IF NOT EXISTS so the migration can be recorded on databases
+-- created before the migrations were introduced.
 
 -- ************************************** `user`
 
-CREATE TABLE `user`
+CREATE TABLE IF NOT EXISTS `user`
 (
  `id`                   varchar(45) NOT NULL ,
  `active`               smallint NOT NULL DEFAULT 1 ,
@@ -29,11 +27,9 @@ CREATE TABLE `user`
 PRIMARY KEY (`id`)
 );
 
-
-
 -- ************************************** `trip`
 
-CREATE TABLE `trip`
+CREATE TABLE IF NOT EXISTS `trip`
 (
  `id`           varchar(45) NOT NULL ,
  `itinerary_id` varchar(45) NOT NULL ,
@@ -49,14 +45,9 @@ CREATE TABLE `trip`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `translation`
 
-CREATE TABLE `translation`
+CREATE TABLE IF NOT EXISTS `translation`
 (
  `id`        varchar(45) NOT NULL ,
  `parent_id` varchar(45) NOT NULL ,
@@ -68,14 +59,9 @@ CREATE TABLE `translation`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `highlight`
 
-CREATE TABLE `highlight`
+CREATE TABLE IF NOT EXISTS `highlight`
 (
  `id`              varchar(45) NOT NULL ,
  `active`          smallint NOT NULL DEFAULT 1 ,
@@ -93,14 +79,9 @@ CREATE TABLE `highlight`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `highlight_image`
 
-CREATE TABLE `highlight_image`
+CREATE TABLE IF NOT EXISTS `highlight_image`
 (
  `id`           varchar(45) NOT NULL ,
  `highlight_id` varchar(45) NOT NULL ,
@@ -113,14 +94,9 @@ KEY `fk_highlight` (`highlight_id`),
 CONSTRAINT `FK_317` FOREIGN KEY `fk_highlight` (`highlight_id`) REFERENCES `highlight` (`id`) ON DELETE CASCADE
 );
 
-
-
-
-
-
 -- ************************************** `location`
 
-CREATE TABLE `location`
+CREATE TABLE IF NOT EXISTS `location`
 (
  `id`         varchar(45) NOT NULL ,
  `country_id` varchar(45) ,
@@ -128,14 +104,9 @@ CREATE TABLE `location`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `event`
 
-CREATE TABLE `event`
+CREATE TABLE IF NOT EXISTS `event`
 (
  `id`                    varchar(45) NOT NULL ,
  `active`                smallint NOT NULL DEFAULT 1,
@@ -152,14 +123,9 @@ CREATE TABLE `event`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `category`
 
-CREATE TABLE `category`
+CREATE TABLE IF NOT EXISTS `category`
 (
  `id`           varchar(45) NOT NULL ,
  `parent_id`    varchar(45) ,
@@ -171,14 +137,9 @@ CREATE TABLE `category`
 PRIMARY KEY (`id`)
 );
 
-
-
-
-
-
 -- ************************************** `trip_participant`
 
-CREATE TABLE `trip_participant`
+CREATE TABLE IF NOT EXISTS `trip_participant`
 (
  `id`      		varchar(45) NOT NULL ,
  `trip_id` 		varchar(45) NOT NULL ,
@@ -194,14 +155,9 @@ UNIQUE KEY `unique_participant` (`trip_id`,`user_id`),
 CONSTRAINT `FK_127` FOREIGN KEY `fk_trip` (`trip_id`) REFERENCES `trip` (`id`) ON DELETE CASCADE
 );
 
-
-
-
-
-
 -- ************************************** `trip_itinerary`
 
-CREATE TABLE `trip_itinerary`
+CREATE TABLE IF NOT EXISTS `trip_itinerary`
 (
  `id`         	varchar(45) NOT NULL ,
  `trip_id`    	varchar(45) NOT NULL ,
@@ -217,14 +173,9 @@ KEY `fk_trip` (`trip_id`),
 CONSTRAINT `FK_75` FOREIGN KEY `fk_trip` (`trip_id`) REFERENCES `trip` (`id`) ON DELETE CASCADE
 );
 
-
-
-
-
-
 -- ************************************** `trip_invite`
 
-CREATE TABLE `trip_invite`
+CREATE TABLE IF NOT EXISTS `trip_invite`
 (
  `id`      		varchar(45) NOT NULL ,
  `email`   		text NOT NULL ,
@@ -236,14 +187,9 @@ KEY `fk_trip` (`trip_id`),
 CONSTRAINT `FK_135` FOREIGN KEY `fk_trip` (`trip_id`) REFERENCES `trip` (`id`) ON DELETE CASCADE
 );
 
-
-
-
-
-
 -- ************************************** `event_schedule`
 
-CREATE TABLE `event_schedule`
+CREATE TABLE IF NOT EXISTS `event_schedule`
 (
  `id`           varchar(45) NOT NULL ,
  `event_id`     varchar(45) NOT NULL ,
@@ -263,14 +209,9 @@ KEY `fk_event` (`event_id`),
 CONSTRAINT `FK_103` FOREIGN KEY `fk_event` (`event_id`) REFERENCES `event` (`id`) ON DELETE CASCADE
 );
 
+-- ************************************** `trip_itinerary_event`
 
-
-
-
-
--- ************************************** `itinerary_event`
-
-CREATE TABLE `trip_itinerary_event`
+CREATE TABLE IF NOT EXISTS `trip_itinerary_event`
 (
  `id`                    varchar(45) NOT NULL ,
  `itinerary_id`          varchar(45) NOT NULL ,
@@ -295,4 +236,3 @@ PRIMARY KEY (`id`, `itinerary_id`, `trip_id`),
 KEY `fk_itinerary_trip` (`itinerary_id`, `trip_id`),
 CONSTRAINT `FK_84` FOREIGN KEY `fk_itinerary_trip` (`itinerary_id`, `trip_id`) REFERENCES `trip_itinerary` (`id`, `trip_id`) ON DELETE CASCADE
 );
-
@@ -0,0 +1,3 @@
+ALTER TABLE `event`
+ DROP COLUMN `longitude`,
+ DROP COLUMN `latitude`;
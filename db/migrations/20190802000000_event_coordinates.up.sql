@@ -0,0 +1,5 @@
+-- Nullable columns keep the running functions working while the migration is applied.
+
+ALTER TABLE `event`
+ ADD COLUMN `latitude`  decimal(10,8) NULL AFTER `address`,
+ ADD COLUMN `longitude` decimal(11,8) NULL AFTER `latitude`;
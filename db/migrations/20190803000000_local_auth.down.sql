@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `user_refresh_token`;
+DROP TABLE IF EXISTS `user_credential`;
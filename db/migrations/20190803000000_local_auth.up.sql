@@ -0,0 +1,34 @@
+-- Accounts of the local identity provider (FMT_AUTH_PROVIDER=local), used to run
+-- the api without AWS Cognito. Only the hashes of the passwords, codes and
+-- refresh tokens are stored.
+
+CREATE TABLE IF NOT EXISTS `user_credential`
+(
+ `id`                   varchar(45) NOT NULL ,
+ `username`             varchar(128) NOT NULL ,
+ `email`                text ,
+ `password_hash`        varchar(60) NOT NULL ,
+ `given_name`           text ,
+ `family_name`          text ,
+ `language_code`        varchar(45) ,
+ `user_groups`          text ,
+ `confirmed`            smallint NOT NULL DEFAULT 0 ,
+ `confirmation_code`    varchar(64) ,
+ `reset_code`           varchar(64) ,
+ `reset_expires_at`     bigint NOT NULL DEFAULT 0 ,
+ `created_date`         timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ,
+ `updated_date`         timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP ,
+PRIMARY KEY (`id`),
+UNIQUE KEY `uk_username` (`username`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_refresh_token`
+(
+ `id`                   varchar(64) NOT NULL ,
+ `user_id`              varchar(45) NOT NULL ,
+ `expires_at`           bigint NOT NULL ,
+ `created_date`         timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ,
+PRIMARY KEY (`id`),
+KEY `fk_user_credential` (`user_id`),
+CONSTRAINT `FK_user_refresh_token_credential` FOREIGN KEY `fk_user_credential` (`user_id`) REFERENCES `user_credential` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE `user_credential`
+ DROP COLUMN `code_attempts`,
+ DROP COLUMN `code_sent_at`;
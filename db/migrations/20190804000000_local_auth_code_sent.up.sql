@@ -0,0 +1,7 @@
+-- Time the last confirmation or reset code was sent, it limits how often the
+-- local identity provider sends new codes. The wrong attempts of the current
+-- code invalidate it after a few failures.
+
+ALTER TABLE `user_credential`
+ ADD COLUMN `code_sent_at`  bigint NOT NULL DEFAULT 0 AFTER `reset_expires_at`,
+ ADD COLUMN `code_attempts` int NOT NULL DEFAULT 0 AFTER `code_sent_at`;
@@ -0,0 +1,7 @@
+ALTER TABLE `trip_invite`
+ DROP COLUMN `responded_date`,
+ DROP COLUMN `responded_by`,
+ DROP COLUMN `expires_date`,
+ DROP COLUMN `token_hash`,
+ DROP COLUMN `status`,
+ DROP COLUMN `role`;
@@ -0,0 +1,11 @@
+-- Invites carry the role given to the invitee, the hash of the signed token
+-- and the expiry date. Invites created before this migration have no token and
+-- are expired by the default expires_date.
+
+ALTER TABLE `trip_invite`
+ ADD COLUMN `role`           varchar(45) NOT NULL DEFAULT 'viewer' AFTER `trip_id`,
+ ADD COLUMN `status`         varchar(45) NOT NULL DEFAULT 'pending' AFTER `role`,
+ ADD COLUMN `token_hash`     varchar(64) NULL AFTER `status`,
+ ADD COLUMN `expires_date`   timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER `token_hash`,
+ ADD COLUMN `responded_by`   varchar(45) NULL AFTER `expires_date`,
+ ADD COLUMN `responded_date` timestamp NULL AFTER `responded_by`;
@@ -0,0 +1,23 @@
+-- Domain events written by the mutations in the same transaction of the
+-- changes. The dispatcher delivers the pending events to the consumers,
+-- delivered_to keeps the consumers that already handled each event and the
+-- events failing max attempts are moved to the dead status.
+
+CREATE TABLE IF NOT EXISTS `outbox`
+(
+ `id`                   varchar(45) NOT NULL ,
+ `type`                 varchar(64) NOT NULL ,
+ `aggregate_id`         varchar(45) NOT NULL ,
+ `actor`                varchar(45) ,
+ `payload`              mediumtext NOT NULL ,
+ `status`               varchar(16) NOT NULL DEFAULT 'pending' ,
+ `attempts`             int NOT NULL DEFAULT 0 ,
+ `delivered_to`         text ,
+ `last_error`           text ,
+ `next_attempt_date`    timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ,
+ `created_date`         timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ,
+ `delivered_date`       timestamp(6) NULL ,
+PRIMARY KEY (`id`),
+KEY `idx_outbox_status` (`status`, `next_attempt_date`),
+KEY `idx_outbox_aggregate` (`aggregate_id`)
+);
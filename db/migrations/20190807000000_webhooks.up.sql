@@ -0,0 +1,42 @@
+-- Outbound webhooks. A subscription without trip_id receives the changes of
+-- every trip and event, otherwise the changes of the trip and of the events.
+-- event_types keeps the comma separated filters like 'trip.*' and the
+-- deliveries log every signed request sent to the subscription url.
+
+CREATE TABLE IF NOT EXISTS `webhook_subscription`
+(
+ `id`                   varchar(45) NOT NULL ,
+ `trip_id`              varchar(45) ,
+ `url`                  varchar(2048) NOT NULL ,
+ `event_types`          varchar(1024) ,
+ `active`               tinyint(1) NOT NULL DEFAULT 1 ,
+ `secret`               varchar(128) NOT NULL ,
+ `created_by`           varchar(45) NOT NULL ,
+ `created_date`         timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ,
+ `updated_by`           varchar(45) NOT NULL ,
+ `updated_date`         timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ,
+PRIMARY KEY (`id`),
+KEY `idx_webhook_subscription_trip` (`trip_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `webhook_delivery`
+(
+ `id`                   varchar(45) NOT NULL ,
+ `subscription_id`      varchar(45) NOT NULL ,
+ `entry_id`             varchar(45) NOT NULL ,
+ `event_type`           varchar(64) NOT NULL ,
+ `payload`              mediumtext NOT NULL ,
+ `status`               varchar(16) NOT NULL DEFAULT 'pending' ,
+ `attempts`             int NOT NULL DEFAULT 0 ,
+ `response_status`      int NOT NULL DEFAULT 0 ,
+ `response_body`        text ,
+ `last_error`           text ,
+ `redelivery_of`        varchar(45) ,
+ `next_attempt_date`    timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ,
+ `created_date`         timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ,
+ `delivered_date`       timestamp(6) NULL ,
+PRIMARY KEY (`id`),
+KEY `idx_webhook_delivery_subscription` (`subscription_id`, `created_date`),
+KEY `idx_webhook_delivery_status` (`status`, `next_attempt_date`),
+CONSTRAINT `fk_webhook_delivery_subscription` FOREIGN KEY (`subscription_id`) REFERENCES `webhook_subscription` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+-- Changes history written by the db package writers in the same transaction
+-- of the changes. old_values and new_values keep JSON objects of the changed
+-- columns, the translations keyed like 'title.en', and parent_id the record
+-- owning the changed one so the history of a record includes its children.
+
+CREATE TABLE IF NOT EXISTS `audit_log`
+(
+ `id`                   varchar(45) NOT NULL ,
+ `table_name`           varchar(64) NOT NULL ,
+ `record_id`            varchar(45) NOT NULL ,
+ `parent_id`            varchar(45) ,
+ `action`               varchar(16) NOT NULL ,
+ `old_values`           mediumtext ,
+ `new_values`           mediumtext ,
+ `actor`                varchar(45) ,
+ `request_id`           varchar(64) ,
+ `created_date`         timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ,
+PRIMARY KEY (`id`),
+KEY `idx_audit_log_record` (`record_id`, `created_date`),
+KEY `idx_audit_log_parent` (`parent_id`, `created_date`),
+KEY `idx_audit_log_request` (`request_id`)
+);
@@ -0,0 +1,19 @@
+-- The soft deleted records are removed, without the columns they would be visible again.
+
+DELETE FROM `event` WHERE `deleted_at` IS NOT NULL;
+ALTER TABLE `event`
+ DROP KEY `idx_event_deleted_at`,
+ DROP COLUMN `deleted_by`,
+ DROP COLUMN `deleted_at`;
+
+DELETE FROM `trip_itinerary` WHERE `deleted_at` IS NOT NULL;
+ALTER TABLE `trip_itinerary`
+ DROP KEY `idx_trip_itinerary_deleted_at`,
+ DROP COLUMN `deleted_by`,
+ DROP COLUMN `deleted_at`;
+
+DELETE FROM `trip` WHERE `deleted_at` IS NOT NULL;
+ALTER TABLE `trip`
+ DROP KEY `idx_trip_deleted_at`,
+ DROP COLUMN `deleted_by`,
+ DROP COLUMN `deleted_at`;
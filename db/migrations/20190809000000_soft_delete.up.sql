@@ -0,0 +1,18 @@
+-- Trips, itineraries and events are soft deleted, the selects leave out the
+-- records with deleted_at unless include_deleted=true is requested. The purge
+-- job removes them after the retention window with the cascades of the children.
+
+ALTER TABLE `trip`
+ ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL ,
+ ADD COLUMN `deleted_by` varchar(45) ,
+ ADD KEY `idx_trip_deleted_at` (`deleted_at`);
+
+ALTER TABLE `trip_itinerary`
+ ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL ,
+ ADD COLUMN `deleted_by` varchar(45) ,
+ ADD KEY `idx_trip_itinerary_deleted_at` (`deleted_at`);
+
+ALTER TABLE `event`
+ ADD COLUMN `deleted_at` timestamp NULL DEFAULT NULL ,
+ ADD COLUMN `deleted_by` varchar(45) ,
+ ADD KEY `idx_event_deleted_at` (`deleted_at`);
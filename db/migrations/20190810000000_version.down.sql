@@ -0,0 +1,5 @@
+ALTER TABLE `category` DROP COLUMN `version`;
+ALTER TABLE `event` DROP COLUMN `version`;
+ALTER TABLE `trip_itinerary_event` DROP COLUMN `version`;
+ALTER TABLE `trip_itinerary` DROP COLUMN `version`;
+ALTER TABLE `trip` DROP COLUMN `version`;
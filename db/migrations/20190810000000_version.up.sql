@@ -0,0 +1,8 @@
+-- The versioned records have a version incremented by every update, the API
+-- returns it as the ETag and the If-Match header of the updates must match it.
+
+ALTER TABLE `trip` ADD COLUMN `version` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `trip_itinerary` ADD COLUMN `version` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `trip_itinerary_event` ADD COLUMN `version` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `event` ADD COLUMN `version` bigint NOT NULL DEFAULT 0;
+ALTER TABLE `category` ADD COLUMN `version` bigint NOT NULL DEFAULT 0;
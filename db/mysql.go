@@ -11,7 +11,7 @@ import (
 	"github.com/gocraft/dbr"
 )
 
-//Connect return a connection to the database
+//Connect opens a new connection to the database, the handlers share the connection returned by Pool
 func Connect() (*dbr.Connection, error) {
 	dbUser := os.Getenv("FMT_DBUSER")
 	dbPass := os.Getenv("FMT_DBPASS")
@@ -27,6 +27,9 @@ func Connect() (*dbr.Connection, error) {
 	return conn, nil
 }
 
+//ErrNotFound is returned when the requested record doesn't exist
+var ErrNotFound = errors.New("invalid id, record not found")
+
 type valid struct {
 	Total int `json:"total" db:"total"`
 }
@@ -51,7 +54,7 @@ func QueryByIDs(session *dbr.Session, table string, ids []string, object interfa
 		return nil, err
 	}
 
-	return results, nil
+	return results.Interface(), nil
 }
 
 //QueryOne load one record from the database
@@ -61,39 +64,94 @@ func QueryOne(session *dbr.Session, table string, id string, object interface{})
 	params := map[string]string{
 		"id": id,
 	}
-	result, err := loadGeneric(session, table, params, object, objectMetadata, []string{})
+	results, err := loadGeneric(session, table, params, object, objectMetadata, []string{})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result) > 0 {
-		return result[0], nil
+	if results.Len() > 0 {
+		return results.Index(0).Interface(), nil
+	}
+	return nil, ErrNotFound
+}
+
+//LoadOne load one record from the database into dest, a pointer to a struct
+func LoadOne(session *dbr.Session, table string, id string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to a struct")
+	}
+
+	result, err := QueryOne(session, table, id, v.Elem().Interface())
+	if err != nil {
+		return err
+	}
+	v.Elem().Set(reflect.ValueOf(result))
+	return nil
+}
+
+//LoadAll load the records matching the request params into dest, a pointer to a slice of structs
+func LoadAll(session *dbr.Session, table string, params map[string]string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice || v.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to a slice of structs")
 	}
-	return nil, errors.New("invalid id, record not found")
+
+	object := reflect.Zero(v.Elem().Type().Elem()).Interface()
+	objectMetadata := parseObjectTagsRecursively("", table, object)
+
+	results, err := loadGeneric(session, table, params, object, objectMetadata, []string{})
+	if err != nil {
+		return err
+	}
+	p, _ := parsePagination(table, params, objectMetadata)
+	results, _, _ = p.cursors(results)
+	v.Elem().Set(results)
+	return nil
 }
 
 //Select load records from the database
 func Select(session *dbr.Session, table string, params map[string]string, object interface{}) (interface{}, error) {
 	objectMetadata := parseObjectTagsRecursively("", table, object)
 
-	var dbresult dbResult
-	tableMetadata, err := loadTableMetadata(session, table, params, objectMetadata)
+	where, err := buildWhereFilters(params, objectMetadata)
+	if err != nil {
+		return nil, err
+	}
+	where, err = withoutDeleted(table, params, where)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parsePagination(table, params, objectMetadata)
+	if err != nil {
+		return nil, err
+	}
+	count, err := parseCountMode(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var dbresult ListResult
+	tableMetadata, err := loadTableMetadata(session, table, params, objectMetadata, where, count)
 	if err != nil {
 		dbresult.Errors = append(dbresult.Errors, err)
 	}
-	dbresult.Metadata = tableMetadata
 
-	if tableMetadata.TotalFiltered == 0 {
-		dbresult.Data = []map[string]interface{}{}
+	empty := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(object)), 0, 0)
+	if count == CountExact && tableMetadata.TotalFiltered == 0 {
+		dbresult.Metadata = tableMetadata
+		dbresult.Data = empty.Interface()
 		return dbresult, nil
 	}
 
 	result, err := loadGeneric(session, table, params, object, objectMetadata, []string{})
 	if err != nil {
 		dbresult.Errors = append(dbresult.Errors, err)
-		result = []map[string]interface{}{}
+		result = empty
 	}
-	dbresult.Data = result
+	result, tableMetadata.NextCursor, tableMetadata.PrevCursor = p.cursors(result)
+	dbresult.Metadata = tableMetadata
+	dbresult.Data = result.Interface()
 
 	return dbresult, nil
 }
@@ -124,6 +182,9 @@ func Insert(tx *dbr.Tx, table string, object interface{}) error {
 //Update change record attributes in the database
 func Update(tx *dbr.Tx, table, id string, object interface{}, values map[string]interface{}) error {
 	objectMap := parseObjectFieldsToUpdatableMap("", object, values)
+	if VersionedTables[table] {
+		objectMap["version"] = dbr.Expr("version + 1")
+	}
 	if len(objectMap) > 0 {
 		_, err := tx.Update(table).SetMap(objectMap).Where(dbr.Eq(table+".id", id)).Exec()
 		if err != nil {
@@ -165,14 +226,19 @@ func Delete(session *dbr.Session, table string, ids ...string) error {
 	}
 	defer tx.RollbackUnlessCommitted()
 
-	_, err = tx.DeleteFrom(table).Where("id IN ?", ids).Exec()
+	err = deleteRecords(tx, table, ids...)
 	if err != nil {
 		return err
 	}
-	_, err = tx.DeleteFrom(TableTranslation).Where("parent_id IN ?", ids).Exec()
+	tx.Commit()
+	return nil
+}
+
+func deleteRecords(tx *dbr.Tx, table string, ids ...string) error {
+	_, err := tx.DeleteFrom(table).Where("id IN ?", ids).Exec()
 	if err != nil {
 		return err
 	}
-	tx.Commit()
-	return nil
+	_, err = tx.DeleteFrom(TableTranslation).Where("parent_id IN ?", ids).Exec()
+	return err
 }
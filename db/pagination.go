@@ -0,0 +1,337 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/gocraft/dbr/dialect"
+)
+
+const (
+	//CountExact runs count queries to return the exact totals (default)
+	CountExact = "exact"
+	//CountEstimate returns the totals estimated by the MySQL statistics
+	CountEstimate = "estimate"
+	//CountNone skips the count queries and returns -1 as totals
+	CountNone = "none"
+)
+
+const (
+	pageModeNone   = ""
+	pageModeOffset = "page"
+	pageModeCursor = "cursor"
+
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+//cursor represents the position of a record in a keyset pagination
+type cursor struct {
+	Sort      string `json:"s"`
+	Order     string `json:"o"`
+	Value     string `json:"v"`
+	ID        string `json:"id"`
+	Direction string `json:"d"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, &FilterError{Param: "cursor", Message: "malformed cursor"}
+	}
+	c := &cursor{}
+	err = json.Unmarshal(b, c)
+	if err != nil || c.ID == "" || (c.Direction != cursorNext && c.Direction != cursorPrev) {
+		return nil, &FilterError{Param: "cursor", Message: "malformed cursor"}
+	}
+	return c, nil
+}
+
+type pagination struct {
+	mode       string
+	page       uint64
+	perPage    uint64
+	table      string
+	sortParam  string
+	sortColumn string
+	asc        bool
+	sortIndex  []int
+	idIndex    []int
+	cursor     *cursor
+	cursorKey  interface{}
+}
+
+//parsePagination validate the page, results, sort, order and cursor params
+func parsePagination(table string, params map[string]string, meta objectMetadata) (pagination, error) {
+	p := pagination{
+		table:   table,
+		page:    1,
+		perPage: recorsPerPage,
+	}
+
+	sortColumn, asc, err := parseSortParams(params, meta)
+	if err != nil {
+		return p, err
+	}
+	p.sortParam = params["sort"]
+	p.sortColumn = sortColumn
+	p.asc = asc
+	p.idIndex = meta.filterable["id"].index
+	if p.sortParam != "" {
+		p.sortIndex = meta.filterable[p.sortParam].index
+	}
+
+	if val, ok := params["results"]; ok {
+		i, err := strconv.ParseUint(val, 10, 64)
+		if err == nil && i > 0 {
+			p.perPage = i
+		}
+	}
+
+	if val, ok := params["page"]; ok {
+		p.mode = pageModeOffset
+		i, err := strconv.ParseUint(val, 10, 64)
+		if err == nil && i > 0 {
+			p.page = i
+		}
+		return p, nil
+	}
+
+	//the cursor param, empty on the first page, selects the keyset pagination
+	if _, ok := params["cursor"]; !ok {
+		return p, nil
+	}
+
+	p.mode = pageModeCursor
+	if p.sortColumn == "" {
+		p.sortParam = "id"
+		p.sortColumn = table + ".id"
+		p.sortIndex = p.idIndex
+		p.asc = true
+	}
+	if strings.Contains(p.sortParam, ".") {
+		return p, &FilterError{Param: "sort", Message: "cursor pagination can't sort by embedded columns"}
+	}
+	//the keyset comparison skips the null values
+	if isNullable(meta.filterable[p.sortParam].kind) {
+		return p, &FilterError{Param: "sort", Message: "cursor pagination can't sort by nullable columns"}
+	}
+
+	if val := params["cursor"]; val != "" {
+		c, err := decodeCursor(val)
+		if err != nil {
+			return p, err
+		}
+		if c.Sort != p.sortParam || c.Order != p.order() {
+			return p, &FilterError{Param: "cursor", Message: "cursor doesn't match the sort and order params"}
+		}
+		key, err := parseFilterValue(c.Value, meta.filterable[p.sortParam].kind)
+		if err != nil {
+			return p, &FilterError{Param: "cursor", Message: "malformed cursor"}
+		}
+		p.cursor = c
+		p.cursorKey = key
+	}
+	return p, nil
+}
+
+//isNullable check if the column type is one of the dbr null types
+func isNullable(kind reflect.Type) bool {
+	if kind == nil || kind.Kind() != reflect.Struct {
+		return false
+	}
+	_, ok := kind.FieldByName("Valid")
+	return ok
+}
+
+func (p pagination) order() string {
+	if p.asc {
+		return "asc"
+	}
+	return "desc"
+}
+
+//backward returns true when the records are loaded in the reverse order of the requested sort
+func (p pagination) backward() bool {
+	return p.cursor != nil && p.cursor.Direction == cursorPrev
+}
+
+//apply includes the order, keyset condition and limit into the select statement
+func (p pagination) apply(stmt *dbr.SelectStmt) {
+	if p.sortColumn == "" {
+		if p.mode == pageModeOffset {
+			stmt.Paginate(p.page, p.perPage)
+		}
+		return
+	}
+
+	asc := p.asc
+	if p.backward() {
+		asc = !asc
+	}
+
+	if p.cursor != nil {
+		idColumn := p.table + ".id"
+		cmp := "<"
+		if asc {
+			cmp = ">"
+		}
+		if p.sortColumn == idColumn {
+			stmt.Where(dbr.Expr(idColumn+" "+cmp+" ?", p.cursor.ID))
+		} else {
+			stmt.Where(dbr.Expr("("+p.sortColumn+" "+cmp+" ? OR ("+p.sortColumn+" = ? AND "+idColumn+" "+cmp+" ?))", p.cursorKey, p.cursorKey, p.cursor.ID))
+		}
+	}
+
+	if asc {
+		stmt.OrderAsc(p.sortColumn)
+	} else {
+		stmt.OrderDesc(p.sortColumn)
+	}
+
+	switch p.mode {
+	case pageModeOffset:
+		stmt.Paginate(p.page, p.perPage)
+	case pageModeCursor:
+		if p.sortColumn != p.table+".id" {
+			if asc {
+				stmt.OrderAsc(p.table + ".id")
+			} else {
+				stmt.OrderDesc(p.table + ".id")
+			}
+		}
+		//one extra record tells if there is another page
+		stmt.Limit(p.perPage + 1)
+	}
+}
+
+//reorder restore the requested order of records loaded backwards
+func (p pagination) reorder(results reflect.Value) {
+	if !p.backward() {
+		return
+	}
+	swap := reflect.Swapper(results.Interface())
+	for i, j := 0, results.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+//cursors removes the extra record loaded by the keyset query and returns the next and previous cursors
+func (p pagination) cursors(results reflect.Value) (reflect.Value, string, string) {
+	if p.mode == pageModeNone || p.sortIndex == nil || p.idIndex == nil || results.Len() == 0 {
+		return results, "", ""
+	}
+
+	hasNext := false
+	hasPrev := false
+	switch p.mode {
+	case pageModeOffset:
+		hasNext = uint64(results.Len()) >= p.perPage
+		hasPrev = p.page > 1
+	case pageModeCursor:
+		more := uint64(results.Len()) > p.perPage
+		if p.backward() {
+			if more {
+				results = results.Slice(1, results.Len())
+			}
+			hasPrev = more
+			hasNext = true
+		} else {
+			if more {
+				results = results.Slice(0, int(p.perPage))
+			}
+			hasNext = more
+			hasPrev = p.cursor != nil
+		}
+	}
+
+	next := ""
+	prev := ""
+	if hasNext {
+		next = p.recordCursor(results.Index(results.Len()-1), cursorNext)
+	}
+	if hasPrev {
+		prev = p.recordCursor(results.Index(0), cursorPrev)
+	}
+	return results, next, prev
+}
+
+func (p pagination) recordCursor(record reflect.Value, direction string) string {
+	c := cursor{
+		Sort:      p.sortParam,
+		Order:     p.order(),
+		ID:        fmt.Sprint(record.FieldByIndex(p.idIndex).Interface()),
+		Direction: direction,
+	}
+	switch v := record.FieldByIndex(p.sortIndex).Interface().(type) {
+	case time.Time:
+		c.Value = v.Format(time.RFC3339Nano)
+	default:
+		c.Value = fmt.Sprint(v)
+	}
+	return c.encode()
+}
+
+//parseCountMode validate the count param
+func parseCountMode(params map[string]string) (string, error) {
+	val, ok := params["count"]
+	if !ok || val == "" {
+		return CountExact, nil
+	}
+	switch val {
+	case CountExact, CountEstimate, CountNone:
+		return val, nil
+	}
+	return "", &FilterError{Param: "count", Message: "count must be exact, estimate or none"}
+}
+
+type tableEstimate struct {
+	Rows     float64 `db:"estimated_rows"`
+	Filtered float64 `db:"filtered"`
+}
+
+//estimateTotal returns the number of rows in the table from the MySQL statistics
+func estimateTotal(session *dbr.Session, table string) (int, error) {
+	var e tableEstimate
+	_, err := session.Select("table_rows AS estimated_rows").
+		From("information_schema.tables").
+		Where(dbr.And(
+			dbr.Expr("table_schema = DATABASE()"),
+			dbr.Eq("table_name", table),
+		)).Load(&e)
+	if err != nil {
+		return -1, err
+	}
+	return int(e.Rows), nil
+}
+
+//estimateFiltered returns the number of rows the optimizer expects the statement to examine
+func estimateFiltered(session *dbr.Session, stmt *dbr.SelectStmt) (int, error) {
+	buf := dbr.NewBuffer()
+	err := stmt.Build(dialect.MySQL, buf)
+	if err != nil {
+		return -1, err
+	}
+	query, err := dbr.InterpolateForDialect(buf.String(), buf.Value(), dialect.MySQL)
+	if err != nil {
+		return -1, err
+	}
+
+	var e tableEstimate
+	_, err = session.SelectBySql("EXPLAIN " + query).Load(&e)
+	if err != nil {
+		return -1, err
+	}
+	return int(e.Rows * e.Filtered / 100), nil
+}
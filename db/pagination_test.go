@@ -0,0 +1,101 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/stretchr/testify/assert"
+)
+
+func paginationTestRecords(ids ...string) reflect.Value {
+	records := []filterTestObject{}
+	for i, id := range ids {
+		records = append(records, filterTestObject{ID: id, Duration: i})
+	}
+	return reflect.ValueOf(records)
+}
+
+func TestParsePaginationModes(t *testing.T) {
+	meta := parseObjectTagsRecursively("", "test", filterTestObject{})
+
+	p, err := parsePagination("test", map[string]string{}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, pageModeNone, p.mode)
+
+	p, err = parsePagination("test", map[string]string{"page": "2", "results": "10"}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, pageModeOffset, p.mode)
+	assert.Equal(t, uint64(2), p.page)
+	assert.Equal(t, uint64(10), p.perPage)
+
+	//results without page nor cursor keeps the list without pagination
+	p, err = parsePagination("test", map[string]string{"results": "10"}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, pageModeNone, p.mode)
+
+	p, err = parsePagination("test", map[string]string{"results": "10", "cursor": ""}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, pageModeCursor, p.mode)
+	assert.Equal(t, "test.id", p.sortColumn)
+
+	_, err = parsePagination("test", map[string]string{"cursor": "not a cursor"}, meta)
+	assert.True(t, IsFilterError(err))
+
+	_, err = parsePagination("test", map[string]string{"cursor": "", "sort": "title.en"}, meta)
+	assert.True(t, IsFilterError(err))
+
+	assert.True(t, isNullable(reflect.TypeOf(dbr.NullTime{})))
+	assert.False(t, isNullable(reflect.TypeOf(time.Time{})))
+	assert.False(t, isNullable(reflect.TypeOf("")))
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	meta := parseObjectTagsRecursively("", "test", filterTestObject{})
+
+	p, err := parsePagination("test", map[string]string{"results": "2", "sort": "duration", "order": "asc", "cursor": ""}, meta)
+	assert.Nil(t, err)
+
+	records, next, prev := p.cursors(paginationTestRecords("a", "b", "c"))
+	assert.Equal(t, 2, records.Len())
+	assert.NotEmpty(t, next)
+	assert.Empty(t, prev)
+
+	p, err = parsePagination("test", map[string]string{"results": "2", "sort": "duration", "order": "asc", "cursor": next}, meta)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", p.cursor.ID)
+	assert.Equal(t, int64(1), p.cursorKey)
+
+	records, next, prev = p.cursors(paginationTestRecords("c"))
+	assert.Equal(t, 1, records.Len())
+	assert.Empty(t, next)
+	assert.NotEmpty(t, prev)
+
+	_, err = parsePagination("test", map[string]string{"results": "2", "sort": "duration", "order": "desc", "cursor": prev}, meta)
+	assert.True(t, IsFilterError(err))
+
+	p, err = parsePagination("test", map[string]string{"results": "2", "sort": "duration", "order": "asc", "cursor": prev}, meta)
+	assert.Nil(t, err)
+	assert.True(t, p.backward())
+
+	loaded := paginationTestRecords("b", "a")
+	p.reorder(loaded)
+	records, next, prev = p.cursors(loaded)
+	assert.Equal(t, []filterTestObject{{ID: "a", Duration: 1}, {ID: "b", Duration: 0}}, records.Interface())
+	assert.NotEmpty(t, next)
+	assert.Empty(t, prev)
+}
+
+func TestParseCountMode(t *testing.T) {
+	count, err := parseCountMode(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, CountExact, count)
+
+	count, err = parseCountMode(map[string]string{"count": "none"})
+	assert.Nil(t, err)
+	assert.Equal(t, CountNone, count)
+
+	_, err = parseCountMode(map[string]string{"count": "maybe"})
+	assert.True(t, IsFilterError(err))
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocraft/dbr"
+)
+
+const (
+	defaultMaxOpenConns    = 5
+	defaultMaxIdleConns    = 2
+	defaultConnMaxLifetime = 5 * time.Minute
+	defaultPingInterval    = time.Minute
+)
+
+//PoolConfig defines the limits of the shared database connection pool
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	//PingInterval is the minimum time between two health checks of the pool
+	PingInterval time.Duration
+}
+
+//PoolConfigFromEnv reads the pool limits from the FMT_DBMAXOPEN, FMT_DBMAXIDLE, FMT_DBCONNLIFETIME and FMT_DBPINGINTERVAL environment variables, missing or invalid values use the defaults
+func PoolConfigFromEnv() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    envInt("FMT_DBMAXOPEN", defaultMaxOpenConns),
+		MaxIdleConns:    envInt("FMT_DBMAXIDLE", defaultMaxIdleConns),
+		ConnMaxLifetime: envDuration("FMT_DBCONNLIFETIME", defaultConnMaxLifetime),
+		PingInterval:    envDuration("FMT_DBPINGINTERVAL", defaultPingInterval),
+	}
+}
+
+//PoolStats reports the usage of the shared connection pool
+type PoolStats struct {
+	Connects          int64         `json:"connects"`
+	Reconnects        int64         `json:"reconnects"`
+	PingFailures      int64         `json:"ping_failures"`
+	Acquired          int64         `json:"acquired"`
+	OpenConnections   int           `json:"open_connections"`
+	InUse             int           `json:"in_use"`
+	Idle              int           `json:"idle"`
+	WaitCount         int64         `json:"wait_count"`
+	WaitDuration      time.Duration `json:"wait_duration"`
+	MaxIdleClosed     int64         `json:"max_idle_closed"`
+	MaxLifetimeClosed int64         `json:"max_lifetime_closed"`
+}
+
+type connectionPool struct {
+	mu       sync.Mutex
+	open     func() (*dbr.Connection, error)
+	config   *PoolConfig
+	conn     *dbr.Connection
+	lastPing time.Time
+	stats    PoolStats
+}
+
+var pool = &connectionPool{open: Connect}
+
+//Pool returns the shared database connection, it's created on the first call and reused while the Lambda container stays warm
+func Pool() (*dbr.Connection, error) {
+	return pool.get()
+}
+
+//ConfigurePool changes the limits of the shared connection pool, by default they are read with PoolConfigFromEnv
+func ConfigurePool(config PoolConfig) {
+	pool.configure(config)
+}
+
+//ClosePool closes the shared connection, the next call to Pool reconnects
+func ClosePool() error {
+	return pool.close()
+}
+
+//Stats returns the usage metrics of the shared connection pool
+func Stats() PoolStats {
+	return pool.usage()
+}
+
+func (p *connectionPool) get() (*dbr.Connection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config == nil {
+		config := PoolConfigFromEnv()
+		p.config = &config
+	}
+
+	if p.conn != nil {
+		if time.Since(p.lastPing) < p.config.PingInterval {
+			p.stats.Acquired++
+			return p.conn, nil
+		}
+		if p.conn.Ping() == nil {
+			p.lastPing = time.Now()
+			p.stats.Acquired++
+			return p.conn, nil
+		}
+		p.stats.PingFailures++
+		p.conn.Close()
+		p.conn = nil
+		p.stats.Reconnects++
+	}
+
+	conn, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	p.apply(conn)
+	err = conn.Ping()
+	if err != nil {
+		p.stats.PingFailures++
+		conn.Close()
+		return nil, err
+	}
+
+	p.conn = conn
+	p.lastPing = time.Now()
+	p.stats.Connects++
+	p.stats.Acquired++
+	return p.conn, nil
+}
+
+func (p *connectionPool) apply(conn *dbr.Connection) {
+	conn.SetMaxOpenConns(p.config.MaxOpenConns)
+	conn.SetMaxIdleConns(p.config.MaxIdleConns)
+	conn.SetConnMaxLifetime(p.config.ConnMaxLifetime)
+}
+
+func (p *connectionPool) configure(config PoolConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = &config
+	if p.conn != nil {
+		p.apply(p.conn)
+	}
+}
+
+func (p *connectionPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+func (p *connectionPool) usage() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	if p.conn != nil {
+		s := p.conn.Stats()
+		stats.OpenConnections = s.OpenConnections
+		stats.InUse = s.InUse
+		stats.Idle = s.Idle
+		stats.WaitCount = s.WaitCount
+		stats.WaitDuration = s.WaitDuration
+		stats.MaxIdleClosed = s.MaxIdleClosed
+		stats.MaxLifetimeClosed = s.MaxLifetimeClosed
+	}
+	return stats
+}
+
+func envInt(name string, value int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 0 {
+		return value
+	}
+	return n
+}
+
+func envDuration(name string, value time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d < 0 {
+		return value
+	}
+	return d
+}
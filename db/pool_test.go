@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/gocraft/dbr/dialect"
+	"github.com/stretchr/testify/assert"
+)
+
+var errPoolTestDown = errors.New("database is down")
+
+type poolTestDriver struct {
+	down int32
+}
+
+func (d *poolTestDriver) Open(name string) (driver.Conn, error) {
+	if atomic.LoadInt32(&d.down) == 1 {
+		return nil, errPoolTestDown
+	}
+	return &poolTestConn{driver: d}, nil
+}
+
+type poolTestConn struct {
+	driver *poolTestDriver
+}
+
+func (c *poolTestConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *poolTestConn) Close() error {
+	return nil
+}
+
+func (c *poolTestConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *poolTestConn) Ping(ctx context.Context) error {
+	if atomic.LoadInt32(&c.driver.down) == 1 {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+var poolTestDB = &poolTestDriver{}
+
+func init() {
+	sql.Register("pooltest", poolTestDB)
+}
+
+func poolTestOpen(opened *int) func() (*dbr.Connection, error) {
+	return func() (*dbr.Connection, error) {
+		*opened++
+		conn, err := sql.Open("pooltest", "")
+		if err != nil {
+			return nil, err
+		}
+		return &dbr.Connection{DB: conn, Dialect: dialect.MySQL, EventReceiver: &dbr.NullEventReceiver{}}, nil
+	}
+}
+
+func TestPoolReuse(t *testing.T) {
+	opened := 0
+	p := &connectionPool{open: poolTestOpen(&opened)}
+	p.configure(PoolConfig{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Minute, PingInterval: time.Hour})
+
+	first, err := p.get()
+	assert.Nil(t, err)
+	second, err := p.get()
+	assert.Nil(t, err)
+	assert.True(t, first == second)
+	assert.Equal(t, 1, opened)
+
+	stats := p.usage()
+	assert.Equal(t, int64(1), stats.Connects)
+	assert.Equal(t, int64(2), stats.Acquired)
+	assert.Equal(t, 1, stats.OpenConnections)
+	assert.Equal(t, 3, first.Stats().MaxOpenConnections)
+
+	assert.Nil(t, p.close())
+	_, err = p.get()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, opened)
+	p.close()
+}
+
+func TestPoolReconnect(t *testing.T) {
+	opened := 0
+	p := &connectionPool{open: poolTestOpen(&opened)}
+	p.configure(PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1, PingInterval: 0})
+	defer p.close()
+
+	_, err := p.get()
+	assert.Nil(t, err)
+
+	atomic.StoreInt32(&poolTestDB.down, 1)
+	_, err = p.get()
+	assert.NotNil(t, err)
+	stats := p.usage()
+	assert.Equal(t, int64(1), stats.Reconnects)
+	assert.Equal(t, int64(2), stats.PingFailures)
+
+	atomic.StoreInt32(&poolTestDB.down, 0)
+	_, err = p.get()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, opened)
+	assert.Equal(t, int64(2), p.usage().Connects)
+}
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	t.Setenv("FMT_DBMAXOPEN", "20")
+	t.Setenv("FMT_DBMAXIDLE", "invalid")
+	t.Setenv("FMT_DBCONNLIFETIME", "90s")
+	t.Setenv("FMT_DBPINGINTERVAL", "")
+
+	config := PoolConfigFromEnv()
+	assert.Equal(t, 20, config.MaxOpenConns)
+	assert.Equal(t, defaultMaxIdleConns, config.MaxIdleConns)
+	assert.Equal(t, 90*time.Second, config.ConnMaxLifetime)
+	assert.Equal(t, defaultPingInterval, config.PingInterval)
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+var scanTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC3339Nano,
+}
+
+//fieldScanner scans a database column directly into a struct field converting the driver value to the field type
+type fieldScanner struct {
+	column string
+	field  reflect.Value
+}
+
+//Scan implements the sql.Scanner interface
+func (s fieldScanner) Scan(src interface{}) error {
+	if s.field.CanAddr() && s.field.Addr().Type().Implements(scannerType) {
+		return s.field.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+
+	if src == nil {
+		s.field.Set(reflect.Zero(s.field.Type()))
+		return nil
+	}
+
+	if s.field.Type() == timeType {
+		t, err := scanTime(src)
+		if err != nil {
+			return s.err(src, err)
+		}
+		s.field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch s.field.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case []byte:
+			s.field.SetString(string(v))
+		case string:
+			s.field.SetString(v)
+		case time.Time:
+			s.field.SetString(v.Format(time.RFC3339))
+		default:
+			s.field.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Bool:
+		b, err := scanBool(src)
+		if err != nil {
+			return s.err(src, err)
+		}
+		s.field.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := scanInt(src)
+		if err != nil {
+			return s.err(src, err)
+		}
+		s.field.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := scanInt(src)
+		if err != nil || i < 0 {
+			return s.err(src, err)
+		}
+		s.field.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := scanFloat(src)
+		if err != nil {
+			return s.err(src, err)
+		}
+		s.field.SetFloat(f)
+		return nil
+	}
+
+	v := reflect.ValueOf(src)
+	if v.Type().ConvertibleTo(s.field.Type()) {
+		s.field.Set(v.Convert(s.field.Type()))
+		return nil
+	}
+	return s.err(src, nil)
+}
+
+func (s fieldScanner) err(src interface{}, err error) error {
+	if err != nil {
+		return fmt.Errorf("column %s: can't scan %T into %s: %s", s.column, src, s.field.Type(), err.Error())
+	}
+	return fmt.Errorf("column %s: can't scan %T into %s", s.column, src, s.field.Type())
+}
+
+func scanTime(src interface{}) (time.Time, error) {
+	switch v := src.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		return parseScanTime(string(v))
+	case string:
+		return parseScanTime(v)
+	}
+	return time.Time{}, fmt.Errorf("unsupported time value")
+}
+
+func parseScanTime(value string) (time.Time, error) {
+	if value == "" || value == "0000-00-00" || value == "0000-00-00 00:00:00" {
+		return time.Time{}, nil
+	}
+	for _, layout := range scanTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func scanBool(src interface{}) (bool, error) {
+	switch v := src.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case []byte:
+		return strconv.ParseBool(string(v))
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("unsupported boolean value")
+}
+
+func scanInt(src interface{}) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		return int64(v), nil
+	case float32:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unsupported integer value")
+}
+
+func scanFloat(src interface{}) (float64, error) {
+	switch v := src.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("unsupported number value")
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldScanner(t *testing.T) {
+	record := filterTestObject{}
+	v := reflect.ValueOf(&record).Elem()
+	meta := parseObjectTagsRecursively("", "test", record)
+
+	values := map[string]interface{}{
+		"test.id":           []byte("a1"),
+		"test.active":       int64(1),
+		"test.begin_offset": []byte("86400.5"),
+		"test.duration":     int64(3600),
+		"title.id":          nil,
+		"test.created_date": time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for i, target := range meta.targets {
+		column := meta.columns[i]
+		s := fieldScanner{column: column, field: v.FieldByIndex(target)}
+		val, ok := values[column]
+		if !ok {
+			val = []byte("x")
+		}
+		assert.Nil(t, s.Scan(val), column)
+	}
+
+	assert.Equal(t, "a1", record.ID)
+	assert.True(t, record.Active)
+	assert.Equal(t, 86400.5, record.BeginOffset)
+	assert.Equal(t, 3600, record.Duration)
+	assert.Equal(t, "", record.Title.ID)
+	assert.Equal(t, "x", record.Title.EN)
+	assert.Equal(t, 2019, record.CreatedDate.Year())
+}
+
+func TestFieldScannerInvalidValue(t *testing.T) {
+	record := filterTestObject{}
+	s := fieldScanner{column: "test.duration", field: reflect.ValueOf(&record).Elem().Field(3)}
+	assert.NotNil(t, s.Scan([]byte("one")))
+
+	s = fieldScanner{column: "test.created_date", field: reflect.ValueOf(&record).Elem().Field(5)}
+	assert.Nil(t, s.Scan([]byte("2019-01-02 03:04:05")))
+	assert.Equal(t, 3, record.CreatedDate.Hour())
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocraft/dbr"
+)
+
+//SoftDeleteTables defines the tables keeping the removed records until they are purged. The selects leave out the
+//records with deleted_at unless the params have include_deleted=true.
+var SoftDeleteTables = map[string]bool{
+	TableTrip:          true,
+	TableTripItinerary: true,
+	TableEvent:         true,
+}
+
+//translatedChildren defines the tables with translations removed by the cascades of the soft delete tables and the
+//column referencing the parent. The translations have no foreign key, so the purge deletes these children itself.
+var translatedChildren = map[string][]childTable{
+	TableTrip:          {{TableTripItineraryEvent, "trip_id"}, {TableTripItinerary, "trip_id"}},
+	TableTripItinerary: {{TableTripItineraryEvent, "itinerary_id"}},
+}
+
+//childTable represents the table of the records referencing a parent record by the column
+type childTable struct {
+	table  string
+	column string
+}
+
+//defaultPurgeRetention keeps the soft deleted records for 30 days
+const defaultPurgeRetention = 30 * 24 * time.Hour
+
+//softDelete is the template of the columns changed by SoftDelete and Restore
+type softDelete struct {
+	DeletedAt dbr.NullTime `json:"deleted_at" db:"deleted_at"`
+	DeletedBy string       `json:"deleted_by" db:"deleted_by"`
+}
+
+//SoftDelete marks the records as removed by the user, they are kept in the table until the purge
+func SoftDelete(tx Writer, table, deletedBy string, ids ...string) error {
+	now := time.Now()
+	for _, id := range ids {
+		err := tx.Update(table, id, softDelete{}, map[string]interface{}{
+			"deleted_at": now,
+			"deleted_by": deletedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Restore clears the removal of the soft deleted records
+func Restore(tx Writer, table string, ids ...string) error {
+	for _, id := range ids {
+		err := tx.Update(table, id, softDelete{}, map[string]interface{}{
+			"deleted_at": nil,
+			"deleted_by": "",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//LoadWithDeleted load one record into dest like Store.LoadOne, including the soft deleted records
+func LoadWithDeleted(store Store, table, id string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to a struct")
+	}
+
+	records := reflect.New(reflect.SliceOf(v.Elem().Type()))
+	err := store.LoadAll(table, map[string]string{"id": id, "include_deleted": "true", "count": "none"}, records.Interface())
+	if err != nil {
+		return err
+	}
+	if records.Elem().Len() == 0 {
+		return ErrNotFound
+	}
+	v.Elem().Set(records.Elem().Index(0))
+	return nil
+}
+
+//Purge removes the records soft deleted before the date from the table, the children are removed by the
+//cascades and the translated ones with their translations. It returns the number of purged records.
+func Purge(store Store, table string, before time.Time) (int, error) {
+	purged := 0
+	for {
+		records := []struct {
+			ID        string       `db:"id"`
+			DeletedAt dbr.NullTime `db:"deleted_at"`
+		}{}
+		err := store.LoadAll(table, map[string]string{
+			"include_deleted": "true",
+			"deleted_at[lte]": before.Format(time.RFC3339Nano),
+			"count":           "none",
+		}, &records)
+		if err != nil {
+			return purged, err
+		}
+		if len(records) == 0 {
+			return purged, nil
+		}
+
+		ids := make([]string, len(records))
+		for i, r := range records {
+			ids[i] = r.ID
+		}
+		children, err := childrenIDs(store, table, ids)
+		if err != nil {
+			return purged, err
+		}
+		err = store.Transaction(func(tx Writer) error {
+			for _, child := range translatedChildren[table] {
+				if len(children[child.table]) == 0 {
+					continue
+				}
+				err := tx.Delete(child.table, children[child.table]...)
+				if err != nil {
+					return err
+				}
+			}
+			return tx.Delete(table, ids...)
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += len(ids)
+	}
+}
+
+//childrenIDs loads the ids of the translated children of the records by table, including the soft deleted ones
+func childrenIDs(store Store, table string, ids []string) (map[string][]string, error) {
+	children := map[string][]string{}
+	for _, child := range translatedChildren[table] {
+		//the filter needs the parent column in the loaded struct
+		record := reflect.StructOf([]reflect.StructField{
+			{Name: "ID", Type: reflect.TypeOf(""), Tag: `db:"id"`},
+			{Name: "Parent", Type: reflect.TypeOf(""), Tag: reflect.StructTag(`db:"` + child.column + `"`)},
+		})
+		records := reflect.New(reflect.SliceOf(record))
+		err := store.LoadAll(child.table, map[string]string{
+			child.column + "[in]": strings.Join(ids, ","),
+			"include_deleted":     "true",
+			"count":               "none",
+		}, records.Interface())
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < records.Elem().Len(); i++ {
+			children[child.table] = append(children[child.table], records.Elem().Index(i).Field(0).String())
+		}
+	}
+	return children, nil
+}
+
+//PurgeRetention returns how long the soft deleted records are kept, FMT_PURGE_RETENTION overrides the 30 days default
+func PurgeRetention() time.Duration {
+	return envDuration("FMT_PURGE_RETENTION", defaultPurgeRetention)
+}
+
+//PurgeAll purges every soft delete table, it returns the number of purged records by table
+func PurgeAll(store Store, before time.Time) (map[string]int, error) {
+	tables := []string{}
+	for table := range SoftDeleteTables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	purged := map[string]int{}
+	for _, table := range tables {
+		n, err := Purge(store, table, before)
+		purged[table] = n
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+//parseIncludeDeleted validate the include_deleted param
+func parseIncludeDeleted(params map[string]string) (bool, error) {
+	val, ok := params["include_deleted"]
+	if !ok || val == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, &FilterError{Param: "include_deleted", Message: "include_deleted must be true or false"}
+	}
+	return include, nil
+}
+
+//withoutDeleted adds the condition leaving out the soft deleted records of the table to the where filters
+func withoutDeleted(table string, params map[string]string, where dbr.Builder) (dbr.Builder, error) {
+	include, err := parseIncludeDeleted(params)
+	if err != nil || include || !SoftDeleteTables[table] {
+		return where, err
+	}
+	condition := dbr.Expr(table + ".deleted_at IS NULL")
+	if where == nil {
+		return condition, nil
+	}
+	return dbr.And(where, condition), nil
+}
+
+//isSoftDeleted check if the stored record has the deleted_at column filled
+func isSoftDeleted(record reflect.Value) bool {
+	f, ok := fieldByTag(record, "db", "deleted_at")
+	return ok && !isNullValue(normalizeValue(f))
+}
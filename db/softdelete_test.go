@@ -0,0 +1,135 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/stretchr/testify/assert"
+)
+
+type softDeleteTestObject struct {
+	ID        string       `json:"id" db:"id" lock:"true"`
+	TripID    string       `json:"trip_id" db:"trip_id" lock:"true"`
+	DeletedAt dbr.NullTime `json:"deleted_at" db:"deleted_at" lock:"true"`
+	DeletedBy string       `json:"deleted_by" db:"deleted_by" lock:"true"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		for _, id := range []string{"a", "b"} {
+			err := tx.Insert(TableTrip, softDeleteTestObject{ID: id})
+			if err != nil {
+				return err
+			}
+			err = tx.Insert(TableTripItinerary, softDeleteTestObject{ID: "i" + id, TripID: id})
+			if err != nil {
+				return err
+			}
+		}
+		return SoftDelete(tx, TableTrip, "user-1", "a")
+	})
+	assert.Nil(t, err)
+
+	records := []softDeleteTestObject{}
+	assert.Nil(t, s.LoadAll(TableTrip, map[string]string{}, &records))
+	assert.Len(t, records, 1)
+	assert.Equal(t, ErrNotFound, s.LoadOne(TableTrip, "a", &softDeleteTestObject{}))
+
+	records = []softDeleteTestObject{}
+	assert.Nil(t, s.LoadAll(TableTrip, map[string]string{"include_deleted": "true"}, &records))
+	assert.Len(t, records, 2)
+
+	_, err = s.Select(TableTrip, map[string]string{"include_deleted": "yes"}, softDeleteTestObject{})
+	assert.True(t, IsFilterError(err))
+
+	record := softDeleteTestObject{}
+	assert.Nil(t, LoadWithDeleted(s, TableTrip, "a", &record))
+	assert.True(t, record.DeletedAt.Valid)
+	assert.Equal(t, "user-1", record.DeletedBy)
+
+	//the tables without soft delete keep the default selects
+	assert.Nil(t, s.LoadOne(TableTripItinerary, "ia", &record))
+
+	err = s.Transaction(func(tx Writer) error {
+		return Restore(tx, TableTrip, "a")
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, s.LoadOne(TableTrip, "a", &record))
+	assert.False(t, record.DeletedAt.Valid)
+	assert.Empty(t, record.DeletedBy)
+}
+
+func TestPurge(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		for _, id := range []string{"a", "b", "c"} {
+			err := tx.Insert(TableTrip, softDeleteTestObject{ID: id})
+			if err != nil {
+				return err
+			}
+			err = tx.Insert(TableTripParticipant, softDeleteTestObject{ID: "p" + id, TripID: id})
+			if err != nil {
+				return err
+			}
+		}
+		return SoftDelete(tx, TableTrip, "user-1", "a", "b")
+	})
+	assert.Nil(t, err)
+
+	purged, err := Purge(s, TableTrip, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = Purge(s, TableTrip, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, purged)
+
+	records := []softDeleteTestObject{}
+	assert.Nil(t, s.LoadAll(TableTrip, map[string]string{"include_deleted": "true"}, &records))
+	assert.Len(t, records, 1)
+
+	//the children of the purged records are removed by the cascades
+	records = []softDeleteTestObject{}
+	assert.Nil(t, s.LoadAll(TableTripParticipant, map[string]string{}, &records))
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "pc", records[0].ID)
+	}
+}
+
+func TestChildrenIDs(t *testing.T) {
+	type itineraryEvent struct {
+		ID          string `json:"id" db:"id" lock:"true"`
+		TripID      string `json:"trip_id" db:"trip_id" lock:"true"`
+		ItineraryID string `json:"itinerary_id" db:"itinerary_id" lock:"true"`
+	}
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		for _, id := range []string{"a", "b"} {
+			err := tx.Insert(TableTripItinerary, softDeleteTestObject{ID: "i" + id, TripID: id})
+			if err != nil {
+				return err
+			}
+			err = tx.Insert(TableTripItineraryEvent, itineraryEvent{ID: "e" + id, TripID: id, ItineraryID: "i" + id})
+			if err != nil {
+				return err
+			}
+		}
+		return SoftDelete(tx, TableTripItinerary, "user-1", "ia")
+	})
+	assert.Nil(t, err)
+
+	//the soft deleted children are included
+	children, err := childrenIDs(s, TableTrip, []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{TableTripItinerary: {"ia"}, TableTripItineraryEvent: {"ea"}}, children)
+
+	children, err = childrenIDs(s, TableTripItinerary, []string{"ia", "ib"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{TableTripItineraryEvent: {"ea", "eb"}}, children)
+
+	children, err = childrenIDs(s, TableEvent, []string{"a"})
+	assert.Nil(t, err)
+	assert.Empty(t, children)
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/gocraft/dbr"
+)
+
+//ErrStateConflict is returned by UpdateState when the record column no longer has the expected value
+var ErrStateConflict = errors.New("the record state was changed by another request")
+
+//stateLocker is implemented by the writers able to lock a record column until the end of the transaction
+type stateLocker interface {
+	lockState(table, id, column string) (string, error)
+}
+
+//UpdateState changes the record like Writer.Update when the column still has the expected value, otherwise it
+//returns ErrStateConflict. The record stays locked until the end of the transaction, so only one of the concurrent
+//requests moving the record out of the state succeeds.
+func UpdateState(tx Writer, table, id, column, expected string, object interface{}, values map[string]interface{}) error {
+	locker, ok := tx.(stateLocker)
+	if !ok {
+		return errors.New("the writer can't lock the table " + table)
+	}
+	current, err := locker.lockState(table, id, column)
+	if err != nil {
+		return err
+	}
+	if current != expected {
+		return ErrStateConflict
+	}
+	return tx.Update(table, id, object, values)
+}
+
+func (w mysqlWriter) lockState(table, id, column string) (string, error) {
+	var state dbr.NullString
+	err := w.tx.SelectBySql("SELECT `"+column+"` FROM "+table+" WHERE id = ? FOR UPDATE", id).LoadOne(&state)
+	if err == dbr.ErrNotFound {
+		return "", ErrNotFound
+	}
+	return state.String, err
+}
+
+func (w *memoryWriter) lockState(table, id, column string) (string, error) {
+	for _, r := range w.tables[table] {
+		if recordID(r) == id {
+			f, ok := fieldByTag(r, "db", column)
+			if !ok {
+				return "", nil
+			}
+			state, _ := normalizeValue(f).(string)
+			return state, nil
+		}
+	}
+	return "", ErrNotFound
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stateTestObject struct {
+	ID     string `json:"id" db:"id" lock:"true"`
+	Status string `json:"status" db:"status"`
+}
+
+func TestUpdateState(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		return tx.Insert(TableTripInvite, stateTestObject{ID: "a", Status: "pending"})
+	})
+	assert.Nil(t, err)
+
+	update := func(id, status string) error {
+		return s.Transaction(func(tx Writer) error {
+			return UpdateState(tx, TableTripInvite, id, "status", "pending", stateTestObject{}, map[string]interface{}{"status": status})
+		})
+	}
+
+	assert.Nil(t, update("a", "accepted"))
+	assert.Equal(t, ErrStateConflict, update("a", "declined"))
+	assert.Equal(t, ErrNotFound, update("b", "accepted"))
+
+	record := stateTestObject{}
+	assert.Nil(t, s.LoadOne(TableTripInvite, "a", &record))
+	assert.Equal(t, "accepted", record.Status)
+}
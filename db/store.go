@@ -0,0 +1,164 @@
+package db
+
+import (
+	"reflect"
+
+	"github.com/gocraft/dbr"
+)
+
+//Store loads and persists the resources records, the resources repositories are built on top of it
+type Store interface {
+	//Select load the records matching the request params with the pagination metadata
+	Select(table string, params map[string]string, object interface{}) (interface{}, error)
+	//QueryOne load one record with the same type of object
+	QueryOne(table, id string, object interface{}) (interface{}, error)
+	//LoadOne load one record into dest, a pointer to a struct
+	LoadOne(table, id string, dest interface{}) error
+	//LoadAll load the records matching the request params into dest, a pointer to a slice of structs
+	LoadAll(table string, params map[string]string, dest interface{}) error
+	//Transaction runs fn in a single transaction, the changes are discarded if fn returns an error
+	Transaction(fn func(tx Writer) error) error
+}
+
+//Writer changes records inside a Store transaction
+type Writer interface {
+	Insert(table string, object interface{}) error
+	Update(table, id string, object interface{}, values map[string]interface{}) error
+	Delete(table string, ids ...string) error
+}
+
+//MySQLStore implements the Store using the shared connection pool of the MySQL database configured in the FMT_DB* environment variables
+type MySQLStore struct{}
+
+//NewMySQLStore returns a Store backed by the MySQL database
+func NewMySQLStore() *MySQLStore {
+	return &MySQLStore{}
+}
+
+//session returns a session on the shared pool, it must not be closed as dbr sessions close the underlying sql.DB
+func (s *MySQLStore) session() (*dbr.Session, error) {
+	conn, err := Pool()
+	if err != nil {
+		return nil, err
+	}
+	return conn.NewSession(nil), nil
+}
+
+//Select load the records matching the request params with the pagination metadata
+func (s *MySQLStore) Select(table string, params map[string]string, object interface{}) (interface{}, error) {
+	session, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	return Select(session, table, params, object)
+}
+
+//QueryOne load one record with the same type of object
+func (s *MySQLStore) QueryOne(table, id string, object interface{}) (interface{}, error) {
+	session, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	return QueryOne(session, table, id, object)
+}
+
+//LoadOne load one record into dest, a pointer to a struct
+func (s *MySQLStore) LoadOne(table, id string, dest interface{}) error {
+	session, err := s.session()
+	if err != nil {
+		return err
+	}
+	return LoadOne(session, table, id, dest)
+}
+
+//LoadAll load the records matching the request params into dest, a pointer to a slice of structs
+func (s *MySQLStore) LoadAll(table string, params map[string]string, dest interface{}) error {
+	session, err := s.session()
+	if err != nil {
+		return err
+	}
+	return LoadAll(session, table, params, dest)
+}
+
+//Transaction runs fn in a single database transaction
+func (s *MySQLStore) Transaction(fn func(tx Writer) error) error {
+	session, err := s.session()
+	if err != nil {
+		return err
+	}
+
+	tx, err := session.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	err = fn(mysqlWriter{tx: tx})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type mysqlWriter struct {
+	tx *dbr.Tx
+}
+
+func (w mysqlWriter) Insert(table string, object interface{}) error {
+	err := Insert(w.tx, table, object)
+	if err != nil || auditIgnoredTables[table] {
+		return err
+	}
+	v := reflect.ValueOf(object)
+	return auditChange(w, table, recordID(v), AuditInsert, nil, auditValues(v))
+}
+
+func (w mysqlWriter) Update(table, id string, object interface{}, values map[string]interface{}) error {
+	if auditIgnoredTables[table] {
+		return Update(w.tx, table, id, object, values)
+	}
+
+	before, err := loadAuditRecord(w.tx, table, id, object)
+	if err == dbr.ErrNotFound {
+		return Update(w.tx, table, id, object, values)
+	}
+	if err != nil {
+		return err
+	}
+	err = Update(w.tx, table, id, object, values)
+	if err != nil {
+		return err
+	}
+
+	after := reflect.New(before.Type()).Elem()
+	after.Set(before)
+	err = applyValues(after, object, values)
+	if err != nil {
+		return err
+	}
+	return auditUpdate(w, table, id, before, after)
+}
+
+func (w mysqlWriter) Delete(table string, ids ...string) error {
+	if auditIgnoredTables[table] {
+		return deleteRecords(w.tx, table, ids...)
+	}
+
+	records, err := loadAuditRows(w.tx, table, ids...)
+	if err != nil {
+		return err
+	}
+	err = deleteRecords(w.tx, table, ids...)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if values, ok := records[id]; ok {
+			err := auditChange(w, table, id, AuditDelete, values, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
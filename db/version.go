@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/gocraft/dbr"
+)
+
+//AnyVersion skips the version check of UpdateVersion, used when the request doesn't inform the version it changes
+const AnyVersion int64 = -1
+
+//ErrVersionConflict is returned by UpdateVersion when the record was changed after the informed version
+var ErrVersionConflict = errors.New("the record was changed by another request")
+
+//VersionedTables defines the tables with the version column, it is incremented by every update of the record
+var VersionedTables = map[string]bool{
+	TableTrip:               true,
+	TableTripItinerary:      true,
+	TableTripItineraryEvent: true,
+	TableEvent:              true,
+	TableCategory:           true,
+}
+
+//versionLocker is implemented by the writers able to lock the record version until the end of the transaction
+type versionLocker interface {
+	lockVersion(table, id string) (int64, error)
+}
+
+//UpdateVersion changes the record like Writer.Update when its version is still the informed one, otherwise it
+//returns ErrVersionConflict. The record stays locked until the end of the transaction, so a concurrent update
+//waits and then fails the check.
+func UpdateVersion(tx Writer, table, id string, version int64, object interface{}, values map[string]interface{}) error {
+	if version != AnyVersion {
+		locker, ok := tx.(versionLocker)
+		if !ok || !VersionedTables[table] {
+			return errors.New("the table " + table + " has no version")
+		}
+		current, err := locker.lockVersion(table, id)
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return ErrVersionConflict
+		}
+	}
+	return tx.Update(table, id, object, values)
+}
+
+func (w mysqlWriter) lockVersion(table, id string) (int64, error) {
+	var version int64
+	err := w.tx.SelectBySql("SELECT version FROM "+table+" WHERE id = ? FOR UPDATE", id).LoadOne(&version)
+	if err == dbr.ErrNotFound {
+		return 0, ErrNotFound
+	}
+	return version, err
+}
+
+func (w *memoryWriter) lockVersion(table, id string) (int64, error) {
+	for _, r := range w.tables[table] {
+		if recordID(r) == id {
+			f, ok := fieldByTag(r, "db", "version")
+			if !ok {
+				return 0, nil
+			}
+			version, _ := normalizeValue(f).(int64)
+			return version, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+//bumpVersion increments the version column of the record, like the updates of the database
+func bumpVersion(table string, record reflect.Value) {
+	if !VersionedTables[table] {
+		return
+	}
+	if f, ok := fieldByTag(record, "db", "version"); ok && f.CanSet() {
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(f.Int() + 1)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type versionTestObject struct {
+	ID      string `json:"id" db:"id" lock:"true"`
+	Title   string `json:"title" db:"title"`
+	Version int64  `json:"version" db:"version" lock:"true"`
+}
+
+func TestUpdateVersion(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.Transaction(func(tx Writer) error {
+		return tx.Insert(TableCategory, versionTestObject{ID: "a"})
+	})
+	assert.Nil(t, err)
+
+	update := func(version int64, title string) error {
+		return s.Transaction(func(tx Writer) error {
+			return UpdateVersion(tx, TableCategory, "a", version, versionTestObject{}, map[string]interface{}{"title": title})
+		})
+	}
+
+	assert.Nil(t, update(0, "first"))
+	assert.Equal(t, ErrVersionConflict, update(0, "stale"))
+	assert.Nil(t, update(AnyVersion, "any"))
+
+	record := versionTestObject{}
+	assert.Nil(t, s.LoadOne(TableCategory, "a", &record))
+	assert.Equal(t, "any", record.Title)
+	assert.Equal(t, int64(2), record.Version)
+
+	err = s.Transaction(func(tx Writer) error {
+		return UpdateVersion(tx, TableCategory, "b", 0, versionTestObject{}, map[string]interface{}{"title": "b"})
+	})
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -1,41 +1,15 @@
 package main
 
 import (
-	"net/http"
-
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/resources/users"
+	"github.com/feedmytrip/api/routers"
 )
 
-func router(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	authentication := auth.Auth{}
-	switch req.Resource {
-	case "/auth/login":
-		switch req.HTTPMethod {
-		case "POST":
-			return authentication.Login(req)
-		}
-	case "/auth/refresh":
-		switch req.HTTPMethod {
-		case "GET":
-			return authentication.Refresh(req)
-		}
-	case "/auth/register":
-		switch req.HTTPMethod {
-		case "POST":
-			return authentication.Register(req)
-		}
-	}
+var provider, providerErr = auth.NewProviderFromEnv()
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusMethodNotAllowed,
-		Headers: map[string]string{
-			"Access-Control-Allow-Origin":      "*",
-			"Access-Control-Allow-Credentials": "true",
-		},
-	}, nil
-}
+var router = routers.Auth(provider, providerErr, users.NewMySQLRepository())
 
 func main() {
 	lambda.Start(router)
@@ -6,19 +6,64 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
 	fmt "github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/resources/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	provider     fmt.IdentityProvider
+	repo         users.Repository
 	refreshToken string
+	accessToken  string
+	code         string
 }
 
-func (suite *FeedMyTripAPITestSuite) Test0010Login() {
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.provider = fmt.NewLocalProvider(db.NewMemoryStore(), fmt.LocalConfig{
+		Key:      apitest.Key(),
+		KeyID:    apitest.KeyID,
+		Issuer:   apitest.Issuer,
+		Audience: apitest.Audience,
+		SendCode: func(kind, email, code string) error {
+			suite.code = code
+			return nil
+		},
+	})
+	suite.repo = users.NewMemoryRepository()
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0010Register() {
 	credentials := `{
-		"username": "test_admin",
+		"username": "test_register",
+		"password": "fmt12345",
+		"family_name": "Sobrenome",
+		"given_name": "Nome",
+		"email": "email@test.com",
+		"group": "Admin",
+		"language_code": "pt"
+	}`
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": apitest.Token("test_admin", "Admin"),
+		},
+		Body: credentials,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.Register(req, suite.provider, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0020Login() {
+	credentials := `{
+		"username": "test_register",
 		"password": "fmt12345"
 	}`
 	req := events.APIGatewayProxyRequest{
@@ -26,16 +71,18 @@ func (suite *FeedMyTripAPITestSuite) Test0010Login() {
 	}
 
 	auth := fmt.Auth{}
-	response, err := auth.Login(req)
+	response, err := auth.Login(req, suite.provider)
 	user := fmt.UserResponse{}
 	json.Unmarshal([]byte(response.Body), &user)
-	suite.refreshToken = *user.Tokens.RefreshToken
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), "Admin", user.Group)
+	suite.refreshToken = *user.Tokens.RefreshToken
+	suite.accessToken = *user.Tokens.AccessToken
 }
 
-func (suite *FeedMyTripAPITestSuite) Test0020RefreshToken() {
+func (suite *FeedMyTripAPITestSuite) Test0030RefreshToken() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
 			"Authorization": suite.refreshToken,
@@ -43,20 +90,35 @@ func (suite *FeedMyTripAPITestSuite) Test0020RefreshToken() {
 	}
 
 	auth := fmt.Auth{}
-	response, err := auth.Refresh(req)
+	response, err := auth.Refresh(req, suite.provider)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
-func (suite *FeedMyTripAPITestSuite) Test0030Register() {
+func (suite *FeedMyTripAPITestSuite) Test0040LoginWrongPassword() {
 	credentials := `{
 		"username": "test_register",
+		"password": "wrong"
+	}`
+	req := events.APIGatewayProxyRequest{
+		Body: credentials,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.Login(req, suite.provider)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0050SignUp() {
+	credentials := `{
+		"username": "test_signup",
 		"password": "fmt12345",
 		"family_name": "Sobrenome",
 		"given_name": "Nome",
-		"email": "email@test.com",
-		"group": "Admin",
+		"email": "signup@test.com",
 		"language_code": "pt"
 	}`
 	req := events.APIGatewayProxyRequest{
@@ -64,11 +126,176 @@ func (suite *FeedMyTripAPITestSuite) Test0030Register() {
 	}
 
 	auth := fmt.Auth{}
-	response, err := auth.Register(req)
+	response, err := auth.SignUp(req, suite.provider, suite.repo)
+	user := fmt.DBUser{}
+	json.Unmarshal([]byte(response.Body), &user)
 
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.False(suite.T(), user.Active)
+	assert.Equal(suite.T(), "User", user.Group)
+	assert.NotEmpty(suite.T(), suite.code)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0060SignUpDuplicated() {
+	tests := []struct {
+		body string
+		code string
+	}{
+		{`{"username": "test_signup", "password": "fmt12345", "email": "other@test.com"}`, "username_exists"},
+		{`{"username": "test_other", "password": "fmt12345", "email": "signup@test.com"}`, "email_exists"},
+	}
+
+	auth := fmt.Auth{}
+	for _, test := range tests {
+		response, err := auth.SignUp(events.APIGatewayProxyRequest{Body: test.body}, suite.provider, suite.repo)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+		assert.Contains(suite.T(), response.Body, `"code":"`+test.code+`"`)
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0070SignUpAdminGroup() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": apitest.Token("test_user"),
+		},
+		Body: `{"username": "test_admin_signup", "password": "fmt12345", "email": "admin@test.com", "group": "Admin"}`,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.SignUp(req, suite.provider, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	//the public sign up accepts only the User group
+	for _, group := range []string{"SuperAdmin", "User,Admin"} {
+		req = events.APIGatewayProxyRequest{
+			Body: `{"username": "test_admin_signup", "password": "fmt12345", "email": "admin@test.com", "group": "` + group + `"}`,
+		}
+		response, err = auth.SignUp(req, suite.provider, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0080ResendConfirmation() {
+	req := events.APIGatewayProxyRequest{
+		Body: `{"username": "test_signup"}`,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.ResendConfirmation(req, suite.provider)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, response.StatusCode, response.Body)
+	assert.Contains(suite.T(), response.Body, `"code":"rate_limited"`)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0090Confirm() {
+	auth := fmt.Auth{}
+	response, err := auth.Login(events.APIGatewayProxyRequest{Body: `{"username": "test_signup", "password": "fmt12345"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	response, err = auth.Confirm(events.APIGatewayProxyRequest{Body: `{"username": "test_signup", "code": "wrong"}`}, suite.provider, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+	assert.Contains(suite.T(), response.Body, `"code":"invalid_code"`)
+
+	response, err = auth.Confirm(events.APIGatewayProxyRequest{Body: `{"username": "test_signup", "code": "` + suite.code + `"}`}, suite.provider, suite.repo)
+	user := fmt.DBUser{}
+	json.Unmarshal([]byte(response.Body), &user)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.True(suite.T(), user.Active)
+
+	response, err = auth.Login(events.APIGatewayProxyRequest{Body: `{"username": "test_signup", "password": "fmt12345"}`}, suite.provider)
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
+
+func (suite *FeedMyTripAPITestSuite) Test0100ForgotPassword() {
+	auth := fmt.Auth{}
+	response, err := auth.ForgotPassword(events.APIGatewayProxyRequest{Body: `{"username": "test_register"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = auth.ForgotPassword(events.APIGatewayProxyRequest{Body: `{"username": "test_register"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, response.StatusCode, response.Body)
+
+	response, err = auth.ForgotPassword(events.APIGatewayProxyRequest{Body: `{"username": "test_unknown"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0110ResetPassword() {
+	auth := fmt.Auth{}
+	response, err := auth.ResetPassword(events.APIGatewayProxyRequest{Body: `{"username": "test_register", "code": "wrong", "password": "fmt54321"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	response, err = auth.ResetPassword(events.APIGatewayProxyRequest{Body: `{"username": "test_register", "code": "` + suite.code + `", "password": "fmt54321"}`}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = auth.Refresh(events.APIGatewayProxyRequest{Headers: map[string]string{"Authorization": suite.refreshToken}}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnauthorized, response.StatusCode, response.Body)
+
+	response, err = auth.Login(events.APIGatewayProxyRequest{Body: `{"username": "test_register", "password": "fmt54321"}`}, suite.provider)
+	user := fmt.UserResponse{}
+	json.Unmarshal([]byte(response.Body), &user)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	suite.refreshToken = *user.Tokens.RefreshToken
+	suite.accessToken = *user.Tokens.AccessToken
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0120ChangePassword() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.accessToken,
+		},
+		Body: `{"previous_password": "wrong", "proposed_password": "fmt12345"}`,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.ChangePassword(req, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	req.Body = `{"previous_password": "fmt54321", "proposed_password": "fmt12345"}`
+	response, err = auth.ChangePassword(req, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = auth.ChangePassword(events.APIGatewayProxyRequest{Body: req.Body}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnauthorized, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0130Logout() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.accessToken,
+		},
+		Body: `{"refresh_token": "` + suite.refreshToken + `"}`,
+	}
+
+	auth := fmt.Auth{}
+	response, err := auth.Logout(req, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = auth.Refresh(events.APIGatewayProxyRequest{Headers: map[string]string{"Authorization": suite.refreshToken}}, suite.provider)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnauthorized, response.StatusCode, response.Body)
+}
+
 func TestFeedMyTripAPITestSuite(t *testing.T) {
 	suite.Run(t, new(FeedMyTripAPITestSuite))
 }
@@ -6,7 +6,8 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
 	"github.com/feedmytrip/api/resources/categories"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -14,17 +15,14 @@ import (
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo       categories.Repository
 	token      string
 	categoryID string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.token = *user.Tokens.AccessToken
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.repo = categories.NewMemoryRepository()
+	suite.token = apitest.Token("test_admin", "Admin")
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewCategory() {
@@ -42,7 +40,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewCategory() {
 	}
 
 	category := categories.Category{}
-	response, err := category.SaveNew(req)
+	response, err := category.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &category)
 	suite.categoryID = category.ID
 
@@ -58,13 +56,15 @@ func (suite *FeedMyTripAPITestSuite) Test0020GetAllCategories() {
 	}
 
 	category := categories.Category{}
-	response, err := category.GetAll(req)
+	response, err := category.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0030UpdateCategory() {
+	db.SetAuditContext("test_admin", "request-0030")
+	defer db.SetAuditContext("", "")
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
 			"Authorization": suite.token,
@@ -80,11 +80,94 @@ func (suite *FeedMyTripAPITestSuite) Test0030UpdateCategory() {
 	}
 
 	category := categories.Category{}
-	response, err := category.Update(req)
+	response, err := category.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
+func (suite *FeedMyTripAPITestSuite) Test0035CategoryHistory() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+		},
+		PathParameters: map[string]string{
+			"id": suite.categoryID,
+		},
+	}
+
+	category := categories.Category{}
+	response, err := category.History(req, suite.repo)
+	result := struct {
+		Data []struct {
+			Action    string                 `json:"action"`
+			Actor     string                 `json:"actor"`
+			RequestID string                 `json:"request_id"`
+			Before    map[string]interface{} `json:"before"`
+			After     map[string]interface{} `json:"after"`
+		} `json:"data"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &result)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.Len(suite.T(), result.Data, 2) {
+		assert.Equal(suite.T(), db.AuditUpdate, result.Data[0].Action)
+		assert.Equal(suite.T(), "test_admin", result.Data[0].Actor)
+		assert.Equal(suite.T(), "request-0030", result.Data[0].RequestID)
+		assert.Equal(suite.T(), "Transporte", result.Data[0].Before["title.pt"])
+		assert.Equal(suite.T(), "Nova Categoria 002", result.Data[0].After["title.pt"])
+		assert.Equal(suite.T(), false, result.Data[0].After["active"])
+		assert.Equal(suite.T(), db.AuditInsert, result.Data[1].Action)
+	}
+
+	req.Headers["Authorization"] = apitest.Token("test_user")
+	response, err = category.History(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0037ConditionalCategoryRequests() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+		},
+		PathParameters: map[string]string{
+			"id": suite.categoryID,
+		},
+	}
+
+	category := categories.Category{}
+	response, err := category.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"1"`, response.Headers["ETag"])
+
+	req.Headers["If-None-Match"] = `"1"`
+	response, err = category.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, response.StatusCode, response.Body)
+	assert.Empty(suite.T(), response.Body)
+	delete(req.Headers, "If-None-Match")
+
+	req.Body = `{"active": true}`
+	req.Headers["If-Match"] = `"0"`
+	response, err = category.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, response.StatusCode, response.Body)
+
+	req.Headers["if-match"] = `W/"1"`
+	delete(req.Headers, "If-Match")
+	response, err = category.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, response.StatusCode, response.Body)
+
+	req.Headers["if-match"] = `"1"`
+	response, err = category.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"2"`, response.Headers["ETag"])
+}
+
 func (suite *FeedMyTripAPITestSuite) Test0040DeleteCategory() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
@@ -96,7 +179,7 @@ func (suite *FeedMyTripAPITestSuite) Test0040DeleteCategory() {
 	}
 
 	category := categories.Category{}
-	response, err := category.Delete(req)
+	response, err := category.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
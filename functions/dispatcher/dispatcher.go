@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/webhooks"
+)
+
+//deadlineMargin keeps time to record the last batch before the lambda timeout
+const deadlineMargin = 10 * time.Second
+
+var store = db.NewMySQLStore()
+var dispatcher = newDispatcher()
+var sender = webhooks.NewSender(webhooks.NewRepository(store))
+
+func newDispatcher() *outbox.Dispatcher {
+	d := outbox.NewDispatcher(store)
+	err := d.Register(trips.NewNotifier(trips.NewRepository(store)))
+	if err == nil {
+		err = d.Register(webhooks.NewFanout(webhooks.NewRepository(store)))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	return d
+}
+
+//result counts the outbox entries and the webhook deliveries processed by the invocation
+type result struct {
+	Outbox   outbox.Result `json:"outbox"`
+	Webhooks outbox.Result `json:"webhooks"`
+}
+
+func handler(ctx context.Context) (result, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-deadlineMargin))
+		defer cancel()
+	}
+	r := result{}
+	var err error
+	r.Outbox, err = dispatcher.Drain(ctx)
+	if err != nil {
+		return r, err
+	}
+	r.Webhooks, err = sender.Drain(ctx)
+	return r, err
+}
+
+func main() {
+	lambda.Start(handler)
+}
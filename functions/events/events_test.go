@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
 	fmt "github.com/feedmytrip/api/resources/events"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -14,18 +15,15 @@ import (
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo       fmt.Repository
 	token      string
 	eventID    string
 	scheduleID string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.token = *user.Tokens.AccessToken
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.repo = fmt.NewMemoryRepository()
+	suite.token = apitest.Token("test_admin", "Admin")
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewEvent() {
@@ -44,7 +42,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewEvent() {
 	}
 
 	event := fmt.Event{}
-	response, err := event.SaveNew(req)
+	response, err := event.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &event)
 	suite.eventID = event.ID
 
@@ -60,7 +58,7 @@ func (suite *FeedMyTripAPITestSuite) Test0020GetAllEvents() {
 	}
 
 	event := fmt.Event{}
-	response, err := event.GetAll(req)
+	response, err := event.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -77,7 +75,7 @@ func (suite *FeedMyTripAPITestSuite) Test0021GetEvent() {
 	}
 
 	event := fmt.Event{}
-	response, err := event.Get(req)
+	response, err := event.Get(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -99,12 +97,44 @@ func (suite *FeedMyTripAPITestSuite) Test0030UpdateEvent() {
 	}
 
 	event := fmt.Event{}
-	response, err := event.Update(req)
+	response, err := event.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
+func (suite *FeedMyTripAPITestSuite) Test0031UpdateEventStaleVersion() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+			"If-Match":      `"0"`,
+		},
+		PathParameters: map[string]string{
+			"id": suite.eventID,
+		},
+		Body: `{
+			"active": true
+		}`,
+	}
+
+	event := fmt.Event{}
+	response, err := event.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, response.StatusCode, response.Body)
+
+	req.Headers["If-Match"] = `"1"`
+	response, err = event.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"2"`, response.Headers["ETag"])
+
+	delete(req.Headers, "If-Match")
+	req.Headers["If-None-Match"] = `W/"2"`
+	response, err = event.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, response.StatusCode, response.Body)
+}
+
 func (suite *FeedMyTripAPITestSuite) Test0040CreateEventSchedule() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
@@ -121,7 +151,7 @@ func (suite *FeedMyTripAPITestSuite) Test0040CreateEventSchedule() {
 	}
 
 	s := fmt.Schedule{}
-	response, err := s.SaveNew(req)
+	response, err := s.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &s)
 	suite.scheduleID = s.ID
 
@@ -140,7 +170,7 @@ func (suite *FeedMyTripAPITestSuite) Test0050GetAllEventSchedules() {
 	}
 
 	s := fmt.Schedule{}
-	response, err := s.GetAll(req)
+	response, err := s.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -162,7 +192,7 @@ func (suite *FeedMyTripAPITestSuite) Test0060UpdateEventSchedule() {
 	}
 
 	s := fmt.Schedule{}
-	response, err := s.Update(req)
+	response, err := s.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -180,10 +210,132 @@ func (suite *FeedMyTripAPITestSuite) Test0070DeleteEventSchedule() {
 	}
 
 	s := fmt.Schedule{}
-	response, err := s.Delete(req)
+	response, err := s.Delete(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0072CreateEventScheduleInvalidWeekDays() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+		},
+		PathParameters: map[string]string{
+			"id": suite.eventID,
+		},
+		Body: `{
+			"start_date": "2019-08-01T09:00:00Z",
+			"end_date": "2019-08-31T18:00:00Z",
+			"week_days": "mon-fri"
+		}`,
+	}
+
+	s := fmt.Schedule{}
+	response, err := s.SaveNew(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0075GetEventAvailability() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+		},
+		PathParameters: map[string]string{
+			"id": suite.eventID,
+		},
+	}
+
+	//open from monday to friday in august and closed on the 5th afternoon
+	for _, body := range []string{
+		`{"start_date": "2019-08-01T09:00:00Z", "end_date": "2019-08-31T18:00:00Z", "week_days": "0111110"}`,
+		`{"start_date": "2019-08-05T12:00:00Z", "end_date": "2019-08-05T23:00:00Z", "fixed_period": true, "closed": true}`,
+	} {
+		req.Body = body
+		s := fmt.Schedule{}
+		response, err := s.SaveNew(req, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+		suite.scheduleID = s.ID
+	}
+
+	req.Body = ""
+	req.QueryStringParameters = map[string]string{
+		"from": "2019-08-02T10:00:00Z",
+		"to":   "2019-08-07",
+	}
+	event := fmt.Event{}
+	response, err := event.Availability(req, suite.repo)
+	result := fmt.AvailabilityResult{}
+	json.Unmarshal([]byte(response.Body), &result)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.True(suite.T(), result.Open)
+	windows := []string{}
+	for _, w := range result.Windows {
+		windows = append(windows, w.Start.Format(time.RFC3339)+" "+w.End.Format(time.RFC3339))
+	}
+	assert.Equal(suite.T(), []string{
+		"2019-08-02T10:00:00Z 2019-08-02T18:00:00Z",
+		"2019-08-05T09:00:00Z 2019-08-05T12:00:00Z",
+		"2019-08-06T09:00:00Z 2019-08-06T18:00:00Z",
+	}, windows)
+
+	req.QueryStringParameters = map[string]string{"from": "2019-08-03", "to": "2019-08-31", "results": "1"}
+	response, err = event.Availability(req, suite.repo)
+	result = fmt.AvailabilityResult{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), result.Open)
+	if assert.Len(suite.T(), result.Windows, 1) {
+		assert.Equal(suite.T(), "2019-08-05T09:00:00Z", result.Windows[0].Start.Format(time.RFC3339))
+	}
+
+	for _, params := range []map[string]string{
+		{"from": "2019-08-07", "to": "2019-08-01"},
+		{"from": "2019-01-01", "to": "2020-06-01"},
+		{"from": "yesterday"},
+		{"results": "0"},
+	} {
+		req.QueryStringParameters = params
+		response, err = event.Availability(req, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+	}
+
+	req.PathParameters["id"] = "unknown"
+	req.QueryStringParameters = nil
+	response, err = event.Availability(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0076UpdateEventScheduleInvalidWeekDays() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.token,
+		},
+		PathParameters: map[string]string{
+			"id":          suite.eventID,
+			"schedule_id": suite.scheduleID,
+		},
+	}
+
+	s := fmt.Schedule{}
+	for body, status := range map[string]int{
+		`{"week_days": "01111102"}`: http.StatusBadRequest,
+		`{"week_days": 127}`:        http.StatusBadRequest,
+		`{"week_days": "1000001"}`:  http.StatusOK,
+		`{"week_days": ""}`:         http.StatusOK,
+	} {
+		req.Body = body
+		response, err := s.Update(req, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), status, response.StatusCode, body)
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0080DeleteEvent() {
@@ -197,10 +349,72 @@ func (suite *FeedMyTripAPITestSuite) Test0080DeleteEvent() {
 	}
 
 	event := fmt.Event{}
-	response, err := event.Delete(req)
+	response, err := event.Delete(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0090GetAllDeletedEvents() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": apitest.Token("test_user"),
+		},
+		QueryStringParameters: map[string]string{
+			"include_deleted": "true",
+		},
+	}
+
+	event := fmt.Event{}
+	response, err := event.GetAll(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.token
+	for _, include := range []string{"false", "true"} {
+		req.QueryStringParameters["include_deleted"] = include
+		response, err = event.GetAll(req, suite.repo)
+		result := struct {
+			Data []fmt.Event `json:"data"`
+		}{}
+		json.Unmarshal([]byte(response.Body), &result)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+		if include == "false" {
+			assert.Empty(suite.T(), result.Data)
+		} else {
+			assert.Len(suite.T(), result.Data, 1)
+		}
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0100RestoreEvent() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": apitest.Token("test_user"),
+		},
+		PathParameters: map[string]string{
+			"id": suite.eventID,
+		},
+	}
+
+	event := fmt.Event{}
+	response, err := event.Restore(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
 
+	req.Headers["Authorization"] = suite.token
+	response, err = event.Restore(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &event)
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), suite.eventID, event.ID)
+	assert.False(suite.T(), event.DeletedAt.Valid)
+
+	response, err = event.Restore(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
 }
 
 func TestFeedMyTripAPITestSuite(t *testing.T) {
@@ -6,7 +6,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
 	"github.com/feedmytrip/api/resources/highlights"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -14,17 +14,14 @@ import (
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo        highlights.Repository
 	token       string
 	HighlightID string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.token = *user.Tokens.AccessToken
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.repo = highlights.NewMemoryRepository()
+	suite.token = apitest.Token("test_admin", "Admin")
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewHighlight() {
@@ -42,7 +39,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewHighlight() {
 	}
 
 	highlight := highlights.Highlight{}
-	response, err := highlight.SaveNew(req)
+	response, err := highlight.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &highlight)
 	suite.HighlightID = highlight.ID
 
@@ -58,7 +55,7 @@ func (suite *FeedMyTripAPITestSuite) Test0020GetAllHighlights() {
 	}
 
 	highlight := highlights.Highlight{}
-	response, err := highlight.GetAll(req)
+	response, err := highlight.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -78,7 +75,7 @@ func (suite *FeedMyTripAPITestSuite) Test0030UpdateHighlight() {
 	}
 
 	highlight := highlights.Highlight{}
-	response, err := highlight.Update(req)
+	response, err := highlight.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -98,7 +95,7 @@ func (suite *FeedMyTripAPITestSuite) Test0040DeleteHighlight() {
 	}
 
 	highlight := highlights.Highlight{}
-	response, err := highlight.Update(req)
+	response, err := highlight.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
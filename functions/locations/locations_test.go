@@ -6,7 +6,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
 	"github.com/feedmytrip/api/resources/locations"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -14,17 +14,14 @@ import (
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo       locations.Repository
 	token      string
 	locationID string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.token = *user.Tokens.AccessToken
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.repo = locations.NewMemoryRepository()
+	suite.token = apitest.Token("test_admin", "Admin")
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewLocation() {
@@ -42,7 +39,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewLocation() {
 	}
 
 	location := locations.Location{}
-	response, err := location.SaveNew(req)
+	response, err := location.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &location)
 	suite.locationID = location.ID
 
@@ -61,7 +58,7 @@ func (suite *FeedMyTripAPITestSuite) Test0020GetAllLocations() {
 	}
 
 	location := locations.Location{}
-	response, err := location.GetAll(req)
+	response, err := location.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -81,7 +78,7 @@ func (suite *FeedMyTripAPITestSuite) Test0030UpdateLocation() {
 	}
 
 	location := locations.Location{}
-	response, err := location.Update(req)
+	response, err := location.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -97,7 +94,7 @@ func (suite *FeedMyTripAPITestSuite) Test0040DeleteLocation() {
 	}
 
 	location := locations.Location{}
-	response, err := location.Delete(req)
+	response, err := location.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
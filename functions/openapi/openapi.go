@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/feedmytrip/api/routers"
+)
+
+var router = routers.OpenAPI()
+
+func main() {
+	lambda.Start(router)
+}
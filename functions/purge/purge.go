@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/feedmytrip/api/db"
+)
+
+var store = db.NewMySQLStore()
+
+//handler removes the records soft deleted before the retention window, it returns the number of purged records by table
+func handler(ctx context.Context) (map[string]int, error) {
+	requestID := ""
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		requestID = lc.AwsRequestID
+	}
+	db.SetAuditContext("", requestID)
+
+	return db.PurgeAll(store, time.Now().Add(-db.PurgeRetention()))
+}
+
+func main() {
+	lambda.Start(handler)
+}
@@ -4,43 +4,59 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/mailer"
+	"github.com/feedmytrip/api/outbox"
 	fmt "github.com/feedmytrip/api/resources/events"
 	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/users"
+	"github.com/feedmytrip/api/routers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo              trips.Repository
+	eventsRepo        fmt.Repository
 	adminToken        string
 	participantToken  string
 	participantUserID string
 	participantID     string
 	itineraryID       string
 	inviteID          string
+	inviteToken       string
+	mailer            *mailer.MemoryMailer
+	store             *db.MemoryStore
+	dispatcher        *outbox.Dispatcher
 	itineraryEventID  string
 	tripID            string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.adminToken = *user.Tokens.AccessToken
-
-	credentials = `{
-		"username": "test_participant",
-		"password": "fmt2018"
-	}`
-	participantUser, _ := auth.LoginUser(credentials)
-	suite.participantToken = *participantUser.Tokens.AccessToken
-	suite.participantUserID = participantUser.UserID
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.store = db.NewMemoryStore()
+	suite.repo = trips.NewRepository(suite.store)
+	suite.eventsRepo = fmt.NewRepository(suite.store)
+	suite.dispatcher = outbox.NewDispatcher(suite.store)
+	suite.dispatcher.Register(trips.NewNotifier(suite.repo))
+	suite.adminToken = apitest.Token("test_admin", "Admin")
+	suite.participantUserID = "test_participant"
+	suite.participantToken = apitest.Token(suite.participantUserID)
+	os.Setenv("FMT_INVITE_SECRET", "apitest")
+	suite.mailer = mailer.NewMemoryMailer()
+	mailer.SetDefault(suite.mailer)
+	suite.repo.Users.Create(users.User{
+		ID:           suite.participantUserID,
+		Active:       true,
+		Email:        "participant@teste.com",
+		LanguageCode: "pt",
+	})
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewTrip() {
@@ -59,7 +75,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewTrip() {
 	}
 
 	trip := trips.Trip{}
-	response, err := trip.SaveNew(req)
+	response, err := trip.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &trip)
 	suite.tripID = trip.ID
 
@@ -75,7 +91,7 @@ func (suite *FeedMyTripAPITestSuite) Test0020GetAllTrips() {
 	}
 
 	trip := trips.Trip{}
-	response, err := trip.GetAll(req)
+	response, err := trip.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -95,10 +111,10 @@ func (suite *FeedMyTripAPITestSuite) Test0030UpdateTrip() {
 	}
 
 	trip := trips.Trip{}
-	response, err := trip.Update(req)
+	response, err := trip.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
-	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0100ForbiddenGetTripParticipants() {
@@ -112,7 +128,7 @@ func (suite *FeedMyTripAPITestSuite) Test0100ForbiddenGetTripParticipants() {
 	}
 
 	participant := trips.Participant{}
-	response, err := participant.GetAll(req)
+	response, err := participant.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
@@ -133,7 +149,7 @@ func (suite *FeedMyTripAPITestSuite) Test0110SaveNewParticipant() {
 	}
 
 	participant := trips.Participant{}
-	response, err := participant.SaveNew(req)
+	response, err := participant.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &participant)
 	suite.participantID = participant.ID
 
@@ -152,7 +168,7 @@ func (suite *FeedMyTripAPITestSuite) Test0120GetTripParticipants() {
 	}
 
 	participant := trips.Participant{}
-	response, err := participant.GetAll(req)
+	response, err := participant.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -172,11 +188,32 @@ func (suite *FeedMyTripAPITestSuite) Test0130UpdateParticipant() {
 		},
 	}
 
+	suite.dispatcher.Dispatch()
+	suite.mailer.Reset()
 	participant := trips.Participant{}
-	response, err := participant.Update(req)
+	response, err := participant.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Empty(suite.T(), suite.mailer.Messages())
+
+	entries := []outbox.Entry{}
+	suite.store.LoadAll(db.TableOutbox, map[string]string{"type": trips.EventParticipantRoleChange, "aggregate_id": suite.participantID}, &entries)
+	if assert.Len(suite.T(), entries, 1) {
+		change := trips.RoleChange{}
+		assert.Nil(suite.T(), entries[0].Decode(&change))
+		assert.Equal(suite.T(), trips.ParticipantViewerRole, change.Role)
+		assert.Equal(suite.T(), suite.participantUserID, change.UserID)
+	}
+
+	result, err := suite.dispatcher.Dispatch()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2, result.Delivered)
+	messages := suite.mailer.Messages()
+	if assert.Len(suite.T(), messages, 1) {
+		assert.Equal(suite.T(), "participant@teste.com", messages[0].To)
+		assert.Contains(suite.T(), messages[0].Subject, "Seu papel na viagem")
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0200ForbiddenSaveNewItinerary() {
@@ -193,7 +230,7 @@ func (suite *FeedMyTripAPITestSuite) Test0200ForbiddenSaveNewItinerary() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.SaveNew(req)
+	response, err := itinerary.SaveNew(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
@@ -212,7 +249,7 @@ func (suite *FeedMyTripAPITestSuite) Test0210SaveNewItinerary() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.SaveNew(req)
+	response, err := itinerary.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &itinerary)
 	suite.itineraryID = itinerary.ID
 
@@ -231,7 +268,7 @@ func (suite *FeedMyTripAPITestSuite) Test0220GetTripItineraries() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.GetAll(req)
+	response, err := itinerary.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -251,11 +288,19 @@ func (suite *FeedMyTripAPITestSuite) Test0230UpdateItinerary() {
 		},
 	}
 
+	suite.dispatcher.Dispatch()
+	suite.mailer.Reset()
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.Update(req)
+	response, err := itinerary.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	suite.dispatcher.Dispatch()
+	messages := suite.mailer.Messages()
+	if assert.Len(suite.T(), messages, 1) {
+		assert.Equal(suite.T(), "participant@teste.com", messages[0].To)
+		assert.Contains(suite.T(), messages[0].Subject, "Novo roteiro atualizado")
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0240ForbiddenUpdateItinerary() {
@@ -273,19 +318,56 @@ func (suite *FeedMyTripAPITestSuite) Test0240ForbiddenUpdateItinerary() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.Update(req)
+	response, err := itinerary.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
 }
 
+func (suite *FeedMyTripAPITestSuite) Test0250ConditionalItineraryRequests() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	response, err := itinerary.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"1"`, response.Headers["ETag"])
+
+	req.Headers["If-None-Match"] = response.Headers["ETag"]
+	response, err = itinerary.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, response.StatusCode, response.Body)
+	delete(req.Headers, "If-None-Match")
+
+	req.Body = `{"title.pt": "Roteiro com versao"}`
+	req.Headers["If-Match"] = `"0"`
+	response, err = itinerary.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, response.StatusCode, response.Body)
+
+	req.Headers["If-Match"] = `"1"`
+	response, err = itinerary.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"2"`, response.Headers["ETag"])
+}
+
 func (suite *FeedMyTripAPITestSuite) Test0300SaveNewInvite() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
 			"Authorization": suite.adminToken,
 		},
 		Body: `{
-			"email": "teste@teste.com"
+			"email": "teste@teste.com",
+			"role": "editor"
 		}`,
 		PathParameters: map[string]string{
 			"id": suite.tripID,
@@ -293,12 +375,20 @@ func (suite *FeedMyTripAPITestSuite) Test0300SaveNewInvite() {
 	}
 
 	invite := trips.Invite{}
-	response, err := invite.SaveNew(req)
+	response, err := invite.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &invite)
 	suite.inviteID = invite.ID
+	suite.inviteToken = invite.Token
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), trips.InvitePending, invite.Status)
+	assert.NotEmpty(suite.T(), invite.Token)
+	messages := suite.mailer.Messages()
+	if assert.NotEmpty(suite.T(), messages) {
+		assert.Equal(suite.T(), "teste@teste.com", messages[len(messages)-1].To)
+		assert.Contains(suite.T(), messages[len(messages)-1].Body, invite.Token)
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0310SaveNewInvalidEmailInvite() {
@@ -315,7 +405,7 @@ func (suite *FeedMyTripAPITestSuite) Test0310SaveNewInvalidEmailInvite() {
 	}
 
 	invite := trips.Invite{}
-	response, err := invite.SaveNew(req)
+	response, err := invite.SaveNew(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
@@ -332,12 +422,119 @@ func (suite *FeedMyTripAPITestSuite) Test0320GetTripInvites() {
 	}
 
 	invite := trips.Invite{}
-	response, err := invite.GetAll(req)
+	response, err := invite.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
+func (suite *FeedMyTripAPITestSuite) Test0330AcceptInvite() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": apitest.Token("test_invitee"),
+		},
+		Body: `{"token": "forged.token"}`,
+		PathParameters: map[string]string{
+			"id":        suite.tripID,
+			"invite_id": suite.inviteID,
+		},
+	}
+
+	invite := trips.Invite{}
+	response, err := invite.Accept(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Body = `{"token": "` + suite.inviteToken + `"}`
+	response, err = invite.Accept(req, suite.repo)
+	participant := trips.Participant{}
+	json.Unmarshal([]byte(response.Body), &participant)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), trips.ParticipantEditorRole, participant.Role)
+
+	role, err := suite.repo.Participants.Role(suite.tripID, "test_invitee")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), trips.ParticipantEditorRole, role)
+
+	response, err = invite.Accept(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+
+	response, err = invite.Decline(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0340DeclineInvite() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		Body: `{"email": "declined@teste.com"}`,
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	invite := trips.Invite{}
+	response, err := invite.SaveNew(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &invite)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), trips.ParticipantViewerRole, invite.Role)
+
+	req.Headers["Authorization"] = apitest.Token("test_declined")
+	req.Body = `{"token": "` + invite.Token + `"}`
+	req.PathParameters["invite_id"] = invite.ID
+	response, err = invite.Decline(req, suite.repo)
+	declined := trips.Invite{}
+	json.Unmarshal([]byte(response.Body), &declined)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), trips.InviteDeclined, declined.Status)
+	assert.Equal(suite.T(), "test_declined", declined.RespondedBy)
+	assert.True(suite.T(), declined.RespondedDate.Valid)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0350InviteStatus() {
+	err := suite.repo.Invites.Create(trips.Invite{
+		ID:          "expired_invite",
+		TripID:      suite.tripID,
+		Email:       "expired@teste.com",
+		Role:        trips.ParticipantViewerRole,
+		Status:      trips.InvitePending,
+		ExpiresDate: time.Now().Add(-time.Hour),
+		CreatedBy:   "test_admin",
+		CreatedDate: time.Now().Add(-8 * 24 * time.Hour),
+	})
+	assert.Nil(suite.T(), err)
+
+	for status, total := range map[string]int{trips.InviteAccepted: 1, trips.InviteDeclined: 1, trips.InviteExpired: 1, trips.InvitePending: 0} {
+		req := events.APIGatewayProxyRequest{
+			Headers: map[string]string{
+				"Authorization": suite.adminToken,
+			},
+			PathParameters: map[string]string{
+				"id": suite.tripID,
+			},
+			QueryStringParameters: map[string]string{
+				"status": status,
+			},
+		}
+
+		invite := trips.Invite{}
+		response, err := invite.GetAll(req, suite.repo)
+		result := struct {
+			Data []trips.Invite `json:"data"`
+		}{}
+		json.Unmarshal([]byte(response.Body), &result)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+		assert.Len(suite.T(), result.Data, total, status)
+	}
+}
+
 func (suite *FeedMyTripAPITestSuite) Test0400SaveNewItineraryEvent() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
@@ -358,7 +555,7 @@ func (suite *FeedMyTripAPITestSuite) Test0400SaveNewItineraryEvent() {
 	}
 
 	event := trips.ItineraryEvent{}
-	response, err := event.SaveNew(req)
+	response, err := event.SaveNew(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &event)
 	suite.itineraryEventID = event.ID
 
@@ -382,7 +579,7 @@ func (suite *FeedMyTripAPITestSuite) Test0410AddGlobalItineraryEvent() {
 	}
 
 	globalEvent := fmt.Event{}
-	response, err := globalEvent.SaveNew(req)
+	response, err := globalEvent.SaveNew(req, suite.eventsRepo)
 	json.Unmarshal([]byte(response.Body), &globalEvent)
 
 	req = events.APIGatewayProxyRequest{
@@ -400,7 +597,7 @@ func (suite *FeedMyTripAPITestSuite) Test0410AddGlobalItineraryEvent() {
 	}
 
 	event := trips.ItineraryEvent{}
-	response, err = event.Add(req)
+	response, err = event.Add(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
@@ -418,10 +615,47 @@ func (suite *FeedMyTripAPITestSuite) Test0420GetItineraryEvents() {
 	}
 
 	event := trips.ItineraryEvent{}
-	response, err := event.GetAll(req)
+	response, err := event.GetAll(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0425ParticipantGetAllItineraryEvents() {
+	suite.repo.ItineraryEvents.Create(trips.ItineraryEvent{
+		ID:          "other_trip_event",
+		TripID:      "other_trip",
+		ItineraryID: "other_itinerary",
+		BeginOffset: -1,
+	})
+
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.participantToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+		},
+		QueryStringParameters: map[string]string{
+			"all": "true",
+		},
+	}
+
+	event := trips.ItineraryEvent{}
+	response, err := event.GetAll(req, suite.repo)
+	result := struct {
+		Data []trips.ItineraryEvent `json:"data"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &result)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.NotEmpty(suite.T(), result.Data)
+	for _, e := range result.Data {
+		assert.Equal(suite.T(), suite.tripID, e.TripID)
+		assert.Equal(suite.T(), suite.itineraryID, e.ItineraryID)
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0430UpdateItineraryEvent() {
@@ -441,12 +675,479 @@ func (suite *FeedMyTripAPITestSuite) Test0430UpdateItineraryEvent() {
 	}
 
 	event := trips.ItineraryEvent{}
-	response, err := event.Update(req)
+	response, err := event.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
 }
 
+func (suite *FeedMyTripAPITestSuite) Test0435ConcurrentItineraryEventUpdate() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+			"If-Match":      `"1"`,
+		},
+		Body: `{
+			"duration": 7200
+		}`,
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+			"event_id":     suite.itineraryEventID,
+		},
+	}
+
+	//both clients loaded the version 1, only the first update is applied
+	event := trips.ItineraryEvent{}
+	response, err := event.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = event.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusPreconditionFailed, response.StatusCode, response.Body)
+
+	delete(req.Headers, "If-Match")
+	req.Headers["If-None-Match"] = `"2"`
+	response, err = event.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0437GetItineraryEventRoute() {
+	req := events.APIGatewayProxyRequest{
+		Resource:   "/trips/{id}/itineraries/{itinerary_id}/events/{event_id}",
+		HTTPMethod: "GET",
+		Headers: map[string]string{
+			"Authorization": suite.participantToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+			"event_id":     suite.itineraryEventID,
+		},
+	}
+
+	router := routers.Trips(suite.repo)
+	response, err := router(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), `"2"`, response.Headers["ETag"])
+
+	req.Headers["If-None-Match"] = response.Headers["ETag"]
+	response, err = router(req)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0440ItineraryHistory() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.participantToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	response, err := itinerary.History(req, suite.repo)
+	result := struct {
+		Data []db.AuditEntry `json:"data"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &result)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.NotEmpty(suite.T(), result.Data) {
+		assert.Equal(suite.T(), db.TableTripItineraryEvent, result.Data[0].TableName)
+		assert.Equal(suite.T(), db.AuditUpdate, result.Data[0].Action)
+		assert.Equal(suite.T(), suite.itineraryEventID, result.Data[0].RecordID)
+	}
+
+	req.PathParameters["itinerary_id"] = "invalid"
+	response, err = itinerary.History(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0450ItineraryEventAvailabilityWarnings() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		Body: `{
+			"title": {
+				"en": "Testing event schedules"
+			}
+		}`,
+	}
+
+	globalEvent := fmt.Event{}
+	globalEvent.SaveNew(req, suite.eventsRepo)
+
+	//open from monday to friday between 09:00 and 18:00
+	req.PathParameters = map[string]string{"id": globalEvent.ID}
+	req.Body = `{
+		"start_date": "2019-08-01T09:00:00Z",
+		"end_date": "2019-08-31T18:00:00Z",
+		"week_days": "0111110"
+	}`
+	schedule := fmt.Schedule{}
+	response, err := schedule.SaveNew(req, suite.eventsRepo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+
+	//the itinerary starts on friday
+	err = suite.repo.Itineraries.Update(suite.itineraryID, db.AnyVersion, map[string]interface{}{
+		"start_date": time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Nil(suite.T(), err)
+
+	req.PathParameters = map[string]string{
+		"id":              suite.tripID,
+		"itinerary_id":    suite.itineraryID,
+		"global_event_id": globalEvent.ID,
+	}
+	req.Body = `{
+		"begin_offset": 122400
+	}`
+	req.QueryStringParameters = map[string]string{"strict": "true"}
+	event := trips.ItineraryEvent{}
+	response, err = event.Add(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+
+	req.QueryStringParameters = nil
+	event = trips.ItineraryEvent{}
+	response, err = event.Add(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &event)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	if assert.Len(suite.T(), event.Warnings, 1) {
+		assert.Equal(suite.T(), trips.WarningEventClosed, event.Warnings[0].Code)
+	}
+
+	//monday from 12:00 until 22:00 is open only until 18:00
+	req.PathParameters = map[string]string{
+		"id":           suite.tripID,
+		"itinerary_id": suite.itineraryID,
+		"event_id":     event.ID,
+	}
+	req.Body = `{
+		"begin_offset": 302400,
+		"duration": 36000
+	}`
+	req.QueryStringParameters = map[string]string{"strict": "true"}
+	response, err = event.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+
+	req.QueryStringParameters = nil
+	result := trips.ItineraryEvent{}
+	response, err = event.Update(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.Len(suite.T(), result.Warnings, 1) {
+		assert.Equal(suite.T(), trips.WarningEventPartiallyOpen, result.Warnings[0].Code)
+		assert.Len(suite.T(), result.Warnings[0].Windows, 1)
+	}
+
+	req.Body = `{
+		"duration": 21600
+	}`
+	req.QueryStringParameters = map[string]string{"strict": "true"}
+	result = trips.ItineraryEvent{}
+	response, err = event.Update(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Empty(suite.T(), result.Warnings)
+
+	req.QueryStringParameters = nil
+	response, err = event.Get(req, suite.repo)
+	result = trips.ItineraryEvent{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Empty(suite.T(), result.Warnings)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0460ItineraryConflicts() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		Body: `{
+			"title.pt": "Roteiro com conflitos"
+		}`,
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	itinerary.SaveNew(req, suite.repo)
+
+	req.PathParameters["itinerary_id"] = itinerary.ID
+	req.Body = `{
+		"title": {
+			"en": "Morning event"
+		},
+		"begin_offset": 3600,
+		"duration": 7200
+	}`
+	event := trips.ItineraryEvent{}
+	response, err := event.SaveNew(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+
+	req.Body = `{
+		"title": {
+			"en": "Overlapping event"
+		},
+		"begin_offset": 7200,
+		"duration": 7200
+	}`
+	req.QueryStringParameters = map[string]string{"check_conflicts": "true"}
+	overlapping := trips.ItineraryEvent{}
+	response, err = overlapping.SaveNew(req, suite.repo)
+	conflict := struct {
+		Details trips.ConflictError `json:"details"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &conflict)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+	assert.Len(suite.T(), conflict.Details.Overlaps, 1)
+
+	req.QueryStringParameters = nil
+	overlapping = trips.ItineraryEvent{}
+	response, err = overlapping.SaveNew(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+
+	req.Body = `{
+		"title": {
+			"en": "Unscheduled event"
+		}
+	}`
+	response, err = (&trips.ItineraryEvent{}).SaveNew(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+
+	response, err = itinerary.Conflicts(req, suite.repo)
+	result := trips.ItineraryConflicts{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.Len(suite.T(), result.Overlaps, 1) {
+		assert.Equal(suite.T(), []string{event.ID, overlapping.ID}, result.Overlaps[0].EventIDs)
+		assert.Equal(suite.T(), float64(7200), result.Overlaps[0].BeginOffset)
+		assert.Equal(suite.T(), float64(10800), result.Overlaps[0].EndOffset)
+	}
+	assert.Empty(suite.T(), result.OutOfRange)
+	assert.Len(suite.T(), result.Unscheduled, 1)
+
+	//the itinerary has a single day
+	req.PathParameters["event_id"] = overlapping.ID
+	req.Body = `{
+		"begin_offset": 86400
+	}`
+	req.QueryStringParameters = map[string]string{"check_conflicts": "true"}
+	response, err = overlapping.Update(req, suite.repo)
+	conflict = struct {
+		Details trips.ConflictError `json:"details"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &conflict)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+	assert.Len(suite.T(), conflict.Details.OutOfRange, 1)
+
+	req.Body = `{
+		"begin_offset": 10800
+	}`
+	response, err = overlapping.Update(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = itinerary.Conflicts(req, suite.repo)
+	result = trips.ItineraryConflicts{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), result.Overlaps)
+
+	req.PathParameters["itinerary_id"] = "invalid"
+	response, err = itinerary.Conflicts(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0470AutoScheduleItinerary() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		Body: `{
+			"title.pt": "Roteiro automatico"
+		}`,
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	itinerary.SaveNew(req, suite.repo)
+	err := suite.repo.Itineraries.Update(itinerary.ID, db.AnyVersion, map[string]interface{}{
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 2, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Nil(suite.T(), err)
+
+	req.PathParameters["itinerary_id"] = itinerary.ID
+	for _, body := range []string{
+		`{"title": {"en": "Fixed event"}, "begin_offset": 32400, "duration": 7200}`,
+		`{"title": {"en": "First unscheduled event"}, "duration": 10800}`,
+		`{"title": {"en": "Second unscheduled event"}, "duration": 43200}`,
+	} {
+		req.Body = body
+		response, err := (&trips.ItineraryEvent{}).SaveNew(req, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	}
+
+	req.Body = `{
+		"day_start": 72000,
+		"day_end": 36000
+	}`
+	response, err := itinerary.AutoSchedule(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	req.Body = ""
+	response, err = itinerary.AutoSchedule(req, suite.repo)
+	preview := trips.AutoSchedule{}
+	json.Unmarshal([]byte(response.Body), &preview)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.False(suite.T(), preview.Applied)
+	assert.Len(suite.T(), preview.Placements, 2)
+	assert.Empty(suite.T(), preview.Unplaced)
+
+	conflicts := trips.ItineraryConflicts{}
+	response, err = itinerary.Conflicts(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &conflicts)
+	assert.Len(suite.T(), conflicts.Unscheduled, 2)
+
+	req.QueryStringParameters = map[string]string{"apply": "true"}
+	response, err = itinerary.AutoSchedule(req, suite.repo)
+	result := trips.AutoSchedule{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.True(suite.T(), result.Applied)
+	assert.Equal(suite.T(), preview.Placements, result.Placements)
+
+	conflicts = trips.ItineraryConflicts{}
+	response, err = itinerary.Conflicts(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &conflicts)
+	assert.Empty(suite.T(), conflicts.Unscheduled)
+	assert.Empty(suite.T(), conflicts.Overlaps)
+	assert.Empty(suite.T(), conflicts.OutOfRange)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0480ItineraryDays() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		Body: `{
+			"title.pt": "Roteiro de tres dias"
+		}`,
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	itinerary.SaveNew(req, suite.repo)
+	err := suite.repo.Itineraries.Update(itinerary.ID, db.AnyVersion, map[string]interface{}{
+		"start_date": time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC),
+		"end_date":   time.Date(2019, 8, 3, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Nil(suite.T(), err)
+
+	req.PathParameters["itinerary_id"] = itinerary.ID
+	ids := []string{}
+	for _, body := range []string{
+		`{"title": {"en": "First day"}, "begin_offset": 36000, "duration": 3600}`,
+		`{"title": {"en": "Second day"}, "begin_offset": 122400, "duration": 3600}`,
+		`{"title": {"en": "Third day night"}, "begin_offset": 255600, "duration": 7200}`,
+		`{"title": {"en": "Unscheduled"}}`,
+	} {
+		req.Body = body
+		event := trips.ItineraryEvent{}
+		response, err := event.SaveNew(req, suite.repo)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+		ids = append(ids, event.ID)
+	}
+	offsets := func() []float64 {
+		result := []float64{}
+		for _, id := range ids {
+			e, _ := suite.repo.ItineraryEvents.Get(id)
+			result = append(result, e.BeginOffset)
+		}
+		return result
+	}
+
+	req.Body = `{
+		"days": [1, 1, 2]
+	}`
+	response, err := itinerary.ReorderDays(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	req.Body = `{
+		"days": [3, 1, 2]
+	}`
+	response, err = itinerary.ReorderDays(req, suite.repo)
+	result := trips.Itinerary{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), "2019-08-03", result.EndDate.Format("2006-01-02"))
+	assert.Equal(suite.T(), []float64{122400, 208800, 82800, -1}, offsets())
+
+	req.Body = `{
+		"day": 1
+	}`
+	response, err = itinerary.InsertDay(req, suite.repo)
+	result = trips.Itinerary{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), "2019-08-04", result.EndDate.Format("2006-01-02"))
+	assert.Equal(suite.T(), []float64{208800, 295200, 169200, -1}, offsets())
+
+	req.Body = ""
+	req.PathParameters["day"] = "9"
+	response, err = itinerary.DeleteDay(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+
+	//the events of the removed day become unscheduled
+	req.PathParameters["day"] = "2"
+	response, err = itinerary.DeleteDay(req, suite.repo)
+	result = trips.Itinerary{}
+	json.Unmarshal([]byte(response.Body), &result)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), "2019-08-03", result.EndDate.Format("2006-01-02"))
+	assert.Equal(suite.T(), []float64{122400, 208800, -1, -1}, offsets())
+}
+
 func (suite *FeedMyTripAPITestSuite) Test0993DeleteItineraryEvent() {
 	req := events.APIGatewayProxyRequest{
 		Headers: map[string]string{
@@ -460,7 +1161,7 @@ func (suite *FeedMyTripAPITestSuite) Test0993DeleteItineraryEvent() {
 	}
 
 	event := trips.ItineraryEvent{}
-	response, err := event.Delete(req)
+	response, err := event.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -478,7 +1179,7 @@ func (suite *FeedMyTripAPITestSuite) Test0994ForbiddenDeleteTripInvite() {
 	}
 
 	invite := trips.Invite{}
-	response, err := invite.Delete(req)
+	response, err := invite.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
@@ -496,7 +1197,7 @@ func (suite *FeedMyTripAPITestSuite) Test0995DeleteTripInvite() {
 	}
 
 	invite := trips.Invite{}
-	response, err := invite.Delete(req)
+	response, err := invite.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -514,7 +1215,7 @@ func (suite *FeedMyTripAPITestSuite) Test0996ForbiddenDeleteItinerary() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.Delete(req)
+	response, err := itinerary.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
@@ -532,10 +1233,19 @@ func (suite *FeedMyTripAPITestSuite) Test0997DeleteItinerary() {
 	}
 
 	itinerary := trips.Itinerary{}
-	response, err := itinerary.Delete(req)
+	response, err := itinerary.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	//the trip webhooks match the event by the trip_id attribute
+	entries := []outbox.Entry{}
+	suite.store.LoadAll(db.TableOutbox, map[string]string{"type": trips.EventItineraryDeleted, "aggregate_id": suite.itineraryID}, &entries)
+	if assert.Len(suite.T(), entries, 1) {
+		data := map[string]string{}
+		assert.Nil(suite.T(), entries[0].Decode(&data))
+		assert.Equal(suite.T(), suite.tripID, data["trip_id"])
+	}
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0998DeleteParticipant() {
@@ -550,7 +1260,7 @@ func (suite *FeedMyTripAPITestSuite) Test0998DeleteParticipant() {
 	}
 
 	participant := trips.Participant{}
-	response, err := participant.Delete(req)
+	response, err := participant.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -568,7 +1278,7 @@ func (suite *FeedMyTripAPITestSuite) Test0999InvalidDeleteTrip() {
 	}
 
 	trip := trips.Trip{}
-	response, err := trip.Delete(req)
+	response, err := trip.Delete(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &trip)
 
 	assert.Nil(suite.T(), err)
@@ -586,11 +1296,204 @@ func (suite *FeedMyTripAPITestSuite) Test1000DeleteTrip() {
 	}
 
 	trip := trips.Trip{}
-	response, err := trip.Delete(req)
+	response, err := trip.Delete(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &trip)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test1010DeletedTripHistory() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.participantToken,
+		},
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	trip := trips.Trip{}
+	response, err := trip.History(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.adminToken
+	response, err = trip.History(req, suite.repo)
+	result := struct {
+		Data []struct {
+			Action   string                 `json:"action"`
+			RecordID string                 `json:"record_id"`
+			After    map[string]interface{} `json:"after"`
+		} `json:"data"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &result)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.NotEmpty(suite.T(), result.Data) {
+		assert.Equal(suite.T(), db.AuditUpdate, result.Data[0].Action)
+		assert.Equal(suite.T(), suite.tripID, result.Data[0].RecordID)
+		assert.Equal(suite.T(), "test_admin", result.Data[0].After["deleted_by"])
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test1020GetAllDeletedTrips() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		QueryStringParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	trip := trips.Trip{}
+	for _, include := range []string{"false", "true"} {
+		req.QueryStringParameters["include_deleted"] = include
+		response, err := trip.GetAll(req, suite.repo)
+		result := struct {
+			Data []trips.Trip `json:"data"`
+		}{}
+		json.Unmarshal([]byte(response.Body), &result)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+		if include == "false" {
+			assert.Empty(suite.T(), result.Data)
+		} else if assert.Len(suite.T(), result.Data, 1) {
+			assert.True(suite.T(), result.Data[0].DeletedAt.Valid)
+			assert.Equal(suite.T(), "test_admin", result.Data[0].DeletedBy)
+		}
+	}
+
+	req.QueryStringParameters["include_deleted"] = "maybe"
+	response, err := trip.GetAll(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test1030RestoreTrip() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	trip := trips.Trip{}
+	response, err := trip.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.participantToken
+	response, err = trip.Restore(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.adminToken
+	response, err = trip.Restore(req, suite.repo)
 	json.Unmarshal([]byte(response.Body), &trip)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.False(suite.T(), trip.DeletedAt.Valid)
+	assert.Empty(suite.T(), trip.DeletedBy)
+
+	response, err = trip.Restore(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, response.StatusCode, response.Body)
+}
 
+func (suite *FeedMyTripAPITestSuite) Test1035GetDeletedItineraryEvents() {
+	suite.repo.ItineraryEvents.Create(trips.ItineraryEvent{
+		ID:          "deleted_itinerary_event",
+		TripID:      suite.tripID,
+		ItineraryID: suite.itineraryID,
+		BeginOffset: -1,
+	})
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+			"event_id":     "deleted_itinerary_event",
+		},
+	}
+
+	event := trips.ItineraryEvent{}
+	response, err := event.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+
+	response, err = event.GetAll(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test1040RestoreItinerary() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.participantToken,
+		},
+		PathParameters: map[string]string{
+			"id":           suite.tripID,
+			"itinerary_id": suite.itineraryID,
+		},
+		QueryStringParameters: map[string]string{
+			"include_deleted": "true",
+		},
+	}
+
+	itinerary := trips.Itinerary{}
+	response, err := itinerary.GetAll(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.adminToken
+	response, err = itinerary.Restore(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &itinerary)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), suite.itineraryID, itinerary.ID)
+	assert.False(suite.T(), itinerary.DeletedAt.Valid)
+
+	req.PathParameters["id"] = "other"
+	response, err = itinerary.Restore(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test1050PurgeDeletedTrip() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": suite.adminToken,
+		},
+		PathParameters: map[string]string{
+			"id": suite.tripID,
+		},
+	}
+
+	trip := trips.Trip{}
+	response, err := trip.Delete(req, suite.repo)
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	purged, err := db.Purge(suite.store, db.TableTrip, time.Now().Add(-time.Hour))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, purged)
+
+	purged, err = db.Purge(suite.store, db.TableTrip, time.Now())
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, purged)
+
+	_, err = suite.repo.Trips.GetWithDeleted(suite.tripID)
+	assert.Equal(suite.T(), db.ErrNotFound, err)
+	_, err = suite.repo.Itineraries.GetWithDeleted(suite.itineraryID)
+	assert.Equal(suite.T(), db.ErrNotFound, err)
 }
 
 func TestFeedMyTripAPITestSuite(t *testing.T) {
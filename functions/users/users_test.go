@@ -5,7 +5,7 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/common/apitest"
 	"github.com/feedmytrip/api/resources/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -13,17 +13,14 @@ import (
 
 type FeedMyTripAPITestSuite struct {
 	suite.Suite
+	repo   users.Repository
 	token  string
 	userID string
 }
 
-func (suite *FeedMyTripAPITestSuite) SetupTest() {
-	credentials := `{
-		"username": "test_admin",
-		"password": "fmt12345"
-	}`
-	user, _ := auth.LoginUser(credentials)
-	suite.token = *user.Tokens.AccessToken
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	suite.repo = users.NewMemoryRepository()
+	suite.token = apitest.Token("test_admin", "Admin")
 }
 
 func (suite *FeedMyTripAPITestSuite) Test0010SaveNewUser() {
@@ -39,7 +36,7 @@ func (suite *FeedMyTripAPITestSuite) Test0010SaveNewUser() {
 	}
 
 	user := users.User{}
-	response, err := user.SaveNew(req)
+	response, err := user.SaveNew(req, suite.repo)
 	suite.userID = "0001"
 
 	assert.Nil(suite.T(), err)
@@ -60,7 +57,7 @@ func (suite *FeedMyTripAPITestSuite) Test0020UpdateUser() {
 	}
 
 	user := users.User{}
-	response, err := user.Update(req)
+	response, err := user.Update(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -74,7 +71,7 @@ func (suite *FeedMyTripAPITestSuite) Test0030GetAllUsers() {
 	}
 
 	user := users.User{}
-	response, err := user.GetAll(req)
+	response, err := user.GetAll(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -91,7 +88,7 @@ func (suite *FeedMyTripAPITestSuite) Test0040DeleteUser() {
 	}
 
 	user := users.User{}
-	response, err := user.Delete(req)
+	response, err := user.Delete(req, suite.repo)
 
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/feedmytrip/api/resources/webhooks"
+	"github.com/feedmytrip/api/routers"
+)
+
+var router = routers.Webhooks(webhooks.NewMySQLRepository())
+
+func main() {
+	lambda.Start(router)
+}
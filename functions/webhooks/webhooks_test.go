@@ -0,0 +1,338 @@
+package main
+
+// Basic imports
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+//received represents a request of the local receiver
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+type FeedMyTripAPITestSuite struct {
+	suite.Suite
+	store          *db.MemoryStore
+	repo           webhooks.Repository
+	dispatcher     *outbox.Dispatcher
+	sender         *webhooks.Sender
+	receiver       *httptest.Server
+	mu             sync.Mutex
+	requests       []received
+	status         int
+	adminToken     string
+	ownerToken     string
+	strangerToken  string
+	tripID         string
+	subscriptionID string
+	secret         string
+	deliveryID     string
+}
+
+func (suite *FeedMyTripAPITestSuite) SetupSuite() {
+	//the receiver listens on the loopback address
+	os.Setenv("FMT_WEBHOOK_PRIVATE_TARGETS", "true")
+	suite.store = db.NewMemoryStore()
+	suite.repo = webhooks.NewRepository(suite.store)
+	suite.dispatcher = outbox.NewDispatcher(suite.store)
+	suite.dispatcher.Register(webhooks.NewFanout(suite.repo))
+	suite.sender = webhooks.NewSender(suite.repo)
+	suite.adminToken = apitest.Token("test_admin", "Admin")
+	suite.ownerToken = apitest.Token("test_owner")
+	suite.strangerToken = apitest.Token("test_stranger")
+	suite.status = http.StatusOK
+	suite.receiver = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		suite.requests = append(suite.requests, received{header: r.Header, body: body})
+		w.WriteHeader(suite.status)
+		w.Write([]byte(`{"received":true}`))
+	}))
+
+	trip := trips.Trip{}
+	response, _ := trip.SaveNew(events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": suite.ownerToken},
+		Body:    `{"title": {"en": "Webhooks trip"}}`,
+	}, suite.repo.Trips)
+	json.Unmarshal([]byte(response.Body), &trip)
+	suite.tripID = trip.ID
+}
+
+func (suite *FeedMyTripAPITestSuite) TearDownSuite() {
+	suite.receiver.Close()
+}
+
+//flush dispatches the outbox and sends the deliveries, it returns the requests received
+func (suite *FeedMyTripAPITestSuite) flush() []received {
+	_, err := suite.dispatcher.Dispatch()
+	assert.Nil(suite.T(), err)
+	_, err = suite.sender.Send()
+	assert.Nil(suite.T(), err)
+
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	requests := suite.requests
+	suite.requests = nil
+	return requests
+}
+
+func (suite *FeedMyTripAPITestSuite) updateTrip(title string) {
+	trip := trips.Trip{}
+	response, err := trip.Update(events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"Authorization": suite.ownerToken},
+		PathParameters: map[string]string{"id": suite.tripID},
+		Body:           `{"title": {"en": "` + title + `"}}`,
+	}, suite.repo.Trips)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0010ForbiddenGlobalSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": suite.ownerToken},
+		Body:    `{"url": "` + suite.receiver.URL + `"}`,
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.SaveNew(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0020ForbiddenNotOwnerSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": suite.strangerToken},
+		Body:    `{"url": "` + suite.receiver.URL + `", "trip_id": "` + suite.tripID + `"}`,
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.SaveNew(req, suite.repo)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0030InvalidSubscription() {
+	for _, body := range []string{
+		`{"url": "ftp://example.com", "trip_id": "` + suite.tripID + `"}`,
+		`{"url": "` + suite.receiver.URL + `", "trip_id": "` + suite.tripID + `", "event_types": "user.*"}`,
+	} {
+		req := events.APIGatewayProxyRequest{
+			Headers: map[string]string{"Authorization": suite.ownerToken},
+			Body:    body,
+		}
+
+		subscription := webhooks.Subscription{}
+		response, err := subscription.SaveNew(req, suite.repo)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusBadRequest, response.StatusCode, body)
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0040SaveNewSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": suite.ownerToken},
+		Body:    `{"url": "` + suite.receiver.URL + `", "trip_id": "` + suite.tripID + `", "event_types": "trip.*"}`,
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.SaveNew(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &subscription)
+	suite.subscriptionID = subscription.ID
+	suite.secret = subscription.Secret
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.True(suite.T(), subscription.Active)
+	assert.Len(suite.T(), subscription.Secret, 64)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0050GetSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"Authorization": suite.ownerToken},
+		PathParameters: map[string]string{"id": suite.subscriptionID},
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.Get(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &subscription)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), "", subscription.Secret)
+
+	req.Headers["Authorization"] = suite.strangerToken
+	response, err = subscription.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0060GetAllSubscriptions() {
+	for token, total := range map[string]int{suite.ownerToken: 1, suite.strangerToken: 0, suite.adminToken: 1} {
+		req := events.APIGatewayProxyRequest{
+			Headers: map[string]string{"Authorization": token},
+		}
+
+		subscription := webhooks.Subscription{}
+		response, err := subscription.GetAll(req, suite.repo)
+		result := struct {
+			Data []webhooks.Subscription `json:"data"`
+		}{}
+		json.Unmarshal([]byte(response.Body), &result)
+
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+		assert.Len(suite.T(), result.Data, total)
+	}
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0070DeliverTripUpdate() {
+	suite.flush()
+	suite.updateTrip("Webhooks trip updated")
+
+	requests := suite.flush()
+	if !assert.Len(suite.T(), requests, 1) {
+		return
+	}
+	header := requests[0].header
+	assert.Equal(suite.T(), trips.EventTripUpdated, header.Get("X-FMT-Event"))
+	assert.Equal(suite.T(), "application/json", header.Get("Content-Type"))
+	timestamp, err := strconv.ParseInt(header.Get("X-FMT-Timestamp"), 10, 64)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), webhooks.Signature(suite.secret, timestamp, requests[0].body), header.Get("X-FMT-Signature"))
+
+	payload := webhooks.Payload{}
+	assert.Nil(suite.T(), json.Unmarshal(requests[0].body, &payload))
+	assert.Equal(suite.T(), trips.EventTripUpdated, payload.Type)
+	assert.Equal(suite.T(), suite.tripID, payload.TripID)
+	assert.Equal(suite.T(), "test_owner", payload.Actor)
+
+	//the entry handled again doesn't duplicate the delivery
+	entry := outbox.Entry{}
+	assert.Nil(suite.T(), suite.store.LoadOne(db.TableOutbox, payload.ID, &entry))
+	assert.Nil(suite.T(), webhooks.NewFanout(suite.repo).Handle(entry))
+	deliveries := []webhooks.Delivery{}
+	suite.store.LoadAll(db.TableWebhookDelivery, map[string]string{"entry_id": entry.ID}, &deliveries)
+	assert.Len(suite.T(), deliveries, 1)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0080RetryFailedDelivery() {
+	suite.status = http.StatusInternalServerError
+	suite.updateTrip("Webhooks trip failing")
+
+	requests := suite.flush()
+	assert.Len(suite.T(), requests, 1)
+
+	req := events.APIGatewayProxyRequest{
+		Headers:               map[string]string{"Authorization": suite.ownerToken},
+		PathParameters:        map[string]string{"id": suite.subscriptionID},
+		QueryStringParameters: map[string]string{"status": webhooks.DeliveryPending},
+	}
+
+	delivery := webhooks.Delivery{}
+	response, err := delivery.GetAll(req, suite.repo)
+	result := struct {
+		Data []webhooks.Delivery `json:"data"`
+	}{}
+	json.Unmarshal([]byte(response.Body), &result)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	if assert.Len(suite.T(), result.Data, 1) {
+		suite.deliveryID = result.Data[0].ID
+		assert.Equal(suite.T(), 1, result.Data[0].Attempts)
+		assert.Equal(suite.T(), http.StatusInternalServerError, result.Data[0].ResponseStatus)
+		assert.Equal(suite.T(), `{"received":true}`, result.Data[0].ResponseBody)
+		assert.True(suite.T(), result.Data[0].NextAttemptDate.After(result.Data[0].CreatedDate))
+	}
+
+	//the delivery waits the backoff before the next attempt
+	assert.Empty(suite.T(), suite.flush())
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0090Redeliver() {
+	suite.status = http.StatusOK
+	req := events.APIGatewayProxyRequest{
+		Headers: map[string]string{"Authorization": suite.ownerToken},
+		PathParameters: map[string]string{
+			"id":          suite.subscriptionID,
+			"delivery_id": suite.deliveryID,
+		},
+	}
+
+	delivery := webhooks.Delivery{}
+	response, err := delivery.Redeliver(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &delivery)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusCreated, response.StatusCode, response.Body)
+	assert.Equal(suite.T(), webhooks.DeliveryDelivered, delivery.Status)
+	assert.Equal(suite.T(), suite.deliveryID, delivery.RedeliveryOf)
+	assert.Len(suite.T(), suite.flush(), 1)
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0100UpdateSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"Authorization": suite.ownerToken},
+		PathParameters: map[string]string{"id": suite.subscriptionID},
+		Body:           `{"active": false, "secret": ""}`,
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.Update(req, suite.repo)
+	json.Unmarshal([]byte(response.Body), &subscription)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+	assert.False(suite.T(), subscription.Active)
+	assert.NotEqual(suite.T(), suite.secret, subscription.Secret)
+	assert.Len(suite.T(), subscription.Secret, 64)
+
+	suite.updateTrip("Webhooks trip inactive")
+	assert.Empty(suite.T(), suite.flush())
+}
+
+func (suite *FeedMyTripAPITestSuite) Test0110DeleteSubscription() {
+	req := events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"Authorization": suite.strangerToken},
+		PathParameters: map[string]string{"id": suite.subscriptionID},
+	}
+
+	subscription := webhooks.Subscription{}
+	response, err := subscription.Delete(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusForbidden, response.StatusCode, response.Body)
+
+	req.Headers["Authorization"] = suite.ownerToken
+	response, err = subscription.Delete(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, response.StatusCode, response.Body)
+
+	response, err = subscription.Get(req, suite.repo)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, response.StatusCode, response.Body)
+}
+
+func TestFeedMyTripAPITestSuite(t *testing.T) {
+	suite.Run(t, new(FeedMyTripAPITestSuite))
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const localSender = "FeedMyTrip <no-reply@feedmytrip.local>"
+
+//FileMailer writes each message as an .eml file, it lets the api run offline and the messages be opened by any email client
+type FileMailer struct {
+	Dir string
+	seq uint64
+}
+
+//NewFileMailer returns a FileMailer creating the directory when it doesn't exist
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, errors.New("FMT_MAILER_DIR is not configured")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+//Send writes the message in a new file
+func (f *FileMailer) Send(message Message) error {
+	now := time.Now()
+	msg, err := format(localSender, message, now)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%04d.eml", now.Format("20060102150405.000000000"), atomic.AddUint64(&f.seq, 1))
+	return ioutil.WriteFile(filepath.Join(f.Dir, name), msg, 0644)
+}
+
+//MemoryMailer keeps the sent messages in memory
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+//NewMemoryMailer returns an empty MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+//Send appends the message to the sent messages
+func (m *MemoryMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+//Messages returns the sent messages in order
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message{}, m.messages...)
+}
+
+//Reset removes the sent messages
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"sync"
+	"time"
+)
+
+//Message represents an email sent by the api
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+//Mailer delivers the messages, SMTPMailer sends them and FileMailer and MemoryMailer keep them for local runs and tests
+type Mailer interface {
+	Send(message Message) error
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultMailer Mailer
+)
+
+//SetDefault replaces the mailer returned by Default, the tests use it to inspect the sent messages
+func SetDefault(m Mailer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultMailer = m
+}
+
+//Default returns the mailer configured by the environment, it's created on the first call
+func Default() (Mailer, error) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultMailer != nil {
+		return defaultMailer, nil
+	}
+	m, err := NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	defaultMailer = m
+	return defaultMailer, nil
+}
+
+//NewFromEnv returns the mailer defined by FMT_MAILER: "smtp" sends with the FMT_SMTP_* variables, "file" writes the messages in FMT_MAILER_DIR
+//and "memory" keeps them in memory. When FMT_MAILER is empty the SMTPMailer is used if FMT_SMTP_HOST is set.
+func NewFromEnv() (Mailer, error) {
+	kind := os.Getenv("FMT_MAILER")
+	if kind == "" {
+		kind = "memory"
+		if os.Getenv("FMT_SMTP_HOST") != "" {
+			kind = "smtp"
+		}
+	}
+
+	switch kind {
+	case "smtp":
+		return NewSMTPMailerFromEnv()
+	case "file":
+		return NewFileMailer(os.Getenv("FMT_MAILER_DIR"))
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", kind)
+}
+
+//SendTemplate renders the template in the recipient language and sends it
+func SendTemplate(m Mailer, to, languageCode, name string, data interface{}) error {
+	if to == "" {
+		return errors.New("missing recipient")
+	}
+	subject, body, err := Render(name, languageCode, data)
+	if err != nil {
+		return err
+	}
+	return m.Send(Message{To: to, Subject: subject, Body: body})
+}
+
+//format returns the message in the RFC 5322 format with a quoted-printable UTF-8 body
+func format(from string, message Message, date time.Time) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+	_, err := w.Write([]byte(message.Body))
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
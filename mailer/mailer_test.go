@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	data := ParticipantRole{TripTitle: "Patagonia", Role: "editor"}
+	tests := []struct {
+		languageCode string
+		subject      string
+	}{
+		{"pt", "Seu papel na viagem Patagonia mudou"},
+		{"en", "Your role in the trip Patagonia has changed"},
+		{"es", "Tu rol en el viaje Patagonia ha cambiado"},
+		{"fr", "Your role in the trip Patagonia has changed"},
+		{"", "Your role in the trip Patagonia has changed"},
+	}
+	for _, test := range tests {
+		subject, body, err := Render(TemplateParticipantRole, test.languageCode, data)
+		assert.Nil(t, err)
+		assert.Equal(t, test.subject, subject, test.languageCode)
+		assert.Contains(t, body, "editor")
+	}
+
+	for name := range templates {
+		for _, languageCode := range []string{"pt", "en", "es"} {
+			_, _, err := Render(name, languageCode, map[string]string{})
+			assert.NotNil(t, err, "%s %s renders without data", name, languageCode)
+		}
+	}
+	_, _, err := Render("unknown", "en", data)
+	assert.NotNil(t, err)
+}
+
+func TestRenderInviteLink(t *testing.T) {
+	_, body, err := Render(TemplateTripInvite, "en", TripInvite{TripTitle: "Patagonia", Role: "viewer", Token: "secret-token"})
+	assert.Nil(t, err)
+	assert.Contains(t, body, "secret-token")
+
+	_, body, err = Render(TemplateTripInvite, "en", TripInvite{TripTitle: "Patagonia", Role: "viewer", Link: "https://app/invite", Token: "secret-token"})
+	assert.Nil(t, err)
+	assert.Contains(t, body, "https://app/invite")
+	assert.NotContains(t, body, "secret-token")
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	assert.Nil(t, SendTemplate(m, "traveler@test.com", "es", TemplateItineraryUpdated, ItineraryUpdated{TripTitle: "Andes", ItineraryTitle: "Día 1"}))
+	assert.NotNil(t, SendTemplate(m, "", "es", TemplateItineraryUpdated, ItineraryUpdated{}))
+
+	messages := m.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "traveler@test.com", messages[0].To)
+	assert.Equal(t, "El itinerario Día 1 del viaje Andes ha sido actualizado", messages[0].Subject)
+
+	m.Reset()
+	assert.Empty(t, m.Messages())
+}
+
+func TestFileMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mailer")
+	assert.Nil(t, err)
+	f, err := NewFileMailer(filepath.Join(dir, "outbox"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.Send(Message{To: "traveler@test.com", Subject: "Convite é válido", Body: "Olá"}))
+	assert.Nil(t, f.Send(Message{To: "other@test.com", Subject: "Second", Body: "Hello"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "outbox", "*.eml"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	content, err := ioutil.ReadFile(files[0])
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "To: traveler@test.com\r\n")
+	assert.Contains(t, string(content), "Subject: =?utf-8?q?Convite_=C3=A9_v=C3=A1lido?=\r\n")
+	assert.Contains(t, string(content), "Ol=C3=A1")
+
+	_, err = NewFileMailer("")
+	assert.NotNil(t, err)
+}
+
+func TestSMTPMailer(t *testing.T) {
+	var sent []string
+	s := &SMTPMailer{
+		Addr:     "smtp.test.com:587",
+		Username: "user",
+		Password: "password",
+		From:     "FeedMyTrip <no-reply@feedmytrip.com>",
+		send: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			assert.NotNil(t, a)
+			sent = append(sent, addr, from, strings.Join(to, ","), string(msg))
+			return nil
+		},
+	}
+
+	assert.Nil(t, s.Send(Message{To: "traveler@test.com", Subject: "Hello", Body: "Body"}))
+	assert.Equal(t, "smtp.test.com:587", sent[0])
+	assert.Equal(t, "no-reply@feedmytrip.com", sent[1])
+	assert.Equal(t, "traveler@test.com", sent[2])
+	assert.Contains(t, sent[3], "From: FeedMyTrip <no-reply@feedmytrip.com>\r\n")
+
+	assert.NotNil(t, s.Send(Message{To: "traveler@test.com\r\nBcc: other@test.com", Subject: "Hello"}))
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+//SMTPMailer sends the messages to a SMTP server
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	//send is replaced in tests, it defaults to smtp.SendMail
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+//NewSMTPMailerFromEnv returns a SMTPMailer configured by FMT_SMTP_HOST, FMT_SMTP_PORT, FMT_SMTP_USERNAME, FMT_SMTP_PASSWORD and FMT_SMTP_FROM
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+	host := os.Getenv("FMT_SMTP_HOST")
+	if host == "" {
+		return nil, errors.New("FMT_SMTP_HOST is not configured")
+	}
+	port := os.Getenv("FMT_SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("FMT_SMTP_FROM")
+	if from == "" {
+		return nil, errors.New("FMT_SMTP_FROM is not configured")
+	}
+
+	return &SMTPMailer{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("FMT_SMTP_USERNAME"),
+		Password: os.Getenv("FMT_SMTP_PASSWORD"),
+		From:     from,
+	}, nil
+}
+
+//Send delivers the message, the connection is upgraded with STARTTLS when the server supports it
+func (s *SMTPMailer) Send(message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") {
+		return errors.New("invalid recipient")
+	}
+	msg, err := format(s.From, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	//the envelope sender is the bare address of From, which can include the display name
+	sender, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return err
+	}
+
+	send := s.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(s.Addr, auth, sender.Address, []string{message.To}, msg)
+}
@@ -0,0 +1,170 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/feedmytrip/api/resources/shared"
+)
+
+const (
+	//TemplateTripInvite is sent to the invitee with the invite token, data is a TripInvite
+	TemplateTripInvite = "trip_invite"
+	//TemplateParticipantRole is sent to the participant when the trip role changes, data is a ParticipantRole
+	TemplateParticipantRole = "participant_role"
+	//TemplateItineraryUpdated is sent to the trip participants when an itinerary changes, data is an ItineraryUpdated
+	TemplateItineraryUpdated = "itinerary_updated"
+)
+
+//TripInvite represents the data of the TemplateTripInvite
+type TripInvite struct {
+	TripTitle   string
+	InvitedBy   string
+	Role        string
+	Link        string
+	Token       string
+	ExpiresDate string
+}
+
+//ParticipantRole represents the data of the TemplateParticipantRole
+type ParticipantRole struct {
+	TripTitle string
+	Role      string
+}
+
+//ItineraryUpdated represents the data of the TemplateItineraryUpdated
+type ItineraryUpdated struct {
+	TripTitle      string
+	ItineraryTitle string
+	UpdatedBy      string
+}
+
+//messageTemplate holds the subject and body of a message in the system languages
+type messageTemplate struct {
+	Subject shared.Translation
+	Body    shared.Translation
+}
+
+var templates = map[string]messageTemplate{
+	TemplateTripInvite: {
+		Subject: shared.Translation{
+			PT: `Você foi convidado para a viagem {{.TripTitle}}`,
+			EN: `You have been invited to the trip {{.TripTitle}}`,
+			ES: `Has sido invitado al viaje {{.TripTitle}}`,
+		},
+		Body: shared.Translation{
+			PT: `Olá,
+
+{{if .InvitedBy}}{{.InvitedBy}} convidou você{{else}}Você foi convidado{{end}} para participar da viagem {{.TripTitle}} como {{.Role}}.
+{{if .Link}}
+Aceite ou recuse o convite em: {{.Link}}
+{{else}}
+Use o código a seguir para aceitar ou recusar o convite: {{.Token}}
+{{end}}
+O convite expira em {{.ExpiresDate}}.
+
+FeedMyTrip`,
+			EN: `Hello,
+
+{{if .InvitedBy}}{{.InvitedBy}} invited you{{else}}You have been invited{{end}} to join the trip {{.TripTitle}} as {{.Role}}.
+{{if .Link}}
+Accept or decline the invite at: {{.Link}}
+{{else}}
+Use the following code to accept or decline the invite: {{.Token}}
+{{end}}
+The invite expires on {{.ExpiresDate}}.
+
+FeedMyTrip`,
+			ES: `Hola,
+
+{{if .InvitedBy}}{{.InvitedBy}} te invitó{{else}}Has sido invitado{{end}} a participar del viaje {{.TripTitle}} como {{.Role}}.
+{{if .Link}}
+Acepta o rechaza la invitación en: {{.Link}}
+{{else}}
+Usa el siguiente código para aceptar o rechazar la invitación: {{.Token}}
+{{end}}
+La invitación expira el {{.ExpiresDate}}.
+
+FeedMyTrip`,
+		},
+	},
+	TemplateParticipantRole: {
+		Subject: shared.Translation{
+			PT: `Seu papel na viagem {{.TripTitle}} mudou`,
+			EN: `Your role in the trip {{.TripTitle}} has changed`,
+			ES: `Tu rol en el viaje {{.TripTitle}} ha cambiado`,
+		},
+		Body: shared.Translation{
+			PT: `Olá,
+
+Agora você é {{.Role}} na viagem {{.TripTitle}}.
+
+FeedMyTrip`,
+			EN: `Hello,
+
+You are now {{.Role}} in the trip {{.TripTitle}}.
+
+FeedMyTrip`,
+			ES: `Hola,
+
+Ahora eres {{.Role}} en el viaje {{.TripTitle}}.
+
+FeedMyTrip`,
+		},
+	},
+	TemplateItineraryUpdated: {
+		Subject: shared.Translation{
+			PT: `O roteiro {{.ItineraryTitle}} da viagem {{.TripTitle}} foi atualizado`,
+			EN: `The itinerary {{.ItineraryTitle}} of the trip {{.TripTitle}} has been updated`,
+			ES: `El itinerario {{.ItineraryTitle}} del viaje {{.TripTitle}} ha sido actualizado`,
+		},
+		Body: shared.Translation{
+			PT: `Olá,
+
+{{if .UpdatedBy}}{{.UpdatedBy}} atualizou{{else}}Foi atualizado{{end}} o roteiro {{.ItineraryTitle}} da viagem {{.TripTitle}}.
+
+FeedMyTrip`,
+			EN: `Hello,
+
+{{if .UpdatedBy}}{{.UpdatedBy}} updated{{else}}There are updates in{{end}} the itinerary {{.ItineraryTitle}} of the trip {{.TripTitle}}.
+
+FeedMyTrip`,
+			ES: `Hola,
+
+{{if .UpdatedBy}}{{.UpdatedBy}} actualizó{{else}}Se actualizó{{end}} el itinerario {{.ItineraryTitle}} del viaje {{.TripTitle}}.
+
+FeedMyTrip`,
+		},
+	},
+}
+
+//Render returns the subject and body of the template in the language code, unknown languages use the english template
+func Render(name, languageCode string, data interface{}) (string, string, error) {
+	t, ok := templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown template %q", name)
+	}
+	subject, err := execute(name+".subject", t.Subject.Text(languageCode), data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := execute(name+".body", t.Body.Text(languageCode), data)
+	if err != nil {
+		return "", "", err
+	}
+	return subject, body, nil
+}
+
+func execute(name, text string, data interface{}) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//Version defines the OpenAPI specification version of the documents
+const Version = "3.0.3"
+
+//Document represents an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+
+	//types keeps the component name of the struct types with a schema
+	types map[reflect.Type]string
+}
+
+//Info describes the API of the document
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+//Tag groups the operations of a resource
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+//PathItem has the operations of a path by the lower case http method
+type PathItem map[string]*Operation
+
+//Components has the schemas referenced by the operations
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+//SecurityScheme describes how the requests are authenticated
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+//Operation describes a method of a path
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	//Security is an empty list for the operations without authentication
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+//Parameter describes a path, query or header parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+//RequestBody describes the json body of an operation
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+//Response describes a response of an operation, Ref points to a response of the components
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+//Header describes a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+//MediaType has the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+//NewDocument returns an empty document of the API
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+		},
+		types: map[reflect.Type]string{},
+	}
+}
+
+//AddOperation adds the operation to the method of the path, the parameters of the path template are added
+//to the operation when it doesn't declare them
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	declared := map[string]bool{}
+	for _, p := range op.Parameters {
+		if p.In == "path" {
+			declared[p.Name] = true
+		}
+	}
+	params := []*Parameter{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && !declared[segment[1:len(segment)-1]] {
+			params = append(params, &Parameter{Name: segment[1 : len(segment)-1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	op.Parameters = append(params, op.Parameters...)
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = PathItem{}
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+//Operations returns the method and path of every operation sorted by path
+func (d *Document) Operations() [][2]string {
+	result := [][2]string{}
+	for path, item := range d.Paths {
+		for method := range item {
+			result = append(result, [2]string{strings.ToUpper(method), path})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i][1] != result[j][1] {
+			return result[i][1] < result[j][1]
+		}
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
+
+//JSON returns a json body with the schema
+func JSON(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
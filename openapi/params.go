@@ -0,0 +1,58 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/feedmytrip/api/db"
+)
+
+//filterOperators are described in the filter params, like created_date[gte]=2019-08-01
+var filterOperators = []string{
+	db.FilterEq, db.FilterNe, db.FilterGt, db.FilterGte, db.FilterLt, db.FilterLte, db.FilterBetween,
+	db.FilterIn, db.FilterContains, db.FilterStartsWith, db.FilterIsNull, db.FilterIsNotNull,
+}
+
+//ListParameters returns the pagination, sort and filter params of the lists of the object records,
+//softDelete adds the include_deleted param
+func (d *Document) ListParameters(object interface{}, softDelete bool) []*Parameter {
+	columns := db.FilterColumns(object)
+	names := []string{}
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := []*Parameter{
+		{Name: "page", In: "query", Description: "Page number of the offset pagination", Schema: &Schema{Type: "integer", Format: "int32"}},
+		{Name: "results", In: "query", Description: "Records per page of the page or cursor pagination", Schema: &Schema{Type: "integer", Format: "int32"}},
+		{Name: "cursor", In: "query", Description: "The next_cursor or prev_cursor of the metadata, empty for the first page of the cursor pagination", Schema: &Schema{Type: "string"}},
+		{Name: "sort", In: "query", Description: "Column that sorts the records", Schema: &Schema{Type: "string", Enum: names}},
+		{Name: "order", In: "query", Schema: &Schema{Type: "string", Enum: []string{"asc", "desc"}}},
+		{Name: "count", In: "query", Description: "How the totals of the metadata are counted", Schema: &Schema{Type: "string", Enum: []string{db.CountExact, db.CountEstimate, db.CountNone}}},
+	}
+	if db.IsSearchable(object) {
+		params = append(params, &Parameter{Name: "filter", In: "query", Description: "Text searched in the filterable text columns", Schema: &Schema{Type: "string"}})
+	}
+	if softDelete {
+		params = append(params, &Parameter{Name: "include_deleted", In: "query", Description: "Lists the soft deleted records too, only for admins", Schema: &Schema{Type: "boolean"}})
+	}
+
+	operators := strings.Join(filterOperators, ", ")
+	for _, name := range names {
+		if db.IsReservedParam(name) {
+			continue
+		}
+		kind := d.schemaOf(columns[name])
+		if kind.Format != "" {
+			kind.Type = kind.Format
+		}
+		params = append(params, &Parameter{
+			Name:        name,
+			In:          "query",
+			Description: "Filter by the " + kind.Type + " column, " + name + "[operator] uses one of " + operators,
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	return params
+}
@@ -0,0 +1,241 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/gocraft/dbr"
+)
+
+//Schema represents the schema of a json value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+//Ref returns the schema pointing to the component
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+//scalars are the schemas of the types marshaled as json scalars
+var scalars = map[reflect.Type]Schema{
+	reflect.TypeOf(time.Time{}):       {Type: "string", Format: "date-time"},
+	reflect.TypeOf(dbr.NullTime{}):    {Type: "string", Format: "date-time", Nullable: true},
+	reflect.TypeOf(dbr.NullString{}):  {Type: "string", Nullable: true},
+	reflect.TypeOf(dbr.NullInt64{}):   {Type: "integer", Format: "int64", Nullable: true},
+	reflect.TypeOf(dbr.NullFloat64{}): {Type: "number", Format: "double", Nullable: true},
+	reflect.TypeOf(dbr.NullBool{}):    {Type: "boolean", Nullable: true},
+	reflect.TypeOf(json.RawMessage{}): {},
+}
+
+//Schema returns the schema of the object, the named structs are added to the components and referenced.
+//The fields of the database records are read-only when they have the lock tag or aren't persisted, like the
+//embedded objects loaded by joins.
+func (d *Document) Schema(object interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(object))
+}
+
+//UpdateSchema returns the schema of the PATCH bodies of the database record, with the columns changed by the
+//updates. The persisted embedded objects are changed with the alias.column keys, like title.pt.
+func (d *Document) UpdateSchema(object interface{}) *Schema {
+	t := reflect.TypeOf(object)
+	name := d.componentName(t) + "Update"
+	if _, ok := d.Components.Schemas[name]; ok {
+		return Ref(name)
+	}
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, Description: "The attributes to change, the others keep their values"}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("persist") != "" && field.Type.Kind() == reflect.Struct {
+			alias := field.Tag.Get("alias")
+			if alias == "" {
+				alias, _ = jsonName(field)
+			}
+			for j := 0; j < field.Type.NumField(); j++ {
+				embedded := field.Type.Field(j)
+				if updatable(embedded) {
+					name, _ := jsonName(embedded)
+					s.Properties[alias+"."+name] = d.schemaOf(embedded.Type)
+				}
+			}
+			continue
+		}
+		if updatable(field) {
+			name, _ := jsonName(field)
+			s.Properties[name] = d.schemaOf(field.Type)
+		}
+	}
+	d.Components.Schemas[name] = s
+	return Ref(name)
+}
+
+//ListSchema returns the schema of the list responses of the object records, with the pagination metadata
+func (d *Document) ListSchema(object interface{}) *Schema {
+	item := d.Schema(object)
+	name := d.componentName(reflect.TypeOf(object)) + "List"
+	if _, ok := d.Components.Schemas[name]; ok {
+		return Ref(name)
+	}
+
+	s := d.structSchema(reflect.TypeOf(db.ListResult{}))
+	s.Description = "A page of the records, the params of the request are described by the metadata"
+	s.Properties["data"] = &Schema{Type: "array", Items: item}
+	s.Properties["errors"] = &Schema{Type: "array", Items: &Schema{Type: "object"}, Nullable: true}
+	d.Components.Schemas[name] = s
+	return Ref(name)
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if s, ok := scalars[t]; ok {
+		return &s
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return withAttributes(d.schemaOf(t.Elem()), false, true)
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := d.componentName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			//the placeholder stops the recursion of the self referencing types
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return Ref(name)
+	}
+	return &Schema{}
+}
+
+//structSchema returns the object schema of the struct json fields
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	record := isRecord(t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			for k, v := range d.structSchema(field.Type).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		readOnly := field.Tag.Get("lock") != "" || (record && field.Tag.Get("db") == "" && field.Tag.Get("persist") == "")
+		s.Properties[name] = withAttributes(d.schemaOf(field.Type), readOnly, false)
+	}
+	return s
+}
+
+//componentName returns the schema name of the struct type, the types with the same name of another package
+//are prefixed with the package name
+func (d *Document) componentName(t reflect.Type) string {
+	if name, ok := d.types[t]; ok {
+		return name
+	}
+	name := t.Name()
+	for other, used := range d.types {
+		if used == name && other != t {
+			name = strings.Title(path.Base(t.PkgPath())) + t.Name()
+			break
+		}
+	}
+	d.types[t] = name
+	return name
+}
+
+//SchemaNames returns the names of the component schemas sorted
+func (d *Document) SchemaNames() []string {
+	names := []string{}
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//withAttributes marks the schema as read-only or nullable, the references are wrapped because their siblings
+//are ignored
+func withAttributes(s *Schema, readOnly, nullable bool) *Schema {
+	if !readOnly && !nullable {
+		return s
+	}
+	if s.Ref != "" {
+		s = &Schema{AllOf: []*Schema{s}}
+	}
+	s.ReadOnly = s.ReadOnly || readOnly
+	s.Nullable = s.Nullable || nullable
+	return s
+}
+
+//jsonName returns the json key of the field, false when it isn't marshaled
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+//isRecord check if the struct is loaded from the database
+func isRecord(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("db") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+//updatable check if the column is changed by the updates
+func updatable(field reflect.StructField) bool {
+	name, ok := jsonName(field)
+	return ok && name != "" && field.Tag.Get("db") != "" && field.Tag.Get("lock") == ""
+}
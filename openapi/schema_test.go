@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gocraft/dbr"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTitle struct {
+	PT string `json:"pt" db:"pt"`
+	EN string `json:"en" db:"en"`
+}
+
+type testRecord struct {
+	ID          string       `json:"id" db:"id" lock:"true"`
+	Title       testTitle    `json:"title" table:"translation" alias:"title" embedded:"true" persist:"true"`
+	Country     testTitle    `json:"country" table:"translation" alias:"country" embedded:"true"`
+	Duration    int          `json:"duration" db:"duration"`
+	DeletedAt   dbr.NullTime `json:"deleted_at" db:"deleted_at" lock:"true"`
+	CreatedDate time.Time    `json:"created_date" db:"created_date" lock:"true"`
+	Secret      string       `json:"-" db:"secret"`
+	Parent      *testRecord  `json:"parent,omitempty"`
+}
+
+func TestSchema(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	assert.Equal(t, Ref("testRecord"), d.Schema(testRecord{}))
+
+	s := d.Components.Schemas["testRecord"]
+	if !assert.NotNil(t, s) {
+		return
+	}
+	assert.Equal(t, &Schema{Type: "string", ReadOnly: true}, s.Properties["id"])
+	assert.Equal(t, Ref("testTitle"), s.Properties["title"])
+	assert.Equal(t, &Schema{AllOf: []*Schema{Ref("testTitle")}, ReadOnly: true}, s.Properties["country"])
+	assert.Equal(t, &Schema{Type: "integer", Format: "int32"}, s.Properties["duration"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time", Nullable: true, ReadOnly: true}, s.Properties["deleted_at"])
+	assert.NotContains(t, s.Properties, "secret")
+	//the self reference points to the component with the nullable and read-only attributes
+	assert.Equal(t, &Schema{AllOf: []*Schema{Ref("testRecord")}, ReadOnly: true, Nullable: true}, s.Properties["parent"])
+}
+
+func TestUpdateSchema(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	assert.Equal(t, Ref("testRecordUpdate"), d.UpdateSchema(testRecord{}))
+
+	s := d.Components.Schemas["testRecordUpdate"]
+	keys := []string{}
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	assert.ElementsMatch(t, []string{"title.pt", "title.en", "duration"}, keys)
+}
+
+func TestListSchema(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	assert.Equal(t, Ref("testRecordList"), d.ListSchema(testRecord{}))
+
+	s := d.Components.Schemas["testRecordList"]
+	assert.Equal(t, &Schema{Type: "array", Items: Ref("testRecord")}, s.Properties["data"])
+	assert.Equal(t, Ref("ListMetadata"), s.Properties["metadata"])
+	assert.Contains(t, d.Components.Schemas["ListMetadata"].Properties, "total_filtered")
+}
+
+func TestAddOperation(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	d.AddOperation("GET", "/trips/{id}/itineraries/{itinerary_id}", &Operation{
+		Parameters: []*Parameter{{Name: "id", In: "path", Required: true, Description: "The trip", Schema: &Schema{Type: "string"}}},
+	})
+	d.AddOperation("PATCH", "/trips/{id}", &Operation{})
+
+	op := d.Paths["/trips/{id}/itineraries/{itinerary_id}"]["get"]
+	if assert.Len(t, op.Parameters, 2) {
+		assert.Equal(t, "itinerary_id", op.Parameters[0].Name)
+		assert.Equal(t, "The trip", op.Parameters[1].Description)
+	}
+	assert.Equal(t, [][2]string{{"PATCH", "/trips/{id}"}, {"GET", "/trips/{id}/itineraries/{itinerary_id}"}}, d.Operations())
+}
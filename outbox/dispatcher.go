@@ -0,0 +1,261 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBatchSize   = 100
+	maxBackoff         = time.Hour
+)
+
+//Consumer handles the outbox entries, entries of unknown types must be ignored.
+//Failed entries are delivered again, so Handle must tolerate repeated deliveries.
+type Consumer interface {
+	Name() string
+	Handle(entry Entry) error
+}
+
+//ConsumerFunc adapts a function to a Consumer named name
+func ConsumerFunc(name string, fn func(entry Entry) error) Consumer {
+	return consumerFunc{name: name, fn: fn}
+}
+
+type consumerFunc struct {
+	name string
+	fn   func(entry Entry) error
+}
+
+func (c consumerFunc) Name() string {
+	return c.name
+}
+
+func (c consumerFunc) Handle(entry Entry) error {
+	return c.fn(entry)
+}
+
+//Result counts the entries processed by the dispatcher
+type Result struct {
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Dead      int `json:"dead"`
+}
+
+func (r Result) total() int {
+	return r.Delivered + r.Retried + r.Dead
+}
+
+func (r *Result) add(other Result) {
+	r.Delivered += other.Delivered
+	r.Retried += other.Retried
+	r.Dead += other.Dead
+}
+
+//Dispatcher delivers the pending outbox entries to the registered consumers in the order they were created.
+//An entry failing in any consumer is retried with exponential backoff, only the consumers that failed receive it
+//again, and it's moved to the dead status after MaxAttempts.
+type Dispatcher struct {
+	store       db.Store
+	consumers   []Consumer
+	MaxAttempts int
+	BatchSize   int
+	//Backoff returns the delay before the next attempt, it defaults to 30 seconds doubled on each attempt up to one hour
+	Backoff func(attempts int) time.Duration
+	now     func() time.Time
+}
+
+//NewDispatcher returns a Dispatcher reading the outbox table of the store
+func NewDispatcher(store db.Store) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		MaxAttempts: defaultMaxAttempts,
+		BatchSize:   defaultBatchSize,
+		Backoff:     defaultBackoff,
+		now:         time.Now,
+	}
+}
+
+//Register adds the consumer, the names must be unique as they track the deliveries of each entry
+func (d *Dispatcher) Register(consumer Consumer) error {
+	name := consumer.Name()
+	if name == "" || strings.Contains(name, ",") {
+		return fmt.Errorf("invalid consumer name %q", name)
+	}
+	for _, c := range d.consumers {
+		if c.Name() == name {
+			return fmt.Errorf("consumer %q already registered", name)
+		}
+	}
+	d.consumers = append(d.consumers, consumer)
+	return nil
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+//Dispatch delivers one batch of the entries due, it returns the number of processed entries by outcome
+func (d *Dispatcher) Dispatch() (Result, error) {
+	result := Result{}
+	now := d.now()
+
+	entries := []Entry{}
+	err := d.store.LoadAll(db.TableOutbox, map[string]string{
+		"status":                 StatusPending,
+		"next_attempt_date[lte]": now.Format(time.RFC3339Nano),
+		"sort":                   "created_date",
+		"order":                  "asc",
+		"page":                   "1",
+		"results":                strconv.Itoa(d.BatchSize),
+		"count":                  "none",
+	}, &entries)
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		values := d.deliver(entry, now)
+		err := d.store.Transaction(func(tx db.Writer) error {
+			return tx.Update(db.TableOutbox, entry.ID, Entry{}, values)
+		})
+		if err != nil {
+			return result, err
+		}
+
+		switch values["status"] {
+		case StatusDelivered:
+			result.Delivered++
+		case StatusDead:
+			result.Dead++
+		default:
+			result.Retried++
+		}
+	}
+	return result, nil
+}
+
+//deliver hands the entry to the consumers that didn't handle it yet and returns the entry changes
+func (d *Dispatcher) deliver(entry Entry, now time.Time) map[string]interface{} {
+	delivered := entry.delivered()
+	failures := []string{}
+	for _, c := range d.consumers {
+		if delivered[c.Name()] {
+			continue
+		}
+		err := handle(c, entry)
+		if err != nil {
+			log.Printf("outbox: %s failed handling %s %s: %s", c.Name(), entry.Type, entry.ID, err.Error())
+			failures = append(failures, c.Name()+": "+err.Error())
+			continue
+		}
+		delivered[c.Name()] = true
+	}
+
+	names := []string{}
+	for name := range delivered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := map[string]interface{}{
+		"delivered_to": strings.Join(names, ","),
+	}
+	if len(failures) == 0 {
+		values["status"] = StatusDelivered
+		values["last_error"] = ""
+		values["delivered_date"] = now
+		return values
+	}
+
+	attempts := entry.Attempts + 1
+	values["attempts"] = attempts
+	values["last_error"] = strings.Join(failures, "; ")
+	if attempts >= d.MaxAttempts {
+		values["status"] = StatusDead
+		return values
+	}
+	values["status"] = StatusPending
+	values["next_attempt_date"] = now.Add(d.Backoff(attempts))
+	return values
+}
+
+//handle protects the dispatcher from consumers panicking, the panic fails the delivery
+func handle(c Consumer, entry Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.Handle(entry)
+}
+
+//Drain dispatches batches until there are no entries due or the context is done
+func (d *Dispatcher) Drain(ctx context.Context) (Result, error) {
+	total := Result{}
+	for {
+		select {
+		case <-ctx.Done():
+			return total, nil
+		default:
+		}
+
+		result, err := d.Dispatch()
+		total.add(result)
+		if err != nil {
+			return total, err
+		}
+		if result.total() < d.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+//Requeue moves a dead entry back to pending with the attempts reset, the consumers that handled it aren't called again
+func (d *Dispatcher) Requeue(id string) error {
+	entry := Entry{}
+	err := d.store.LoadOne(db.TableOutbox, id, &entry)
+	if err != nil {
+		return err
+	}
+	if entry.Status != StatusDead {
+		return errors.New("outbox entry " + id + " is " + entry.Status + ", only dead entries can be requeued")
+	}
+	return d.store.Transaction(func(tx db.Writer) error {
+		return tx.Update(db.TableOutbox, id, Entry{}, map[string]interface{}{
+			"status":            StatusPending,
+			"attempts":          0,
+			"next_attempt_date": d.now(),
+		})
+	})
+}
+
+//Dead returns the dead entries, the most recent first
+func (d *Dispatcher) Dead(limit int) ([]Entry, error) {
+	entries := []Entry{}
+	err := d.store.LoadAll(db.TableOutbox, map[string]string{
+		"status":  StatusDead,
+		"sort":    "created_date",
+		"order":   "desc",
+		"page":    "1",
+		"results": strconv.Itoa(limit),
+		"count":   "none",
+	}, &entries)
+	return entries, err
+}
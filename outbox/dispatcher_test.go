@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	name    string
+	fail    int
+	entries []Entry
+}
+
+func (r *recorder) Name() string {
+	return r.name
+}
+
+func (r *recorder) Handle(entry Entry) error {
+	if r.fail > 0 {
+		r.fail--
+		return errors.New("unavailable")
+	}
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func testDispatcher(store db.Store, now *time.Time, consumers ...Consumer) *Dispatcher {
+	d := NewDispatcher(store)
+	d.now = func() time.Time { return *now }
+	for _, c := range consumers {
+		d.Register(c)
+	}
+	return d
+}
+
+func writeEvents(t *testing.T, store db.Store, events ...Event) {
+	err := store.Transaction(func(tx db.Writer) error {
+		return Write(tx, events...)
+	})
+	assert.Nil(t, err)
+}
+
+func loadEntry(t *testing.T, store db.Store, aggregateID string) Entry {
+	entries := []Entry{}
+	err := store.LoadAll(db.TableOutbox, map[string]string{"aggregate_id": aggregateID}, &entries)
+	assert.Nil(t, err)
+	if !assert.Len(t, entries, 1) {
+		return Entry{}
+	}
+	return entries[0]
+}
+
+func TestWriteDiscardedWithTransaction(t *testing.T) {
+	store := db.NewMemoryStore()
+	err := store.Transaction(func(tx db.Writer) error {
+		err := Write(tx, NewEvent("trip.created", "1", "user", nil))
+		if err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	assert.NotNil(t, err)
+
+	entries := []Entry{}
+	store.LoadAll(db.TableOutbox, map[string]string{}, &entries)
+	assert.Empty(t, entries)
+}
+
+func TestNewEventCopiesValues(t *testing.T) {
+	values := map[string]interface{}{"title": "Trip"}
+	event := NewEvent("trip.updated", "1", "user", values)
+
+	assert.Equal(t, map[string]interface{}{"id": "1", "title": "Trip"}, event.Data)
+	assert.Equal(t, map[string]interface{}{"title": "Trip"}, values)
+	assert.Equal(t, map[string]interface{}{"id": "1"}, NewEvent("trip.deleted", "1", "user", nil).Data)
+}
+
+func TestDispatchInOrder(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Now()
+	c := &recorder{name: "recorder"}
+	d := testDispatcher(store, &now, c)
+
+	writeEvents(t, store, NewEvent("trip.created", "1", "user", map[string]interface{}{"title": "Trip"}))
+	writeEvents(t, store, NewEvent("trip.deleted", "2", "user", nil))
+	now = now.Add(time.Second)
+
+	result, err := d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Delivered: 2}, result)
+	if assert.Len(t, c.entries, 2) {
+		assert.Equal(t, "trip.created", c.entries[0].Type)
+		assert.Equal(t, "trip", c.entries[0].Aggregate())
+		data := map[string]string{}
+		assert.Nil(t, c.entries[0].Decode(&data))
+		assert.Equal(t, "Trip", data["title"])
+		assert.Equal(t, "trip.deleted", c.entries[1].Type)
+	}
+
+	entry := loadEntry(t, store, "1")
+	assert.Equal(t, StatusDelivered, entry.Status)
+	assert.Equal(t, "recorder", entry.DeliveredTo)
+	assert.True(t, entry.DeliveredDate.Valid)
+
+	result, err = d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{}, result)
+}
+
+func TestDispatchBatchSize(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Now()
+	c := &recorder{name: "recorder"}
+	d := testDispatcher(store, &now, c)
+	d.BatchSize = 2
+
+	for _, id := range []string{"1", "2", "3"} {
+		writeEvents(t, store, NewEvent("trip.created", id, "user", nil))
+		now = now.Add(time.Second)
+	}
+
+	result, err := d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Delivered: 2}, result)
+	result, err = d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Delivered: 1}, result)
+	if assert.Len(t, c.entries, 3) {
+		assert.Equal(t, "3", c.entries[2].AggregateID)
+	}
+}
+
+func TestDispatchRetriesFailedConsumers(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Now()
+	ok := &recorder{name: "ok"}
+	failing := &recorder{name: "failing", fail: 1}
+	d := testDispatcher(store, &now, ok, failing)
+
+	writeEvents(t, store, NewEvent("participant.role_changed", "1", "user", nil))
+	now = now.Add(time.Second)
+
+	result, err := d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Retried: 1}, result)
+	entry := loadEntry(t, store, "1")
+	assert.Equal(t, StatusPending, entry.Status)
+	assert.Equal(t, 1, entry.Attempts)
+	assert.Equal(t, "ok", entry.DeliveredTo)
+	assert.Equal(t, "failing: unavailable", entry.LastError)
+
+	//the entry waits the backoff before the next attempt
+	result, err = d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{}, result)
+
+	now = now.Add(defaultBackoff(1))
+	result, err = d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Delivered: 1}, result)
+	assert.Len(t, ok.entries, 1)
+	assert.Len(t, failing.entries, 1)
+	assert.Equal(t, "failing,ok", loadEntry(t, store, "1").DeliveredTo)
+}
+
+func TestDispatchDeadLetter(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Now()
+	failing := &recorder{name: "failing", fail: 3}
+	d := testDispatcher(store, &now, failing)
+	d.MaxAttempts = 2
+	d.Backoff = func(attempts int) time.Duration { return time.Minute }
+
+	writeEvents(t, store, NewEvent("itinerary.day_swapped", "1", "user", nil))
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Minute)
+		_, err := d.Dispatch()
+		assert.Nil(t, err)
+	}
+
+	entry := loadEntry(t, store, "1")
+	assert.Equal(t, StatusDead, entry.Status)
+	assert.Equal(t, 2, entry.Attempts)
+
+	dead, err := d.Dead(10)
+	assert.Nil(t, err)
+	assert.Len(t, dead, 1)
+
+	now = now.Add(time.Hour)
+	result, err := d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{}, result)
+
+	assert.Nil(t, d.Requeue(entry.ID))
+	assert.NotNil(t, d.Requeue(entry.ID))
+	failing.fail = 0
+	result, err = d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Delivered: 1}, result)
+}
+
+func TestDispatchRecoversPanic(t *testing.T) {
+	store := db.NewMemoryStore()
+	now := time.Now()
+	d := testDispatcher(store, &now, ConsumerFunc("panic", func(entry Entry) error {
+		panic("boom")
+	}))
+
+	writeEvents(t, store, NewEvent("trip.created", "1", "user", nil))
+	now = now.Add(time.Second)
+	result, err := d.Dispatch()
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Retried: 1}, result)
+	assert.Equal(t, "panic: panic: boom", loadEntry(t, store, "1").LastError)
+}
+
+func TestRegisterUniqueNames(t *testing.T) {
+	d := NewDispatcher(db.NewMemoryStore())
+	assert.Nil(t, d.Register(&recorder{name: "a"}))
+	assert.NotNil(t, d.Register(&recorder{name: "a"}))
+	assert.NotNil(t, d.Register(&recorder{name: "a,b"}))
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, defaultBackoff(1))
+	assert.Equal(t, time.Minute, defaultBackoff(2))
+	assert.Equal(t, time.Hour, defaultBackoff(20))
+}
@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/gocraft/dbr"
+	"github.com/google/uuid"
+)
+
+const (
+	//StatusPending defines the entries waiting to be delivered to the consumers
+	StatusPending = "pending"
+	//StatusDelivered defines the entries handled by every consumer
+	StatusDelivered = "delivered"
+	//StatusDead defines the entries that failed MaxAttempts times, they are kept for inspection and can be requeued
+	StatusDead = "dead"
+)
+
+//Event represents a domain event recorded by a mutation, like "trip.created" or "itinerary.day_swapped"
+type Event struct {
+	Type        string
+	AggregateID string
+	Actor       string
+	Data        interface{}
+}
+
+//Entry represents an event stored in the outbox table
+type Entry struct {
+	ID              string       `json:"id" db:"id"`
+	Type            string       `json:"type" db:"type"`
+	AggregateID     string       `json:"aggregate_id" db:"aggregate_id"`
+	Actor           string       `json:"actor" db:"actor"`
+	Payload         string       `json:"payload" db:"payload"`
+	Status          string       `json:"status" db:"status"`
+	Attempts        int          `json:"attempts" db:"attempts"`
+	DeliveredTo     string       `json:"delivered_to" db:"delivered_to"`
+	LastError       string       `json:"last_error" db:"last_error"`
+	NextAttemptDate time.Time    `json:"next_attempt_date" db:"next_attempt_date"`
+	CreatedDate     time.Time    `json:"created_date" db:"created_date"`
+	DeliveredDate   dbr.NullTime `json:"delivered_date" db:"delivered_date"`
+}
+
+//Aggregate returns the aggregate part of the event type, "trip" for "trip.created"
+func (e Entry) Aggregate() string {
+	return strings.SplitN(e.Type, ".", 2)[0]
+}
+
+//Decode unmarshals the event data into v
+func (e Entry) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(e.Payload), v)
+}
+
+//delivered returns the consumers that already handled the entry
+func (e Entry) delivered() map[string]bool {
+	consumers := map[string]bool{}
+	for _, c := range strings.Split(e.DeliveredTo, ",") {
+		if c != "" {
+			consumers[c] = true
+		}
+	}
+	return consumers
+}
+
+//NewEvent returns the event of the record id, a map of changed values is copied with the id attribute
+//and nil data becomes the id attribute alone
+func NewEvent(eventType, id, actor string, data interface{}) Event {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if values, ok := data.(map[string]interface{}); ok {
+		changes := map[string]interface{}{}
+		for k, v := range values {
+			changes[k] = v
+		}
+		changes["id"] = id
+		data = changes
+	}
+	return Event{Type: eventType, AggregateID: id, Actor: actor, Data: data}
+}
+
+//Write inserts the events in the outbox table, it must be called with the transaction writing the changes
+//so the events are only recorded when the changes are committed
+func Write(tx db.Writer, events ...Event) error {
+	now := time.Now()
+	for _, e := range events {
+		payload, err := json.Marshal(e.Data)
+		if err != nil {
+			return err
+		}
+		err = tx.Insert(db.TableOutbox, Entry{
+			ID:              uuid.New().String(),
+			Type:            e.Type,
+			AggregateID:     e.AggregateID,
+			Actor:           e.Actor,
+			Payload:         string(payload),
+			Status:          StatusPending,
+			NextAttemptDate: now,
+			CreatedDate:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
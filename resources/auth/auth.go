@@ -8,24 +8,18 @@ import (
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
-	"github.com/gbrlsnchs/jwt"
-)
-
-//TODO: set as lambda environment variables
-const (
-	clientID   = "2i1vka74ub2c6b3i5l6o3aaio"
-	userPoolID = "us-east-1_0JwI28hrb"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/users"
 )
 
 //Auth represents the attribute to authenticate an user
 type Auth struct{}
 
-type userCredentials struct {
+//Credentials represents the user attributes sent to sign up or login
+type Credentials struct {
 	Username     string `json:"username"`
 	Password     string `json:"password"`
 	GivenName    string `json:"given_name"`
@@ -61,166 +55,178 @@ type DBUser struct {
 	UpdatedDate  time.Time `json:"updated_date" db:"updated_date"`
 }
 
-//Register creates a new user in AWS Cognito and in the Database
-func (a *Auth) Register(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+//Register creates a new user in the identity provider and in the Database
+func (a *Auth) Register(request events.APIGatewayProxyRequest, provider IdentityProvider, repo users.Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	credentials := userCredentials{}
-	err := json.Unmarshal([]byte(request.Body), &credentials)
+	credentials := Credentials{}
+	err = json.Unmarshal([]byte(request.Body), &credentials)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
 
-	sess, err := getAWSSession("us-east-1")
+	userID, err := provider.SignUp(credentials)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return providerError(err)
 	}
 
-	svc := cognitoidentityprovider.New(sess)
-	signUpParams := &cognitoidentityprovider.SignUpInput{
-		Username: aws.String(credentials.Username),
-		Password: aws.String(credentials.Password),
-		UserAttributes: []*cognitoidentityprovider.AttributeType{
-			&cognitoidentityprovider.AttributeType{
-				Name:  aws.String("given_name"),
-				Value: aws.String(credentials.GivenName),
-			},
-			&cognitoidentityprovider.AttributeType{
-				Name:  aws.String("family_name"),
-				Value: aws.String(credentials.FamilyName),
-			},
-			&cognitoidentityprovider.AttributeType{
-				Name:  aws.String("email"),
-				Value: aws.String(credentials.Email),
-			},
-			&cognitoidentityprovider.AttributeType{
-				Name:  aws.String("custom:language_code"),
-				Value: aws.String(credentials.LanguageCode),
-			},
-		},
-		ClientId: aws.String(clientID),
-	}
-
-	result, err := svc.SignUp(signUpParams)
+	err = provider.AdminConfirm(credentials.Username)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return providerError(err)
 	}
 
-	confirmSignUpParams := &cognitoidentityprovider.AdminConfirmSignUpInput{
-		UserPoolId: aws.String(userPoolID),
-		Username:   aws.String(credentials.Username),
+	if credentials.Group != "User" {
+		err = provider.AddToGroup(credentials.Username, credentials.Group)
+		if err != nil {
+			return providerError(err)
+		}
 	}
 
-	_, err = svc.AdminConfirmSignUp(confirmSignUpParams)
+	user, err := createUser(repo, userID, credentials, true, tokenUser.UserID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	if credentials.Group != "User" {
-		addUserToGroupParams := &cognitoidentityprovider.AdminAddUserToGroupInput{
-			GroupName:  aws.String(credentials.Group),
-			UserPoolId: aws.String(userPoolID),
-			Username:   aws.String(credentials.Username),
-		}
+	return common.APIResponse(user, http.StatusCreated)
+}
 
-		_, err := svc.AdminAddUserToGroup(addUserToGroupParams)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
+//SignUp creates an unconfirmed user in the identity provider and in the Database, the user confirms the account with the code sent by email.
+//Anyone can sign up in the User group, only admin users can sign up users in other groups.
+func (a *Auth) SignUp(request events.APIGatewayProxyRequest, provider IdentityProvider, repo users.Repository) (events.APIGatewayProxyResponse, error) {
+	credentials := Credentials{}
+	err := json.Unmarshal([]byte(request.Body), &credentials)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if credentials.Username == "" || credentials.Password == "" || credentials.Email == "" {
+		return common.APIError(http.StatusBadRequest, ErrInvalidSignUp)
 	}
 
-	user := DBUser{
-		ID:           *result.UserSub,
-		Active:       true,
-		FirstName:    credentials.GivenName,
-		LastName:     credentials.FamilyName,
-		Group:        credentials.Group,
-		Username:     credentials.Username,
-		Email:        credentials.Email,
-		LanguageCode: credentials.LanguageCode,
+	if credentials.Group == "" {
+		credentials.Group = "User"
+	}
+	if credentials.Group != "User" && !common.IsTokenUserAdmin(request) {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can sign up users in groups other than User"))
 	}
-	user.CreatedBy = tokenUser.UserID
-	user.CreatedDate = time.Now()
-	user.UpdatedBy = tokenUser.UserID
-	user.UpdatedDate = time.Now()
 
-	conn, err := db.Connect()
-	if err != nil {
+	_, err = repo.FindByEmail(credentials.Email)
+	if err == nil {
+		return providerError(ErrEmailExists)
+	}
+	if err != db.ErrNotFound {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	userID, err := provider.SignUp(credentials)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return providerError(err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Insert(tx, db.TableUser, user)
+	if credentials.Group != "User" {
+		err = provider.AddToGroup(credentials.Username, credentials.Group)
+		if err != nil {
+			return providerError(err)
+		}
+	}
+
+	user, err := createUser(repo, userID, credentials, false, userID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
 	return common.APIResponse(user, http.StatusCreated)
 }
 
-//Login validate user credentials with AWS Cognito and returns an APIGatewayProxyResponse
-func (a *Auth) Login(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	user, err := LoginUser(request.Body)
+//Confirm validates the code sent to the user email and activates the user
+func (a *Auth) Confirm(request events.APIGatewayProxyRequest, provider IdentityProvider, repo users.Repository) (events.APIGatewayProxyResponse, error) {
+	confirmation := struct {
+		Username string `json:"username"`
+		Code     string `json:"code"`
+	}{}
+	err := json.Unmarshal([]byte(request.Body), &confirmation)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
+	if confirmation.Username == "" || confirmation.Code == "" {
+		return common.APIError(http.StatusBadRequest, errors.New("missing username or code"))
+	}
+
+	err = provider.Confirm(confirmation.Username, confirmation.Code)
+	if err != nil {
+		return providerError(err)
+	}
+
+	user, err := repo.FindByUsername(confirmation.Username)
+	if err == db.ErrNotFound {
+		return providerError(ErrUserNotFound)
+	}
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+	err = repo.SetActive(user.ID, true, outbox.NewEvent(users.EventUserConfirmed, user.ID, user.ID, nil))
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	user.Active = true
 	return common.APIResponse(user, http.StatusOK)
 }
 
-//LoginUser validate user credentials with AWS Cognito
-func LoginUser(credentialsJSON string) (*UserResponse, error) {
-	credentials := userCredentials{}
-	err := json.Unmarshal([]byte(credentialsJSON), &credentials)
+//ResendConfirmation sends a new confirmation code to the user email
+func (a *Auth) ResendConfirmation(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	resend := struct {
+		Username string `json:"username"`
+	}{}
+	err := json.Unmarshal([]byte(request.Body), &resend)
 	if err != nil {
-		return nil, err
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	if credentials.Username == "" || credentials.Password == "" {
-		return nil, errors.New("empty username or password")
+	if resend.Username == "" {
+		return common.APIError(http.StatusBadRequest, errors.New("missing username"))
 	}
 
-	sess, err := getAWSSession("us-east-1")
+	err = provider.ResendConfirmation(resend.Username)
 	if err != nil {
-		return nil, err
+		return providerError(err)
 	}
+	return common.APIResponse(nil, http.StatusOK)
+}
 
-	svc := cognitoidentityprovider.New(sess)
-	authInput := &cognitoidentityprovider.AdminInitiateAuthInput{
-		ClientId:   aws.String(clientID),
-		UserPoolId: aws.String(userPoolID),
-		AuthFlow:   aws.String("ADMIN_NO_SRP_AUTH"),
-		AuthParameters: map[string]*string{
-			"USERNAME": aws.String(credentials.Username),
-			"PASSWORD": aws.String(credentials.Password),
-		},
+//Login validate user credentials with the identity provider and returns an APIGatewayProxyResponse
+func (a *Auth) Login(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	user, err := LoginUser(provider, request.Body)
+	if err == ErrRateLimited {
+		return providerError(err)
 	}
-	authOutput, err := svc.AdminInitiateAuth(authInput)
 	if err != nil {
-		return nil, err
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	return parseUserResponse(authOutput), nil
+	return common.APIResponse(user, http.StatusOK)
 }
 
-//Refresh take in a valid refresh token and return new tokens
-func (a *Auth) Refresh(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	sess, err := getAWSSession("us-east-1")
+//LoginUser validate user credentials with the identity provider
+func LoginUser(provider IdentityProvider, credentialsJSON string) (*UserResponse, error) {
+	credentials := Credentials{}
+	err := json.Unmarshal([]byte(credentialsJSON), &credentials)
 	if err != nil {
-		return common.APIError(http.StatusBadRequest, err)
+		return nil, err
+	}
+
+	if credentials.Username == "" || credentials.Password == "" {
+		return nil, errors.New("empty username or password")
 	}
 
+	return provider.Login(credentials.Username, credentials.Password)
+}
+
+//Refresh take in a valid refresh token and return new tokens
+func (a *Auth) Refresh(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
 	refreshToken := ""
 	if val, ok := request.Headers["Authorization"]; ok {
 		refreshToken = val
@@ -230,56 +236,170 @@ func (a *Auth) Refresh(request events.APIGatewayProxyRequest) (events.APIGateway
 		return common.APIError(http.StatusRequestHeaderFieldsTooLarge, errors.New("missing header Authorization"))
 	}
 
-	svc := cognitoidentityprovider.New(sess)
-	authInput := &cognitoidentityprovider.AdminInitiateAuthInput{
-		ClientId:   aws.String(clientID),
-		UserPoolId: aws.String(userPoolID),
-		AuthFlow:   aws.String("REFRESH_TOKEN"),
-		AuthParameters: map[string]*string{
-			"REFRESH_TOKEN": aws.String(refreshToken),
-		},
-	}
-	authOutput, err := svc.AdminInitiateAuth(authInput)
+	user, err := provider.Refresh(refreshToken)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return providerError(err)
 	}
-	user := parseUserResponse(authOutput)
 	return common.APIResponse(user, http.StatusOK)
 }
 
-type payload struct {
-	*jwt.JWT
-	Sub        string   `json:"sub"`
-	Email      string   `json:"email"`
-	GivenName  string   `json:"given_name"`
-	FamilyName string   `json:"family_name"`
-	Groups     []string `json:"cognito:groups"`
+//ForgotPassword sends the user a code to reset the password, unknown usernames get the same response to not disclose the registered users
+func (a *Auth) ForgotPassword(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	forgot := struct {
+		Username string `json:"username"`
+	}{}
+	err := json.Unmarshal([]byte(request.Body), &forgot)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if forgot.Username == "" {
+		return common.APIError(http.StatusBadRequest, errors.New("missing username"))
+	}
+
+	err = provider.ForgotPassword(forgot.Username)
+	if err != nil && err != ErrUserNotFound {
+		return providerError(err)
+	}
+	return common.APIResponse(nil, http.StatusOK)
 }
 
-func parseUserResponse(authOutput *cognitoidentityprovider.AdminInitiateAuthOutput) *UserResponse {
-	u := &UserResponse{}
-	u.Tokens = authOutput.AuthenticationResult
+//ResetPassword changes the password with the code sent by ForgotPassword
+func (a *Auth) ResetPassword(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	reset := struct {
+		Username string `json:"username"`
+		Code     string `json:"code"`
+		Password string `json:"password"`
+	}{}
+	err := json.Unmarshal([]byte(request.Body), &reset)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if reset.Username == "" || reset.Code == "" || reset.Password == "" {
+		return common.APIError(http.StatusBadRequest, errors.New("missing username, code or password"))
+	}
+
+	err = provider.ResetPassword(reset.Username, reset.Code, reset.Password)
+	if err == ErrUserNotFound {
+		return providerError(ErrInvalidCode)
+	}
+	if err != nil {
+		return providerError(err)
+	}
+	return common.APIResponse(nil, http.StatusOK)
+}
 
-	jwtPayload, _, _ := jwt.Parse(*u.Tokens.IdToken)
-	payload := payload{}
-	jwt.Unmarshal(jwtPayload, &payload)
+//ChangePassword changes the password of the authenticated user
+func (a *Auth) ChangePassword(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	_, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	change := struct {
+		PreviousPassword string `json:"previous_password"`
+		ProposedPassword string `json:"proposed_password"`
+	}{}
+	err = json.Unmarshal([]byte(request.Body), &change)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if change.PreviousPassword == "" || change.ProposedPassword == "" {
+		return common.APIError(http.StatusBadRequest, errors.New("missing previous_password or proposed_password"))
+	}
 
-	u.Email = payload.Email
-	u.FirstName = payload.GivenName
-	u.LastName = payload.FamilyName
-	u.UserID = payload.Sub
-	if len(payload.Groups) > 0 {
-		u.Group = strings.Join(payload.Groups, ",")
+	err = provider.ChangePassword(accessToken(request), change.PreviousPassword, change.ProposedPassword)
+	if err != nil {
+		return providerError(err)
 	}
-	return u
+	return common.APIResponse(nil, http.StatusOK)
 }
 
-func getAWSSession(region string) (*session.Session, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	)
+//Logout revokes the refresh token sent in the body, all_devices revokes every refresh token of the authenticated user
+func (a *Auth) Logout(request events.APIGatewayProxyRequest, provider IdentityProvider) (events.APIGatewayProxyResponse, error) {
+	_, err := common.GetTokenUser(request)
 	if err != nil {
-		return nil, err
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	logout := struct {
+		RefreshToken string `json:"refresh_token"`
+		AllDevices   bool   `json:"all_devices"`
+	}{}
+	err = json.Unmarshal([]byte(request.Body), &logout)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if logout.RefreshToken == "" && !logout.AllDevices {
+		return common.APIError(http.StatusBadRequest, errors.New("missing refresh_token"))
+	}
+
+	if logout.RefreshToken != "" {
+		err = provider.Revoke(logout.RefreshToken)
+		if err != nil {
+			return providerError(err)
+		}
+	}
+	if logout.AllDevices {
+		err = provider.Logout(accessToken(request))
+		if err != nil {
+			return providerError(err)
+		}
+	}
+	return common.APIResponse(nil, http.StatusOK)
+}
+
+//accessToken returns the Authorization header without the Bearer prefix
+func accessToken(request events.APIGatewayProxyRequest) string {
+	return strings.TrimPrefix(request.Headers["Authorization"], "Bearer ")
+}
+
+//createUser saves the signed up user in the Database
+func createUser(repo users.Repository, userID string, credentials Credentials, active bool, createdBy string) (DBUser, error) {
+	user := DBUser{
+		ID:           userID,
+		Active:       active,
+		FirstName:    credentials.GivenName,
+		LastName:     credentials.FamilyName,
+		Group:        credentials.Group,
+		Username:     credentials.Username,
+		Email:        credentials.Email,
+		LanguageCode: credentials.LanguageCode,
+	}
+	user.CreatedBy = createdBy
+	user.CreatedDate = time.Now()
+	user.UpdatedBy = createdBy
+	user.UpdatedDate = time.Now()
+
+	err := repo.Create(users.User{
+		ID:           user.ID,
+		Active:       user.Active,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Group:        user.Group,
+		Username:     user.Username,
+		Email:        user.Email,
+		LanguageCode: user.LanguageCode,
+		CreatedBy:    user.CreatedBy,
+		CreatedDate:  user.CreatedDate,
+		UpdatedBy:    user.UpdatedBy,
+		UpdatedDate:  user.UpdatedDate,
+	}, outbox.NewEvent(users.EventUserCreated, user.ID, createdBy, user))
+	return user, err
+}
+
+//providerError returns the api error response with the status code of the identity provider error
+func providerError(err error) (events.APIGatewayProxyResponse, error) {
+	switch err {
+	case ErrUserExists, ErrEmailExists:
+		return common.APIError(http.StatusConflict, err)
+	case ErrRateLimited:
+		return common.APIError(http.StatusTooManyRequests, err)
+	case ErrInvalidToken:
+		return common.APIError(http.StatusUnauthorized, err)
+	case ErrUserNotFound:
+		return common.APIError(http.StatusNotFound, err)
+	case ErrInvalidCredentials, ErrInvalidSignUp, ErrInvalidPassword, ErrInvalidCode, ErrUserNotConfirmed:
+		return common.APIError(http.StatusBadRequest, err)
 	}
-	return sess, nil
+	return common.APIError(http.StatusInternalServerError, err)
 }
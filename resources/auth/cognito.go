@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/feedmytrip/api/common"
+	"github.com/gbrlsnchs/jwt"
+)
+
+//CognitoProvider implements the IdentityProvider with an AWS Cognito user pool
+type CognitoProvider struct {
+	Region     string
+	UserPoolID string
+	ClientID   string
+}
+
+//NewCognitoProviderFromEnv returns a CognitoProvider configured by the FMT_COGNITO_REGION, FMT_COGNITO_USER_POOL_ID and FMT_COGNITO_CLIENT_ID environment variables
+func NewCognitoProviderFromEnv() *CognitoProvider {
+	region, userPoolID, clientID := common.CognitoSettings()
+	return &CognitoProvider{
+		Region:     region,
+		UserPoolID: userPoolID,
+		ClientID:   clientID,
+	}
+}
+
+func (c *CognitoProvider) client() (*cognitoidentityprovider.CognitoIdentityProvider, error) {
+	sess, err := getAWSSession(c.Region)
+	if err != nil {
+		return nil, err
+	}
+	return cognitoidentityprovider.New(sess), nil
+}
+
+//SignUp creates the user in the Cognito user pool
+func (c *CognitoProvider) SignUp(credentials Credentials) (string, error) {
+	svc, err := c.client()
+	if err != nil {
+		return "", err
+	}
+
+	signUpParams := &cognitoidentityprovider.SignUpInput{
+		Username: aws.String(credentials.Username),
+		Password: aws.String(credentials.Password),
+		UserAttributes: []*cognitoidentityprovider.AttributeType{
+			&cognitoidentityprovider.AttributeType{
+				Name:  aws.String("given_name"),
+				Value: aws.String(credentials.GivenName),
+			},
+			&cognitoidentityprovider.AttributeType{
+				Name:  aws.String("family_name"),
+				Value: aws.String(credentials.FamilyName),
+			},
+			&cognitoidentityprovider.AttributeType{
+				Name:  aws.String("email"),
+				Value: aws.String(credentials.Email),
+			},
+			&cognitoidentityprovider.AttributeType{
+				Name:  aws.String("custom:language_code"),
+				Value: aws.String(credentials.LanguageCode),
+			},
+		},
+		ClientId: aws.String(c.ClientID),
+	}
+
+	result, err := svc.SignUp(signUpParams)
+	if err != nil {
+		return "", cognitoError(err)
+	}
+	return *result.UserSub, nil
+}
+
+//Confirm activates the user with the code sent by Cognito
+func (c *CognitoProvider) Confirm(username, code string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.ConfirmSignUp(&cognitoidentityprovider.ConfirmSignUpInput{
+		ClientId:         aws.String(c.ClientID),
+		Username:         aws.String(username),
+		ConfirmationCode: aws.String(code),
+	})
+	return cognitoError(err)
+}
+
+//ResendConfirmation asks Cognito to send a new confirmation code
+func (c *CognitoProvider) ResendConfirmation(username string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.ResendConfirmationCode(&cognitoidentityprovider.ResendConfirmationCodeInput{
+		ClientId: aws.String(c.ClientID),
+		Username: aws.String(username),
+	})
+	return cognitoError(err)
+}
+
+//AdminConfirm activates the user without the confirmation code
+func (c *CognitoProvider) AdminConfirm(username string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.AdminConfirmSignUp(&cognitoidentityprovider.AdminConfirmSignUpInput{
+		UserPoolId: aws.String(c.UserPoolID),
+		Username:   aws.String(username),
+	})
+	return cognitoError(err)
+}
+
+//AddToGroup includes the user in the Cognito group
+func (c *CognitoProvider) AddToGroup(username, group string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.AdminAddUserToGroup(&cognitoidentityprovider.AdminAddUserToGroupInput{
+		GroupName:  aws.String(group),
+		UserPoolId: aws.String(c.UserPoolID),
+		Username:   aws.String(username),
+	})
+	return cognitoError(err)
+}
+
+//Login validate user credentials with AWS Cognito
+func (c *CognitoProvider) Login(username, password string) (*UserResponse, error) {
+	svc, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	authInput := &cognitoidentityprovider.AdminInitiateAuthInput{
+		ClientId:   aws.String(c.ClientID),
+		UserPoolId: aws.String(c.UserPoolID),
+		AuthFlow:   aws.String("ADMIN_NO_SRP_AUTH"),
+		AuthParameters: map[string]*string{
+			"USERNAME": aws.String(username),
+			"PASSWORD": aws.String(password),
+		},
+	}
+	authOutput, err := svc.AdminInitiateAuth(authInput)
+	if err != nil {
+		return nil, cognitoError(err)
+	}
+	return parseUserResponse(authOutput), nil
+}
+
+//Refresh returns new tokens from AWS Cognito
+func (c *CognitoProvider) Refresh(refreshToken string) (*UserResponse, error) {
+	svc, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	authInput := &cognitoidentityprovider.AdminInitiateAuthInput{
+		ClientId:   aws.String(c.ClientID),
+		UserPoolId: aws.String(c.UserPoolID),
+		AuthFlow:   aws.String("REFRESH_TOKEN"),
+		AuthParameters: map[string]*string{
+			"REFRESH_TOKEN": aws.String(refreshToken),
+		},
+	}
+	authOutput, err := svc.AdminInitiateAuth(authInput)
+	if err != nil {
+		return nil, tokenError(cognitoError(err))
+	}
+	return parseUserResponse(authOutput), nil
+}
+
+//Logout signs out the user from every device invalidating the refresh tokens
+func (c *CognitoProvider) Logout(accessToken string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.GlobalSignOut(&cognitoidentityprovider.GlobalSignOutInput{
+		AccessToken: aws.String(accessToken),
+	})
+	return tokenError(cognitoError(err))
+}
+
+//Revoke asks Cognito to revoke the refresh token
+func (c *CognitoProvider) Revoke(refreshToken string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.RevokeToken(&cognitoidentityprovider.RevokeTokenInput{
+		ClientId: aws.String(c.ClientID),
+		Token:    aws.String(refreshToken),
+	})
+	return tokenError(cognitoError(err))
+}
+
+//ForgotPassword asks Cognito to send the reset code to the user
+func (c *CognitoProvider) ForgotPassword(username string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.ForgotPassword(&cognitoidentityprovider.ForgotPasswordInput{
+		ClientId: aws.String(c.ClientID),
+		Username: aws.String(username),
+	})
+	return cognitoError(err)
+}
+
+//ResetPassword changes the password with the code sent by Cognito
+func (c *CognitoProvider) ResetPassword(username, code, password string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.ConfirmForgotPassword(&cognitoidentityprovider.ConfirmForgotPasswordInput{
+		ClientId:         aws.String(c.ClientID),
+		Username:         aws.String(username),
+		ConfirmationCode: aws.String(code),
+		Password:         aws.String(password),
+	})
+	return cognitoError(err)
+}
+
+//ChangePassword changes the password of the access token user in Cognito
+func (c *CognitoProvider) ChangePassword(accessToken, previousPassword, proposedPassword string) error {
+	svc, err := c.client()
+	if err != nil {
+		return err
+	}
+	_, err = svc.ChangePassword(&cognitoidentityprovider.ChangePasswordInput{
+		AccessToken:      aws.String(accessToken),
+		PreviousPassword: aws.String(previousPassword),
+		ProposedPassword: aws.String(proposedPassword),
+	})
+	return cognitoError(err)
+}
+
+//cognitoError converts the Cognito exceptions to the identity provider errors
+func cognitoError(err error) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+	switch aerr.Code() {
+	case cognitoidentityprovider.ErrCodeUsernameExistsException:
+		return ErrUserExists
+	case cognitoidentityprovider.ErrCodeAliasExistsException:
+		return ErrEmailExists
+	case cognitoidentityprovider.ErrCodeUserNotFoundException:
+		return ErrUserNotFound
+	case cognitoidentityprovider.ErrCodeUserNotConfirmedException:
+		return ErrUserNotConfirmed
+	case cognitoidentityprovider.ErrCodeNotAuthorizedException:
+		return ErrInvalidCredentials
+	case cognitoidentityprovider.ErrCodeInvalidPasswordException:
+		return ErrInvalidPassword
+	case cognitoidentityprovider.ErrCodeCodeMismatchException, cognitoidentityprovider.ErrCodeExpiredCodeException:
+		return ErrInvalidCode
+	case cognitoidentityprovider.ErrCodeLimitExceededException, cognitoidentityprovider.ErrCodeTooManyRequestsException, cognitoidentityprovider.ErrCodeTooManyFailedAttemptsException:
+		return ErrRateLimited
+	}
+	return err
+}
+
+//tokenError reports the rejected refresh and access tokens as ErrInvalidToken
+func tokenError(err error) error {
+	if err == ErrInvalidCredentials {
+		return ErrInvalidToken
+	}
+	return err
+}
+
+type payload struct {
+	*jwt.JWT
+	Sub        string   `json:"sub"`
+	Email      string   `json:"email"`
+	GivenName  string   `json:"given_name"`
+	FamilyName string   `json:"family_name"`
+	Groups     []string `json:"cognito:groups"`
+}
+
+func parseUserResponse(authOutput *cognitoidentityprovider.AdminInitiateAuthOutput) *UserResponse {
+	u := &UserResponse{}
+	u.Tokens = authOutput.AuthenticationResult
+
+	jwtPayload, _, _ := jwt.Parse(*u.Tokens.IdToken)
+	payload := payload{}
+	jwt.Unmarshal(jwtPayload, &payload)
+
+	u.Email = payload.Email
+	u.FirstName = payload.GivenName
+	u.LastName = payload.FamilyName
+	u.UserID = payload.Sub
+	if len(payload.Groups) > 0 {
+		u.Group = strings.Join(payload.Groups, ",")
+	}
+	return u
+}
+
+func getAWSSession(region string) (*session.Session, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
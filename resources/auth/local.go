@@ -0,0 +1,555 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultAccessTokenTTL  = time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	resetCodeTTL           = time.Hour
+	codeResendInterval     = time.Minute
+	//maxCodeAttempts wrong codes invalidate the confirmation or reset code, a new one must be sent
+	maxCodeAttempts = 5
+)
+
+const (
+	//CodeConfirmation identifies the codes sent to confirm the account
+	CodeConfirmation = "confirmation"
+	//CodeResetPassword identifies the codes sent to reset the password
+	CodeResetPassword = "reset_password"
+)
+
+//LocalConfig defines the signing key and the claims of the tokens issued by the LocalProvider
+type LocalConfig struct {
+	Key             *rsa.PrivateKey
+	KeyID           string
+	Issuer          string
+	Audience        string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	//SendCode delivers the confirmation and reset codes to the user, the codes are logged when it's nil
+	SendCode func(kind, email, code string) error
+}
+
+//LocalProvider implements the IdentityProvider storing bcrypt password hashes in the database and issuing its own RS256 tokens
+type LocalProvider struct {
+	store    db.Store
+	config   LocalConfig
+	verifier *common.TokenVerifier
+	//codes generates the confirmation and password reset codes
+	codes func() string
+	now   func() time.Time
+}
+
+//credential represents the account of an user in the LocalProvider
+type credential struct {
+	ID               string    `json:"id" db:"id"`
+	Username         string    `json:"username" db:"username"`
+	Email            string    `json:"email" db:"email"`
+	PasswordHash     string    `json:"password_hash" db:"password_hash"`
+	GivenName        string    `json:"given_name" db:"given_name"`
+	FamilyName       string    `json:"family_name" db:"family_name"`
+	LanguageCode     string    `json:"language_code" db:"language_code"`
+	Groups           string    `json:"user_groups" db:"user_groups"`
+	Confirmed        bool      `json:"confirmed" db:"confirmed"`
+	ConfirmationCode string    `json:"confirmation_code" db:"confirmation_code"`
+	ResetCode        string    `json:"reset_code" db:"reset_code"`
+	ResetExpiresAt   int64     `json:"reset_expires_at" db:"reset_expires_at"`
+	CodeSentAt       int64     `json:"code_sent_at" db:"code_sent_at"`
+	CodeAttempts     int       `json:"code_attempts" db:"code_attempts"`
+	CreatedDate      time.Time `json:"created_date" db:"created_date"`
+	UpdatedDate      time.Time `json:"updated_date" db:"updated_date"`
+}
+
+//refreshToken represents a refresh token issued by the LocalProvider, only its hash is stored
+type refreshToken struct {
+	ID          string    `json:"id" db:"id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	ExpiresAt   int64     `json:"expires_at" db:"expires_at"`
+	CreatedDate time.Time `json:"created_date" db:"created_date"`
+}
+
+//NewLocalProvider returns a LocalProvider keeping the accounts in the store
+func NewLocalProvider(store db.Store, config LocalConfig) *LocalProvider {
+	if config.AccessTokenTTL == 0 {
+		config.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if config.RefreshTokenTTL == 0 {
+		config.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	if config.SendCode == nil {
+		config.SendCode = logCode
+	}
+	return &LocalProvider{
+		store:  store,
+		config: config,
+		verifier: &common.TokenVerifier{
+			Keys:     common.NewStaticKeySet(map[string]*rsa.PublicKey{config.KeyID: &config.Key.PublicKey}),
+			Issuer:   config.Issuer,
+			Audience: config.Audience,
+			TokenUse: []string{"access"},
+		},
+		codes: randomCode,
+		now:   time.Now,
+	}
+}
+
+//SignUp stores the unconfirmed account with the bcrypt hash of the password
+func (l *LocalProvider) SignUp(credentials Credentials) (string, error) {
+	if credentials.Username == "" || credentials.Password == "" || credentials.Email == "" {
+		return "", ErrInvalidSignUp
+	}
+	_, err := l.credential(credentials.Username)
+	if err == nil {
+		return "", ErrUserExists
+	}
+	if err != ErrUserNotFound {
+		return "", err
+	}
+	sameEmail := []credential{}
+	err = l.store.LoadAll(db.TableUserCredential, map[string]string{"email": credentials.Email, "count": "none"}, &sameEmail)
+	if err != nil {
+		return "", err
+	}
+	if len(sameEmail) > 0 {
+		return "", ErrEmailExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	code := l.codes()
+	c := credential{
+		ID:               uuid.New().String(),
+		Username:         credentials.Username,
+		Email:            credentials.Email,
+		PasswordHash:     string(hash),
+		GivenName:        credentials.GivenName,
+		FamilyName:       credentials.FamilyName,
+		LanguageCode:     credentials.LanguageCode,
+		ConfirmationCode: hashCode(code),
+		CodeSentAt:       l.now().Unix(),
+		CreatedDate:      l.now(),
+		UpdatedDate:      l.now(),
+	}
+	err = l.store.Transaction(func(tx db.Writer) error {
+		return tx.Insert(db.TableUserCredential, c)
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.ID, l.config.SendCode(CodeConfirmation, c.Email, code)
+}
+
+//Confirm activates the account with the confirmation code
+func (l *LocalProvider) Confirm(username, code string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	if c.Confirmed {
+		return nil
+	}
+	if c.CodeAttempts >= maxCodeAttempts {
+		return ErrRateLimited
+	}
+	if !sameCode(c.ConfirmationCode, code) {
+		return l.failedCode(c, "confirmation_code")
+	}
+	return l.update(c.ID, map[string]interface{}{"confirmed": true, "confirmation_code": "", "code_sent_at": 0, "code_attempts": 0})
+}
+
+//ResendConfirmation replaces the confirmation code, a new code is sent at most once a minute
+func (l *LocalProvider) ResendConfirmation(username string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	if c.Confirmed {
+		return ErrInvalidCode
+	}
+	if l.now().Unix() < c.CodeSentAt+int64(codeResendInterval.Seconds()) {
+		return ErrRateLimited
+	}
+	code := l.codes()
+	err = l.update(c.ID, map[string]interface{}{
+		"confirmation_code": hashCode(code),
+		"code_sent_at":      l.now().Unix(),
+		"code_attempts":     0,
+	})
+	if err != nil {
+		return err
+	}
+	return l.config.SendCode(CodeConfirmation, c.Email, code)
+}
+
+//AdminConfirm activates the account without the confirmation code
+func (l *LocalProvider) AdminConfirm(username string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	return l.update(c.ID, map[string]interface{}{"confirmed": true, "confirmation_code": "", "code_sent_at": 0, "code_attempts": 0})
+}
+
+//AddToGroup includes the group in the account, the groups are issued in the cognito:groups claim
+func (l *LocalProvider) AddToGroup(username, group string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	groups := splitGroups(c.Groups)
+	for _, g := range groups {
+		if g == group {
+			return nil
+		}
+	}
+	groups = append(groups, group)
+	return l.update(c.ID, map[string]interface{}{"user_groups": strings.Join(groups, ",")})
+}
+
+//Login checks the password hash and issues new tokens
+func (l *LocalProvider) Login(username, password string) (*UserResponse, error) {
+	c, err := l.credential(username)
+	if err == ErrUserNotFound {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if !c.Confirmed {
+		return nil, ErrUserNotConfirmed
+	}
+
+	token := randomToken()
+	err = l.store.Transaction(func(tx db.Writer) error {
+		return tx.Insert(db.TableUserRefreshToken, refreshToken{
+			ID:          hashCode(token),
+			UserID:      c.ID,
+			ExpiresAt:   l.now().Add(l.config.RefreshTokenTTL).Unix(),
+			CreatedDate: l.now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l.userResponse(c, token)
+}
+
+//Refresh issues new access and id tokens for a stored refresh token
+func (l *LocalProvider) Refresh(token string) (*UserResponse, error) {
+	stored := refreshToken{}
+	err := l.store.LoadOne(db.TableUserRefreshToken, hashCode(token), &stored)
+	if err == db.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if l.now().Unix() >= stored.ExpiresAt {
+		return nil, ErrInvalidToken
+	}
+
+	c := credential{}
+	err = l.store.LoadOne(db.TableUserCredential, stored.UserID, &c)
+	if err == db.ErrNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.userResponse(c, "")
+}
+
+//Logout removes the refresh tokens of the access token user
+func (l *LocalProvider) Logout(accessToken string) error {
+	user, err := l.verifier.Verify(accessToken)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	return l.revokeAll(user.UserID)
+}
+
+//Revoke removes the refresh token, unknown tokens are ignored
+func (l *LocalProvider) Revoke(token string) error {
+	return l.store.Transaction(func(tx db.Writer) error {
+		return tx.Delete(db.TableUserRefreshToken, hashCode(token))
+	})
+}
+
+func (l *LocalProvider) revokeAll(userID string) error {
+	tokens := []refreshToken{}
+	err := l.store.LoadAll(db.TableUserRefreshToken, map[string]string{"user_id": userID, "count": "none"}, &tokens)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	ids := []string{}
+	for _, t := range tokens {
+		ids = append(ids, t.ID)
+	}
+	return l.store.Transaction(func(tx db.Writer) error {
+		return tx.Delete(db.TableUserRefreshToken, ids...)
+	})
+}
+
+//ForgotPassword stores a new reset code valid for one hour, a new code is sent at most once a minute
+func (l *LocalProvider) ForgotPassword(username string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	if l.now().Unix() < c.CodeSentAt+int64(codeResendInterval.Seconds()) {
+		return ErrRateLimited
+	}
+	code := l.codes()
+	err = l.update(c.ID, map[string]interface{}{
+		"reset_code":       hashCode(code),
+		"reset_expires_at": l.now().Add(resetCodeTTL).Unix(),
+		"code_sent_at":     l.now().Unix(),
+		"code_attempts":    0,
+	})
+	if err != nil {
+		return err
+	}
+	return l.config.SendCode(CodeResetPassword, c.Email, code)
+}
+
+//ResetPassword changes the password hash when the reset code is valid and revokes the refresh tokens
+func (l *LocalProvider) ResetPassword(username, code, password string) error {
+	c, err := l.credential(username)
+	if err != nil {
+		return err
+	}
+	if c.CodeAttempts >= maxCodeAttempts {
+		return ErrRateLimited
+	}
+	if c.ResetCode == "" || l.now().Unix() >= c.ResetExpiresAt {
+		return ErrInvalidCode
+	}
+	if !sameCode(c.ResetCode, code) {
+		return l.failedCode(c, "reset_code")
+	}
+	if password == "" {
+		return ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = l.update(c.ID, map[string]interface{}{
+		"password_hash":    string(hash),
+		"reset_code":       "",
+		"reset_expires_at": 0,
+		"code_attempts":    0,
+	})
+	if err != nil {
+		return err
+	}
+	return l.revokeAll(c.ID)
+}
+
+//ChangePassword checks the previous password of the access token user, stores the hash of the proposed one and revokes the refresh tokens
+func (l *LocalProvider) ChangePassword(accessToken, previousPassword, proposedPassword string) error {
+	user, err := l.verifier.Verify(accessToken)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if proposedPassword == "" {
+		return ErrInvalidPassword
+	}
+
+	c := credential{}
+	err = l.store.LoadOne(db.TableUserCredential, user.UserID, &c)
+	if err == db.ErrNotFound {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(previousPassword)) != nil {
+		return ErrInvalidCredentials
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(proposedPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = l.update(c.ID, map[string]interface{}{"password_hash": string(hash)})
+	if err != nil {
+		return err
+	}
+	return l.revokeAll(c.ID)
+}
+
+func (l *LocalProvider) credential(username string) (credential, error) {
+	credentials := []credential{}
+	err := l.store.LoadAll(db.TableUserCredential, map[string]string{"username": username, "count": "none"}, &credentials)
+	if err != nil {
+		return credential{}, err
+	}
+	if len(credentials) == 0 {
+		return credential{}, ErrUserNotFound
+	}
+	return credentials[0], nil
+}
+
+//failedCode counts a wrong code of the account, the code column is cleared after maxCodeAttempts failures
+func (l *LocalProvider) failedCode(c credential, column string) error {
+	values := map[string]interface{}{"code_attempts": c.CodeAttempts + 1}
+	if c.CodeAttempts+1 >= maxCodeAttempts {
+		values[column] = ""
+	}
+	err := l.update(c.ID, values)
+	if err != nil {
+		return err
+	}
+	if c.CodeAttempts+1 >= maxCodeAttempts {
+		return ErrRateLimited
+	}
+	return ErrInvalidCode
+}
+
+func (l *LocalProvider) update(id string, values map[string]interface{}) error {
+	values["updated_date"] = l.now()
+	return l.store.Transaction(func(tx db.Writer) error {
+		return tx.Update(db.TableUserCredential, id, credential{}, values)
+	})
+}
+
+//userResponse signs the access and id tokens of the account, the refresh token is only returned on login
+func (l *LocalProvider) userResponse(c credential, refresh string) (*UserResponse, error) {
+	expires := l.now().Add(l.config.AccessTokenTTL)
+	claims := map[string]interface{}{
+		"sub":                  c.ID,
+		"cognito:groups":       splitGroups(c.Groups),
+		"iss":                  l.config.Issuer,
+		"exp":                  expires.Unix(),
+		"iat":                  l.now().Unix(),
+		"username":             c.Username,
+		"email":                c.Email,
+		"given_name":           c.GivenName,
+		"family_name":          c.FamilyName,
+		"custom:language_code": c.LanguageCode,
+	}
+
+	claims["token_use"] = "access"
+	claims["client_id"] = l.config.Audience
+	accessToken, err := l.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(claims, "client_id")
+	claims["token_use"] = "id"
+	claims["aud"] = l.config.Audience
+	idToken, err := l.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &cognitoidentityprovider.AuthenticationResultType{
+		AccessToken: aws.String(accessToken),
+		IdToken:     aws.String(idToken),
+		ExpiresIn:   aws.Int64(int64(l.config.AccessTokenTTL.Seconds())),
+		TokenType:   aws.String("Bearer"),
+	}
+	if refresh != "" {
+		tokens.RefreshToken = aws.String(refresh)
+	}
+
+	return &UserResponse{
+		UserID:    c.ID,
+		Group:     c.Groups,
+		Email:     c.Email,
+		FirstName: c.GivenName,
+		LastName:  c.FamilyName,
+		Tokens:    tokens,
+	}, nil
+}
+
+func (l *LocalProvider) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": l.config.KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, l.config.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func splitGroups(groups string) []string {
+	if groups == "" {
+		return []string{}
+	}
+	return strings.Split(groups, ",")
+}
+
+//logCode prints the codes when there's no delivery configured, it lets the local provider be used offline
+func logCode(kind, email, code string) error {
+	log.Printf("auth: %s code for %s: %s", kind, email, code)
+	return nil
+}
+
+//randomCode returns the 6 digits codes sent to confirm the account and reset the password
+func randomCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//hashCode returns the stored form of the codes and refresh tokens
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func sameCode(hash, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashCode(code))) == 1
+}
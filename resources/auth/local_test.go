@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func localTestProvider(t *testing.T) *LocalProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	l := NewLocalProvider(db.NewMemoryStore(), LocalConfig{
+		Key:      key,
+		KeyID:    "test",
+		Issuer:   "issuer",
+		Audience: "audience",
+	})
+	l.codes = func() string { return "123456" }
+	l.config.SendCode = func(kind, email, code string) error { return nil }
+	return l
+}
+
+//advance moves the provider clock forward
+func advance(l *LocalProvider, d time.Duration) {
+	now := l.now()
+	l.now = func() time.Time { return now.Add(d) }
+}
+
+func TestLocalProviderSignUp(t *testing.T) {
+	l := localTestProvider(t)
+
+	id, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, id)
+
+	_, err = l.SignUp(Credentials{Username: "traveler", Password: "other", Email: "other@test.com"})
+	assert.Equal(t, ErrUserExists, err)
+	_, err = l.SignUp(Credentials{Username: "other", Password: "other", Email: "traveler@test.com"})
+	assert.Equal(t, ErrEmailExists, err)
+	_, err = l.SignUp(Credentials{Username: "other", Password: "other"})
+	assert.Equal(t, ErrInvalidSignUp, err)
+
+	_, err = l.Login("traveler", "secret")
+	assert.Equal(t, ErrUserNotConfirmed, err)
+
+	assert.Equal(t, ErrInvalidCode, l.Confirm("traveler", "000000"))
+	assert.Nil(t, l.Confirm("traveler", "123456"))
+	assert.Nil(t, l.AddToGroup("traveler", "Admin"))
+
+	_, err = l.Login("traveler", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = l.Login("nobody", "secret")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	user, err := l.Login("traveler", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, id, user.UserID)
+	assert.Equal(t, "Admin", user.Group)
+	assert.Equal(t, "traveler@test.com", user.Email)
+
+	tokenUser, err := l.verifier.Verify(*user.Tokens.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, id, tokenUser.UserID)
+	assert.Equal(t, []string{"Admin"}, tokenUser.Groups)
+
+	idVerifier := &common.TokenVerifier{Keys: l.verifier.Keys, Issuer: "issuer", Audience: "audience", TokenUse: []string{"id"}}
+	_, err = idVerifier.Verify(*user.Tokens.IdToken)
+	assert.Nil(t, err)
+}
+
+func TestLocalProviderResendConfirmation(t *testing.T) {
+	l := localTestProvider(t)
+	sent := []string{}
+	l.config.SendCode = func(kind, email, code string) error {
+		sent = append(sent, kind+":"+email+":"+code)
+		return nil
+	}
+	_, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrRateLimited, l.ResendConfirmation("traveler"))
+	advance(l, time.Minute)
+	l.codes = func() string { return "654321" }
+	assert.Nil(t, l.ResendConfirmation("traveler"))
+	assert.Equal(t, []string{"confirmation:traveler@test.com:123456", "confirmation:traveler@test.com:654321"}, sent)
+
+	assert.Equal(t, ErrInvalidCode, l.Confirm("traveler", "123456"))
+	assert.Nil(t, l.Confirm("traveler", "654321"))
+	advance(l, time.Minute)
+	assert.Equal(t, ErrInvalidCode, l.ResendConfirmation("traveler"))
+	assert.Equal(t, ErrUserNotFound, l.ResendConfirmation("nobody"))
+}
+
+func TestLocalProviderRefreshAndLogout(t *testing.T) {
+	l := localTestProvider(t)
+	_, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+	assert.Nil(t, l.AdminConfirm("traveler"))
+
+	user, err := l.Login("traveler", "secret")
+	assert.Nil(t, err)
+
+	refreshed, err := l.Refresh(*user.Tokens.RefreshToken)
+	assert.Nil(t, err)
+	assert.Equal(t, user.UserID, refreshed.UserID)
+	assert.Nil(t, refreshed.Tokens.RefreshToken)
+
+	_, err = l.Refresh("unknown")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	other, err := l.Login("traveler", "secret")
+	assert.Nil(t, err)
+	assert.Nil(t, l.Revoke(*other.Tokens.RefreshToken))
+	assert.Nil(t, l.Revoke("unknown"))
+	_, err = l.Refresh(*other.Tokens.RefreshToken)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = l.Refresh(*user.Tokens.RefreshToken)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrInvalidToken, l.Logout("invalid"))
+	assert.Nil(t, l.Logout(*user.Tokens.AccessToken))
+	_, err = l.Refresh(*user.Tokens.RefreshToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestLocalProviderResetPassword(t *testing.T) {
+	l := localTestProvider(t)
+	_, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+	assert.Nil(t, l.AdminConfirm("traveler"))
+
+	assert.Equal(t, ErrInvalidCode, l.ResetPassword("traveler", "123456", "new"))
+	assert.Equal(t, ErrUserNotFound, l.ForgotPassword("nobody"))
+	assert.Nil(t, l.ForgotPassword("traveler"))
+	assert.Equal(t, ErrRateLimited, l.ForgotPassword("traveler"))
+	advance(l, time.Minute)
+	assert.Nil(t, l.ForgotPassword("traveler"))
+	assert.Equal(t, ErrInvalidCode, l.ResetPassword("traveler", "000000", "new"))
+	assert.Nil(t, l.ResetPassword("traveler", "123456", "new"))
+	assert.Equal(t, ErrInvalidCode, l.ResetPassword("traveler", "123456", "again"))
+
+	_, err = l.Login("traveler", "secret")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = l.Login("traveler", "new")
+	assert.Nil(t, err)
+}
+
+func TestLocalProviderCodeAttempts(t *testing.T) {
+	l := localTestProvider(t)
+	_, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+
+	for i := 1; i < maxCodeAttempts; i++ {
+		assert.Equal(t, ErrInvalidCode, l.Confirm("traveler", "000000"))
+	}
+	assert.Equal(t, ErrRateLimited, l.Confirm("traveler", "000000"))
+	assert.Equal(t, ErrRateLimited, l.Confirm("traveler", "123456"))
+	advance(l, time.Minute)
+	assert.Nil(t, l.ResendConfirmation("traveler"))
+	assert.Nil(t, l.Confirm("traveler", "123456"))
+
+	advance(l, time.Minute)
+	assert.Nil(t, l.ForgotPassword("traveler"))
+	for i := 1; i < maxCodeAttempts; i++ {
+		assert.Equal(t, ErrInvalidCode, l.ResetPassword("traveler", "000000", "new"))
+	}
+	assert.Equal(t, ErrRateLimited, l.ResetPassword("traveler", "000000", "new"))
+	assert.Equal(t, ErrRateLimited, l.ResetPassword("traveler", "123456", "new"))
+	advance(l, time.Minute)
+	assert.Nil(t, l.ForgotPassword("traveler"))
+	assert.Nil(t, l.ResetPassword("traveler", "123456", "new"))
+	_, err = l.Login("traveler", "new")
+	assert.Nil(t, err)
+}
+
+func TestLocalProviderChangePassword(t *testing.T) {
+	l := localTestProvider(t)
+	_, err := l.SignUp(Credentials{Username: "traveler", Password: "secret", Email: "traveler@test.com"})
+	assert.Nil(t, err)
+	assert.Nil(t, l.AdminConfirm("traveler"))
+	user, err := l.Login("traveler", "secret")
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrInvalidToken, l.ChangePassword("invalid", "secret", "new"))
+	assert.Equal(t, ErrInvalidCredentials, l.ChangePassword(*user.Tokens.AccessToken, "wrong", "new"))
+	assert.Nil(t, l.ChangePassword(*user.Tokens.AccessToken, "secret", "new"))
+	_, err = l.Refresh(*user.Tokens.RefreshToken)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = l.Login("traveler", "secret")
+	assert.Equal(t, ErrInvalidCredentials, err)
+	_, err = l.Login("traveler", "new")
+	assert.Nil(t, err)
+}
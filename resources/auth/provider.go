@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"os"
+
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+)
+
+//Error represents an identity provider error, the Code is returned in the api error details so the clients can handle it
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//Detail returns the error code included in the api error response
+func (e *Error) Detail() interface{} {
+	return map[string]string{"code": e.Code}
+}
+
+var (
+	//ErrInvalidCredentials is returned when the username or password don't match
+	ErrInvalidCredentials = &Error{Code: "invalid_credentials", Message: "invalid username or password"}
+	//ErrInvalidSignUp is returned when the sign up misses the username, password or email
+	ErrInvalidSignUp = &Error{Code: "invalid_signup", Message: "username, password and email are required"}
+	//ErrInvalidPassword is returned when the password doesn't match the password policy
+	ErrInvalidPassword = &Error{Code: "invalid_password", Message: "password doesn't match the password policy"}
+	//ErrUserExists is returned when the username is already registered
+	ErrUserExists = &Error{Code: "username_exists", Message: "username already exists"}
+	//ErrEmailExists is returned when the email is already used by another user
+	ErrEmailExists = &Error{Code: "email_exists", Message: "email already registered"}
+	//ErrUserNotFound is returned when the username isn't registered
+	ErrUserNotFound = &Error{Code: "user_not_found", Message: "user not found"}
+	//ErrUserNotConfirmed is returned when the user tries to login before confirming the account
+	ErrUserNotConfirmed = &Error{Code: "user_not_confirmed", Message: "user is not confirmed"}
+	//ErrInvalidCode is returned when a confirmation or reset code is wrong or expired
+	ErrInvalidCode = &Error{Code: "invalid_code", Message: "invalid or expired code"}
+	//ErrInvalidToken is returned when a refresh or access token can't be used
+	ErrInvalidToken = &Error{Code: "invalid_token", Message: "invalid or expired token"}
+	//ErrRateLimited is returned when the user asks for codes or attempts too often
+	ErrRateLimited = &Error{Code: "rate_limited", Message: "too many attempts, try again later"}
+)
+
+//IdentityProvider authenticates the users and manages their accounts, AWS Cognito and the LocalProvider implement it
+type IdentityProvider interface {
+	//SignUp creates an unconfirmed account returning the user id
+	SignUp(credentials Credentials) (string, error)
+	//Confirm activates the account with the code sent to the user
+	Confirm(username, code string) error
+	//ResendConfirmation sends a new confirmation code to an unconfirmed user
+	ResendConfirmation(username string) error
+	//AdminConfirm activates the account without the confirmation code
+	AdminConfirm(username string) error
+	//AddToGroup includes the user in a group such as Admin
+	AddToGroup(username, group string) error
+	Login(username, password string) (*UserResponse, error)
+	//Refresh returns new access and id tokens for the refresh token
+	Refresh(refreshToken string) (*UserResponse, error)
+	//Logout revokes every refresh token of the access token user
+	Logout(accessToken string) error
+	//Revoke invalidates the refresh token and the tokens issued with it
+	Revoke(refreshToken string) error
+	//ForgotPassword sends the user a code to reset the password
+	ForgotPassword(username string) error
+	//ResetPassword changes the password with the code sent by ForgotPassword
+	ResetPassword(username, code, password string) error
+	//ChangePassword changes the password of the access token user
+	ChangePassword(accessToken, previousPassword, proposedPassword string) error
+}
+
+//NewProviderFromEnv returns the provider defined by FMT_AUTH_PROVIDER, "local" uses the LocalProvider with the key in FMT_LOCAL_AUTH_KEY and any other value uses AWS Cognito
+func NewProviderFromEnv() (IdentityProvider, error) {
+	if os.Getenv("FMT_AUTH_PROVIDER") != "local" {
+		return NewCognitoProviderFromEnv(), nil
+	}
+
+	key, err := common.LoadRSAPrivateKey(os.Getenv("FMT_LOCAL_AUTH_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalProvider(db.NewMySQLStore(), LocalConfig{
+		Key:      key,
+		KeyID:    common.LocalKeyID,
+		Issuer:   envOrDefault("FMT_JWT_ISSUER", common.LocalIssuer),
+		Audience: envOrDefault("FMT_JWT_AUDIENCE", common.LocalAudience),
+	}), nil
+}
+
+func envOrDefault(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/google/uuid"
 
 	"github.com/feedmytrip/api/resources/shared"
@@ -25,37 +26,48 @@ type Category struct {
 	CreatedDate    time.Time          `json:"created_date" db:"created_date" lock:"true"`
 	UpdatedBy      string             `json:"updated_by" db:"updated_by"`
 	UpdatedDate    time.Time          `json:"updated_date" db:"updated_date"`
+	Version        int64              `json:"version" db:"version" lock:"true"`
 	CreatedUser    shared.User        `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = category.created_by" embedded:"true"`
 	UpdatedUser    shared.User        `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = category.updated_by" embedded:"true"`
 }
 
 //GetAll returns all categories available in the database
-func (c *Category) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
+func (c *Category) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
+	return common.APIResponse(result, http.StatusOK)
+}
 
-	result, err := db.Select(session, db.TableCategory, request.QueryStringParameters, Category{})
+//Get returns a category
+func (c *Category) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Get(request.PathParameters["id"])
+	if err == db.ErrNotFound {
+		return common.APIError(http.StatusNotFound, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIConditionalResponse(request, result, result.Version)
 }
 
 //SaveNew creates a new category
-func (c *Category) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (c *Category) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), c)
+	err = json.Unmarshal([]byte(request.Body), c)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -74,45 +86,40 @@ func (c *Category) SaveNew(request events.APIGatewayProxyRequest) (events.APIGat
 	c.CreatedDate = time.Now()
 	c.UpdatedBy = tokenUser.UserID
 	c.UpdatedDate = time.Now()
+	c.Version = 0
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	err = repo.Create(*c, outbox.NewEvent(EventCategoryCreated, c.ID, tokenUser.UserID, c))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Insert(tx, db.TableCategory, *c)
+	result, err := repo.Get(c.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
+	return common.APIVersionedResponse(result, result.Version, http.StatusCreated)
+}
 
-	result, err := db.QueryOne(session, db.TableCategory, c.ID, Category{})
+//Update change categories attributes in the database, the If-Match header requires the version of the ETag
+func (c *Category) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	return common.APIResponse(result, http.StatusCreated)
-}
-
-//Update change categories attributes in the database
-func (c *Category) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
+	version, err := common.IfMatch(request, func() (int64, error) {
+		category, err := repo.Get(request.PathParameters["id"])
+		return category.Version, err
+	})
+	if err != nil {
+		return common.APIError(http.StatusPreconditionFailed, err)
+	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -120,52 +127,51 @@ func (c *Category) Update(request events.APIGatewayProxyRequest) (events.APIGate
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	conn, err := db.Connect()
+	err = repo.Update(request.PathParameters["id"], version, jsonMap, outbox.NewEvent(EventCategoryUpdated, request.PathParameters["id"], tokenUser.UserID, jsonMap))
+	if err == db.ErrVersionConflict {
+		return common.APIError(http.StatusPreconditionFailed, common.ErrPreconditionFailed)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	result, err := repo.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Update(tx, db.TableCategory, request.PathParameters["id"], *c, jsonMap)
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
+}
+
+//History returns the changes of the category, the most recent first
+func (c *Category) History(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	if !tokenUser.IsAdmin() {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableCategory, request.PathParameters["id"], Category{})
+	result, err := repo.History(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIResponse(map[string]interface{}{"data": result}, http.StatusOK)
 }
 
 //Delete removes categories from the database
-func (c *Category) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (c *Category) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Delete(session, db.TableCategory, request.PathParameters["id"])
+	err = repo.Delete(request.PathParameters["id"], outbox.NewEvent(EventCategoryDeleted, request.PathParameters["id"], tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
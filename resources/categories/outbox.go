@@ -0,0 +1,8 @@
+package categories
+
+//Domain events written in the outbox by the categories mutations
+const (
+	EventCategoryCreated = "category.created"
+	EventCategoryUpdated = "category.updated"
+	EventCategoryDeleted = "category.deleted"
+)
@@ -0,0 +1,81 @@
+package categories
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//Repository loads and persists the categories
+type Repository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Category, error)
+	//History returns the changes of the category, the most recent first
+	History(id string) ([]db.AuditEntry, error)
+	Create(category Category, events ...outbox.Event) error
+	//Update changes the category when its version is still the informed one, db.AnyVersion skips the check
+	Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+type repository struct {
+	store db.Store
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return repository{store: store}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the categories in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+func (r repository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableCategory, params, Category{})
+}
+
+func (r repository) Get(id string) (Category, error) {
+	category := Category{}
+	err := r.store.LoadOne(db.TableCategory, id, &category)
+	return category, err
+}
+
+func (r repository) History(id string) ([]db.AuditEntry, error) {
+	return db.History(r.store, id)
+}
+
+func (r repository) Create(category Category, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableCategory, category)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableCategory, id, version, Category{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableCategory, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
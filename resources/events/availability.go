@@ -0,0 +1,314 @@
+package events
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+)
+
+const (
+	//availabilityDays is the range of the availability requests without the to param
+	availabilityDays = 7
+	//maxAvailabilityRange limits the range of the availability requests
+	maxAvailabilityRange = 366 * 24 * time.Hour
+)
+
+//ErrInvalidWeekDays is returned when the week_days of a schedule are out of the format
+var ErrInvalidWeekDays = errors.New("invalid week_days, it must have 7 digits 0 or 1 from sunday to saturday, like 0111110")
+
+//endOfTime is the end of the schedules without end_date
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+//Window represents a period the event is open, the end is exclusive
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+//Availability evaluates the schedules of an event. Each schedule applies from the start_date until the end_date:
+//a fixed_period is open all the time between them, a fixed_date only on the day of the start_date and the others on
+//every day of the period enabled in week_days. The days are open from the time of the start_date until the time of
+//the end_date, the next day when it's earlier, and all day when both times are equal.
+//The annually schedules repeat the period every year and the closed schedules close the event in their windows,
+//overriding the openings. The events without opening schedules are open any time out of the closures.
+type Availability struct {
+	openings []Schedule
+	closures []Schedule
+}
+
+//AvailabilityResult represents the response of the event availability, open tells if the event is open at from
+type AvailabilityResult struct {
+	EventID string    `json:"event_id"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Open    bool      `json:"open"`
+	Windows []Window  `json:"windows"`
+}
+
+//NewAvailability returns the availability defined by the schedules of an event
+func NewAvailability(schedules []Schedule) Availability {
+	a := Availability{}
+	for _, s := range schedules {
+		if s.Closed {
+			a.closures = append(a.closures, s)
+		} else {
+			a.openings = append(a.openings, s)
+		}
+	}
+	return a
+}
+
+//IsOpen check if the event is open at the time
+func (a Availability) IsOpen(t time.Time) bool {
+	return len(a.Windows(t, t.Add(time.Nanosecond), 1)) > 0
+}
+
+//Windows returns the open windows between from and to, cut at both limits. Only the first n windows are returned
+//when n is greater than zero.
+func (a Availability) Windows(from, to time.Time, n int) []Window {
+	result := []Window{}
+	if !from.Before(to) {
+		return result
+	}
+
+	open := []Window{{Start: from, End: to}}
+	if len(a.openings) > 0 {
+		open = scheduleWindows(a.openings, from, to)
+	}
+	for _, w := range subtractWindows(open, scheduleWindows(a.closures, from, to)) {
+		if w.Start.Before(from) {
+			w.Start = from
+		}
+		if w.End.After(to) {
+			w.End = to
+		}
+		if !w.Start.Before(w.End) {
+			continue
+		}
+		result = append(result, w)
+		if n > 0 && len(result) == n {
+			break
+		}
+	}
+	return result
+}
+
+//Availability returns the open windows of the event between the from and to params, with at most results windows
+func (e *Event) Availability(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	from, to, err := availabilityRange(request.QueryStringParameters, time.Now().UTC())
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	results := 0
+	if val, ok := request.QueryStringParameters["results"]; ok {
+		results, err = strconv.Atoi(val)
+		if err != nil || results <= 0 {
+			return common.APIError(http.StatusBadRequest, errors.New("invalid results param"))
+		}
+	}
+
+	event, err := repo.Events.Get(request.PathParameters["id"])
+	if err == db.ErrNotFound {
+		return common.APIError(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+	schedules, err := repo.Schedules.All(event.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	availability := NewAvailability(schedules)
+	return common.APIResponse(AvailabilityResult{
+		EventID: event.ID,
+		From:    from,
+		To:      to,
+		Open:    availability.IsOpen(from),
+		Windows: availability.Windows(from, to, results),
+	}, http.StatusOK)
+}
+
+//availabilityRange parses the from and to params as RFC 3339 times or dates, from defaults to now and to to a week later
+func availabilityRange(params map[string]string, now time.Time) (time.Time, time.Time, error) {
+	from, to := now, time.Time{}
+	var err error
+	if val := params["from"]; val != "" {
+		from, err = parseAvailabilityTime(val)
+		if err != nil {
+			return from, to, errors.New("invalid from param, use a RFC 3339 time or a date like 2019-08-01")
+		}
+	}
+	to = from.AddDate(0, 0, availabilityDays)
+	if val := params["to"]; val != "" {
+		to, err = parseAvailabilityTime(val)
+		if err != nil {
+			return from, to, errors.New("invalid to param, use a RFC 3339 time or a date like 2019-08-01")
+		}
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("the to param must be after from")
+	}
+	if to.Sub(from) > maxAvailabilityRange {
+		return from, to, errors.New("the availability range is limited to 366 days")
+	}
+	return from, to, nil
+}
+
+func parseAvailabilityTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+	}
+	return t, err
+}
+
+//validateWeekDays check the week_days format, the empty value opens every day of the week
+func validateWeekDays(weekDays string) error {
+	if weekDays == "" {
+		return nil
+	}
+	if len(weekDays) != 7 {
+		return ErrInvalidWeekDays
+	}
+	for _, day := range weekDays {
+		if day != '0' && day != '1' {
+			return ErrInvalidWeekDays
+		}
+	}
+	return nil
+}
+
+//opensOn check if week_days enables the day of the week
+func (s Schedule) opensOn(day time.Weekday) bool {
+	return len(s.WeekDays) != 7 || s.WeekDays[day] == '1'
+}
+
+//windows returns the windows of the schedule around from and to, the callers cut them at the limits
+func (s Schedule) windows(from, to time.Time) []Window {
+	windows := []Window{}
+	location := s.StartDate.Location()
+	opening, closing := timeOfDay(s.StartDate), timeOfDay(s.EndDate)
+	if closing <= opening {
+		closing += 24 * time.Hour
+	}
+
+	for _, period := range s.periods(from, to) {
+		if s.FixedPeriod {
+			windows = append(windows, period)
+			continue
+		}
+		end := period.End
+		if s.FixedDate {
+			end = period.Start
+		}
+
+		//the windows crossing midnight start on the day before from
+		first := startOfDay(period.Start, location)
+		if day := startOfDay(from, location).AddDate(0, 0, -1); day.After(first) {
+			first = day
+		}
+		last := startOfDay(end, location)
+		if day := startOfDay(to, location); day.Before(last) {
+			last = day
+		}
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			if s.FixedDate || s.opensOn(day.Weekday()) {
+				windows = append(windows, Window{Start: day.Add(opening), End: day.Add(closing)})
+			}
+		}
+	}
+	return windows
+}
+
+//periods returns the periods between the start_date and the end_date of the schedule, the annually schedules have
+//one period by year around from and to
+func (s Schedule) periods(from, to time.Time) []Window {
+	start, end := s.StartDate, s.EndDate
+	if end.IsZero() {
+		end = endOfTime
+	}
+	if !s.Annually || start.IsZero() || end == endOfTime {
+		return []Window{{Start: start, End: end}}
+	}
+
+	periods := []Window{}
+	years := end.Year() - start.Year()
+	for year := from.Year() - years - 1; year <= to.Year(); year++ {
+		periods = append(periods, Window{Start: inYear(start, year), End: inYear(end, year+years)})
+	}
+	return periods
+}
+
+//scheduleWindows returns the windows of the schedules sorted and merged
+func scheduleWindows(schedules []Schedule, from, to time.Time) []Window {
+	windows := []Window{}
+	for _, s := range schedules {
+		windows = append(windows, s.windows(from, to)...)
+	}
+	return mergeWindows(windows)
+}
+
+//mergeWindows sorts the windows and joins the ones overlapping or touching each other
+func mergeWindows(windows []Window) []Window {
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+	merged := []Window{}
+	for _, w := range windows {
+		if !w.Start.Before(w.End) {
+			continue
+		}
+		last := len(merged) - 1
+		if last >= 0 && !w.Start.After(merged[last].End) {
+			if w.End.After(merged[last].End) {
+				merged[last].End = w.End
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+//subtractWindows removes the closed windows from the open ones, both sorted and merged
+func subtractWindows(open, closed []Window) []Window {
+	result := []Window{}
+	for _, w := range open {
+		for _, c := range closed {
+			if !c.End.After(w.Start) || !c.Start.Before(w.End) {
+				continue
+			}
+			if c.Start.After(w.Start) {
+				result = append(result, Window{Start: w.Start, End: c.Start})
+			}
+			w.Start = c.End
+			if !w.Start.Before(w.End) {
+				break
+			}
+		}
+		if w.Start.Before(w.End) {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+func startOfDay(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+func inYear(t time.Time, year int) time.Time {
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
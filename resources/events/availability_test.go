@@ -0,0 +1,123 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func formatWindows(windows []Window) []string {
+	result := []string{}
+	for _, w := range windows {
+		result = append(result, w.Start.Format(time.RFC3339)+" "+w.End.Format(time.RFC3339))
+	}
+	return result
+}
+
+func TestAvailabilityWindows(t *testing.T) {
+	tests := []struct {
+		name      string
+		schedules []Schedule
+		from, to  string
+		expected  []string
+	}{
+		{
+			"without schedules",
+			nil,
+			"2019-08-01T00:00:00Z", "2019-08-02T00:00:00Z",
+			[]string{"2019-08-01T00:00:00Z 2019-08-02T00:00:00Z"},
+		},
+		{
+			"only closures",
+			[]Schedule{{Closed: true, FixedDate: true, StartDate: date("2019-08-01T12:00:00Z"), EndDate: date("2019-08-01T14:00:00Z")}},
+			"2019-08-01T00:00:00Z", "2019-08-02T00:00:00Z",
+			[]string{"2019-08-01T00:00:00Z 2019-08-01T12:00:00Z", "2019-08-01T14:00:00Z 2019-08-02T00:00:00Z"},
+		},
+		{
+			"week days",
+			[]Schedule{{StartDate: date("2019-08-01T09:00:00Z"), EndDate: date("2019-08-31T17:00:00Z"), WeekDays: "1000001"}},
+			"2019-08-01T00:00:00Z", "2019-08-08T00:00:00Z",
+			[]string{"2019-08-03T09:00:00Z 2019-08-03T17:00:00Z", "2019-08-04T09:00:00Z 2019-08-04T17:00:00Z"},
+		},
+		{
+			"overnight",
+			[]Schedule{{StartDate: date("2019-08-01T22:00:00Z"), EndDate: date("2019-08-03T02:00:00Z")}},
+			"2019-08-02T01:00:00Z", "2019-08-05T00:00:00Z",
+			[]string{"2019-08-02T01:00:00Z 2019-08-02T02:00:00Z", "2019-08-02T22:00:00Z 2019-08-03T02:00:00Z", "2019-08-03T22:00:00Z 2019-08-04T02:00:00Z"},
+		},
+		{
+			"all day and merged",
+			[]Schedule{
+				{StartDate: date("2019-08-01T00:00:00Z"), EndDate: date("2019-08-02T00:00:00Z")},
+				{FixedDate: true, StartDate: date("2019-08-03T08:00:00Z"), EndDate: date("2019-08-03T12:00:00Z")},
+			},
+			"2019-07-31T00:00:00Z", "2019-08-05T00:00:00Z",
+			[]string{"2019-08-01T00:00:00Z 2019-08-03T00:00:00Z", "2019-08-03T08:00:00Z 2019-08-03T12:00:00Z"},
+		},
+		{
+			"fixed period with a closure",
+			[]Schedule{
+				{FixedPeriod: true, StartDate: date("2019-08-01T10:00:00Z"), EndDate: date("2019-08-03T20:00:00Z")},
+				{Closed: true, StartDate: date("2019-08-01T00:00:00Z"), EndDate: date("2019-08-31T08:00:00Z"), WeekDays: "0000010"},
+			},
+			"2019-08-01T00:00:00Z", "2019-08-05T00:00:00Z",
+			[]string{"2019-08-01T10:00:00Z 2019-08-02T00:00:00Z", "2019-08-02T08:00:00Z 2019-08-03T20:00:00Z"},
+		},
+		{
+			"annually across the new year",
+			[]Schedule{
+				{Annually: true, FixedPeriod: true, StartDate: date("2010-12-20T00:00:00Z"), EndDate: date("2011-01-10T00:00:00Z")},
+				{Annually: true, Closed: true, FixedDate: true, StartDate: date("2010-12-25T00:00:00Z"), EndDate: date("2010-12-25T00:00:00Z")},
+			},
+			"2019-01-01T00:00:00Z", "2020-01-01T00:00:00Z",
+			[]string{"2019-01-01T00:00:00Z 2019-01-10T00:00:00Z", "2019-12-20T00:00:00Z 2019-12-25T00:00:00Z", "2019-12-26T00:00:00Z 2020-01-01T00:00:00Z"},
+		},
+		{
+			"out of the period",
+			[]Schedule{{StartDate: date("2019-08-01T09:00:00Z"), EndDate: date("2019-08-31T17:00:00Z")}},
+			"2019-09-01T00:00:00Z", "2019-09-30T00:00:00Z",
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		a := NewAvailability(test.schedules)
+		windows := a.Windows(date(test.from), date(test.to), 0)
+		assert.Equal(t, test.expected, formatWindows(windows), test.name)
+	}
+}
+
+func TestAvailabilityIsOpen(t *testing.T) {
+	a := NewAvailability([]Schedule{
+		{StartDate: date("2019-08-01T09:00:00Z"), EndDate: date("2019-08-31T17:00:00Z"), WeekDays: "0111110"},
+		{Closed: true, FixedDate: true, StartDate: date("2019-08-15T00:00:00Z"), EndDate: date("2019-08-15T00:00:00Z")},
+	})
+
+	assert.True(t, a.IsOpen(date("2019-08-01T09:00:00Z")))
+	assert.True(t, a.IsOpen(date("2019-08-14T16:59:59Z")))
+	assert.False(t, a.IsOpen(date("2019-08-14T17:00:00Z")))
+	assert.False(t, a.IsOpen(date("2019-08-03T12:00:00Z")))
+	assert.False(t, a.IsOpen(date("2019-08-15T12:00:00Z")))
+	assert.False(t, a.IsOpen(date("2019-09-02T12:00:00Z")))
+
+	windows := a.Windows(date("2019-08-01T00:00:00Z"), date("2019-09-01T00:00:00Z"), 2)
+	assert.Equal(t, []string{"2019-08-01T09:00:00Z 2019-08-01T17:00:00Z", "2019-08-02T09:00:00Z 2019-08-02T17:00:00Z"}, formatWindows(windows))
+}
+
+func TestValidateWeekDays(t *testing.T) {
+	for _, weekDays := range []string{"", "0000000", "1111111", "0111110"} {
+		assert.Nil(t, validateWeekDays(weekDays), weekDays)
+	}
+	for _, weekDays := range []string{"011111", "01111100", "mon,tue", "0111112", "0 11110"} {
+		assert.Equal(t, ErrInvalidWeekDays, validateWeekDays(weekDays), weekDays)
+	}
+}
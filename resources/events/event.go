@@ -9,7 +9,9 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
+	"github.com/gocraft/dbr"
 	"github.com/google/uuid"
 )
 
@@ -30,45 +32,38 @@ type Event struct {
 	CityID              string             `json:"city_id" db:"city_id"`
 	City                shared.Translation `json:"city" table:"translation" alias:"city" on:"city.parent_id = event.city_id and city.field = 'title'" embedded:"true"`
 	Address             string             `json:"address" db:"address"`
+	Latitude            dbr.NullFloat64    `json:"latitude" db:"latitude"`
+	Longitude           dbr.NullFloat64    `json:"longitude" db:"longitude"`
 	CreatedBy           string             `json:"created_by" db:"created_by" lock:"true"`
 	CreatedDate         time.Time          `json:"created_date" db:"created_date" lock:"true"`
 	UpdatedBy           string             `json:"updated_by" db:"updated_by"`
 	UpdatedDate         time.Time          `json:"updated_date" db:"updated_date"`
+	DeletedAt           dbr.NullTime       `json:"deleted_at" db:"deleted_at" lock:"true"`
+	DeletedBy           string             `json:"deleted_by" db:"deleted_by" lock:"true"`
+	Version             int64              `json:"version" db:"version" lock:"true"`
 	CreatedUser         shared.User        `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = event.created_by" embedded:"true"`
 	UpdatedUser         shared.User        `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = event.updated_by" embedded:"true"`
 }
 
 //Get return an event
-func (e *Event) Get(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
+func (e *Event) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Events.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.QueryOne(session, db.TableEvent, request.PathParameters["id"], Event{})
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIConditionalResponse(request, result, result.Version)
 }
 
-//GetAll returns all events available in the database
-func (e *Event) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+//GetAll returns all events available in the database, admins can include the soft deleted ones with include_deleted=true
+func (e *Event) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	if request.QueryStringParameters["include_deleted"] != "" && !common.IsTokenUserAdmin(request) {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can list the deleted events"))
+	}
+	result, err := repo.Events.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.Select(session, db.TableEvent, request.QueryStringParameters, Event{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -77,13 +72,16 @@ func (e *Event) GetAll(request events.APIGatewayProxyRequest) (events.APIGateway
 }
 
 //SaveNew creates a new event
-func (e *Event) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (e *Event) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), e)
+	err = json.Unmarshal([]byte(request.Body), e)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -106,45 +104,42 @@ func (e *Event) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewa
 	e.CreatedDate = time.Now()
 	e.UpdatedBy = tokenUser.UserID
 	e.UpdatedDate = time.Now()
+	e.DeletedAt = dbr.NullTime{}
+	e.DeletedBy = ""
+	e.Version = 0
 
-	conn, err := db.Connect()
+	err = repo.Events.Create(*e, outbox.NewEvent(EventCreated, e.ID, tokenUser.UserID, e))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	result, err := repo.Events.Get(e.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Insert(tx, db.TableEvent, *e)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
+	return common.APIVersionedResponse(result, result.Version, http.StatusCreated)
+}
 
-	result, err := db.QueryOne(session, db.TableEvent, e.ID, Event{})
+//Update change event attributes in the database, the If-Match header requires the version of the ETag
+func (e *Event) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	return common.APIResponse(result, http.StatusCreated)
-}
-
-//Update change event attributes in the database
-func (e *Event) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
+	version, err := common.IfMatch(request, func() (int64, error) {
+		event, err := repo.Events.Get(request.PathParameters["id"])
+		return event.Version, err
+	})
+	if err != nil {
+		return common.APIError(http.StatusPreconditionFailed, err)
+	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -152,55 +147,88 @@ func (e *Event) Update(request events.APIGatewayProxyRequest) (events.APIGateway
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	conn, err := db.Connect()
+	err = repo.Events.Update(request.PathParameters["id"], version, jsonMap, outbox.NewEvent(EventUpdated, request.PathParameters["id"], tokenUser.UserID, jsonMap))
+	if err == db.ErrVersionConflict {
+		return common.APIError(http.StatusPreconditionFailed, common.ErrPreconditionFailed)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	result, err := repo.Events.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Update(tx, db.TableEvent, request.PathParameters["id"], *e, jsonMap)
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
+}
+
+//History returns the changes of the event and of its schedules, the most recent first
+func (e *Event) History(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	if !tokenUser.IsAdmin() {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
+	}
+
+	result, err := repo.Events.History(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
+	return common.APIResponse(map[string]interface{}{"data": result}, http.StatusOK)
+}
 
-	result, err := db.QueryOne(session, db.TableEvent, request.PathParameters["id"], Event{})
+//Delete marks the event as removed, admins can restore it until the purge
+func (e *Event) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	if !tokenUser.IsAdmin() {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	err = repo.Events.Delete(request.PathParameters["id"], tokenUser.UserID, outbox.NewEvent(EventDeleted, request.PathParameters["id"], tokenUser.UserID, nil))
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+
+	return common.APIResponse(nil, http.StatusOK)
 }
 
-//Delete removes event from the database
-func (e *Event) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+//Restore brings back a soft deleted event with its schedules
+func (e *Event) Restore(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
+	event, err := repo.Events.GetWithDeleted(request.PathParameters["id"])
+	if err == db.ErrNotFound {
+		return common.APIError(http.StatusNotFound, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
+	if !event.DeletedAt.Valid {
+		return common.APIError(http.StatusConflict, errors.New("event is not deleted"))
+	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
+	err = repo.Events.Restore(event.ID, outbox.NewEvent(EventRestored, event.ID, tokenUser.UserID, nil))
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
 
-	err = db.Delete(session, db.TableEvent, request.PathParameters["id"])
+	result, err := repo.Events.Get(event.ID)
 	if err != nil {
-		return common.APIError(http.StatusBadRequest, err)
+		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(nil, http.StatusOK)
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
 }
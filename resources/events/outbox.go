@@ -0,0 +1,24 @@
+package events
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//Domain events written in the outbox by the events mutations, the data of the schedule events has the event_id attribute
+const (
+	EventCreated         = "event.created"
+	EventUpdated         = "event.updated"
+	EventDeleted         = "event.deleted"
+	EventRestored        = "event.restored"
+	EventScheduleCreated = "event_schedule.created"
+	EventScheduleUpdated = "event_schedule.updated"
+	EventScheduleDeleted = "event_schedule.deleted"
+)
+
+//scheduleEvent returns an event of the schedule in the request path, data is the changed values
+func scheduleEvent(eventType string, request events.APIGatewayProxyRequest, actor string, values map[string]interface{}) outbox.Event {
+	event := outbox.NewEvent(eventType, request.PathParameters["schedule_id"], actor, values)
+	event.Data.(map[string]interface{})["event_id"] = request.PathParameters["id"]
+	return event
+}
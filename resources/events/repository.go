@@ -0,0 +1,184 @@
+package events
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//EventRepository loads and persists the events
+type EventRepository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Event, error)
+	//History returns the changes of the event and of its schedules, the most recent first
+	History(id string) ([]db.AuditEntry, error)
+	Create(event Event, events ...outbox.Event) error
+	//Update changes the event when its version is still the informed one, db.AnyVersion skips the check
+	Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error
+	//Delete marks the event as removed by the user, the event is kept until the purge
+	Delete(id, deletedBy string, events ...outbox.Event) error
+	//GetWithDeleted returns the event even when it is soft deleted
+	GetWithDeleted(id string) (Event, error)
+	//Restore clears the removal of the soft deleted event
+	Restore(id string, events ...outbox.Event) error
+}
+
+//ScheduleRepository loads and persists the events schedules
+type ScheduleRepository interface {
+	List(eventID string, params map[string]string) (interface{}, error)
+	Get(id string) (Schedule, error)
+	//All returns every schedule of the event
+	All(eventID string) ([]Schedule, error)
+	Create(schedule Schedule, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//Repository groups the repositories of the events aggregates
+type Repository struct {
+	Events    EventRepository
+	Schedules ScheduleRepository
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return Repository{
+		Events:    eventRepository{store: store},
+		Schedules: scheduleRepository{store: store},
+	}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the events in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+type eventRepository struct {
+	store db.Store
+}
+
+func (r eventRepository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableEvent, params, Event{})
+}
+
+func (r eventRepository) Get(id string) (Event, error) {
+	event := Event{}
+	err := r.store.LoadOne(db.TableEvent, id, &event)
+	return event, err
+}
+
+func (r eventRepository) History(id string) ([]db.AuditEntry, error) {
+	return db.History(r.store, id)
+}
+
+func (r eventRepository) Create(event Event, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableEvent, event)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r eventRepository) Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableEvent, id, version, Event{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r eventRepository) Delete(id, deletedBy string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.SoftDelete(tx, db.TableEvent, deletedBy, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r eventRepository) GetWithDeleted(id string) (Event, error) {
+	event := Event{}
+	err := db.LoadWithDeleted(r.store, db.TableEvent, id, &event)
+	return event, err
+}
+
+func (r eventRepository) Restore(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.Restore(tx, db.TableEvent, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+type scheduleRepository struct {
+	store db.Store
+}
+
+func (r scheduleRepository) List(eventID string, params map[string]string) (interface{}, error) {
+	filters := map[string]string{}
+	for k, v := range params {
+		filters[k] = v
+	}
+	filters["event_id"] = eventID
+	return r.store.Select(db.TableEventSchedule, filters, Schedule{})
+}
+
+func (r scheduleRepository) Get(id string) (Schedule, error) {
+	schedule := Schedule{}
+	err := r.store.LoadOne(db.TableEventSchedule, id, &schedule)
+	return schedule, err
+}
+
+func (r scheduleRepository) All(eventID string) ([]Schedule, error) {
+	filter := map[string]string{
+		"event_id": eventID,
+		"results":  "1000",
+		"sort":     "start_date",
+		"order":    "asc",
+		"count":    "none",
+	}
+	schedules := []Schedule{}
+	err := r.store.LoadAll(db.TableEventSchedule, filter, &schedules)
+	return schedules, err
+}
+
+func (r scheduleRepository) Create(schedule Schedule, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableEventSchedule, schedule)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r scheduleRepository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableEventSchedule, id, Schedule{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r scheduleRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableEventSchedule, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/google/uuid"
 )
@@ -33,25 +34,11 @@ type Schedule struct {
 }
 
 //GetAll returns all schedules for an event available in the database
-func (s *Schedule) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"event_id": request.PathParameters["id"],
-		}
-	} else {
-		request.QueryStringParameters["event_id"] = request.PathParameters["id"]
+func (s *Schedule) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Schedules.List(request.PathParameters["id"], request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	result, err := db.Select(session, db.TableEventSchedule, request.QueryStringParameters, Schedule{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -60,13 +47,20 @@ func (s *Schedule) GetAll(request events.APIGatewayProxyRequest) (events.APIGate
 }
 
 //SaveNew creates a new schedule for the event
-func (s *Schedule) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (s *Schedule) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), s)
+	err = json.Unmarshal([]byte(request.Body), s)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	err = validateWeekDays(s.WeekDays)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -78,28 +72,12 @@ func (s *Schedule) SaveNew(request events.APIGatewayProxyRequest) (events.APIGat
 	s.UpdatedBy = tokenUser.UserID
 	s.UpdatedDate = time.Now()
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Insert(tx, db.TableEventSchedule, *s)
+	err = repo.Schedules.Create(*s, outbox.NewEvent(EventScheduleCreated, s.ID, tokenUser.UserID, s))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableEventSchedule, s.ID, Schedule{})
+	result, err := repo.Schedules.Get(s.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -108,43 +86,36 @@ func (s *Schedule) SaveNew(request events.APIGatewayProxyRequest) (events.APIGat
 }
 
 //Update change event schedule attributes in the database
-func (s *Schedule) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (s *Schedule) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
+	if val, ok := jsonMap["week_days"]; ok && val != nil {
+		weekDays, isString := val.(string)
+		if !isString || validateWeekDays(weekDays) != nil {
+			return common.APIError(http.StatusBadRequest, ErrInvalidWeekDays)
+		}
+	}
 
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	conn, err := db.Connect()
+	err = repo.Schedules.Update(request.PathParameters["schedule_id"], jsonMap, scheduleEvent(EventScheduleUpdated, request, tokenUser.UserID, jsonMap))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Update(tx, db.TableEventSchedule, request.PathParameters["schedule_id"], *s, jsonMap)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableEventSchedule, request.PathParameters["schedule_id"], Schedule{})
+	result, err := repo.Schedules.Get(request.PathParameters["schedule_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -153,22 +124,16 @@ func (s *Schedule) Update(request events.APIGatewayProxyRequest) (events.APIGate
 }
 
 //Delete removes event schedule from the database
-func (s *Schedule) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (s *Schedule) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Delete(session, db.TableEventSchedule, request.PathParameters["schedule_id"])
+	err = repo.Schedules.Delete(request.PathParameters["schedule_id"], scheduleEvent(EventScheduleDeleted, request, tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
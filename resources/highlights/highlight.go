@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/google/uuid"
 )
@@ -38,17 +39,8 @@ type Highlight struct {
 }
 
 //Get return a highlight
-func (h *Highlight) Get(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.QueryOne(session, db.TableHighlight, request.PathParameters["id"], Highlight{})
+func (h *Highlight) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Highlights.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -57,17 +49,11 @@ func (h *Highlight) Get(request events.APIGatewayProxyRequest) (events.APIGatewa
 }
 
 //GetAll returns all highlights available in the database
-func (h *Highlight) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+func (h *Highlight) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Highlights.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.Select(session, db.TableHighlight, request.QueryStringParameters, Highlight{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -76,13 +62,16 @@ func (h *Highlight) GetAll(request events.APIGatewayProxyRequest) (events.APIGat
 }
 
 //SaveNew creates a new highlight
-func (h *Highlight) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (h *Highlight) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), h)
+	err = json.Unmarshal([]byte(request.Body), h)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -106,45 +95,31 @@ func (h *Highlight) SaveNew(request events.APIGatewayProxyRequest) (events.APIGa
 	h.UpdatedBy = tokenUser.UserID
 	h.UpdatedDate = time.Now()
 
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Insert(tx, db.TableHighlight, *h)
+	err = repo.Highlights.Create(*h, outbox.NewEvent(EventHighlightCreated, h.ID, tokenUser.UserID, h))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableHighlight, h.ID, Highlight{})
+	result, err := repo.Highlights.Get(h.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIResponse(result, http.StatusCreated)
 }
 
 //Update change highlight attributes in the database
-func (h *Highlight) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (h *Highlight) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -152,29 +127,12 @@ func (h *Highlight) Update(request events.APIGatewayProxyRequest) (events.APIGat
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	conn, err := db.Connect()
-	defer conn.Close()
+	err = repo.Highlights.Update(request.PathParameters["id"], jsonMap, outbox.NewEvent(EventHighlightUpdated, request.PathParameters["id"], tokenUser.UserID, jsonMap))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Update(tx, db.TableHighlight, request.PathParameters["id"], *h, jsonMap)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableHighlight, request.PathParameters["id"], Highlight{})
+	result, err := repo.Highlights.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -183,24 +141,18 @@ func (h *Highlight) Update(request events.APIGatewayProxyRequest) (events.APIGat
 }
 
 //Delete removes highlight from the database
-func (h *Highlight) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (h *Highlight) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
 	//TODO: Delete all highlight images and then delete highlight folder on AWS S3
 
-	err = db.Delete(session, db.TableHighlight, request.PathParameters["id"])
+	err = repo.Highlights.Delete(request.PathParameters["id"], outbox.NewEvent(EventHighlightDeleted, request.PathParameters["id"], tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
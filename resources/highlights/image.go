@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/google/uuid"
 )
@@ -25,25 +26,11 @@ type HighlightImage struct {
 }
 
 //GetAll returns all highlight images
-func (h *HighlightImage) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"highlight_id": request.PathParameters["id"],
-		}
-	} else {
-		request.QueryStringParameters["highlight_id"] = request.PathParameters["id"]
+func (h *HighlightImage) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.Images.List(request.PathParameters["id"], request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	result, err := db.Select(session, db.TableHighlightImage, request.QueryStringParameters, HighlightImage{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -52,21 +39,15 @@ func (h *HighlightImage) GetAll(request events.APIGatewayProxyRequest) (events.A
 }
 
 //SaveNew creates a new highlight image
-func (h *HighlightImage) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (h *HighlightImage) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admins can create highlight images"))
 	}
 
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
 	err = json.Unmarshal([]byte(request.Body), h)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
@@ -77,39 +58,25 @@ func (h *HighlightImage) SaveNew(request events.APIGatewayProxyRequest) (events.
 	h.CreatedBy = tokenUser.UserID
 	h.CreatedDate = time.Now()
 
-	tx, err := session.Begin()
+	err = repo.Images.Create(*h, outbox.NewEvent(EventHighlightImageCreated, h.ID, tokenUser.UserID, h))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Insert(tx, db.TableHighlightImage, *h)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
 
 	return common.APIResponse(h, http.StatusCreated)
 }
 
 //Delete remove highlight image
-func (h *HighlightImage) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (h *HighlightImage) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admins can delete highlight image"))
 	}
 
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	err = db.Delete(session, db.TableHighlightImage, request.PathParameters["image_id"])
+	err = repo.Images.Delete(request.PathParameters["image_id"], outbox.NewEvent(EventHighlightImageDeleted, request.PathParameters["image_id"], tokenUser.UserID, map[string]interface{}{"highlight_id": request.PathParameters["id"]}))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
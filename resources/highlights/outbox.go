@@ -0,0 +1,10 @@
+package highlights
+
+//Domain events written in the outbox by the highlights mutations, the data of the image events has the highlight_id attribute
+const (
+	EventHighlightCreated      = "highlight.created"
+	EventHighlightUpdated      = "highlight.updated"
+	EventHighlightDeleted      = "highlight.deleted"
+	EventHighlightImageCreated = "highlight_image.created"
+	EventHighlightImageDeleted = "highlight_image.deleted"
+)
@@ -0,0 +1,123 @@
+package highlights
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//HighlightRepository loads and persists the highlights
+type HighlightRepository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Highlight, error)
+	Create(highlight Highlight, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//ImageRepository loads and persists the highlights images
+type ImageRepository interface {
+	List(highlightID string, params map[string]string) (interface{}, error)
+	Create(image HighlightImage, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//Repository groups the repositories of the highlights aggregates
+type Repository struct {
+	Highlights HighlightRepository
+	Images     ImageRepository
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return Repository{
+		Highlights: highlightRepository{store: store},
+		Images:     imageRepository{store: store},
+	}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the highlights in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+type highlightRepository struct {
+	store db.Store
+}
+
+func (r highlightRepository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableHighlight, params, Highlight{})
+}
+
+func (r highlightRepository) Get(id string) (Highlight, error) {
+	highlight := Highlight{}
+	err := r.store.LoadOne(db.TableHighlight, id, &highlight)
+	return highlight, err
+}
+
+func (r highlightRepository) Create(highlight Highlight, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableHighlight, highlight)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r highlightRepository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableHighlight, id, Highlight{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r highlightRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableHighlight, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+type imageRepository struct {
+	store db.Store
+}
+
+func (r imageRepository) List(highlightID string, params map[string]string) (interface{}, error) {
+	filters := map[string]string{}
+	for k, v := range params {
+		filters[k] = v
+	}
+	filters["highlight_id"] = highlightID
+	return r.store.Select(db.TableHighlightImage, filters, HighlightImage{})
+}
+
+func (r imageRepository) Create(image HighlightImage, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableHighlightImage, image)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r imageRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableHighlightImage, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
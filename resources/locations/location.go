@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/google/uuid"
 )
@@ -21,13 +22,16 @@ type Location struct {
 }
 
 //SaveNew creates a new country or city
-func (l *Location) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (l *Location) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), l)
+	err = json.Unmarshal([]byte(request.Body), l)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -42,41 +46,20 @@ func (l *Location) SaveNew(request events.APIGatewayProxyRequest) (events.APIGat
 	l.Title.Field = "title"
 	l.Title.ParentID = l.ID
 
-	conn, err := db.Connect()
+	err = repo.Create(*l, outbox.NewEvent(EventLocationCreated, l.ID, tokenUser.UserID, l))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Insert(tx, db.TableLocation, *l)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
 	return common.APIResponse(l, http.StatusCreated)
 }
 
 //GetAll returns a list of locations
-func (l *Location) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+func (l *Location) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.Select(session, db.TableLocation, request.QueryStringParameters, Location{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -85,40 +68,27 @@ func (l *Location) GetAll(request events.APIGatewayProxyRequest) (events.APIGate
 }
 
 //Update change location attributes
-func (l *Location) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (l *Location) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
 
-	conn, err := db.Connect()
+	err = repo.Update(request.PathParameters["id"], jsonMap, outbox.NewEvent(EventLocationUpdated, request.PathParameters["id"], tokenUser.UserID, jsonMap))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Update(tx, db.TableLocation, request.PathParameters["id"], *l, jsonMap)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableLocation, request.PathParameters["id"], Location{})
+	result, err := repo.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -127,22 +97,16 @@ func (l *Location) Update(request events.APIGatewayProxyRequest) (events.APIGate
 }
 
 //Delete remove location from the database
-func (l *Location) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (l *Location) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Delete(session, db.TableLocation, request.PathParameters["id"])
+	err = repo.Delete(request.PathParameters["id"], outbox.NewEvent(EventLocationDeleted, request.PathParameters["id"], tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
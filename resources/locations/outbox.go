@@ -0,0 +1,8 @@
+package locations
+
+//Domain events written in the outbox by the locations mutations
+const (
+	EventLocationCreated = "location.created"
+	EventLocationUpdated = "location.updated"
+	EventLocationDeleted = "location.deleted"
+)
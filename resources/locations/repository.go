@@ -0,0 +1,74 @@
+package locations
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//Repository loads and persists the locations
+type Repository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Location, error)
+	Create(location Location, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+type repository struct {
+	store db.Store
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return repository{store: store}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the locations in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+func (r repository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableLocation, params, Location{})
+}
+
+func (r repository) Get(id string) (Location, error) {
+	location := Location{}
+	err := r.store.LoadOne(db.TableLocation, id, &location)
+	return location, err
+}
+
+func (r repository) Create(location Location, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableLocation, location)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableLocation, id, Location{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableLocation, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
@@ -18,3 +18,17 @@ func (t *Translation) IsEmpty() bool {
 	}
 	return false
 }
+
+//Text returns the translation in the language code (pt, es or en), falling back to the first language with text
+func (t *Translation) Text(languageCode string) string {
+	texts := map[string]string{"pt": t.PT, "es": t.ES, "en": t.EN}
+	if text := texts[languageCode]; text != "" {
+		return text
+	}
+	for _, lang := range []string{"en", "pt", "es"} {
+		if texts[lang] != "" {
+			return texts[lang]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,302 @@
+package trips
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	fmt "github.com/feedmytrip/api/resources/events"
+)
+
+//Daily hours of the auto schedule without the day_start and day_end attributes, in seconds from the start of the day
+const (
+	defaultDayStart = 9 * 3600
+	defaultDayEnd   = 21 * 3600
+)
+
+//AutoSchedule represents the placements of the unscheduled events of the itinerary, applied with apply=true.
+//Unplaced has the events without a free slot long enough in any day.
+type AutoSchedule struct {
+	ItineraryID string      `json:"itinerary_id"`
+	DayStart    float64     `json:"day_start"`
+	DayEnd      float64     `json:"day_end"`
+	Applied     bool        `json:"applied"`
+	Placements  []Placement `json:"placements"`
+	Unplaced    []string    `json:"unplaced"`
+}
+
+//Placement represents an unscheduled itinerary event placed in a day of the itinerary, day 1 is the first one
+type Placement struct {
+	EventID     string  `json:"event_id"`
+	CityID      string  `json:"city_id"`
+	Day         int     `json:"day"`
+	BeginOffset float64 `json:"begin_offset"`
+	EndOffset   float64 `json:"end_offset"`
+}
+
+//span represents a period of the itinerary between two offsets, the end is exclusive
+type span struct {
+	begin float64
+	end   float64
+}
+
+//AutoSchedule places the unscheduled events in the free slots of the days of the itinerary between day_start
+//and day_end, out of the existing events and inside the schedules of their global events. The events of the same
+//city are placed on the days already visiting it. The request only returns the preview unless apply=true.
+func (i *Itinerary) AutoSchedule(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	apply := request.QueryStringParameters["apply"] == "true"
+	action := ActionView
+	if apply {
+		action = ActionUpdate
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, action, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	hours := struct {
+		DayStart float64 `json:"day_start"`
+		DayEnd   float64 `json:"day_end"`
+	}{defaultDayStart, defaultDayEnd}
+	if request.Body != "" {
+		err = json.Unmarshal([]byte(request.Body), &hours)
+		if err != nil {
+			return common.APIError(http.StatusBadRequest, err)
+		}
+	}
+	if hours.DayStart < 0 || hours.DayEnd > 86400 || hours.DayStart >= hours.DayEnd {
+		return common.APIError(http.StatusBadRequest, errors.New("invalid day_start and/or day_end, they must be seconds of the day with day_start before day_end"))
+	}
+
+	itineraryEvents, err := repo.ItineraryEvents.All(itinerary.TripID, itinerary.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+	checker := newScheduleChecker(repo, itinerary)
+	for _, e := range itineraryEvents {
+		if e.BeginOffset == -1 && e.GlobalEventID != "" {
+			_, err = checker.availability(e.GlobalEventID)
+			if err != nil {
+				return common.APIError(http.StatusInternalServerError, err)
+			}
+		}
+	}
+
+	result := autoSchedule(itinerary, itineraryEvents, hours.DayStart, hours.DayEnd, checker.availabilities)
+	if !apply || len(result.Placements) == 0 {
+		return common.APIResponse(result, http.StatusOK)
+	}
+
+	versions := map[string]int64{}
+	for _, e := range itineraryEvents {
+		versions[e.ID] = e.Version
+	}
+	updates := map[string]EventChange{}
+	for _, p := range result.Placements {
+		jsonMap := make(map[string]interface{})
+		jsonMap["trip_id"] = itinerary.TripID
+		jsonMap["itinerary_id"] = itinerary.ID
+		jsonMap["id"] = p.EventID
+		jsonMap["begin_offset"] = p.BeginOffset
+		jsonMap["updated_by"] = tokenUser.UserID
+		jsonMap["updated_date"] = time.Now()
+		updates[p.EventID] = EventChange{Version: versions[p.EventID], Values: jsonMap}
+	}
+	scheduled := tripEvent(EventItineraryScheduled, itinerary.ID, itinerary.TripID, tokenUser.UserID, map[string]interface{}{
+		"placements": result.Placements,
+	})
+
+	err = repo.ItineraryEvents.UpdateMany(updates, scheduled)
+	if err == db.ErrVersionConflict {
+		//the events scheduled by another request conflict with the placements, other changes fail the precondition
+		for _, p := range result.Placements {
+			current, err := repo.ItineraryEvents.Get(p.EventID)
+			if err == nil && current.BeginOffset != -1 {
+				return common.APIError(http.StatusConflict, errors.New("the itinerary event "+p.EventID+" was scheduled by another request"))
+			}
+		}
+	}
+	if err != nil {
+		return updateError(err)
+	}
+
+	result.Applied = true
+	return common.APIResponse(result, http.StatusOK)
+}
+
+//autoSchedule places the events with begin_offset -1 in the earliest free slot of the days, the ones of a city
+//first on the days with events of the same city, then on the days without cities and at last on the others
+func autoSchedule(itinerary Itinerary, itineraryEvents []ItineraryEvent, dayStart, dayEnd float64, availabilities map[string]fmt.Availability) AutoSchedule {
+	result := AutoSchedule{
+		ItineraryID: itinerary.ID,
+		DayStart:    dayStart,
+		DayEnd:      dayEnd,
+		Placements:  []Placement{},
+		Unplaced:    []string{},
+	}
+	days := int(itineraryLength(itinerary) / 86400)
+	busy := make([][]span, days)
+	cities := make([]map[string]bool, days)
+	for day := range cities {
+		cities[day] = map[string]bool{}
+	}
+	occupy := func(e ItineraryEvent, begin float64) {
+		end := begin + float64(e.Duration)
+		first, last := int(begin/86400), int(math.Ceil(end/86400))-1
+		for day := first; day < days && (day <= last || day == first); day++ {
+			busy[day] = append(busy[day], span{begin: begin, end: end})
+			if e.CityID != "" {
+				cities[day][e.CityID] = true
+			}
+		}
+	}
+
+	unscheduled := []ItineraryEvent{}
+	for _, e := range itineraryEvents {
+		switch {
+		case e.BeginOffset == -1:
+			unscheduled = append(unscheduled, e)
+		case e.BeginOffset >= 0 && e.BeginOffset < float64(days)*86400:
+			occupy(e, e.BeginOffset)
+		}
+	}
+	sort.SliceStable(unscheduled, func(a, b int) bool {
+		if unscheduled[a].CityID != unscheduled[b].CityID {
+			return unscheduled[a].CityID < unscheduled[b].CityID
+		}
+		return unscheduled[a].CreatedDate.Before(unscheduled[b].CreatedDate)
+	})
+
+	for _, e := range unscheduled {
+		placed := false
+		for _, day := range dayOrder(cities, e.CityID) {
+			from := float64(day)*86400 + dayStart
+			free := freeSpans(span{begin: from, end: float64(day)*86400 + dayEnd}, busy[day])
+			if availability, ok := availabilities[e.GlobalEventID]; ok {
+				free = intersectSpans(free, openSpans(availability, dayZero(itinerary), from, float64(day)*86400+dayEnd))
+			}
+			begin, ok := firstFit(free, float64(e.Duration))
+			if !ok {
+				continue
+			}
+			occupy(e, begin)
+			result.Placements = append(result.Placements, Placement{
+				EventID:     e.ID,
+				CityID:      e.CityID,
+				Day:         day + 1,
+				BeginOffset: begin,
+				EndOffset:   begin + float64(e.Duration),
+			})
+			placed = true
+			break
+		}
+		if !placed {
+			result.Unplaced = append(result.Unplaced, e.ID)
+		}
+	}
+	return result
+}
+
+//dayOrder returns the days to try for an event of the city, the events without city try the days in order
+func dayOrder(cities []map[string]bool, cityID string) []int {
+	order := []int{}
+	if cityID == "" {
+		for day := range cities {
+			order = append(order, day)
+		}
+		return order
+	}
+	others := []int{}
+	for day := range cities {
+		if cities[day][cityID] {
+			order = append(order, day)
+		}
+	}
+	for day := range cities {
+		switch {
+		case cities[day][cityID]:
+		case len(cities[day]) == 0:
+			order = append(order, day)
+		default:
+			others = append(others, day)
+		}
+	}
+	return append(order, others...)
+}
+
+//freeSpans returns the parts of the period out of the busy spans
+func freeSpans(period span, busy []span) []span {
+	sort.Slice(busy, func(a, b int) bool {
+		return busy[a].begin < busy[b].begin
+	})
+	free := []span{}
+	for _, b := range busy {
+		if b.end <= period.begin || b.begin >= period.end {
+			continue
+		}
+		if b.begin > period.begin {
+			free = append(free, span{begin: period.begin, end: b.begin})
+		}
+		if b.end > period.begin {
+			period.begin = b.end
+		}
+	}
+	if period.begin < period.end {
+		free = append(free, period)
+	}
+	return free
+}
+
+//openSpans returns the open windows of the availability between the offsets from the start of the first day
+func openSpans(availability fmt.Availability, start time.Time, from, to float64) []span {
+	spans := []span{}
+	windows := availability.Windows(start.Add(time.Duration(from)*time.Second), start.Add(time.Duration(to)*time.Second), 0)
+	for _, w := range windows {
+		spans = append(spans, span{begin: w.Start.Sub(start).Seconds(), end: w.End.Sub(start).Seconds()})
+	}
+	return spans
+}
+
+//intersectSpans returns the periods in both lists, each one sorted without overlaps
+func intersectSpans(a, b []span) []span {
+	result := []span{}
+	for _, x := range a {
+		for _, y := range b {
+			s := span{begin: x.begin, end: x.end}
+			if y.begin > s.begin {
+				s.begin = y.begin
+			}
+			if y.end < s.end {
+				s.end = y.end
+			}
+			if s.begin < s.end {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+//firstFit returns the begin of the first span long enough for the duration
+func firstFit(spans []span, duration float64) (float64, bool) {
+	for _, s := range spans {
+		if s.end-s.begin >= duration {
+			return s.begin, true
+		}
+	}
+	return 0, false
+}
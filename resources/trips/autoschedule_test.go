@@ -0,0 +1,53 @@
+package trips
+
+import (
+	"testing"
+	"time"
+
+	fmt "github.com/feedmytrip/api/resources/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoSchedule(t *testing.T) {
+	//thursday and friday
+	start := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+	itinerary := Itinerary{ID: "itinerary", StartDate: start, EndDate: start.AddDate(0, 0, 1)}
+	created := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	itineraryEvents := []ItineraryEvent{
+		{ID: "fixed", CityID: "rome", BeginOffset: 32400, Duration: 7200},
+		{ID: "paris", CityID: "paris", BeginOffset: -1, Duration: 10800, CreatedDate: created(1)},
+		{ID: "rome", CityID: "rome", BeginOffset: -1, Duration: 10800, CreatedDate: created(2)},
+		{ID: "museum", GlobalEventID: "museum", BeginOffset: -1, Duration: 7200, CreatedDate: created(3)},
+		{ID: "long", BeginOffset: -1, Duration: 50400, CreatedDate: created(4)},
+		{ID: "free", BeginOffset: -1, Duration: 3600, CreatedDate: created(5)},
+	}
+	availabilities := map[string]fmt.Availability{
+		//the museum opens only on fridays after 14:00
+		"museum": fmt.NewAvailability([]fmt.Schedule{{StartDate: start.Add(14 * time.Hour), EndDate: start.AddDate(0, 1, 0).Add(18 * time.Hour), WeekDays: "0000010"}}),
+	}
+
+	result := autoSchedule(itinerary, itineraryEvents, 32400, 75600, availabilities)
+	assert.Equal(t, "itinerary", result.ItineraryID)
+	assert.Equal(t, []Placement{
+		{EventID: "museum", Day: 2, BeginOffset: 136800, EndOffset: 144000},
+		{EventID: "free", Day: 1, BeginOffset: 39600, EndOffset: 43200},
+		//paris goes to the day without cities and rome to the day visiting it
+		{EventID: "paris", CityID: "paris", Day: 2, BeginOffset: 118800, EndOffset: 129600},
+		{EventID: "rome", CityID: "rome", Day: 1, BeginOffset: 43200, EndOffset: 54000},
+	}, result.Placements)
+	assert.Equal(t, []string{"long"}, result.Unplaced)
+
+	//the offsets count from the midnight of a start_date later in the day
+	itinerary.StartDate = start.Add(15 * time.Hour)
+	itinerary.EndDate = itinerary.StartDate.AddDate(0, 0, 1)
+	later := autoSchedule(itinerary, itineraryEvents, 32400, 75600, availabilities)
+	assert.Equal(t, result.Placements, later.Placements)
+}
+
+func TestFreeSpans(t *testing.T) {
+	busy := []span{{begin: 50, end: 60}, {begin: 0, end: 20}, {begin: 15, end: 30}, {begin: 90, end: 120}}
+	assert.Equal(t, []span{{begin: 30, end: 50}, {begin: 60, end: 90}}, freeSpans(span{begin: 10, end: 100}, busy))
+	assert.Equal(t, []span{{begin: 0, end: 10}}, freeSpans(span{begin: 0, end: 10}, nil))
+}
@@ -0,0 +1,143 @@
+package trips
+
+import (
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	fmt "github.com/feedmytrip/api/resources/events"
+)
+
+//Codes of the availability warnings of the itinerary events
+const (
+	WarningEventClosed        = "event_closed"
+	WarningEventPartiallyOpen = "event_partially_open"
+)
+
+//AvailabilityWarning represents a placement of the itinerary event out of the open windows of its global event,
+//windows has the open windows between the start and the end of the placement
+type AvailabilityWarning struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Start   time.Time    `json:"start"`
+	End     time.Time    `json:"end"`
+	Windows []fmt.Window `json:"windows"`
+}
+
+//PlacementError is returned by the strict requests placing the itinerary event out of the global event schedules
+type PlacementError struct {
+	Warnings []AvailabilityWarning `json:"warnings"`
+}
+
+func (e *PlacementError) Error() string {
+	return "the global event isn't open during the itinerary event"
+}
+
+//Detail returns the attributes used in the api error response
+func (e *PlacementError) Detail() interface{} {
+	return e
+}
+
+//scheduleChecker computes the availability warnings of the itinerary events, each itinerary and the schedules of
+//each global event are loaded once
+type scheduleChecker struct {
+	repo           Repository
+	itineraries    map[string]Itinerary
+	availabilities map[string]fmt.Availability
+}
+
+func newScheduleChecker(repo Repository, itineraries ...Itinerary) *scheduleChecker {
+	c := &scheduleChecker{
+		repo:           repo,
+		itineraries:    map[string]Itinerary{},
+		availabilities: map[string]fmt.Availability{},
+	}
+	for _, itinerary := range itineraries {
+		c.itineraries[itinerary.ID] = itinerary
+	}
+	return c
+}
+
+//check returns the warnings of the itinerary event placed from the midnight of the itinerary start_date plus the
+//begin_offset during the duration, both in seconds. The events without global event or begin_offset aren't checked.
+func (c *scheduleChecker) check(e ItineraryEvent) ([]AvailabilityWarning, error) {
+	if e.GlobalEventID == "" || e.BeginOffset < 0 {
+		return nil, nil
+	}
+	itinerary, ok := c.itineraries[e.ItineraryID]
+	if !ok {
+		var err error
+		itinerary, err = c.repo.Itineraries.Get(e.ItineraryID)
+		if err == db.ErrNotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.itineraries[e.ItineraryID] = itinerary
+	}
+	availability, err := c.availability(e.GlobalEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := dayZero(itinerary).Add(time.Duration(e.BeginOffset * float64(time.Second)))
+	end := start.Add(time.Duration(e.Duration) * time.Second)
+	if !end.After(start) {
+		if availability.IsOpen(start) {
+			return nil, nil
+		}
+		return []AvailabilityWarning{closedWarning(start, end)}, nil
+	}
+
+	windows := availability.Windows(start, end, 0)
+	switch {
+	case len(windows) == 0:
+		return []AvailabilityWarning{closedWarning(start, end)}, nil
+	case len(windows) == 1 && windows[0].Start.Equal(start) && windows[0].End.Equal(end):
+		return nil, nil
+	}
+	return []AvailabilityWarning{{
+		Code:    WarningEventPartiallyOpen,
+		Message: "the global event is closed during part of the itinerary event",
+		Start:   start,
+		End:     end,
+		Windows: windows,
+	}}, nil
+}
+
+//availability returns the availability defined by the schedules of the global event
+func (c *scheduleChecker) availability(globalEventID string) (fmt.Availability, error) {
+	availability, ok := c.availabilities[globalEventID]
+	if ok {
+		return availability, nil
+	}
+	schedules, err := c.repo.GlobalSchedules.All(globalEventID)
+	if err != nil {
+		return availability, err
+	}
+	availability = fmt.NewAvailability(schedules)
+	c.availabilities[globalEventID] = availability
+	return availability, nil
+}
+
+//withWarnings sets the availability warnings of the itinerary events
+func (c *scheduleChecker) withWarnings(itineraryEvents []ItineraryEvent) error {
+	for i := range itineraryEvents {
+		warnings, err := c.check(itineraryEvents[i])
+		if err != nil {
+			return err
+		}
+		itineraryEvents[i].Warnings = warnings
+	}
+	return nil
+}
+
+func closedWarning(start, end time.Time) AvailabilityWarning {
+	return AvailabilityWarning{
+		Code:    WarningEventClosed,
+		Message: "the global event is closed during the itinerary event",
+		Start:   start,
+		End:     end,
+		Windows: []fmt.Window{},
+	}
+}
@@ -0,0 +1,30 @@
+package trips
+
+import (
+	"testing"
+	"time"
+
+	fmt "github.com/feedmytrip/api/resources/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleCheckerStartOfDay(t *testing.T) {
+	//the itinerary starts at 15:30, the offsets still count from midnight
+	start := time.Date(2019, 8, 1, 15, 30, 0, 0, time.UTC)
+	itinerary := Itinerary{ID: "itinerary", StartDate: start, EndDate: start.AddDate(0, 0, 1)}
+	c := newScheduleChecker(NewMemoryRepository(), itinerary)
+	midnight := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+	//the museum opens every day from 09:00 until 12:00
+	c.availabilities["museum"] = fmt.NewAvailability([]fmt.Schedule{{StartDate: midnight.Add(9 * time.Hour), EndDate: midnight.AddDate(0, 1, 0).Add(12 * time.Hour), WeekDays: "1111111"}})
+
+	warnings, err := c.check(ItineraryEvent{ItineraryID: "itinerary", GlobalEventID: "museum", BeginOffset: 36000, Duration: 3600})
+	assert.Nil(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = c.check(ItineraryEvent{ItineraryID: "itinerary", GlobalEventID: "museum", BeginOffset: 127800, Duration: 3600})
+	assert.Nil(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, WarningEventPartiallyOpen, warnings[0].Code)
+		assert.Equal(t, midnight.AddDate(0, 0, 1).Add(690*time.Minute), warnings[0].Start)
+	}
+}
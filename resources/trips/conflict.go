@@ -0,0 +1,170 @@
+package trips
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+)
+
+//EventOverlap represents two itinerary events scheduled at the same time, the offsets limit the shared period
+type EventOverlap struct {
+	EventIDs    []string `json:"event_ids"`
+	BeginOffset float64  `json:"begin_offset"`
+	EndOffset   float64  `json:"end_offset"`
+}
+
+//ItineraryConflicts represents the scheduling problems of the itinerary events: the overlapping pairs, the events
+//out of the days of the itinerary and the events without begin_offset
+type ItineraryConflicts struct {
+	ItineraryID string           `json:"itinerary_id"`
+	Overlaps    []EventOverlap   `json:"overlaps"`
+	OutOfRange  []ItineraryEvent `json:"out_of_range"`
+	Unscheduled []ItineraryEvent `json:"unscheduled"`
+}
+
+//ConflictError is returned by the requests checking the conflicts of the itinerary event they save
+type ConflictError struct {
+	Overlaps   []EventOverlap   `json:"overlaps"`
+	OutOfRange []ItineraryEvent `json:"out_of_range"`
+}
+
+func (e *ConflictError) Error() string {
+	return "the itinerary event conflicts with the itinerary schedule"
+}
+
+//Detail returns the attributes used in the api error response
+func (e *ConflictError) Detail() interface{} {
+	return e
+}
+
+//Conflicts returns the overlapping events, the events out of the itinerary days and the unscheduled ones
+func (i *Itinerary) Conflicts(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+
+	itineraryEvents, err := repo.ItineraryEvents.All(itinerary.TripID, itinerary.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(findConflicts(itinerary, itineraryEvents), http.StatusOK)
+}
+
+//findConflicts checks the itinerary events, the scheduled ones are placed from begin_offset during duration seconds
+func findConflicts(itinerary Itinerary, itineraryEvents []ItineraryEvent) ItineraryConflicts {
+	result := ItineraryConflicts{
+		ItineraryID: itinerary.ID,
+		Overlaps:    []EventOverlap{},
+		OutOfRange:  []ItineraryEvent{},
+		Unscheduled: []ItineraryEvent{},
+	}
+	length := itineraryLength(itinerary)
+
+	scheduled := []ItineraryEvent{}
+	for _, e := range itineraryEvents {
+		switch {
+		case e.BeginOffset == -1:
+			result.Unscheduled = append(result.Unscheduled, e)
+			continue
+		case e.BeginOffset < 0 || e.endOffset() > length:
+			result.OutOfRange = append(result.OutOfRange, e)
+		}
+		scheduled = append(scheduled, e)
+	}
+
+	sort.SliceStable(scheduled, func(a, b int) bool {
+		return scheduled[a].BeginOffset < scheduled[b].BeginOffset
+	})
+	for a := range scheduled {
+		for b := a + 1; b < len(scheduled) && scheduled[b].BeginOffset < scheduled[a].endOffset(); b++ {
+			end := math.Min(scheduled[a].endOffset(), scheduled[b].endOffset())
+			if end <= scheduled[b].BeginOffset {
+				continue
+			}
+			result.Overlaps = append(result.Overlaps, EventOverlap{
+				EventIDs:    []string{scheduled[a].ID, scheduled[b].ID},
+				BeginOffset: scheduled[b].BeginOffset,
+				EndOffset:   end,
+			})
+		}
+	}
+	return result
+}
+
+//eventConflicts returns the conflicts of the itinerary event saved with the values, nil when there aren't any
+func eventConflicts(repo Repository, e ItineraryEvent, values map[string]interface{}) (*ConflictError, error) {
+	if val, ok := values["begin_offset"].(float64); ok {
+		e.BeginOffset = val
+	}
+	if val, ok := values["duration"].(float64); ok {
+		e.Duration = int(val)
+	}
+
+	itinerary, err := repo.Itineraries.Get(e.ItineraryID)
+	if err != nil {
+		return nil, err
+	}
+	itineraryEvents, err := repo.ItineraryEvents.All(e.TripID, e.ItineraryID)
+	if err != nil {
+		return nil, err
+	}
+	placed := []ItineraryEvent{e}
+	for _, itineraryEvent := range itineraryEvents {
+		if itineraryEvent.ID != e.ID {
+			placed = append(placed, itineraryEvent)
+		}
+	}
+
+	conflicts := findConflicts(itinerary, placed)
+	result := &ConflictError{Overlaps: []EventOverlap{}, OutOfRange: []ItineraryEvent{}}
+	for _, overlap := range conflicts.Overlaps {
+		if overlap.EventIDs[0] == e.ID || overlap.EventIDs[1] == e.ID {
+			result.Overlaps = append(result.Overlaps, overlap)
+		}
+	}
+	for _, outOfRange := range conflicts.OutOfRange {
+		if outOfRange.ID == e.ID {
+			result.OutOfRange = append(result.OutOfRange, outOfRange)
+		}
+	}
+	if len(result.Overlaps) == 0 && len(result.OutOfRange) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+//endOffset returns the offset in seconds when the itinerary event ends
+func (e ItineraryEvent) endOffset() float64 {
+	return e.BeginOffset + float64(e.Duration)
+}
+
+//itineraryLength returns the seconds from the start of the first day until the end of the last day of the itinerary,
+//the calendar days are counted in UTC so the daylight saving changes don't shorten them
+func itineraryLength(itinerary Itinerary) float64 {
+	start := dayZero(itinerary)
+	year, month, day := itinerary.EndDate.In(start.Location()).Date()
+	last := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	return (last.Sub(first).Hours()/24 + 1) * 86400
+}
+
+//dayZero returns the start of the day of the itinerary start_date, the begin offsets are counted from it
+func dayZero(itinerary Itinerary) time.Time {
+	year, month, day := itinerary.StartDate.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, itinerary.StartDate.Location())
+}
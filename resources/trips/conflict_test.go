@@ -0,0 +1,54 @@
+package trips
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindConflicts(t *testing.T) {
+	start := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+	//two days, from offset 0 until 172800
+	itinerary := Itinerary{ID: "itinerary", StartDate: start, EndDate: start.AddDate(0, 0, 1)}
+	itineraryEvents := []ItineraryEvent{
+		{ID: "late", BeginOffset: 165600, Duration: 21600},
+		{ID: "morning", BeginOffset: 32400, Duration: 10800},
+		{ID: "lunch", BeginOffset: 39600, Duration: 7200},
+		{ID: "afternoon", BeginOffset: 46800, Duration: 3600},
+		{ID: "instant", BeginOffset: 50400, Duration: 0},
+		{ID: "unscheduled", BeginOffset: -1, Duration: 21600},
+	}
+
+	result := findConflicts(itinerary, itineraryEvents)
+	assert.Equal(t, "itinerary", result.ItineraryID)
+	assert.Equal(t, []EventOverlap{{EventIDs: []string{"morning", "lunch"}, BeginOffset: 39600, EndOffset: 43200}}, result.Overlaps)
+	if assert.Len(t, result.OutOfRange, 1) {
+		assert.Equal(t, "late", result.OutOfRange[0].ID)
+	}
+	if assert.Len(t, result.Unscheduled, 1) {
+		assert.Equal(t, "unscheduled", result.Unscheduled[0].ID)
+	}
+
+	//an event inside another overlaps it and the following ones
+	itineraryEvents = append(itineraryEvents, ItineraryEvent{ID: "day", BeginOffset: 28800, Duration: 36000})
+	result = findConflicts(itinerary, itineraryEvents)
+	ids := [][]string{}
+	for _, overlap := range result.Overlaps {
+		ids = append(ids, overlap.EventIDs)
+	}
+	assert.Equal(t, [][]string{{"day", "morning"}, {"day", "lunch"}, {"day", "afternoon"}, {"morning", "lunch"}}, ids)
+}
+
+func TestItineraryLength(t *testing.T) {
+	start := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, float64(86400), itineraryLength(Itinerary{StartDate: start, EndDate: start}))
+	assert.Equal(t, float64(172800), itineraryLength(Itinerary{StartDate: start, EndDate: start.AddDate(0, 0, 1)}))
+
+	//the days count from the midnight of a start_date later in the day
+	afternoon := start.Add(15*time.Hour + 30*time.Minute)
+	itinerary := Itinerary{ID: "itinerary", StartDate: afternoon, EndDate: start.AddDate(0, 0, 1).Add(10 * time.Hour)}
+	assert.Equal(t, float64(172800), itineraryLength(itinerary))
+	result := findConflicts(itinerary, []ItineraryEvent{{ID: "second_day", BeginOffset: 129600, Duration: 3600}})
+	assert.Empty(t, result.OutOfRange)
+}
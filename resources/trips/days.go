@@ -0,0 +1,182 @@
+package trips
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+)
+
+//DayChange represents the data of the itinerary.days_reordered, itinerary.day_inserted and itinerary.day_deleted
+//events, days has the new order of the days and day the inserted or deleted one
+type DayChange struct {
+	TripID      string    `json:"trip_id"`
+	ItineraryID string    `json:"itinerary_id"`
+	Days        []int     `json:"days,omitempty"`
+	Day         int       `json:"day,omitempty"`
+	EndDate     time.Time `json:"end_date"`
+	EventIDs    []string  `json:"event_ids"`
+}
+
+//ReorderDays moves the events of each day of the itinerary to the position of the day in the days body attribute,
+//a permutation of all day numbers. The events keep their time of the day and duration.
+func (i *Itinerary) ReorderDays(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	return shiftDays(request, repo, EventItineraryReordered, func(itinerary Itinerary, days int) (func(day int) int, DayChange, error) {
+		body := struct {
+			Days []int `json:"days"`
+		}{}
+		err := json.Unmarshal([]byte(request.Body), &body)
+		if err != nil {
+			return nil, DayChange{}, err
+		}
+		if len(body.Days) != days {
+			return nil, DayChange{}, errors.New("invalid days body attribute, it must have every day of the itinerary once")
+		}
+		position := map[int]int{}
+		for idx, day := range body.Days {
+			if _, ok := position[day]; ok || day <= 0 || day > days {
+				return nil, DayChange{}, errors.New("invalid days body attribute, it must have every day of the itinerary once")
+			}
+			position[day] = idx + 1
+		}
+		target := func(day int) int {
+			if day > days {
+				return day
+			}
+			return position[day]
+		}
+		return target, DayChange{Days: body.Days, EndDate: itinerary.EndDate}, nil
+	})
+}
+
+//InsertDay adds an empty day in the position of the day body attribute, from 1 until the day after the last one,
+//moving the following days forward and extending the itinerary end_date
+func (i *Itinerary) InsertDay(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	return shiftDays(request, repo, EventItineraryDayInserted, func(itinerary Itinerary, days int) (func(day int) int, DayChange, error) {
+		body := struct {
+			Day int `json:"day"`
+		}{}
+		err := json.Unmarshal([]byte(request.Body), &body)
+		if err != nil {
+			return nil, DayChange{}, err
+		}
+		if body.Day <= 0 || body.Day > days+1 {
+			return nil, DayChange{}, errors.New("invalid day body attribute")
+		}
+		target := func(day int) int {
+			if day >= body.Day {
+				return day + 1
+			}
+			return day
+		}
+		return target, DayChange{Day: body.Day, EndDate: itinerary.EndDate.AddDate(0, 0, 1)}, nil
+	})
+}
+
+//DeleteDay removes the day of the path from the itinerary, its events become unscheduled, the following days move
+//backward and the itinerary end_date shrinks
+func (i *Itinerary) DeleteDay(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	return shiftDays(request, repo, EventItineraryDayDeleted, func(itinerary Itinerary, days int) (func(day int) int, DayChange, error) {
+		deleted, err := strconv.Atoi(request.PathParameters["day"])
+		if err != nil || deleted <= 0 || deleted > days {
+			return nil, DayChange{}, errors.New("invalid day path param")
+		}
+		if days == 1 {
+			return nil, DayChange{}, errors.New("the itinerary must have at least one day")
+		}
+		target := func(day int) int {
+			switch {
+			case day == deleted:
+				return 0
+			case day > deleted:
+				return day - 1
+			}
+			return day
+		}
+		return target, DayChange{Day: deleted, EndDate: itinerary.EndDate.AddDate(0, 0, -1)}, nil
+	})
+}
+
+//dayTarget validates the request changing the days of the itinerary with the number of days, the returned func
+//gives the new day of the events of each day and zero to unschedule them
+type dayTarget func(itinerary Itinerary, days int) (func(day int) int, DayChange, error)
+
+//shiftDays moves the events of the itinerary by whole days and updates its end_date in a single transaction, the
+//versions of the itinerary and of the moved events guard against concurrent changes
+func shiftDays(request events.APIGatewayProxyRequest, repo Repository, eventType string, target dayTarget) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionUpdate, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	newDay, change, err := target(itinerary, int(itineraryLength(itinerary)/86400))
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+
+	itineraryEvents, err := repo.ItineraryEvents.All(itinerary.TripID, itinerary.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	updates := map[string]EventChange{}
+	for _, e := range itineraryEvents {
+		if e.BeginOffset < 0 {
+			continue
+		}
+		day := int(e.BeginOffset/86400) + 1
+		moved := newDay(day)
+		if moved == day {
+			continue
+		}
+		jsonMap := make(map[string]interface{})
+		jsonMap["trip_id"] = e.TripID
+		jsonMap["itinerary_id"] = e.ItineraryID
+		jsonMap["id"] = e.ID
+		jsonMap["begin_offset"] = e.BeginOffset + float64(moved-day)*86400
+		if moved == 0 {
+			jsonMap["begin_offset"] = float64(-1)
+		}
+		jsonMap["updated_by"] = tokenUser.UserID
+		jsonMap["updated_date"] = time.Now()
+		updates[e.ID] = EventChange{Version: e.Version, Values: jsonMap}
+	}
+
+	change.TripID = itinerary.TripID
+	change.ItineraryID = itinerary.ID
+	change.EventIDs = []string{}
+	for id := range updates {
+		change.EventIDs = append(change.EventIDs, id)
+	}
+	sort.Strings(change.EventIDs)
+
+	values := map[string]interface{}{
+		"end_date":     change.EndDate,
+		"updated_by":   tokenUser.UserID,
+		"updated_date": time.Now(),
+	}
+	err = repo.Itineraries.ShiftDays(itinerary.ID, itinerary.Version, values, updates, tripEvent(eventType, itinerary.ID, itinerary.TripID, tokenUser.UserID, change))
+	if err != nil {
+		return updateError(err)
+	}
+
+	result, err := repo.Itineraries.Get(itinerary.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
+}
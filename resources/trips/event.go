@@ -9,165 +9,151 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
-	fmt "github.com/feedmytrip/api/resources/events"
 	"github.com/feedmytrip/api/resources/shared"
-	"github.com/gocraft/dbr"
 	"github.com/google/uuid"
 )
 
 //ItineraryEvent represents an event inside a trip itinerary
 type ItineraryEvent struct {
-	ID                  string             `json:"id" db:"id" lock:"true"`
-	TripID              string             `json:"trip_id" db:"trip_id" lock:"true"`
-	ItineraryID         string             `json:"itinerary_id" db:"itinerary_id" lock:"true"`
-	GlobalEventID       string             `json:"global_event_id" db:"global_event_id" lock:"true"`
-	Title               shared.Translation `json:"title" table:"translation" alias:"title" on:"title.parent_id = trip_itinerary_event.id and title.field = 'title'" embedded:"true" persist:"true"`
-	Description         shared.Translation `json:"description" table:"translation" alias:"description" on:"description.parent_id = trip_itinerary_event.id and description.field = 'description'" embedded:"true" persist:"true"`
-	BeginOffset         float64            `json:"begin_offset" db:"begin_offset"`
-	Duration            int                `json:"duration" db:"duration"`
-	MainCategoryID      string             `json:"main_category_id" db:"main_category_id"`
-	MainCategory        shared.Translation `json:"main_category" table:"translation" alias:"main_category" on:"main_category.parent_id = trip_itinerary_event.main_category_id and main_category.field = 'title'" embedded:"true"`
-	SecondaryCategoryID string             `json:"secondary_category_id" db:"secondary_category_id"`
-	SecondaryCategory   shared.Translation `json:"secondary_category" table:"translation" alias:"secondary_category" on:"secondary_category.parent_id = trip_itinerary_event.secondary_category_id and secondary_category.field = 'title'" embedded:"true"`
-	CountryID           string             `json:"country_id" db:"country_id"`
-	Country             shared.Translation `json:"country" table:"translation" alias:"country" on:"country.parent_id = trip_itinerary_event.country_id and country.field = 'title'" embedded:"true"`
-	RegionID            string             `json:"region_id" db:"region_id"`
-	Region              shared.Translation `json:"region" table:"translation" alias:"region" on:"region.parent_id = trip_itinerary_event.region_id and region.field = 'title'" embedded:"true"`
-	CityID              string             `json:"city_id" db:"city_id"`
-	City                shared.Translation `json:"city" table:"translation" alias:"city" on:"city.parent_id = trip_itinerary_event.city_id and city.field = 'title'" embedded:"true"`
-	Address             string             `json:"address" db:"address"`
-	CreatedBy           string             `json:"created_by" db:"created_by" lock:"true"`
-	CreatedDate         time.Time          `json:"created_date" db:"created_date" lock:"true"`
-	UpdatedBy           string             `json:"updated_by" db:"updated_by"`
-	UpdatedDate         time.Time          `json:"updated_date" db:"updated_date"`
-	EvaluatedBy         string             `json:"evaluated_by" db:"evaluated_by"`
-	EvaluatedDate       time.Time          `json:"evaluated_date" db:"evaluated_date"`
-	EvaluatedComment    string             `json:"evaluated_comment" db:"evaluated_comment"`
-	CreatedUser         shared.User        `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip_itinerary_event.created_by" embedded:"true"`
-	UpdatedUser         shared.User        `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = trip_itinerary_event.updated_by" embedded:"true"`
-	EvaluatedUser       shared.User        `json:"evaluated_user" table:"user" alias:"evaluated_user" on:"evaluated_user.id = trip_itinerary_event.evaluated_by" embedded:"true"`
+	ID                  string                `json:"id" db:"id" lock:"true"`
+	TripID              string                `json:"trip_id" db:"trip_id" lock:"true"`
+	ItineraryID         string                `json:"itinerary_id" db:"itinerary_id" lock:"true"`
+	GlobalEventID       string                `json:"global_event_id" db:"global_event_id" lock:"true"`
+	Title               shared.Translation    `json:"title" table:"translation" alias:"title" on:"title.parent_id = trip_itinerary_event.id and title.field = 'title'" embedded:"true" persist:"true"`
+	Description         shared.Translation    `json:"description" table:"translation" alias:"description" on:"description.parent_id = trip_itinerary_event.id and description.field = 'description'" embedded:"true" persist:"true"`
+	BeginOffset         float64               `json:"begin_offset" db:"begin_offset"`
+	Duration            int                   `json:"duration" db:"duration"`
+	MainCategoryID      string                `json:"main_category_id" db:"main_category_id"`
+	MainCategory        shared.Translation    `json:"main_category" table:"translation" alias:"main_category" on:"main_category.parent_id = trip_itinerary_event.main_category_id and main_category.field = 'title'" embedded:"true"`
+	SecondaryCategoryID string                `json:"secondary_category_id" db:"secondary_category_id"`
+	SecondaryCategory   shared.Translation    `json:"secondary_category" table:"translation" alias:"secondary_category" on:"secondary_category.parent_id = trip_itinerary_event.secondary_category_id and secondary_category.field = 'title'" embedded:"true"`
+	CountryID           string                `json:"country_id" db:"country_id"`
+	Country             shared.Translation    `json:"country" table:"translation" alias:"country" on:"country.parent_id = trip_itinerary_event.country_id and country.field = 'title'" embedded:"true"`
+	RegionID            string                `json:"region_id" db:"region_id"`
+	Region              shared.Translation    `json:"region" table:"translation" alias:"region" on:"region.parent_id = trip_itinerary_event.region_id and region.field = 'title'" embedded:"true"`
+	CityID              string                `json:"city_id" db:"city_id"`
+	City                shared.Translation    `json:"city" table:"translation" alias:"city" on:"city.parent_id = trip_itinerary_event.city_id and city.field = 'title'" embedded:"true"`
+	Address             string                `json:"address" db:"address"`
+	CreatedBy           string                `json:"created_by" db:"created_by" lock:"true"`
+	CreatedDate         time.Time             `json:"created_date" db:"created_date" lock:"true"`
+	UpdatedBy           string                `json:"updated_by" db:"updated_by"`
+	UpdatedDate         time.Time             `json:"updated_date" db:"updated_date"`
+	EvaluatedBy         string                `json:"evaluated_by" db:"evaluated_by"`
+	EvaluatedDate       time.Time             `json:"evaluated_date" db:"evaluated_date"`
+	EvaluatedComment    string                `json:"evaluated_comment" db:"evaluated_comment"`
+	Version             int64                 `json:"version" db:"version" lock:"true"`
+	CreatedUser         shared.User           `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip_itinerary_event.created_by" embedded:"true"`
+	UpdatedUser         shared.User           `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = trip_itinerary_event.updated_by" embedded:"true"`
+	EvaluatedUser       shared.User           `json:"evaluated_user" table:"user" alias:"evaluated_user" on:"evaluated_user.id = trip_itinerary_event.evaluated_by" embedded:"true"`
+	Warnings            []AvailabilityWarning `json:"warnings,omitempty"`
 }
 
 //Get return an itinerary event
-func (e *ItineraryEvent) Get(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (e *ItineraryEvent) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-		)
-		table := db.TableTripParticipant
-		total, err := db.Validate(session, []string{"count(id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to view this events"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	result, err := db.QueryOne(session, db.TableTripItineraryEvent, request.PathParameters["event_id"], ItineraryEvent{})
+	//the events of soft deleted itineraries are not found
+	_, err = tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	result, err := tripItineraryEvent(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"], request.PathParameters["event_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	result.Warnings, err = newScheduleChecker(repo).check(result)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIConditionalResponse(request, result, result.Version)
 }
 
 //GetAll returns all itinerary events available in the database
-func (e *ItineraryEvent) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (e *ItineraryEvent) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-		)
-		table := db.TableTripParticipant
-		total, err := db.Validate(session, []string{"count(id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to view this events"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
+	}
+	_, err = tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
 	}
 
 	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"trip_id":      request.PathParameters["id"],
-			"itinerary_id": request.PathParameters["itinerary_id"],
-		}
-	} else if request.QueryStringParameters["all"] != "true" && !tokenUser.IsAdmin() {
+		request.QueryStringParameters = map[string]string{}
+	}
+	//only the admins list the events of every itinerary with all=true
+	if request.QueryStringParameters["all"] != "true" || !tokenUser.IsAdmin() {
 		request.QueryStringParameters["trip_id"] = request.PathParameters["id"]
 		request.QueryStringParameters["itinerary_id"] = request.PathParameters["itinerary_id"]
 	}
+	delete(request.QueryStringParameters, "all")
 
-	result, err := db.Select(session, db.TableTripItineraryEvent, request.QueryStringParameters, ItineraryEvent{})
+	result, err := repo.ItineraryEvents.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
+	if list, ok := result.(db.ListResult); ok {
+		if itineraryEvents, ok := list.Data.([]ItineraryEvent); ok {
+			err = newScheduleChecker(repo).withWarnings(itineraryEvents)
+			if err != nil {
+				return common.APIError(http.StatusInternalServerError, err)
+			}
+		}
+	}
 
 	return common.APIResponse(result, http.StatusOK)
 }
 
 //Add clone an global event to this itinerary
-func (e *ItineraryEvent) Add(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (e *ItineraryEvent) Add(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionCreate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this event"))
-		}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
 	}
 
-	result, err := db.QueryOne(session, db.TableEvent, request.PathParameters["global_event_id"], fmt.Event{})
+	globalEvent, err := repo.GlobalEvents.Get(request.PathParameters["global_event_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	jsonBytes, _ := json.Marshal(result)
-	json.Unmarshal(jsonBytes, e)
+	e.Title = globalEvent.Title
+	e.Description = globalEvent.Description
+	e.MainCategoryID = globalEvent.MainCategoryID
+	e.MainCategory = globalEvent.MainCategory
+	e.SecondaryCategoryID = globalEvent.SecondaryCategoryID
+	e.SecondaryCategory = globalEvent.SecondaryCategory
+	e.CountryID = globalEvent.CountryID
+	e.Country = globalEvent.Country
+	e.RegionID = globalEvent.RegionID
+	e.Region = globalEvent.Region
+	e.CityID = globalEvent.CityID
+	e.City = globalEvent.City
+	e.Address = globalEvent.Address
 
 	e.ID = uuid.New().String()
 	e.TripID = request.PathParameters["id"]
@@ -185,6 +171,7 @@ func (e *ItineraryEvent) Add(request events.APIGatewayProxyRequest) (events.APIG
 	e.CreatedDate = time.Now()
 	e.UpdatedBy = tokenUser.UserID
 	e.UpdatedDate = time.Now()
+	e.Version = 0
 
 	if request.Body != "" {
 		jsonMap := make(map[string]interface{})
@@ -198,54 +185,42 @@ func (e *ItineraryEvent) Add(request events.APIGatewayProxyRequest) (events.APIG
 		}
 	}
 
-	tx, err := session.Begin()
-	defer tx.RollbackUnlessCommitted()
+	warnings, err := newScheduleChecker(repo, itinerary).check(*e)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
+	if len(warnings) > 0 && request.QueryStringParameters["strict"] == "true" {
+		return common.APIError(http.StatusConflict, &PlacementError{Warnings: warnings})
+	}
 
-	err = db.Insert(tx, db.TableTripItineraryEvent, *e)
+	err = repo.ItineraryEvents.Create(*e, tripEvent(EventItineraryEventAdded, e.ID, e.TripID, tokenUser.UserID, e))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
+	e.Warnings = warnings
 	return common.APIResponse(e, http.StatusCreated)
 }
 
-//SaveNew creates a new itinerary event
-func (e *ItineraryEvent) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//SaveNew creates a new itinerary event, unscheduled unless the body has the begin_offset.
+//With check_conflicts=true the events overlapping others or out of the itinerary days are rejected.
+func (e *ItineraryEvent) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionCreate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this event"))
-		}
+	_, err = tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
 	}
 
+	e.BeginOffset = -1
+	e.Duration = 21600
 	err = json.Unmarshal([]byte(request.Body), e)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
@@ -267,8 +242,6 @@ func (e *ItineraryEvent) SaveNew(request events.APIGatewayProxyRequest) (events.
 	e.Description.Table = db.TableTripItineraryEvent
 	e.Description.Field = "description"
 	e.Description.ParentID = e.ID
-	e.BeginOffset = -1
-	e.Duration = 21600
 	e.CreatedBy = tokenUser.UserID
 	e.CreatedDate = time.Now()
 	e.UpdatedBy = tokenUser.UserID
@@ -276,53 +249,45 @@ func (e *ItineraryEvent) SaveNew(request events.APIGatewayProxyRequest) (events.
 
 	e.EvaluatedBy = ""
 	e.EvaluatedComment = ""
+	e.Version = 0
+	e.Warnings = nil
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+	if request.QueryStringParameters["check_conflicts"] == "true" {
+		conflicts, err := eventConflicts(repo, *e, nil)
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+		if conflicts != nil {
+			return common.APIError(http.StatusConflict, conflicts)
+		}
 	}
-	defer tx.RollbackUnlessCommitted()
 
-	err = db.Insert(tx, db.TableTripItineraryEvent, *e)
+	err = repo.ItineraryEvents.Create(*e, tripEvent(EventItineraryEventAdded, e.ID, e.TripID, tokenUser.UserID, e))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
 	return common.APIResponse(e, http.StatusCreated)
 }
 
-//Update change event attributes in the database
-func (e *ItineraryEvent) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//Update change event attributes in the database, the If-Match header requires the version of the ETag
+func (e *ItineraryEvent) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionUpdate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this event"))
-		}
+	itineraryEvent, err := tripItineraryEvent(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"], request.PathParameters["event_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	version, err := common.IfMatch(request, func() (int64, error) { return itineraryEvent.Version, nil })
+	if err != nil {
+		return common.APIError(http.StatusPreconditionFailed, err)
 	}
 
 	jsonMap := make(map[string]interface{})
@@ -337,64 +302,70 @@ func (e *ItineraryEvent) Update(request events.APIGatewayProxyRequest) (events.A
 		delete(jsonMap, "evaluated_comment")
 	}
 
+	checker := newScheduleChecker(repo)
+	if request.QueryStringParameters["strict"] == "true" {
+		placement := itineraryEvent
+		if val, ok := jsonMap["begin_offset"].(float64); ok {
+			placement.BeginOffset = val
+		}
+		if val, ok := jsonMap["duration"].(float64); ok {
+			placement.Duration = int(val)
+		}
+		warnings, err := checker.check(placement)
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+		if len(warnings) > 0 {
+			return common.APIError(http.StatusConflict, &PlacementError{Warnings: warnings})
+		}
+	}
+	if request.QueryStringParameters["check_conflicts"] == "true" {
+		conflicts, err := eventConflicts(repo, itineraryEvent, jsonMap)
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+		if conflicts != nil {
+			return common.APIError(http.StatusConflict, conflicts)
+		}
+	}
+
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	tx, err := session.Begin()
+	err = repo.ItineraryEvents.Update(itineraryEvent.ID, version, jsonMap, tripEvent(EventItineraryEventUpdated, itineraryEvent.ID, itineraryEvent.TripID, tokenUser.UserID, jsonMap))
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return updateError(err)
 	}
-	defer tx.RollbackUnlessCommitted()
 
-	err = db.Update(tx, db.TableTripItineraryEvent, request.PathParameters["event_id"], *e, jsonMap)
+	result, err := repo.ItineraryEvents.Get(request.PathParameters["event_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableTripItineraryEvent, request.PathParameters["event_id"], ItineraryEvent{})
+	result.Warnings, err = checker.check(result)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
 }
 
 //Delete removes event from the database
-func (e *ItineraryEvent) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (e *ItineraryEvent) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItineraryEvent, ActionDelete, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this event"))
-		}
+	itineraryEvent, err := tripItineraryEvent(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"], request.PathParameters["event_id"])
+	if err != nil {
+		return resourceError(err)
 	}
 
-	err = db.Delete(session, db.TableTripItineraryEvent, request.PathParameters["event_id"])
+	err = repo.ItineraryEvents.Delete(itineraryEvent.ID, tripEvent(EventItineraryEventDeleted, itineraryEvent.ID, itineraryEvent.TripID, tokenUser.UserID, map[string]interface{}{"itinerary_id": itineraryEvent.ItineraryID}))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -402,8 +373,8 @@ func (e *ItineraryEvent) Delete(request events.APIGatewayProxyRequest) (events.A
 	return common.APIResponse(nil, http.StatusOK)
 }
 
-func (e *ItineraryEvent) clone(tx *dbr.Tx, tripID, itineraryID, userID string, offset float64) error {
-
+//clone prepares a copy of the event to be inserted into another itinerary
+func (e *ItineraryEvent) clone(tripID, itineraryID, userID string, offset float64) {
 	e.ID = uuid.New().String()
 	e.TripID = tripID
 	e.ItineraryID = itineraryID
@@ -416,7 +387,14 @@ func (e *ItineraryEvent) clone(tx *dbr.Tx, tripID, itineraryID, userID string, o
 	e.CreatedDate = time.Now()
 	e.UpdatedBy = userID
 	e.UpdatedDate = time.Now()
+	e.Version = 0
+}
 
-	err := db.Insert(tx, db.TableTripItineraryEvent, *e)
-	return err
+//tripItineraryEvent loads the event returning db.ErrNotFound when it doesn't belong to the trip itinerary
+func tripItineraryEvent(repo Repository, tripID, itineraryID, eventID string) (ItineraryEvent, error) {
+	event, err := repo.ItineraryEvents.Get(eventID)
+	if err == nil && (event.TripID != tripID || event.ItineraryID != itineraryID) {
+		return ItineraryEvent{}, db.ErrNotFound
+	}
+	return event, err
 }
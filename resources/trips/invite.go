@@ -15,51 +15,57 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	//InvitePending defines an invite waiting for the invitee answer
+	InvitePending = "pending"
+	//InviteAccepted defines an invite accepted by the invitee
+	InviteAccepted = "accepted"
+	//InviteDeclined defines an invite declined by the invitee
+	InviteDeclined = "declined"
+	//InviteExpired defines an invite not answered before the expiry date
+	InviteExpired = "expired"
+)
+
+//inviteTTL defines how long an invite can be answered when the expires_date isn't informed
+const inviteTTL = 7 * 24 * time.Hour
+
 //Invite represents an invite to a Trip
 type Invite struct {
-	ID          string      `json:"id" db:"id" lock:"true"`
-	TripID      string      `json:"trip_id" db:"trip_id" lock:"true"`
-	Email       string      `json:"email" db:"email" lock:"true"`
-	CreatedBy   string      `json:"created_by" db:"created_by" lock:"true"`
-	CreatedDate time.Time   `json:"created_date" db:"created_date" lock:"true"`
-	CreatedUser shared.User `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip_invite.created_by" embedded:"true"`
+	ID            string       `json:"id" db:"id" lock:"true"`
+	TripID        string       `json:"trip_id" db:"trip_id" lock:"true"`
+	Email         string       `json:"email" db:"email" lock:"true"`
+	Role          string       `json:"role" db:"role" lock:"true"`
+	Status        string       `json:"status" db:"status" lock:"true"`
+	TokenHash     string       `json:"-" db:"token_hash" lock:"true"`
+	Token         string       `json:"token,omitempty"`
+	ExpiresDate   time.Time    `json:"expires_date" db:"expires_date" lock:"true"`
+	RespondedBy   string       `json:"responded_by" db:"responded_by" lock:"true"`
+	RespondedDate dbr.NullTime `json:"responded_date" db:"responded_date" lock:"true"`
+	CreatedBy     string       `json:"created_by" db:"created_by" lock:"true"`
+	CreatedDate   time.Time    `json:"created_date" db:"created_date" lock:"true"`
+	CreatedUser   shared.User  `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip_invite.created_by" embedded:"true"`
 }
 
-//GetAll returns all itineraries from the trip
-func (i *Invite) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//GetAll returns all invites from the trip, the pending invites past the expiry date are listed as expired
+func (i *Invite) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip participant can access this resource"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceInvite, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"trip_id": request.PathParameters["id"],
-		}
-	} else {
-		request.QueryStringParameters["trip_id"] = request.PathParameters["id"]
+	err = repo.Invites.Expire(request.PathParameters["id"], time.Now())
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	result, err := db.Select(session, db.TableTripInvite, request.QueryStringParameters, Invite{})
+	result, err := repo.Invites.List(request.PathParameters["id"], request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -67,34 +73,15 @@ func (i *Invite) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewa
 	return common.APIResponse(result, http.StatusOK)
 }
 
-//SaveNew creates a new invite
-func (i *Invite) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//SaveNew creates a new invite with the token the invitee uses to accept or decline it
+func (i *Invite) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("role", ParticipantOwnerRole),
-				dbr.Eq("role", ParticipantAdminRole),
-			),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip owner or admins can create invites"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceInvite, ActionCreate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
 	err = json.Unmarshal([]byte(request.Body), i)
@@ -108,66 +95,191 @@ func (i *Invite) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatew
 		return common.APIError(http.StatusBadRequest, errors.New("invalid email"))
 	}
 
+	if i.Role == "" {
+		i.Role = ParticipantViewerRole
+	}
+	if i.Role != ParticipantAdminRole && i.Role != ParticipantEditorRole && i.Role != ParticipantViewerRole {
+		return common.APIError(http.StatusBadRequest, errors.New("invalid role, invites can be admin, editor or viewer"))
+	}
+	if i.ExpiresDate.IsZero() {
+		i.ExpiresDate = time.Now().Add(inviteTTL)
+	}
+	if !i.ExpiresDate.After(time.Now()) {
+		return common.APIError(http.StatusBadRequest, errors.New("expires_date must be in the future"))
+	}
+
 	i.ID = uuid.New().String()
 	i.TripID = request.PathParameters["id"]
+	i.Status = InvitePending
+	i.RespondedBy = ""
+	i.RespondedDate = dbr.NullTime{}
 	i.CreatedBy = tokenUser.UserID
 	i.CreatedDate = time.Now()
 
-	tx, err := session.Begin()
+	token, err := signInvite(*i)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
+	i.Token = ""
+	i.TokenHash = hashInviteToken(token)
 
-	err = db.Insert(tx, db.TableTripInvite, *i)
+	err = repo.Invites.Create(*i, inviteEvent(EventInviteCreated, *i, tokenUser.UserID))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
-	//TODO: send invite to email
+	notifyInvite(repo, *i, token, tokenUser)
+	i.Token = token
 
 	return common.APIResponse(i, http.StatusCreated)
 }
 
-//Delete remove participant
-func (i *Invite) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//Accept adds the authenticated user to the trip with the invite role and consumes the invite
+func (i *Invite) Accept(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	invite, err := answerableInvite(request, repo)
+	if err != nil {
+		return inviteError(err)
+	}
+
+	role, err := repo.Participants.Role(invite.TripID, tokenUser.UserID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
+	if role != "" {
+		return common.APIError(http.StatusConflict, errors.New("user is already a trip participant"))
+	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["invite_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripInvite + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this itinerary"))
-		}
+	participant := Participant{
+		ID:          uuid.New().String(),
+		TripID:      invite.TripID,
+		UserID:      tokenUser.UserID,
+		Role:        invite.Role,
+		CreatedBy:   tokenUser.UserID,
+		CreatedDate: time.Now(),
+		UpdatedBy:   tokenUser.UserID,
+		UpdatedDate: time.Now(),
+	}
+	invite.Status = InviteAccepted
+	err = repo.Invites.Accept(invite.ID, participant, answerValues(InviteAccepted, tokenUser.UserID),
+		inviteEvent(EventInviteAccepted, invite, tokenUser.UserID),
+		tripEvent(EventParticipantAdded, participant.ID, participant.TripID, tokenUser.UserID, participant))
+	if err != nil {
+		return inviteError(err)
 	}
 
-	err = db.Delete(session, db.TableTripInvite, request.PathParameters["invite_id"])
+	return common.APIResponse(participant, http.StatusCreated)
+}
+
+//Decline consumes the invite without adding the user to the trip
+func (i *Invite) Decline(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	invite, err := answerableInvite(request, repo)
+	if err != nil {
+		return inviteError(err)
+	}
+
+	invite.Status = InviteDeclined
+	err = repo.Invites.Update(invite.ID, answerValues(InviteDeclined, tokenUser.UserID), inviteEvent(EventInviteDeclined, invite, tokenUser.UserID))
+	if err != nil {
+		return inviteError(err)
+	}
+
+	result, err := repo.Invites.Get(invite.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(result, http.StatusOK)
+}
+
+//Delete remove participant
+func (i *Invite) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	invite, err := tripInvite(repo, request.PathParameters["id"], request.PathParameters["invite_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceInvite, ActionDelete, invite.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	err = repo.Invites.Delete(invite.ID, inviteEvent(EventInviteDeleted, invite, tokenUser.UserID))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
 	return common.APIResponse(nil, http.StatusOK)
 }
+
+//answerableInvite loads the invite and checks the token sent in the request body
+func answerableInvite(request events.APIGatewayProxyRequest, repo Repository) (Invite, error) {
+	answer := struct {
+		Token string `json:"token"`
+	}{}
+	err := json.Unmarshal([]byte(request.Body), &answer)
+	if err != nil || answer.Token == "" {
+		return Invite{}, ErrInviteToken
+	}
+
+	invite, err := tripInvite(repo, request.PathParameters["id"], request.PathParameters["invite_id"])
+	if err != nil {
+		return Invite{}, err
+	}
+	//the invites of soft deleted trips can't be answered until the trip is restored
+	_, err = repo.Trips.Get(invite.TripID)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	err = verifyInvite(answer.Token, invite, time.Now())
+	if err == ErrInviteExpired && invite.Status == InvitePending {
+		invite.Status = InviteExpired
+		err = repo.Invites.Update(invite.ID, map[string]interface{}{"status": InviteExpired}, inviteEvent(EventInviteExpired, invite, ""))
+		if err != nil {
+			return Invite{}, err
+		}
+		return Invite{}, ErrInviteExpired
+	}
+	return invite, err
+}
+
+func answerValues(status, userID string) map[string]interface{} {
+	return map[string]interface{}{
+		"status":         status,
+		"responded_by":   userID,
+		"responded_date": time.Now(),
+	}
+}
+
+//inviteError returns the api error response for the invite answer errors
+func inviteError(err error) (events.APIGatewayProxyResponse, error) {
+	switch err {
+	case ErrInviteToken:
+		return common.APIError(http.StatusForbidden, err)
+	case ErrInviteExpired:
+		return common.APIError(http.StatusGone, err)
+	case ErrInviteConsumed:
+		return common.APIError(http.StatusConflict, err)
+	}
+	return resourceError(err)
+}
+
+//tripInvite loads the invite returning db.ErrNotFound when it doesn't belong to the trip
+func tripInvite(repo Repository, tripID, inviteID string) (Invite, error) {
+	invite, err := repo.Invites.Get(inviteID)
+	if err == nil && invite.TripID != tripID {
+		return Invite{}, db.ErrNotFound
+	}
+	return invite, err
+}
@@ -0,0 +1,116 @@
+package trips
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrInviteToken is returned when the invite token is malformed, forged or from another invite
+	ErrInviteToken = errors.New("invalid invite token")
+	//ErrInviteExpired is returned when the invite is answered after the expiry date
+	ErrInviteExpired = errors.New("invite expired")
+	//ErrInviteConsumed is returned when the invite was already accepted or declined
+	ErrInviteConsumed = errors.New("invite already answered")
+)
+
+//inviteClaims represents the attributes signed in the invite token
+type inviteClaims struct {
+	InviteID string `json:"invite_id"`
+	TripID   string `json:"trip_id"`
+	Role     string `json:"role"`
+	Expires  int64  `json:"exp"`
+	Nonce    string `json:"nonce"`
+}
+
+//inviteSecret returns the FMT_INVITE_SECRET key signing the invite tokens
+func inviteSecret() ([]byte, error) {
+	secret := os.Getenv("FMT_INVITE_SECRET")
+	if secret == "" {
+		return nil, errors.New("FMT_INVITE_SECRET is not configured")
+	}
+	return []byte(secret), nil
+}
+
+//signInvite returns the token sent to the invitee, only its hash is stored in the invite
+func signInvite(invite Invite) (string, error) {
+	secret, err := inviteSecret()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 16)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(inviteClaims{
+		InviteID: invite.ID,
+		TripID:   invite.TripID,
+		Role:     invite.Role,
+		Expires:  invite.ExpiresDate.Unix(),
+		Nonce:    base64.RawURLEncoding.EncodeToString(nonce),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(inviteSignature(secret, encoded)), nil
+}
+
+//verifyInvite checks the token signature and that it was issued to the invite
+func verifyInvite(token string, invite Invite, now time.Time) error {
+	secret, err := inviteSecret()
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return ErrInviteToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, inviteSignature(secret, parts[0])) {
+		return ErrInviteToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInviteToken
+	}
+	claims := inviteClaims{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return ErrInviteToken
+	}
+
+	if claims.InviteID != invite.ID || claims.TripID != invite.TripID || !hmac.Equal([]byte(hashInviteToken(token)), []byte(invite.TokenHash)) {
+		return ErrInviteToken
+	}
+	if invite.Status == InviteExpired || now.Unix() >= claims.Expires || !now.Before(invite.ExpiresDate) {
+		return ErrInviteExpired
+	}
+	if invite.Status != InvitePending {
+		return ErrInviteConsumed
+	}
+	return nil
+}
+
+func inviteSignature(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
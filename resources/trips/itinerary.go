@@ -5,11 +5,13 @@ import (
 	"errors"
 	"math"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/gocraft/dbr"
 	"github.com/google/uuid"
@@ -27,45 +29,31 @@ type Itinerary struct {
 	CreatedDate time.Time          `json:"created_date" db:"created_date" lock:"true"`
 	UpdatedBy   string             `json:"updated_by" db:"updated_by"`
 	UpdatedDate time.Time          `json:"updated_date" db:"updated_date"`
+	DeletedAt   dbr.NullTime       `json:"deleted_at" db:"deleted_at" lock:"true"`
+	DeletedBy   string             `json:"deleted_by" db:"deleted_by" lock:"true"`
+	Version     int64              `json:"version" db:"version" lock:"true"`
 	CreatedUser shared.User        `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip_itinerary.created_by" embedded:"true"`
 	UpdatedUser shared.User        `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = trip_itinerary.updated_by" embedded:"true"`
 }
 
-//GetAll returns all itineraries from the trip
-func (i *Itinerary) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//GetAll returns all itineraries from the trip, admins can include the soft deleted ones with include_deleted=true
+func (i *Itinerary) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip participant can access this resource"))
-		}
+	if request.QueryStringParameters["include_deleted"] != "" && !tokenUser.IsAdmin() {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can list the deleted itineraries"))
 	}
-
-	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"trip_id": request.PathParameters["id"],
-		}
-	} else {
-		request.QueryStringParameters["trip_id"] = request.PathParameters["id"]
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	result, err := db.Select(session, db.TableTripItinerary, request.QueryStringParameters, Itinerary{})
+	result, err := repo.Itineraries.List(request.PathParameters["id"], request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -73,31 +61,34 @@ func (i *Itinerary) GetAll(request events.APIGatewayProxyRequest) (events.APIGat
 	return common.APIResponse(result, http.StatusOK)
 }
 
-//SaveNew add a new itinerary to the trip
-func (i *Itinerary) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//Get returns an itinerary of the trip
+func (i *Itinerary) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
+	result, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
 
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Neq("role", ParticipantViewerRole),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("viewer participants can't create itineraries"))
-		}
+	return common.APIConditionalResponse(request, result, result.Version)
+}
+
+//SaveNew add a new itinerary to the trip
+func (i *Itinerary) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionCreate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
 	err = json.Unmarshal([]byte(request.Body), i)
@@ -118,54 +109,35 @@ func (i *Itinerary) SaveNew(request events.APIGatewayProxyRequest) (events.APIGa
 	i.CreatedDate = time.Now()
 	i.UpdatedBy = tokenUser.UserID
 	i.UpdatedDate = time.Now()
+	i.DeletedAt = dbr.NullTime{}
+	i.DeletedBy = ""
+	i.Version = 0
 
-	tx, err := session.Begin()
+	err = repo.Itineraries.Create(*i, tripEvent(EventItineraryCreated, i.ID, i.TripID, tokenUser.UserID, i))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
 
-	err = db.Insert(tx, db.TableTripItinerary, *i)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
-
-	return common.APIResponse(i, http.StatusCreated)
+	return common.APIVersionedResponse(i, i.Version, http.StatusCreated)
 }
 
-//Update change itinerary attributes
-func (i *Itinerary) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//Update change itinerary attributes, the If-Match header requires the version of the ETag
+func (i *Itinerary) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to update this itinerary"))
-		}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	version, err := common.IfMatch(request, func() (int64, error) { return itinerary.Version, nil })
+	if err != nil {
+		return common.APIError(http.StatusPreconditionFailed, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionUpdate, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
 	}
 
 	jsonMap := make(map[string]interface{})
@@ -177,77 +149,41 @@ func (i *Itinerary) Update(request events.APIGatewayProxyRequest) (events.APIGat
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Update(tx, db.TableTripItinerary, request.PathParameters["itinerary_id"], *i, jsonMap)
+	err = repo.Itineraries.Update(itinerary.ID, version, jsonMap, tripEvent(EventItineraryUpdated, itinerary.ID, itinerary.TripID, tokenUser.UserID, jsonMap))
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return updateError(err)
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableTripItinerary, request.PathParameters["itinerary_id"], Itinerary{})
+	result, err := repo.Itineraries.Get(request.PathParameters["itinerary_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
 }
 
 //Append include an existing itinerary to this one
-func (i *Itinerary) Append(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (i *Itinerary) Append(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to update this itinerary"))
-		}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
 	}
-
-	result, err := db.QueryOne(session, db.TableTripItinerary, request.PathParameters["itinerary_id"], Itinerary{})
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionUpdate, itinerary.CreatedBy)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return authorizationError(err)
 	}
 
-	itineraryBytes, _ := json.Marshal(result)
-	json.Unmarshal(itineraryBytes, i)
+	*i = itinerary
 
-	result, err = db.QueryOne(session, db.TableTripItinerary, request.PathParameters["append_itinerary_id"], Itinerary{})
+	appendItinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["append_itinerary_id"])
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return resourceError(err)
 	}
 
-	appendItinerary := &Itinerary{}
-	itineraryBytes, _ = json.Marshal(result)
-	json.Unmarshal(itineraryBytes, appendItinerary)
-
 	itineraryOffset := float64(math.Floor((i.EndDate.Sub(i.StartDate).Hours())/24+1) * 86400)
 
 	appendItinerarytotalDays := int(math.Floor((appendItinerary.EndDate.Sub(appendItinerary.StartDate).Hours())/24 + 1))
@@ -255,35 +191,17 @@ func (i *Itinerary) Append(request events.APIGatewayProxyRequest) (events.APIGat
 	i.EndDate = i.EndDate.AddDate(0, 0, appendItinerarytotalDays)
 
 	//get appended itinerary events
-	filter := map[string]string{
-		"trip_id":      appendItinerary.TripID,
-		"itinerary_id": appendItinerary.ID,
-		"results":      "1000",
-	}
-	result, err = db.Select(session, db.TableTripItineraryEvent, filter, ItineraryEvent{})
+	itineraryEvents, err := repo.ItineraryEvents.All(appendItinerary.TripID, appendItinerary.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	data := &resultItinerary{}
-	dataBytes, _ := json.Marshal(result)
-	json.Unmarshal(dataBytes, data)
-
-	if len(data.Events) <= 0 {
+	if len(itineraryEvents) <= 0 {
 		return common.APIResponse(nil, http.StatusOK)
 	}
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	for _, e := range data.Events {
-		err := e.clone(tx, request.PathParameters["id"], request.PathParameters["itinerary_id"], tokenUser.UserID, itineraryOffset)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
+	for idx := range itineraryEvents {
+		itineraryEvents[idx].clone(request.PathParameters["id"], request.PathParameters["itinerary_id"], tokenUser.UserID, itineraryOffset)
 	}
 
 	jsonMapUpdate := make(map[string]interface{})
@@ -292,51 +210,37 @@ func (i *Itinerary) Append(request events.APIGatewayProxyRequest) (events.APIGat
 	jsonMapUpdate["end_date"] = i.EndDate
 	jsonMapUpdate["updated_date"] = time.Now()
 
-	err = db.Update(tx, db.TableTripItinerary, request.PathParameters["itinerary_id"], *i, jsonMapUpdate)
+	eventIDs := []string{}
+	for _, e := range itineraryEvents {
+		eventIDs = append(eventIDs, e.ID)
+	}
+	appended := tripEvent(EventItineraryAppended, i.ID, i.TripID, tokenUser.UserID, map[string]interface{}{
+		"appended_itinerary_id": appendItinerary.ID,
+		"end_date":              i.EndDate,
+		"event_ids":             eventIDs,
+	})
+
+	err = repo.Itineraries.Append(request.PathParameters["itinerary_id"], itineraryEvents, jsonMapUpdate, appended)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
 	return common.APIResponse(nil, http.StatusOK)
 }
 
-type resultItinerary struct {
-	Events []ItineraryEvent `json:"data"`
-}
-
 //SwapDay change itinerary events offset to rearrange days
-func (i *Itinerary) SwapDay(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (i *Itinerary) SwapDay(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to update this itinerary"))
-		}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionUpdate, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
 	}
 
 	jsonMap := make(map[string]interface{})
@@ -356,32 +260,17 @@ func (i *Itinerary) SwapDay(request events.APIGatewayProxyRequest) (events.APIGa
 	targetOffset := (toDay - 1) * 86400
 
 	//get appended itinerary events
-	filter := map[string]string{
-		"trip_id":      request.PathParameters["id"],
-		"itinerary_id": request.PathParameters["itinerary_id"],
-		"results":      "1000",
-		"sort":         "begin_offset",
-	}
-	result, err := db.Select(session, db.TableTripItineraryEvent, filter, ItineraryEvent{})
+	itineraryEvents, err := repo.ItineraryEvents.All(request.PathParameters["id"], request.PathParameters["itinerary_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	data := &resultItinerary{}
-	dataBytes, _ := json.Marshal(result)
-	json.Unmarshal(dataBytes, data)
-
-	if len(data.Events) <= 0 {
+	if len(itineraryEvents) <= 0 {
 		return common.APIResponse(nil, http.StatusOK)
 	}
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	for _, e := range data.Events {
+	updates := map[string]EventChange{}
+	for _, e := range itineraryEvents {
 		update := false
 		if e.BeginOffset >= targetOffset && e.BeginOffset < sourceOffset {
 			//swift one day forward
@@ -405,55 +294,124 @@ func (i *Itinerary) SwapDay(request events.APIGatewayProxyRequest) (events.APIGa
 			jsonMap["begin_offset"] = e.BeginOffset
 			jsonMap["updated_by"] = tokenUser.UserID
 			jsonMap["updated_date"] = time.Now()
-			err = db.Update(tx, db.TableTripItineraryEvent, e.ID, e, jsonMap)
-			if err != nil {
-				return common.APIError(http.StatusInternalServerError, err)
-			}
+			updates[e.ID] = EventChange{Version: e.Version, Values: jsonMap}
 		}
 	}
 
-	tx.Commit()
+	swap := DaySwap{
+		TripID:      itinerary.TripID,
+		ItineraryID: itinerary.ID,
+		From:        int(fromDay),
+		To:          int(toDay),
+		EventIDs:    []string{},
+	}
+	for id := range updates {
+		swap.EventIDs = append(swap.EventIDs, id)
+	}
+	sort.Strings(swap.EventIDs)
+
+	err = repo.ItineraryEvents.UpdateMany(updates, outbox.Event{Type: EventItineraryDaySwapped, AggregateID: itinerary.ID, Actor: tokenUser.UserID, Data: swap})
+	if err != nil {
+		return updateError(err)
+	}
 
 	return common.APIResponse(nil, http.StatusOK)
 }
 
-//Delete remove itinerary
-func (i *Itinerary) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//History returns the changes of the itinerary and of its events, the most recent first.
+//The history of a removed itinerary is found by the trip recorded in its changes.
+func (i *Itinerary) History(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	result, err := repo.Itineraries.History(request.PathParameters["itinerary_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("i.id", request.PathParameters["itinerary_id"]),
-			dbr.Eq("p.trip_id", request.PathParameters["id"]),
-			dbr.Eq("p.user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("i.created_by", tokenUser.UserID),
-				dbr.Eq("p.role", ParticipantOwnerRole),
-				dbr.Eq("p.role", ParticipantAdminRole),
-			),
-		)
-		table := db.TableTripParticipant + " p , " + db.TableTripItinerary + " i"
-		total, err := db.Validate(session, []string{"count(p.id) total"}, table, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("not allowed to delete this itinerary"))
+	_, err = tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	for _, entry := range result {
+		if err == db.ErrNotFound && entry.RecordID == request.PathParameters["itinerary_id"] && entry.ParentID == request.PathParameters["id"] {
+			err = nil
 		}
 	}
+	if err != nil {
+		return resourceError(err)
+	}
 
-	err = db.Delete(session, db.TableTripItinerary, request.PathParameters["itinerary_id"])
+	return common.APIResponse(map[string]interface{}{"data": result}, http.StatusOK)
+}
+
+//Delete marks the itinerary as removed, it can be restored until the purge
+func (i *Itinerary) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	itinerary, err := tripItinerary(repo, request.PathParameters["id"], request.PathParameters["itinerary_id"])
+	if err != nil {
+		return resourceError(err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceItinerary, ActionDelete, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
+	}
+
+	err = repo.Itineraries.Delete(itinerary.ID, tokenUser.UserID, tripEvent(EventItineraryDeleted, itinerary.ID, itinerary.TripID, tokenUser.UserID, map[string]interface{}{}))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
 	return common.APIResponse(nil, http.StatusOK)
 }
+
+//Restore brings back a soft deleted itinerary with its events
+func (i *Itinerary) Restore(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	itinerary, err := repo.Itineraries.GetWithDeleted(request.PathParameters["itinerary_id"])
+	if err == nil && itinerary.TripID != request.PathParameters["id"] {
+		err = db.ErrNotFound
+	}
+	if err != nil {
+		return resourceError(err)
+	}
+	err = authorize(repo, tokenUser, itinerary.TripID, ResourceItinerary, ActionRestore, itinerary.CreatedBy)
+	if err != nil {
+		return authorizationError(err)
+	}
+	if !itinerary.DeletedAt.Valid {
+		return common.APIError(http.StatusConflict, errors.New("itinerary is not deleted"))
+	}
+
+	itinerary.DeletedAt = dbr.NullTime{}
+	itinerary.DeletedBy = ""
+	err = repo.Itineraries.Restore(itinerary.ID, tripEvent(EventItineraryRestored, itinerary.ID, itinerary.TripID, tokenUser.UserID, itinerary))
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	result, err := repo.Itineraries.Get(itinerary.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
+}
+
+//tripItinerary loads the itinerary returning db.ErrNotFound when it doesn't belong to the trip
+func tripItinerary(repo Repository, tripID, itineraryID string) (Itinerary, error) {
+	itinerary, err := repo.Itineraries.Get(itineraryID)
+	if err == nil && itinerary.TripID != tripID {
+		return Itinerary{}, db.ErrNotFound
+	}
+	return itinerary, err
+}
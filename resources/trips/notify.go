@@ -0,0 +1,162 @@
+package trips
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/mailer"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//notifyInvite sends the invite token to the invitee, in the invitee language when the email belongs to a user.
+//Failures are logged and don't fail the request, the invite can be created again.
+func notifyInvite(repo Repository, invite Invite, token string, tokenUser *common.TokenUser) {
+	languageCode := tokenUser.LanguageCode
+	invitee, err := repo.Users.FindByEmail(invite.Email)
+	if err == nil {
+		languageCode = invitee.LanguageCode
+	}
+
+	link := ""
+	if appURL := os.Getenv("FMT_APP_URL"); appURL != "" {
+		link = strings.TrimSuffix(appURL, "/") + "/trips/" + invite.TripID + "/invites/" + invite.ID + "?token=" + url.QueryEscape(token)
+	}
+
+	err = sendMail(invite.Email, languageCode, mailer.TemplateTripInvite, mailer.TripInvite{
+		TripTitle:   tripTitle(repo, invite.TripID, languageCode),
+		InvitedBy:   userName(repo, tokenUser.UserID),
+		Role:        invite.Role,
+		Link:        link,
+		Token:       token,
+		ExpiresDate: invite.ExpiresDate.Format("2006-01-02 15:04 MST"),
+	})
+	if err != nil {
+		log.Printf("trips: %s", err.Error())
+	}
+}
+
+//Notifier is the outbox consumer sending the emails of the trips events. The invite email is sent by the request
+//because the outbox doesn't keep the invite token.
+type Notifier struct {
+	repo Repository
+}
+
+//NewNotifier returns a Notifier loading the notification data from the repository
+func NewNotifier(repo Repository) Notifier {
+	return Notifier{repo: repo}
+}
+
+//Name identifies the consumer in the outbox deliveries
+func (n Notifier) Name() string {
+	return "trips.notifier"
+}
+
+//Handle sends the email of the participant.role_changed and itinerary.updated events
+func (n Notifier) Handle(entry outbox.Entry) error {
+	switch entry.Type {
+	case EventParticipantRoleChange:
+		change := RoleChange{}
+		err := entry.Decode(&change)
+		if err != nil {
+			return err
+		}
+		return notifyRole(n.repo, change)
+	case EventItineraryUpdated:
+		itinerary, err := n.repo.Itineraries.Get(entry.AggregateID)
+		if err == db.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return notifyItinerary(n.repo, itinerary, entry.Actor)
+	}
+	return nil
+}
+
+//notifyRole tells the participant about the new trip role
+func notifyRole(repo Repository, change RoleChange) error {
+	user, err := repo.Users.Get(change.UserID)
+	if err == db.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return sendMail(user.Email, user.LanguageCode, mailer.TemplateParticipantRole, mailer.ParticipantRole{
+		TripTitle: tripTitle(repo, change.TripID, user.LanguageCode),
+		Role:      change.Role,
+	})
+}
+
+//notifyItinerary tells the trip participants, except who made the change, that the itinerary was updated.
+//Failures sending to a participant are only logged, retrying would send the email again to the others.
+func notifyItinerary(repo Repository, itinerary Itinerary, updatedBy string) error {
+	participants, err := repo.Participants.All(itinerary.TripID)
+	if err != nil {
+		return err
+	}
+
+	name := userName(repo, updatedBy)
+	for _, p := range participants {
+		if p.UserID == updatedBy {
+			continue
+		}
+		user, err := repo.Users.Get(p.UserID)
+		if err != nil {
+			logNotifyError(err)
+			continue
+		}
+		err = sendMail(user.Email, user.LanguageCode, mailer.TemplateItineraryUpdated, mailer.ItineraryUpdated{
+			TripTitle:      tripTitle(repo, itinerary.TripID, user.LanguageCode),
+			ItineraryTitle: itinerary.Title.Text(user.LanguageCode),
+			UpdatedBy:      name,
+		})
+		if err != nil {
+			log.Printf("trips: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+//sendMail delivers the template with the default mailer
+func sendMail(to, languageCode, name string, data interface{}) error {
+	m, err := mailer.Default()
+	if err == nil {
+		err = mailer.SendTemplate(m, to, languageCode, name, data)
+	}
+	if err != nil {
+		return fmt.Errorf("can't send %s to %s: %s", name, to, err.Error())
+	}
+	return nil
+}
+
+func tripTitle(repo Repository, tripID, languageCode string) string {
+	trip, err := repo.Trips.Get(tripID)
+	if err != nil {
+		logNotifyError(err)
+		return ""
+	}
+	return trip.Title.Text(languageCode)
+}
+
+func userName(repo Repository, userID string) string {
+	user, err := repo.Users.Get(userID)
+	if err != nil {
+		logNotifyError(err)
+		return ""
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+//logNotifyError logs the errors loading the notification data, users missing in the database are skipped silently
+func logNotifyError(err error) {
+	if err != db.ErrNotFound {
+		log.Printf("trips: can't load notification data: %s", err.Error())
+	}
+}
@@ -0,0 +1,69 @@
+package trips
+
+import (
+	"github.com/feedmytrip/api/outbox"
+)
+
+//Domain events written in the outbox by the trips mutations, the trip events have the trip id as AggregateID
+//and the data of the other events has the trip_id attribute
+const (
+	EventTripCreated           = "trip.created"
+	EventTripUpdated           = "trip.updated"
+	EventTripDeleted           = "trip.deleted"
+	EventTripRestored          = "trip.restored"
+	EventParticipantAdded      = "participant.added"
+	EventParticipantUpdated    = "participant.updated"
+	EventParticipantRoleChange = "participant.role_changed"
+	EventParticipantRemoved    = "participant.removed"
+	EventInviteCreated         = "invite.created"
+	EventInviteAccepted        = "invite.accepted"
+	EventInviteDeclined        = "invite.declined"
+	EventInviteExpired         = "invite.expired"
+	EventInviteDeleted         = "invite.deleted"
+	EventItineraryCreated      = "itinerary.created"
+	EventItineraryUpdated      = "itinerary.updated"
+	EventItineraryAppended     = "itinerary.appended"
+	EventItineraryDaySwapped   = "itinerary.day_swapped"
+	EventItineraryScheduled    = "itinerary.auto_scheduled"
+	EventItineraryReordered    = "itinerary.days_reordered"
+	EventItineraryDayInserted  = "itinerary.day_inserted"
+	EventItineraryDayDeleted   = "itinerary.day_deleted"
+	EventItineraryDeleted      = "itinerary.deleted"
+	EventItineraryRestored     = "itinerary.restored"
+	EventItineraryEventAdded   = "itinerary_event.added"
+	EventItineraryEventUpdated = "itinerary_event.updated"
+	EventItineraryEventDeleted = "itinerary_event.deleted"
+)
+
+//RoleChange represents the data of the participant.role_changed event
+type RoleChange struct {
+	ParticipantID string `json:"participant_id"`
+	TripID        string `json:"trip_id"`
+	UserID        string `json:"user_id"`
+	PreviousRole  string `json:"previous_role"`
+	Role          string `json:"role"`
+}
+
+//DaySwap represents the data of the itinerary.day_swapped event
+type DaySwap struct {
+	TripID      string   `json:"trip_id"`
+	ItineraryID string   `json:"itinerary_id"`
+	From        int      `json:"from"`
+	To          int      `json:"to"`
+	EventIDs    []string `json:"event_ids"`
+}
+
+//tripEvent returns an event of a trip aggregate record, data is the record or the changed values
+func tripEvent(eventType, id, tripID, actor string, data interface{}) outbox.Event {
+	event := outbox.NewEvent(eventType, id, actor, data)
+	if values, ok := event.Data.(map[string]interface{}); ok {
+		values["trip_id"] = tripID
+	}
+	return event
+}
+
+//inviteEvent returns an event of the invite without the token, only the invitee receives it
+func inviteEvent(eventType string, invite Invite, actor string) outbox.Event {
+	invite.Token = ""
+	return tripEvent(eventType, invite.ID, invite.TripID, actor, invite)
+}
@@ -6,11 +6,10 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/gocraft/dbr"
-
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/google/uuid"
 )
@@ -41,40 +40,20 @@ type Participant struct {
 }
 
 //GetAll returns all participant a from the trip
-func (p *Participant) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (p *Participant) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip participant can access this resource"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceParticipant, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	if request.QueryStringParameters == nil {
-		request.QueryStringParameters = map[string]string{
-			"trip_id": request.PathParameters["id"],
-		}
-	} else {
-		request.QueryStringParameters["trip_id"] = request.PathParameters["id"]
+	result, err := repo.Participants.List(request.PathParameters["id"], request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	result, err := db.Select(session, db.TableTripParticipant, request.QueryStringParameters, Participant{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -83,33 +62,14 @@ func (p *Participant) GetAll(request events.APIGatewayProxyRequest) (events.APIG
 }
 
 //SaveNew add a new participant to the trip
-func (p *Participant) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (p *Participant) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("role", ParticipantOwnerRole),
-				dbr.Eq("role", ParticipantAdminRole),
-			),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip owner or admins can make changes"))
-		}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceParticipant, ActionCreate, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
 	err = json.Unmarshal([]byte(request.Body), p)
@@ -124,64 +84,31 @@ func (p *Participant) SaveNew(request events.APIGatewayProxyRequest) (events.API
 	p.UpdatedBy = tokenUser.UserID
 	p.UpdatedDate = time.Now()
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Insert(tx, db.TableTripParticipant, *p)
+	err = repo.Participants.Create(*p, tripEvent(EventParticipantAdded, p.ID, p.TripID, tokenUser.UserID, p))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
 	return common.APIResponse(p, http.StatusCreated)
 }
 
 //Update change participant attributes
-func (p *Participant) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (p *Participant) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	filter := dbr.And(
-		dbr.Eq("id", request.PathParameters["participant_id"]),
-		dbr.Eq("trip_id", request.PathParameters["id"]),
-		dbr.Neq("role", ParticipantOwnerRole),
-	)
-	total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceParticipant, ActionUpdate, "")
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return authorizationError(err)
 	}
 
-	if total <= 0 {
-		return common.APIError(http.StatusForbidden, errors.New("trip owner can't be updated"))
+	participant, err := tripParticipant(repo, request.PathParameters["id"], request.PathParameters["participant_id"])
+	if err != nil {
+		return resourceError(err)
 	}
-
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("role", ParticipantOwnerRole),
-				dbr.Eq("role", ParticipantAdminRole),
-			),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip admin can update participants"))
-		}
+	if participant.Role == ParticipantOwnerRole {
+		return common.APIError(http.StatusForbidden, errors.New("trip owner can't be updated"))
 	}
 
 	jsonMap := make(map[string]interface{})
@@ -193,20 +120,28 @@ func (p *Participant) Update(request events.APIGatewayProxyRequest) (events.APIG
 	jsonMap["updated_by"] = tokenUser.UserID
 	jsonMap["updated_date"] = time.Now()
 
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-
-	err = db.Update(tx, db.TableTripParticipant, request.PathParameters["participant_id"], *p, jsonMap)
+	changes := []outbox.Event{tripEvent(EventParticipantUpdated, participant.ID, participant.TripID, tokenUser.UserID, jsonMap)}
+	if role, ok := jsonMap["role"].(string); ok && role != participant.Role {
+		changes = append(changes, outbox.Event{
+			Type:        EventParticipantRoleChange,
+			AggregateID: participant.ID,
+			Actor:       tokenUser.UserID,
+			Data: RoleChange{
+				ParticipantID: participant.ID,
+				TripID:        participant.TripID,
+				UserID:        participant.UserID,
+				PreviousRole:  participant.Role,
+				Role:          role,
+			},
+		})
+	}
+
+	err = repo.Participants.Update(request.PathParameters["participant_id"], jsonMap, changes...)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableTripParticipant, request.PathParameters["participant_id"], Participant{})
+	result, err := repo.Participants.Get(request.PathParameters["participant_id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -215,52 +150,37 @@ func (p *Participant) Update(request events.APIGatewayProxyRequest) (events.APIG
 }
 
 //Delete remove participant
-func (p *Participant) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+func (p *Participant) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceParticipant, ActionDelete, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	filter := dbr.And(
-		dbr.Eq("id", request.PathParameters["participant_id"]),
-		dbr.Eq("trip_id", request.PathParameters["id"]),
-		dbr.Neq("role", ParticipantOwnerRole),
-	)
-	total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
+	participant, err := tripParticipant(repo, request.PathParameters["id"], request.PathParameters["participant_id"])
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return resourceError(err)
 	}
-	if total <= 0 {
+	if participant.Role == ParticipantOwnerRole {
 		return common.APIError(http.StatusForbidden, errors.New("trip owner can't be deleted"))
 	}
 
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("role", ParticipantOwnerRole),
-				dbr.Eq("role", ParticipantAdminRole),
-			),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip participant can access this resource"))
-		}
-	}
-
-	err = db.Delete(session, db.TableTripParticipant, request.PathParameters["participant_id"])
+	err = repo.Participants.Delete(participant.ID, tripEvent(EventParticipantRemoved, participant.ID, participant.TripID, tokenUser.UserID, participant))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
 	return common.APIResponse(nil, http.StatusOK)
 }
+
+//tripParticipant loads the participant returning db.ErrNotFound when it doesn't belong to the trip
+func tripParticipant(repo Repository, tripID, participantID string) (Participant, error) {
+	participant, err := repo.Participants.Get(participantID)
+	if err == nil && participant.TripID != tripID {
+		return Participant{}, db.ErrNotFound
+	}
+	return participant, err
+}
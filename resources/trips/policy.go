@@ -0,0 +1,175 @@
+package trips
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+)
+
+const (
+	//ActionView defines the permission to read trip resources
+	ActionView = "view"
+	//ActionCreate defines the permission to create trip resources
+	ActionCreate = "create"
+	//ActionUpdate defines the permission to change trip resources
+	ActionUpdate = "update"
+	//ActionDelete defines the permission to remove trip resources
+	ActionDelete = "delete"
+	//ActionRestore defines the permission to bring back soft deleted trip resources
+	ActionRestore = "restore"
+)
+
+const (
+	//ResourceTrip defines the trip itself in the policy
+	ResourceTrip = "trip"
+	//ResourceParticipant defines the trip participants in the policy
+	ResourceParticipant = "participant"
+	//ResourceInvite defines the trip invites in the policy
+	ResourceInvite = "invite"
+	//ResourceItinerary defines the trip itineraries in the policy
+	ResourceItinerary = "itinerary"
+	//ResourceItineraryEvent defines the trip itineraries events in the policy
+	ResourceItineraryEvent = "itinerary_event"
+)
+
+//policyRule lists the participant roles allowed to run an action, creator also allows the participant that created the resource
+type policyRule struct {
+	roles   []string
+	creator bool
+}
+
+var (
+	everyone = []string{ParticipantOwnerRole, ParticipantAdminRole, ParticipantEditorRole, ParticipantViewerRole}
+	managers = []string{ParticipantOwnerRole, ParticipantAdminRole}
+	editors  = []string{ParticipantOwnerRole, ParticipantAdminRole, ParticipantEditorRole}
+)
+
+//tripPolicy maps the resource and action to the rule evaluated for the trip participants
+var tripPolicy = map[string]map[string]policyRule{
+	ResourceTrip: {
+		ActionView:    {roles: everyone},
+		ActionUpdate:  {roles: managers},
+		ActionDelete:  {roles: []string{ParticipantOwnerRole}},
+		ActionRestore: {roles: []string{ParticipantOwnerRole}},
+	},
+	ResourceParticipant: {
+		ActionView:   {roles: everyone},
+		ActionCreate: {roles: managers},
+		ActionUpdate: {roles: managers},
+		ActionDelete: {roles: managers},
+	},
+	ResourceInvite: {
+		ActionView:   {roles: everyone},
+		ActionCreate: {roles: managers},
+		ActionDelete: {roles: managers, creator: true},
+	},
+	ResourceItinerary: {
+		ActionView:    {roles: everyone},
+		ActionCreate:  {roles: editors},
+		ActionUpdate:  {roles: managers, creator: true},
+		ActionDelete:  {roles: managers, creator: true},
+		ActionRestore: {roles: managers, creator: true},
+	},
+	ResourceItineraryEvent: {
+		ActionView:   {roles: everyone},
+		ActionCreate: {roles: editors},
+		ActionUpdate: {roles: editors},
+		ActionDelete: {roles: editors},
+	},
+}
+
+var policyResourceNames = map[string]string{
+	ResourceTrip:           "trip",
+	ResourceParticipant:    "participants",
+	ResourceInvite:         "invites",
+	ResourceItinerary:      "itineraries",
+	ResourceItineraryEvent: "itinerary events",
+}
+
+//Allowed evaluates the trip policy for a participant role, an empty role means the user doesn't participate in the trip
+func Allowed(role, resource, action string, creator bool) bool {
+	if role == "" {
+		return false
+	}
+	rule, ok := tripPolicy[resource][action]
+	if !ok {
+		return false
+	}
+	if rule.creator && creator {
+		return true
+	}
+	for _, r := range rule.roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+//PolicyError is returned when the trip policy denies the action to the user
+type PolicyError struct {
+	Role     string `json:"role"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+func (e *PolicyError) Error() string {
+	if e.Role == "" {
+		return fmt.Sprintf("only trip participants can %s %s", e.Action, policyResourceNames[e.Resource])
+	}
+	return fmt.Sprintf("%s participants can't %s %s", e.Role, e.Action, policyResourceNames[e.Resource])
+}
+
+//Detail returns the attributes used in the api error response
+func (e *PolicyError) Detail() interface{} {
+	return e
+}
+
+//IsPolicyError check if the error was caused by the trip policy denying the action
+func IsPolicyError(err error) bool {
+	_, ok := err.(*PolicyError)
+	return ok
+}
+
+//authorize checks the trip policy loading the user role, users in the Admin group are always allowed.
+//createdBy is the creator of the target resource, informed only for the actions allowed to the creator
+func authorize(repo Repository, user *common.TokenUser, tripID, resource, action, createdBy string) error {
+	if user.IsAdmin() {
+		return nil
+	}
+	role, err := repo.Participants.Role(tripID, user.UserID)
+	if err != nil {
+		return err
+	}
+	if !Allowed(role, resource, action, createdBy != "" && createdBy == user.UserID) {
+		return &PolicyError{Role: role, Resource: resource, Action: action}
+	}
+	return nil
+}
+
+//authorizationError returns the api response for the authorize errors
+func authorizationError(err error) (events.APIGatewayProxyResponse, error) {
+	if IsPolicyError(err) {
+		return common.APIError(http.StatusForbidden, err)
+	}
+	return common.APIError(http.StatusInternalServerError, err)
+}
+
+//updateError returns the api response for the errors changing a versioned trip resource
+func updateError(err error) (events.APIGatewayProxyResponse, error) {
+	if err == db.ErrVersionConflict {
+		return common.APIError(http.StatusPreconditionFailed, common.ErrPreconditionFailed)
+	}
+	return resourceError(err)
+}
+
+//resourceError returns the api response for the errors loading a trip resource
+func resourceError(err error) (events.APIGatewayProxyResponse, error) {
+	if err == db.ErrNotFound {
+		return common.APIError(http.StatusNotFound, err)
+	}
+	return common.APIError(http.StatusInternalServerError, err)
+}
@@ -0,0 +1,94 @@
+package trips
+
+import (
+	"testing"
+
+	"github.com/feedmytrip/api/common"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	owner  = ParticipantOwnerRole
+	admin  = ParticipantAdminRole
+	editor = ParticipantEditorRole
+	viewer = ParticipantViewerRole
+)
+
+func TestPolicyMatrix(t *testing.T) {
+	tests := []struct {
+		resource string
+		action   string
+		creator  bool
+		allowed  []string
+	}{
+		{ResourceTrip, ActionView, false, []string{owner, admin, editor, viewer}},
+		{ResourceTrip, ActionCreate, false, []string{}},
+		{ResourceTrip, ActionUpdate, false, []string{owner, admin}},
+		{ResourceTrip, ActionDelete, false, []string{owner}},
+		{ResourceTrip, ActionRestore, false, []string{owner}},
+		{ResourceTrip, ActionRestore, true, []string{owner}},
+
+		{ResourceParticipant, ActionView, false, []string{owner, admin, editor, viewer}},
+		{ResourceParticipant, ActionCreate, false, []string{owner, admin}},
+		{ResourceParticipant, ActionUpdate, false, []string{owner, admin}},
+		{ResourceParticipant, ActionDelete, false, []string{owner, admin}},
+		{ResourceParticipant, ActionDelete, true, []string{owner, admin}},
+		{ResourceParticipant, ActionRestore, false, []string{}},
+
+		{ResourceInvite, ActionView, false, []string{owner, admin, editor, viewer}},
+		{ResourceInvite, ActionCreate, false, []string{owner, admin}},
+		{ResourceInvite, ActionUpdate, false, []string{}},
+		{ResourceInvite, ActionDelete, false, []string{owner, admin}},
+		{ResourceInvite, ActionDelete, true, []string{owner, admin, editor, viewer}},
+
+		{ResourceItinerary, ActionView, false, []string{owner, admin, editor, viewer}},
+		{ResourceItinerary, ActionCreate, false, []string{owner, admin, editor}},
+		{ResourceItinerary, ActionUpdate, false, []string{owner, admin}},
+		{ResourceItinerary, ActionUpdate, true, []string{owner, admin, editor, viewer}},
+		{ResourceItinerary, ActionDelete, false, []string{owner, admin}},
+		{ResourceItinerary, ActionDelete, true, []string{owner, admin, editor, viewer}},
+		{ResourceItinerary, ActionRestore, false, []string{owner, admin}},
+		{ResourceItinerary, ActionRestore, true, []string{owner, admin, editor, viewer}},
+
+		{ResourceItineraryEvent, ActionView, false, []string{owner, admin, editor, viewer}},
+		{ResourceItineraryEvent, ActionCreate, false, []string{owner, admin, editor}},
+		{ResourceItineraryEvent, ActionUpdate, false, []string{owner, admin, editor}},
+		{ResourceItineraryEvent, ActionDelete, false, []string{owner, admin, editor}},
+		{ResourceItineraryEvent, ActionRestore, false, []string{}},
+	}
+
+	for _, test := range tests {
+		for _, role := range []string{owner, admin, editor, viewer, ""} {
+			expected := false
+			for _, r := range test.allowed {
+				if r == role {
+					expected = true
+				}
+			}
+			assert.Equal(t, expected, Allowed(role, test.resource, test.action, test.creator), "role %q %s %s creator=%v", role, test.action, test.resource, test.creator)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	repo := NewMemoryRepository()
+	for i, role := range []string{owner, editor, viewer} {
+		assert.Nil(t, repo.Participants.Create(Participant{ID: string(rune('a' + i)), TripID: "trip", UserID: role, Role: role}))
+	}
+
+	globalAdmin := &common.TokenUser{UserID: "global", Groups: []string{"Admin"}}
+	assert.Nil(t, authorize(repo, globalAdmin, "trip", ResourceTrip, ActionDelete, ""))
+	assert.Nil(t, authorize(repo, globalAdmin, "other", ResourceItinerary, ActionUpdate, ""))
+
+	assert.Nil(t, authorize(repo, &common.TokenUser{UserID: owner}, "trip", ResourceTrip, ActionDelete, ""))
+	assert.Nil(t, authorize(repo, &common.TokenUser{UserID: editor}, "trip", ResourceItineraryEvent, ActionCreate, ""))
+	assert.Nil(t, authorize(repo, &common.TokenUser{UserID: viewer}, "trip", ResourceItinerary, ActionUpdate, viewer))
+
+	err := authorize(repo, &common.TokenUser{UserID: viewer}, "trip", ResourceItineraryEvent, ActionCreate, "")
+	assert.True(t, IsPolicyError(err))
+	assert.Equal(t, "viewer participants can't create itinerary events", err.Error())
+
+	err = authorize(repo, &common.TokenUser{UserID: owner}, "other", ResourceTrip, ActionView, "")
+	assert.True(t, IsPolicyError(err))
+	assert.Equal(t, "only trip participants can view trip", err.Error())
+}
@@ -0,0 +1,614 @@
+package trips
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	fmt "github.com/feedmytrip/api/resources/events"
+	"github.com/feedmytrip/api/resources/users"
+	"github.com/gocraft/dbr"
+)
+
+//TripRepository loads and persists the trips
+type TripRepository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Trip, error)
+	//History returns the changes of the trip and of its participants, invites and itineraries, the most recent first
+	History(id string) ([]db.AuditEntry, error)
+	//Create inserts the trip with its default itinerary and owner participant
+	Create(trip Trip, itinerary Itinerary, owner Participant, events ...outbox.Event) error
+	//Update changes the trip when its version is still the informed one, db.AnyVersion skips the check
+	Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error
+	//Delete marks the trip as removed by the user, the trip is kept until the purge
+	Delete(id, deletedBy string, events ...outbox.Event) error
+	//GetWithDeleted returns the trip even when it is soft deleted
+	GetWithDeleted(id string) (Trip, error)
+	//Restore clears the removal of the soft deleted trip
+	Restore(id string, events ...outbox.Event) error
+}
+
+//ParticipantRepository loads and persists the trips participants
+type ParticipantRepository interface {
+	List(tripID string, params map[string]string) (interface{}, error)
+	Get(id string) (Participant, error)
+	//Role returns the highest role of the user in the trip or an empty string if the user isn't a participant
+	//or the trip is soft deleted
+	Role(tripID, userID string) (string, error)
+	//All returns every participant of the trip
+	All(tripID string) ([]Participant, error)
+	Create(participant Participant, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//InviteRepository loads and persists the trips invites
+type InviteRepository interface {
+	List(tripID string, params map[string]string) (interface{}, error)
+	Get(id string) (Invite, error)
+	Create(invite Invite, events ...outbox.Event) error
+	//Update changes the answer attributes of the invite while it's pending, otherwise it returns ErrInviteConsumed
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	//Accept inserts the participant and updates the answer of the pending invite in a single transaction
+	Accept(id string, participant Participant, values map[string]interface{}, events ...outbox.Event) error
+	//Expire marks the pending invites of the trip past the expiry date as expired
+	Expire(tripID string, now time.Time) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//ItineraryRepository loads and persists the trips itineraries
+type ItineraryRepository interface {
+	List(tripID string, params map[string]string) (interface{}, error)
+	Get(id string) (Itinerary, error)
+	//History returns the changes of the itinerary and of its events, the most recent first
+	History(id string) ([]db.AuditEntry, error)
+	Create(itinerary Itinerary, events ...outbox.Event) error
+	//Update changes the itinerary when its version is still the informed one, db.AnyVersion skips the check
+	Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error
+	//Append inserts the events into the itinerary and updates its attributes
+	Append(id string, itineraryEvents []ItineraryEvent, values map[string]interface{}, events ...outbox.Event) error
+	//ShiftDays updates the itinerary and changes its events by id in a single transaction, when the versions of the
+	//itinerary and of the changed events are still the informed ones
+	ShiftDays(id string, version int64, values map[string]interface{}, changes map[string]EventChange, events ...outbox.Event) error
+	//Delete marks the itinerary as removed by the user, the itinerary is kept until the purge
+	Delete(id, deletedBy string, events ...outbox.Event) error
+	//GetWithDeleted returns the itinerary even when it is soft deleted
+	GetWithDeleted(id string) (Itinerary, error)
+	//Restore clears the removal of the soft deleted itinerary
+	Restore(id string, events ...outbox.Event) error
+}
+
+//ItineraryEventRepository loads and persists the itineraries events
+type ItineraryEventRepository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (ItineraryEvent, error)
+	//All returns every event of the itinerary sorted by begin_offset
+	All(tripID, itineraryID string) ([]ItineraryEvent, error)
+	Create(event ItineraryEvent, events ...outbox.Event) error
+	//Update changes the itinerary event when its version is still the informed one, db.AnyVersion skips the check
+	Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error
+	//UpdateMany changes the events by id in a single transaction, it returns db.ErrVersionConflict when any of them
+	//was changed after the informed version
+	UpdateMany(changes map[string]EventChange, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//EventChange represents the values changing an itinerary event loaded at the version
+type EventChange struct {
+	Version int64
+	Values  map[string]interface{}
+}
+
+//Repository groups the repositories of the trips aggregates
+type Repository struct {
+	Trips           TripRepository
+	Participants    ParticipantRepository
+	Invites         InviteRepository
+	Itineraries     ItineraryRepository
+	ItineraryEvents ItineraryEventRepository
+	GlobalEvents    fmt.EventRepository
+	GlobalSchedules fmt.ScheduleRepository
+	Users           users.Repository
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return Repository{
+		Trips:           tripRepository{store: store},
+		Participants:    participantRepository{store: store},
+		Invites:         inviteRepository{store: store},
+		Itineraries:     itineraryRepository{store: store},
+		ItineraryEvents: itineraryEventRepository{store: store},
+		GlobalEvents:    fmt.NewRepository(store).Events,
+		GlobalSchedules: fmt.NewRepository(store).Schedules,
+		Users:           users.NewRepository(store),
+	}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the trips in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+func tripFilter(tripID string, params map[string]string) map[string]string {
+	filters := map[string]string{}
+	for k, v := range params {
+		filters[k] = v
+	}
+	filters["trip_id"] = tripID
+	return filters
+}
+
+type tripRepository struct {
+	store db.Store
+}
+
+func (r tripRepository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableTrip, params, Trip{})
+}
+
+func (r tripRepository) Get(id string) (Trip, error) {
+	trip := Trip{}
+	err := r.store.LoadOne(db.TableTrip, id, &trip)
+	return trip, err
+}
+
+func (r tripRepository) History(id string) ([]db.AuditEntry, error) {
+	return db.History(r.store, id)
+}
+
+func (r tripRepository) Create(trip Trip, itinerary Itinerary, owner Participant, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableTrip, trip)
+		if err != nil {
+			return err
+		}
+		err = tx.Insert(db.TableTripItinerary, itinerary)
+		if err != nil {
+			return err
+		}
+		err = tx.Insert(db.TableTripParticipant, owner)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r tripRepository) Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableTrip, id, version, Trip{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r tripRepository) Delete(id, deletedBy string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.SoftDelete(tx, db.TableTrip, deletedBy, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r tripRepository) GetWithDeleted(id string) (Trip, error) {
+	trip := Trip{}
+	err := db.LoadWithDeleted(r.store, db.TableTrip, id, &trip)
+	return trip, err
+}
+
+func (r tripRepository) Restore(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.Restore(tx, db.TableTrip, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+var participantRoleRank = map[string]int{
+	ParticipantViewerRole: 1,
+	ParticipantEditorRole: 2,
+	ParticipantAdminRole:  3,
+	ParticipantOwnerRole:  4,
+}
+
+type participantRepository struct {
+	store db.Store
+}
+
+func (r participantRepository) List(tripID string, params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableTripParticipant, tripFilter(tripID, params), Participant{})
+}
+
+func (r participantRepository) Get(id string) (Participant, error) {
+	participant := Participant{}
+	err := r.store.LoadOne(db.TableTripParticipant, id, &participant)
+	return participant, err
+}
+
+func (r participantRepository) Role(tripID, userID string) (string, error) {
+	participants := []Participant{}
+	err := r.store.LoadAll(db.TableTripParticipant, map[string]string{"trip_id": tripID, "user_id": userID}, &participants)
+	if err != nil {
+		return "", err
+	}
+
+	role := ""
+	for _, p := range participants {
+		if role == "" || participantRoleRank[p.Role] > participantRoleRank[role] {
+			role = p.Role
+		}
+	}
+	if role == "" {
+		return role, nil
+	}
+
+	//the participants of soft deleted trips have no role until the trip is restored
+	trips := []struct {
+		ID        string       `db:"id"`
+		DeletedAt dbr.NullTime `db:"deleted_at"`
+	}{}
+	err = r.store.LoadAll(db.TableTrip, map[string]string{"id": tripID, "include_deleted": "true", "count": "none"}, &trips)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range trips {
+		if t.DeletedAt.Valid {
+			return "", nil
+		}
+	}
+	return role, nil
+}
+
+func (r participantRepository) All(tripID string) ([]Participant, error) {
+	participants := []Participant{}
+	err := r.store.LoadAll(db.TableTripParticipant, map[string]string{"trip_id": tripID, "count": "none"}, &participants)
+	return participants, err
+}
+
+func (r participantRepository) Create(participant Participant, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableTripParticipant, participant)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r participantRepository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableTripParticipant, id, Participant{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r participantRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableTripParticipant, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+type inviteRepository struct {
+	store db.Store
+}
+
+func (r inviteRepository) List(tripID string, params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableTripInvite, tripFilter(tripID, params), Invite{})
+}
+
+func (r inviteRepository) Get(id string) (Invite, error) {
+	invite := Invite{}
+	err := r.store.LoadOne(db.TableTripInvite, id, &invite)
+	return invite, err
+}
+
+func (r inviteRepository) Create(invite Invite, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableTripInvite, invite)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+//inviteAnswer defines the invite attributes changed after the invite is created, they are locked in the Invite attributes
+type inviteAnswer struct {
+	Status        string    `json:"status" db:"status"`
+	RespondedBy   string    `json:"responded_by" db:"responded_by"`
+	RespondedDate time.Time `json:"responded_date" db:"responded_date"`
+}
+
+func (r inviteRepository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := answerInvite(tx, id, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r inviteRepository) Accept(id string, participant Participant, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := answerInvite(tx, id, values)
+		if err != nil {
+			return err
+		}
+		err = tx.Insert(db.TableTripParticipant, participant)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r inviteRepository) Expire(tripID string, now time.Time) error {
+	invites := []Invite{}
+	err := r.store.LoadAll(db.TableTripInvite, map[string]string{"trip_id": tripID, "status": InvitePending, "count": "none"}, &invites)
+	if err != nil {
+		return err
+	}
+
+	expired := []Invite{}
+	for _, i := range invites {
+		if !now.Before(i.ExpiresDate) {
+			expired = append(expired, i)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	return r.store.Transaction(func(tx db.Writer) error {
+		for _, i := range expired {
+			err := answerInvite(tx, i.ID, map[string]interface{}{"status": InviteExpired})
+			if err == ErrInviteConsumed {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = outbox.Write(tx, inviteEvent(EventInviteExpired, i, ""))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//answerInvite locks the invite and changes its answer when it's still pending, so the token is used only once
+func answerInvite(tx db.Writer, id string, values map[string]interface{}) error {
+	err := db.UpdateState(tx, db.TableTripInvite, id, "status", InvitePending, inviteAnswer{}, values)
+	if err == db.ErrStateConflict {
+		return ErrInviteConsumed
+	}
+	return err
+}
+
+func (r inviteRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableTripInvite, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+type itineraryRepository struct {
+	store db.Store
+}
+
+func (r itineraryRepository) List(tripID string, params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableTripItinerary, tripFilter(tripID, params), Itinerary{})
+}
+
+func (r itineraryRepository) Get(id string) (Itinerary, error) {
+	itinerary := Itinerary{}
+	err := r.store.LoadOne(db.TableTripItinerary, id, &itinerary)
+	return itinerary, err
+}
+
+func (r itineraryRepository) History(id string) ([]db.AuditEntry, error) {
+	return db.History(r.store, id)
+}
+
+func (r itineraryRepository) Create(itinerary Itinerary, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableTripItinerary, itinerary)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryRepository) Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableTripItinerary, id, version, Itinerary{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryRepository) Append(id string, itineraryEvents []ItineraryEvent, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		for _, e := range itineraryEvents {
+			err := tx.Insert(db.TableTripItineraryEvent, e)
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Update(db.TableTripItinerary, id, Itinerary{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryRepository) ShiftDays(id string, version int64, values map[string]interface{}, changes map[string]EventChange, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableTripItinerary, id, version, Itinerary{}, values)
+		if err != nil {
+			return err
+		}
+		err = updateEvents(tx, changes)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryRepository) Delete(id, deletedBy string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.SoftDelete(tx, db.TableTripItinerary, deletedBy, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryRepository) GetWithDeleted(id string) (Itinerary, error) {
+	itinerary := Itinerary{}
+	err := db.LoadWithDeleted(r.store, db.TableTripItinerary, id, &itinerary)
+	return itinerary, err
+}
+
+func (r itineraryRepository) Restore(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.Restore(tx, db.TableTripItinerary, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+//itineraryEventsPage is the number of events loaded by each query of All
+var itineraryEventsPage = 1000
+
+type itineraryEventRepository struct {
+	store db.Store
+}
+
+func (r itineraryEventRepository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableTripItineraryEvent, params, ItineraryEvent{})
+}
+
+func (r itineraryEventRepository) Get(id string) (ItineraryEvent, error) {
+	event := ItineraryEvent{}
+	err := r.store.LoadOne(db.TableTripItineraryEvent, id, &event)
+	return event, err
+}
+
+//All loads the events in pages sorted by id, so the itineraries with more than one page aren't truncated
+func (r itineraryEventRepository) All(tripID, itineraryID string) ([]ItineraryEvent, error) {
+	itineraryEvents := []ItineraryEvent{}
+	for page := 1; ; page++ {
+		filter := map[string]string{
+			"trip_id":      tripID,
+			"itinerary_id": itineraryID,
+			"page":         strconv.Itoa(page),
+			"results":      strconv.Itoa(itineraryEventsPage),
+			"sort":         "id",
+			"order":        "asc",
+		}
+		loaded := []ItineraryEvent{}
+		err := r.store.LoadAll(db.TableTripItineraryEvent, filter, &loaded)
+		if err != nil {
+			return nil, err
+		}
+		itineraryEvents = append(itineraryEvents, loaded...)
+		if len(loaded) < itineraryEventsPage {
+			break
+		}
+	}
+	sort.SliceStable(itineraryEvents, func(i, j int) bool {
+		return itineraryEvents[i].BeginOffset < itineraryEvents[j].BeginOffset
+	})
+	return itineraryEvents, nil
+}
+
+func (r itineraryEventRepository) Create(event ItineraryEvent, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableTripItineraryEvent, event)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryEventRepository) Update(id string, version int64, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := db.UpdateVersion(tx, db.TableTripItineraryEvent, id, version, ItineraryEvent{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r itineraryEventRepository) UpdateMany(changes map[string]EventChange, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := updateEvents(tx, changes)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+//updateEvents locks and changes the itinerary events in id order, a concurrent change of any of them fails the
+//transaction with db.ErrVersionConflict instead of being overwritten
+func updateEvents(tx db.Writer, changes map[string]EventChange) error {
+	ids := []string{}
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		err := db.UpdateVersion(tx, db.TableTripItineraryEvent, id, changes[id].Version, ItineraryEvent{}, changes[id].Values)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r itineraryEventRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableTripItineraryEvent, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
@@ -0,0 +1,99 @@
+package trips
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItineraryEventsAllPages(t *testing.T) {
+	repo := NewRepository(db.NewMemoryStore())
+	for _, offset := range []float64{3600, -1, 0, 7200, 1800} {
+		err := repo.ItineraryEvents.Create(ItineraryEvent{ID: "event-" + strconv.Itoa(int(offset)), TripID: "trip", ItineraryID: "itinerary", BeginOffset: offset})
+		assert.Nil(t, err)
+	}
+	repo.ItineraryEvents.Create(ItineraryEvent{ID: "other", TripID: "trip", ItineraryID: "other"})
+
+	page := itineraryEventsPage
+	itineraryEventsPage = 2
+	defer func() {
+		itineraryEventsPage = page
+	}()
+
+	itineraryEvents, err := repo.ItineraryEvents.All("trip", "itinerary")
+	assert.Nil(t, err)
+	offsets := []float64{}
+	for _, e := range itineraryEvents {
+		offsets = append(offsets, e.BeginOffset)
+	}
+	assert.Equal(t, []float64{-1, 0, 1800, 3600, 7200}, offsets)
+}
+
+func TestInviteAcceptOnce(t *testing.T) {
+	repo := NewRepository(db.NewMemoryStore())
+	err := repo.Invites.Create(Invite{ID: "invite", TripID: "trip", Role: ParticipantViewerRole, Status: InvitePending})
+	assert.Nil(t, err)
+
+	accept := func(id, userID string) error {
+		return repo.Invites.Accept("invite", Participant{ID: id, TripID: "trip", UserID: userID, Role: ParticipantViewerRole}, answerValues(InviteAccepted, userID))
+	}
+	assert.Nil(t, accept("first", "user"))
+	assert.Equal(t, ErrInviteConsumed, accept("second", "other"))
+	assert.Equal(t, ErrInviteConsumed, repo.Invites.Update("invite", answerValues(InviteDeclined, "other")))
+
+	participants, err := repo.Participants.All("trip")
+	assert.Nil(t, err)
+	if assert.Len(t, participants, 1) {
+		assert.Equal(t, "user", participants[0].UserID)
+	}
+	invite, err := repo.Invites.Get("invite")
+	assert.Nil(t, err)
+	assert.Equal(t, InviteAccepted, invite.Status)
+}
+
+func TestShiftDaysVersions(t *testing.T) {
+	repo := NewRepository(db.NewMemoryStore())
+	assert.Nil(t, repo.Itineraries.Create(Itinerary{ID: "itinerary", TripID: "trip"}))
+	for _, id := range []string{"a", "b"} {
+		assert.Nil(t, repo.ItineraryEvents.Create(ItineraryEvent{ID: id, TripID: "trip", ItineraryID: "itinerary", BeginOffset: 3600}))
+	}
+	//b is moved by another request after it was loaded
+	assert.Nil(t, repo.ItineraryEvents.Update("b", db.AnyVersion, map[string]interface{}{"begin_offset": float64(7200)}))
+
+	changes := map[string]EventChange{
+		"a": {Version: 0, Values: map[string]interface{}{"begin_offset": float64(90000)}},
+		"b": {Version: 0, Values: map[string]interface{}{"begin_offset": float64(90000)}},
+	}
+	assert.Equal(t, db.ErrVersionConflict, repo.Itineraries.ShiftDays("itinerary", 0, map[string]interface{}{}, changes))
+	a, err := repo.ItineraryEvents.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3600), a.BeginOffset)
+
+	changes["b"] = EventChange{Version: 1, Values: changes["b"].Values}
+	assert.Nil(t, repo.Itineraries.ShiftDays("itinerary", 0, map[string]interface{}{}, changes))
+	b, err := repo.ItineraryEvents.Get("b")
+	assert.Nil(t, err)
+	assert.Equal(t, float64(90000), b.BeginOffset)
+}
+
+func TestUpdateManyVersions(t *testing.T) {
+	repo := NewRepository(db.NewMemoryStore())
+	for _, id := range []string{"a", "b"} {
+		assert.Nil(t, repo.ItineraryEvents.Create(ItineraryEvent{ID: id, TripID: "trip", ItineraryID: "itinerary", BeginOffset: -1}))
+	}
+	//b is scheduled by another request after it was loaded
+	assert.Nil(t, repo.ItineraryEvents.Update("b", db.AnyVersion, map[string]interface{}{"begin_offset": float64(7200)}))
+
+	changes := map[string]EventChange{
+		"a": {Version: 0, Values: map[string]interface{}{"begin_offset": float64(3600)}},
+		"b": {Version: 0, Values: map[string]interface{}{"begin_offset": float64(3600)}},
+	}
+	assert.Equal(t, db.ErrVersionConflict, repo.ItineraryEvents.UpdateMany(changes))
+	for id, offset := range map[string]float64{"a": -1, "b": 7200} {
+		e, err := repo.ItineraryEvents.Get(id)
+		assert.Nil(t, err)
+		assert.Equal(t, offset, e.BeginOffset)
+	}
+}
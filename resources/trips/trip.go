@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 	"github.com/gocraft/dbr"
 	"github.com/google/uuid"
@@ -32,46 +33,46 @@ type Trip struct {
 	CreatedDate time.Time          `json:"created_date" db:"created_date" lock:"true"`
 	UpdatedBy   string             `json:"updated_by" db:"updated_by"`
 	UpdatedDate time.Time          `json:"updated_date" db:"updated_date"`
+	DeletedAt   dbr.NullTime       `json:"deleted_at" db:"deleted_at" lock:"true"`
+	DeletedBy   string             `json:"deleted_by" db:"deleted_by" lock:"true"`
+	Version     int64              `json:"version" db:"version" lock:"true"`
 	CreatedUser shared.User        `json:"created_user" table:"user" alias:"created_user" on:"created_user.id = trip.created_by" embedded:"true"`
 	UpdatedUser shared.User        `json:"updated_user" table:"user" alias:"updated_user" on:"updated_user.id = trip.updated_by" embedded:"true"`
 }
 
 //Get return a trip
-func (t *Trip) Get(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
+func (t *Trip) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceTrip, ActionView, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.QueryOne(session, db.TableTrip, request.PathParameters["id"], Trip{})
+	result, err := repo.Trips.Get(request.PathParameters["id"])
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return resourceError(err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIConditionalResponse(request, result, result.Version)
 }
 
 //GetAll returns all trips available in the database
-func (t *Trip) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (t *Trip) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access all trips"))
 	}
 
-	conn, err := db.Connect()
-	defer conn.Close()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+	result, err := repo.Trips.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	result, err := db.Select(session, db.TableTrip, request.QueryStringParameters, Trip{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -80,10 +81,13 @@ func (t *Trip) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayP
 }
 
 //SaveNew creates a new trip
-func (t *Trip) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (t *Trip) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 
-	err := json.Unmarshal([]byte(request.Body), t)
+	err = json.Unmarshal([]byte(request.Body), t)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -106,6 +110,9 @@ func (t *Trip) SaveNew(request events.APIGatewayProxyRequest) (events.APIGateway
 	t.CreatedDate = time.Now()
 	t.UpdatedBy = tokenUser.UserID
 	t.UpdatedDate = time.Now()
+	t.DeletedAt = dbr.NullTime{}
+	t.DeletedBy = ""
+	t.Version = 0
 
 	t.Scope = "user"
 	if tokenUser.IsAdmin() {
@@ -141,136 +148,138 @@ func (t *Trip) SaveNew(request events.APIGatewayProxyRequest) (events.APIGateway
 	ownerParticipant.UpdatedBy = tokenUser.UserID
 	ownerParticipant.UpdatedDate = time.Now()
 
-	conn, err := db.Connect()
+	err = repo.Trips.Create(*t, defaultItinerary, ownerParticipant, outbox.NewEvent(EventTripCreated, t.ID, tokenUser.UserID, t))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
+	result, err := repo.Trips.Get(t.ID)
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
 
-	err = db.Insert(tx, db.TableTrip, *t)
+	return common.APIVersionedResponse(result, result.Version, http.StatusCreated)
+}
+
+//Update change trip attributes in the database, the If-Match header requires the version of the ETag
+func (t *Trip) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	err = db.Insert(tx, db.TableTripItinerary, defaultItinerary)
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceTrip, ActionUpdate, "")
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return authorizationError(err)
+	}
+	version, err := common.IfMatch(request, func() (int64, error) {
+		trip, err := repo.Trips.Get(request.PathParameters["id"])
+		return trip.Version, err
+	})
+	if err != nil {
+		return common.APIError(http.StatusPreconditionFailed, err)
 	}
 
-	err = db.Insert(tx, db.TableTripParticipant, ownerParticipant)
+	jsonMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusBadRequest, err)
 	}
 
-	tx.Commit()
+	jsonMap["updated_by"] = tokenUser.UserID
+	jsonMap["updated_date"] = time.Now()
 
-	result, err := db.QueryOne(session, db.TableTrip, t.ID, Trip{})
+	err = repo.Trips.Update(request.PathParameters["id"], version, jsonMap, tripEvent(EventTripUpdated, request.PathParameters["id"], request.PathParameters["id"], tokenUser.UserID, jsonMap))
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return updateError(err)
 	}
 
-	return common.APIResponse(result, http.StatusCreated)
-}
-
-//Update change trip attributes in the database
-func (t *Trip) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+	result, err := repo.Trips.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
+}
 
-	tokenUser := common.GetTokenUser(request)
-	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Or(
-				dbr.Eq("role", ParticipantOwnerRole),
-				dbr.Eq("role", ParticipantAdminRole),
-			),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
-		if err != nil {
-			return common.APIError(http.StatusInternalServerError, err)
-		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip admin or owner can make changes"))
-		}
+//History returns the changes of the trip and of its participants, invites and itineraries, the most recent first.
+//Admins can read the history of removed trips.
+func (t *Trip) History(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
 	}
-
-	jsonMap := make(map[string]interface{})
-	err = json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceTrip, ActionView, "")
 	if err != nil {
-		return common.APIError(http.StatusBadRequest, err)
+		return authorizationError(err)
 	}
 
-	jsonMap["updated_by"] = tokenUser.UserID
-	jsonMap["updated_date"] = time.Now()
-
-	tx, err := session.Begin()
+	result, err := repo.Trips.History(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
-	defer tx.RollbackUnlessCommitted()
 
-	err = db.Update(tx, db.TableTrip, request.PathParameters["id"], *t, jsonMap)
+	return common.APIResponse(map[string]interface{}{"data": result}, http.StatusOK)
+}
+
+//Delete marks the trip as removed, the owners can restore it until the purge
+func (t *Trip) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	err = authorize(repo, tokenUser, request.PathParameters["id"], ResourceTrip, ActionDelete, "")
+	if err != nil {
+		return authorizationError(err)
 	}
 
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableTrip, request.PathParameters["id"], Trip{})
+	err = repo.Trips.Delete(request.PathParameters["id"], tokenUser.UserID, tripEvent(EventTripDeleted, request.PathParameters["id"], request.PathParameters["id"], tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(result, http.StatusOK)
+	return common.APIResponse(nil, http.StatusOK)
 }
 
-//Delete removes event from the database
-func (t *Trip) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	defer conn.Close()
+//Restore brings back a soft deleted trip with its participants and itineraries
+func (t *Trip) Restore(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
 	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+	trip, err := repo.Trips.GetWithDeleted(request.PathParameters["id"])
+	if err != nil {
+		return resourceError(err)
 	}
 
-	session := conn.NewSession(nil)
-	defer session.Close()
-
-	tokenUser := common.GetTokenUser(request)
+	//the participants of a soft deleted trip have no role, so the policy is checked with the kept participants
 	if !tokenUser.IsAdmin() {
-		filter := dbr.And(
-			dbr.Eq("trip_id", request.PathParameters["id"]),
-			dbr.Eq("user_id", tokenUser.UserID),
-			dbr.Eq("role", ParticipantOwnerRole),
-		)
-		total, err := db.Validate(session, []string{"count(id) total"}, db.TableTripParticipant, filter)
+		participants, err := repo.Participants.All(trip.ID)
 		if err != nil {
 			return common.APIError(http.StatusInternalServerError, err)
 		}
-		if total <= 0 {
-			return common.APIError(http.StatusForbidden, errors.New("only trip participant can access this resource"))
+		role := ""
+		for _, p := range participants {
+			if p.UserID == tokenUser.UserID && (role == "" || participantRoleRank[p.Role] > participantRoleRank[role]) {
+				role = p.Role
+			}
+		}
+		if !Allowed(role, ResourceTrip, ActionRestore, false) {
+			return authorizationError(&PolicyError{Role: role, Resource: ResourceTrip, Action: ActionRestore})
 		}
 	}
+	if !trip.DeletedAt.Valid {
+		return common.APIError(http.StatusConflict, errors.New("trip is not deleted"))
+	}
 
-	err = db.Delete(session, db.TableTrip, request.PathParameters["id"])
+	err = repo.Trips.Restore(trip.ID, tripEvent(EventTripRestored, trip.ID, trip.ID, tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	return common.APIResponse(nil, http.StatusOK)
+	result, err := repo.Trips.Get(trip.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIVersionedResponse(result, result.Version, http.StatusOK)
 }
@@ -0,0 +1,9 @@
+package users
+
+//Domain events written in the outbox by the users mutations
+const (
+	EventUserCreated   = "user.created"
+	EventUserUpdated   = "user.updated"
+	EventUserConfirmed = "user.confirmed"
+	EventUserDeleted   = "user.deleted"
+)
@@ -0,0 +1,112 @@
+package users
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+//Repository loads and persists the users
+type Repository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (User, error)
+	FindByUsername(username string) (User, error)
+	FindByEmail(email string) (User, error)
+	Create(user User, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	SetActive(id string, active bool, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+type repository struct {
+	store db.Store
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return repository{store: store}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the users in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+func (r repository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableUser, params, User{})
+}
+
+func (r repository) Get(id string) (User, error) {
+	user := User{}
+	err := r.store.LoadOne(db.TableUser, id, &user)
+	return user, err
+}
+
+func (r repository) FindByUsername(username string) (User, error) {
+	return r.findOne("username", username)
+}
+
+func (r repository) FindByEmail(email string) (User, error) {
+	return r.findOne("email", email)
+}
+
+func (r repository) findOne(column, value string) (User, error) {
+	users := []User{}
+	err := r.store.LoadAll(db.TableUser, map[string]string{column: value, "count": "none"}, &users)
+	if err != nil {
+		return User{}, err
+	}
+	if len(users) == 0 {
+		return User{}, db.ErrNotFound
+	}
+	return users[0], nil
+}
+
+func (r repository) Create(user User, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableUser, user)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableUser, id, User{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+//userStatus updates the active column, it's locked in the User attributes
+type userStatus struct {
+	Active bool `json:"active" db:"active"`
+}
+
+func (r repository) SetActive(id string, active bool, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableUser, id, userStatus{}, map[string]interface{}{"active": active})
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r repository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableUser, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
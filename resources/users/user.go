@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/feedmytrip/api/common"
 	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
 	"github.com/feedmytrip/api/resources/shared"
 )
 
@@ -40,17 +41,11 @@ type User struct {
 }
 
 //GetAll returns all users available in the database
-func (u *User) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
+func (u *User) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	result, err := repo.List(request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
 	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	result, err := db.Select(session, db.TableUser, request.QueryStringParameters, User{})
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -59,13 +54,16 @@ func (u *User) GetAll(request events.APIGatewayProxyRequest) (events.APIGatewayP
 }
 
 //SaveNew creates a new user
-func (u *User) SaveNew(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (u *User) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	err := json.Unmarshal([]byte(request.Body), u)
+	err = json.Unmarshal([]byte(request.Body), u)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -76,64 +74,36 @@ func (u *User) SaveNew(request events.APIGatewayProxyRequest) (events.APIGateway
 	u.UpdatedBy = tokenUser.UserID
 	u.UpdatedDate = time.Now()
 
-	conn, err := db.Connect()
+	err = repo.Create(*u, outbox.NewEvent(EventUserCreated, u.ID, tokenUser.UserID, u))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Insert(tx, db.TableUser, *u)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
 	return common.APIResponse(u, http.StatusCreated)
 }
 
 //Update change user attributes in the database
-func (u *User) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (u *User) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
 	jsonMap := make(map[string]interface{})
-	err := json.Unmarshal([]byte(request.Body), &jsonMap)
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
 
-	conn, err := db.Connect()
+	err = repo.Update(request.PathParameters["id"], jsonMap, outbox.NewEvent(EventUserUpdated, request.PathParameters["id"], tokenUser.UserID, jsonMap))
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
 
-	session := conn.NewSession(nil)
-	tx, err := session.Begin()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-	defer tx.RollbackUnlessCommitted()
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Update(tx, db.TableUser, request.PathParameters["id"], *u, jsonMap)
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	tx.Commit()
-
-	result, err := db.QueryOne(session, db.TableUser, request.PathParameters["id"], User{})
+	result, err := repo.Get(request.PathParameters["id"])
 	if err != nil {
 		return common.APIError(http.StatusInternalServerError, err)
 	}
@@ -142,22 +112,16 @@ func (u *User) Update(request events.APIGatewayProxyRequest) (events.APIGatewayP
 }
 
 //Delete removes user from the database
-func (u *User) Delete(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	tokenUser := common.GetTokenUser(request)
+func (u *User) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
 	if !tokenUser.IsAdmin() {
 		return common.APIError(http.StatusForbidden, errors.New("only admin users can access this resource"))
 	}
 
-	conn, err := db.Connect()
-	if err != nil {
-		return common.APIError(http.StatusInternalServerError, err)
-	}
-
-	session := conn.NewSession(nil)
-	defer session.Close()
-	defer conn.Close()
-
-	err = db.Delete(session, db.TableUser, request.PathParameters["id"])
+	err = repo.Delete(request.PathParameters["id"], outbox.NewEvent(EventUserDeleted, request.PathParameters["id"], tokenUser.UserID, nil))
 	if err != nil {
 		return common.APIError(http.StatusBadRequest, err)
 	}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/gocraft/dbr"
+	"github.com/google/uuid"
+)
+
+const (
+	//DeliveryPending defines a delivery waiting to be sent, including the ones waiting the backoff of a failed attempt
+	DeliveryPending = "pending"
+	//DeliveryDelivered defines a delivery answered with a 2xx status by the subscription url
+	DeliveryDelivered = "delivered"
+	//DeliveryFailed defines a delivery that failed the max attempts, it can be redelivered manually
+	DeliveryFailed = "failed"
+)
+
+//Delivery represents a request sent to a subscription, the log keeps the attempts and the last response
+type Delivery struct {
+	ID              string       `json:"id" db:"id" lock:"true"`
+	SubscriptionID  string       `json:"subscription_id" db:"subscription_id" lock:"true"`
+	EntryID         string       `json:"entry_id" db:"entry_id" lock:"true"`
+	EventType       string       `json:"event_type" db:"event_type" lock:"true"`
+	Payload         string       `json:"payload" db:"payload" lock:"true"`
+	Status          string       `json:"status" db:"status"`
+	Attempts        int          `json:"attempts" db:"attempts"`
+	ResponseStatus  int          `json:"response_status" db:"response_status"`
+	ResponseBody    string       `json:"response_body" db:"response_body"`
+	LastError       string       `json:"last_error" db:"last_error"`
+	RedeliveryOf    string       `json:"redelivery_of" db:"redelivery_of" lock:"true"`
+	NextAttemptDate time.Time    `json:"next_attempt_date" db:"next_attempt_date"`
+	CreatedDate     time.Time    `json:"created_date" db:"created_date" lock:"true"`
+	DeliveredDate   dbr.NullTime `json:"delivered_date" db:"delivered_date"`
+}
+
+//GetAll returns the delivery log of the subscription
+func (d *Delivery) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	subscription, err := ownSubscription(repo, tokenUser, request.PathParameters["id"])
+	if err != nil {
+		return subscriptionError(err)
+	}
+
+	result, err := repo.Deliveries.List(subscription.ID, request.QueryStringParameters)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(result, http.StatusOK)
+}
+
+//Redeliver sends again the payload of a delivery, a new delivery is logged and sent immediately.
+//Failed attempts of the new delivery are retried by the sender like the other deliveries.
+func (d *Delivery) Redeliver(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	subscription, err := ownSubscription(repo, tokenUser, request.PathParameters["id"])
+	if err != nil {
+		return subscriptionError(err)
+	}
+
+	original, err := repo.Deliveries.Get(request.PathParameters["delivery_id"])
+	if err == nil && original.SubscriptionID != subscription.ID {
+		err = db.ErrNotFound
+	}
+	if err == db.ErrNotFound {
+		return common.APIError(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	now := time.Now()
+	delivery := Delivery{
+		ID:              uuid.New().String(),
+		SubscriptionID:  original.SubscriptionID,
+		EntryID:         original.EntryID,
+		EventType:       original.EventType,
+		Payload:         original.Payload,
+		Status:          DeliveryPending,
+		RedeliveryOf:    original.ID,
+		NextAttemptDate: now,
+		CreatedDate:     now,
+	}
+	err = repo.Deliveries.Create(delivery)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	result, err := NewSender(repo).Deliver(delivery)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(result, http.StatusCreated)
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/google/uuid"
+)
+
+//tripAggregates defines the outbox aggregates belonging to a trip, they are sent to the global and trip subscriptions
+var tripAggregates = map[string]bool{
+	"trip":            true,
+	"participant":     true,
+	"invite":          true,
+	"itinerary":       true,
+	"itinerary_event": true,
+}
+
+//globalAggregates defines the outbox aggregates shared by every trip, they are sent to every subscription
+var globalAggregates = map[string]bool{
+	"event":          true,
+	"event_schedule": true,
+}
+
+//Payload represents the body of the webhook requests
+type Payload struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	TripID      string          `json:"trip_id,omitempty"`
+	Actor       string          `json:"actor"`
+	CreatedDate time.Time       `json:"created_date"`
+	Data        json.RawMessage `json:"data"`
+}
+
+//Fanout is the outbox consumer creating the deliveries of the subscriptions matching each entry.
+//The requests are sent by the Sender, so a slow subscription doesn't hold the other outbox consumers.
+type Fanout struct {
+	repo Repository
+}
+
+//NewFanout returns a Fanout creating the deliveries in the repository
+func NewFanout(repo Repository) Fanout {
+	return Fanout{repo: repo}
+}
+
+//Name identifies the consumer in the outbox deliveries
+func (f Fanout) Name() string {
+	return "webhooks"
+}
+
+//Handle creates one delivery for each active subscription matching the entry. The delivery id is derived from the
+//entry and the subscription, so an entry handled again doesn't duplicate the deliveries.
+func (f Fanout) Handle(entry outbox.Entry) error {
+	aggregate := entry.Aggregate()
+	if !tripAggregates[aggregate] && !globalAggregates[aggregate] {
+		return nil
+	}
+
+	tripID := ""
+	if aggregate == "trip" {
+		tripID = entry.AggregateID
+	} else if tripAggregates[aggregate] {
+		data := struct {
+			TripID string `json:"trip_id"`
+		}{}
+		err := entry.Decode(&data)
+		if err != nil {
+			return err
+		}
+		tripID = data.TripID
+	}
+
+	subscriptions, err := f.repo.Subscriptions.Active()
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, s := range subscriptions {
+		if !s.matches(entry.Type, tripID) {
+			continue
+		}
+		id := uuid.NewSHA1(uuid.NameSpaceOID, []byte(entry.ID+"/"+s.ID)).String()
+		_, err := f.repo.Deliveries.Get(id)
+		if err == nil {
+			continue
+		}
+		if err != db.ErrNotFound {
+			return err
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(Payload{
+				ID:          entry.ID,
+				Type:        entry.Type,
+				AggregateID: entry.AggregateID,
+				TripID:      tripID,
+				Actor:       entry.Actor,
+				CreatedDate: entry.CreatedDate,
+				Data:        json.RawMessage(entry.Payload),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		err = f.repo.Deliveries.Create(Delivery{
+			ID:              id,
+			SubscriptionID:  s.ID,
+			EntryID:         entry.ID,
+			EventType:       entry.Type,
+			Payload:         string(payload),
+			Status:          DeliveryPending,
+			NextAttemptDate: now,
+			CreatedDate:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//matches checks the subscription receives the event, the trip subscriptions only receive the trip aggregates of their trip
+func (s Subscription) matches(eventType, tripID string) bool {
+	aggregate := strings.SplitN(eventType, ".", 2)[0]
+	if s.TripID != "" && tripAggregates[aggregate] && s.TripID != tripID {
+		return false
+	}
+	if strings.TrimSpace(s.EventTypes) == "" {
+		return true
+	}
+	for _, t := range strings.Split(s.EventTypes, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == eventType || t == aggregate+".*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,8 @@
+package webhooks
+
+//Domain events written in the outbox by the webhooks mutations
+const (
+	EventSubscriptionCreated = "webhook.created"
+	EventSubscriptionUpdated = "webhook.updated"
+	EventSubscriptionDeleted = "webhook.deleted"
+)
@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/trips"
+)
+
+//SubscriptionRepository loads and persists the webhook subscriptions
+type SubscriptionRepository interface {
+	List(params map[string]string) (interface{}, error)
+	Get(id string) (Subscription, error)
+	//Active returns the enabled subscriptions
+	Active() ([]Subscription, error)
+	Create(subscription Subscription, events ...outbox.Event) error
+	Update(id string, values map[string]interface{}, events ...outbox.Event) error
+	Delete(id string, events ...outbox.Event) error
+}
+
+//DeliveryRepository loads and persists the webhook deliveries
+type DeliveryRepository interface {
+	List(subscriptionID string, params map[string]string) (interface{}, error)
+	Get(id string) (Delivery, error)
+	//Due returns the pending deliveries with the next attempt before now, the oldest first
+	Due(now time.Time, limit int) ([]Delivery, error)
+	Create(delivery Delivery) error
+	//Update changes the attempt attributes of the delivery
+	Update(id string, values map[string]interface{}) error
+}
+
+//Repository groups the repositories of the webhooks aggregates, the trips are used to check the trip owners
+type Repository struct {
+	Subscriptions SubscriptionRepository
+	Deliveries    DeliveryRepository
+	Trips         trips.Repository
+}
+
+//NewRepository returns a Repository on top of the store
+func NewRepository(store db.Store) Repository {
+	return Repository{
+		Subscriptions: subscriptionRepository{store: store},
+		Deliveries:    deliveryRepository{store: store},
+		Trips:         trips.NewRepository(store),
+	}
+}
+
+//NewMySQLRepository returns a Repository backed by the MySQL database
+func NewMySQLRepository() Repository {
+	return NewRepository(db.NewMySQLStore())
+}
+
+//NewMemoryRepository returns a Repository keeping the webhooks in memory
+func NewMemoryRepository() Repository {
+	return NewRepository(db.NewMemoryStore())
+}
+
+type subscriptionRepository struct {
+	store db.Store
+}
+
+func (r subscriptionRepository) List(params map[string]string) (interface{}, error) {
+	return r.store.Select(db.TableWebhookSubscription, params, Subscription{})
+}
+
+func (r subscriptionRepository) Get(id string) (Subscription, error) {
+	subscription := Subscription{}
+	err := r.store.LoadOne(db.TableWebhookSubscription, id, &subscription)
+	return subscription, err
+}
+
+func (r subscriptionRepository) Active() ([]Subscription, error) {
+	subscriptions := []Subscription{}
+	err := r.store.LoadAll(db.TableWebhookSubscription, map[string]string{"active": "true", "count": "none"}, &subscriptions)
+	return subscriptions, err
+}
+
+func (r subscriptionRepository) Create(subscription Subscription, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Insert(db.TableWebhookSubscription, subscription)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+//subscriptionChange defines the subscription attributes changed by the owner, the secret is hidden in the Subscription attributes
+type subscriptionChange struct {
+	URL         string    `json:"url" db:"url"`
+	EventTypes  string    `json:"event_types" db:"event_types"`
+	Active      bool      `json:"active" db:"active"`
+	Secret      string    `json:"secret" db:"secret"`
+	UpdatedBy   string    `json:"updated_by" db:"updated_by"`
+	UpdatedDate time.Time `json:"updated_date" db:"updated_date"`
+}
+
+func (r subscriptionRepository) Update(id string, values map[string]interface{}, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Update(db.TableWebhookSubscription, id, subscriptionChange{}, values)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+func (r subscriptionRepository) Delete(id string, events ...outbox.Event) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		err := tx.Delete(db.TableWebhookSubscription, id)
+		if err != nil {
+			return err
+		}
+		return outbox.Write(tx, events...)
+	})
+}
+
+type deliveryRepository struct {
+	store db.Store
+}
+
+func (r deliveryRepository) List(subscriptionID string, params map[string]string) (interface{}, error) {
+	filters := map[string]string{}
+	for k, v := range params {
+		filters[k] = v
+	}
+	filters["subscription_id"] = subscriptionID
+	return r.store.Select(db.TableWebhookDelivery, filters, Delivery{})
+}
+
+func (r deliveryRepository) Get(id string) (Delivery, error) {
+	delivery := Delivery{}
+	err := r.store.LoadOne(db.TableWebhookDelivery, id, &delivery)
+	return delivery, err
+}
+
+func (r deliveryRepository) Due(now time.Time, limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := r.store.LoadAll(db.TableWebhookDelivery, map[string]string{
+		"status":                 DeliveryPending,
+		"next_attempt_date[lte]": now.Format(time.RFC3339Nano),
+		"sort":                   "created_date",
+		"order":                  "asc",
+		"page":                   "1",
+		"results":                strconv.Itoa(limit),
+		"count":                  "none",
+	}, &deliveries)
+	return deliveries, err
+}
+
+func (r deliveryRepository) Create(delivery Delivery) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		return tx.Insert(db.TableWebhookDelivery, delivery)
+	})
+}
+
+func (r deliveryRepository) Update(id string, values map[string]interface{}) error {
+	return r.store.Transaction(func(tx db.Writer) error {
+		return tx.Update(db.TableWebhookDelivery, id, Delivery{}, values)
+	})
+}
@@ -0,0 +1,196 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultBatchSize   = 50
+	maxBackoff         = 6 * time.Hour
+	//maxResponseBody defines the size of the response body kept in the delivery log
+	maxResponseBody = 1024
+)
+
+//Signature returns the X-FMT-Signature header value of the body sent at timestamp, the v1 value is the hex encoded
+//HMAC-SHA256 of "<timestamp>.<body>" with the subscription secret
+func Signature(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//Sender posts the pending deliveries to the subscriptions. A delivery answered with a status other than 2xx
+//is retried with exponential backoff and marked as failed after MaxAttempts.
+type Sender struct {
+	repo        Repository
+	Client      *http.Client
+	MaxAttempts int
+	BatchSize   int
+	//Backoff returns the delay before the next attempt, it defaults to one minute doubled on each attempt up to six hours
+	Backoff func(attempts int) time.Duration
+	now     func() time.Time
+}
+
+//NewSender returns a Sender of the repository deliveries
+func NewSender(repo Repository) *Sender {
+	return &Sender{
+		repo:        repo,
+		Client:      newClient(),
+		MaxAttempts: defaultMaxAttempts,
+		BatchSize:   defaultBatchSize,
+		Backoff:     defaultBackoff,
+		now:         time.Now,
+	}
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+//Send posts one batch of the deliveries due, it returns the number of processed deliveries by outcome
+func (s *Sender) Send() (outbox.Result, error) {
+	result := outbox.Result{}
+	deliveries, err := s.repo.Deliveries.Due(s.now(), s.BatchSize)
+	if err != nil {
+		return result, err
+	}
+
+	for _, d := range deliveries {
+		d, err = s.Deliver(d)
+		if err != nil {
+			return result, err
+		}
+		switch d.Status {
+		case DeliveryDelivered:
+			result.Delivered++
+		case DeliveryFailed:
+			result.Dead++
+		default:
+			result.Retried++
+		}
+	}
+	return result, nil
+}
+
+//Drain sends batches until there are no deliveries due or the context is done
+func (s *Sender) Drain(ctx context.Context) (outbox.Result, error) {
+	total := outbox.Result{}
+	for {
+		select {
+		case <-ctx.Done():
+			return total, nil
+		default:
+		}
+
+		result, err := s.Send()
+		total.Delivered += result.Delivered
+		total.Retried += result.Retried
+		total.Dead += result.Dead
+		if err != nil {
+			return total, err
+		}
+		if result.Delivered+result.Retried+result.Dead < s.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+//Deliver posts the delivery to its subscription and records the attempt, it returns the updated delivery.
+//The error is only returned when the attempt can't be recorded, the request errors are kept in the delivery.
+func (s *Sender) Deliver(delivery Delivery) (Delivery, error) {
+	now := s.now()
+	values := map[string]interface{}{}
+
+	subscription, err := s.repo.Subscriptions.Get(delivery.SubscriptionID)
+	if err != nil && err != db.ErrNotFound {
+		return delivery, err
+	}
+	switch {
+	case err == db.ErrNotFound:
+		values["status"] = DeliveryFailed
+		values["last_error"] = "subscription not found"
+	case !subscription.Active:
+		values["status"] = DeliveryFailed
+		values["last_error"] = "subscription inactive"
+	default:
+		values = s.post(subscription, delivery, now)
+	}
+
+	err = s.repo.Deliveries.Update(delivery.ID, values)
+	if err != nil {
+		return delivery, err
+	}
+	return s.repo.Deliveries.Get(delivery.ID)
+}
+
+//post sends the request and returns the delivery changes
+func (s *Sender) post(subscription Subscription, delivery Delivery, now time.Time) map[string]interface{} {
+	attempts := delivery.Attempts + 1
+	values := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": 0,
+		"response_body":   "",
+		"last_error":      "",
+	}
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "FeedMyTrip-Webhooks/1.0")
+		req.Header.Set("X-FMT-Event", delivery.EventType)
+		req.Header.Set("X-FMT-Delivery", delivery.ID)
+		req.Header.Set("X-FMT-Timestamp", strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set("X-FMT-Signature", Signature(subscription.SigningSecret, now.Unix(), body))
+
+		var resp *http.Response
+		resp, err = s.Client.Do(req)
+		if err == nil {
+			response, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+			resp.Body.Close()
+			values["response_status"] = resp.StatusCode
+			values["response_body"] = string(response)
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				values["status"] = DeliveryDelivered
+				values["delivered_date"] = now
+				return values
+			}
+			values["last_error"] = resp.Status
+		}
+	}
+	if err != nil {
+		values["last_error"] = err.Error()
+	}
+	log.Printf("webhooks: delivery %s to %s failed: %s", delivery.ID, subscription.URL, values["last_error"])
+
+	if attempts >= s.MaxAttempts {
+		values["status"] = DeliveryFailed
+		return values
+	}
+	values["status"] = DeliveryPending
+	values["next_attempt_date"] = now.Add(s.Backoff(attempts))
+	return values
+}
@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	//printf "1565000000.{}" | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "t=1565000000,v1=e91c501204fadf89284a1ba96116b746b0c4760c8278c83f654d197572c56d98", Signature("secret", 1565000000, []byte("{}")))
+	assert.NotEqual(t, Signature("secret", 1565000000, []byte("{}")), Signature("other", 1565000000, []byte("{}")))
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	global := Subscription{EventTypes: ""}
+	assert.True(t, global.matches("trip.updated", "1"))
+	assert.True(t, global.matches("event.created", ""))
+
+	trip := Subscription{TripID: "1", EventTypes: "trip.*, event.updated"}
+	assert.True(t, trip.matches("trip.updated", "1"))
+	assert.False(t, trip.matches("trip.updated", "2"))
+	assert.False(t, trip.matches("itinerary.updated", "1"))
+	assert.True(t, trip.matches("event.updated", ""))
+	assert.False(t, trip.matches("event.deleted", ""))
+}
+
+func TestSenderRetriesAndFails(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	t.Setenv("FMT_WEBHOOK_PRIVATE_TARGETS", "true")
+
+	repo := NewMemoryRepository()
+	now := time.Now()
+	s := NewSender(repo)
+	s.now = func() time.Time { return now }
+	s.MaxAttempts = 2
+
+	repo.Subscriptions.Create(Subscription{ID: "s", URL: receiver.URL, Active: true, SigningSecret: "secret"})
+	for _, id := range []string{"d1", "d2"} {
+		repo.Deliveries.Create(Delivery{ID: id, SubscriptionID: "s", EventType: "trip.updated", Payload: "{}", Status: DeliveryPending, NextAttemptDate: now, CreatedDate: now})
+	}
+
+	result, err := s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Retried)
+	d, _ := repo.Deliveries.Get("d1")
+	assert.Equal(t, DeliveryPending, d.Status)
+	assert.Equal(t, http.StatusServiceUnavailable, d.ResponseStatus)
+	assert.Equal(t, 1, d.Attempts)
+
+	//the deliveries wait the backoff before the next attempt
+	result, err = s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Retried)
+
+	now = now.Add(defaultBackoff(1))
+	repo.Deliveries.Update("d2", map[string]interface{}{"next_attempt_date": now.Add(time.Hour)})
+	result, err = s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Dead)
+	d, _ = repo.Deliveries.Get("d1")
+	assert.Equal(t, DeliveryFailed, d.Status)
+	assert.Equal(t, 2, d.Attempts)
+
+	status = http.StatusNoContent
+	now = now.Add(time.Hour)
+	result, err = s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Delivered)
+	d, _ = repo.Deliveries.Get("d2")
+	assert.Equal(t, DeliveryDelivered, d.Status)
+	assert.True(t, d.DeliveredDate.Valid)
+}
+
+func TestSenderBatchSize(t *testing.T) {
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer receiver.Close()
+	t.Setenv("FMT_WEBHOOK_PRIVATE_TARGETS", "true")
+
+	repo := NewMemoryRepository()
+	now := time.Now()
+	s := NewSender(repo)
+	s.now = func() time.Time { return now }
+	s.BatchSize = 2
+
+	repo.Subscriptions.Create(Subscription{ID: "s", URL: receiver.URL, Active: true, SigningSecret: "secret"})
+	for i, id := range []string{"d1", "d2", "d3"} {
+		created := now.Add(time.Duration(i) * time.Second)
+		repo.Deliveries.Create(Delivery{ID: id, SubscriptionID: "s", EventType: "trip.updated", Payload: "{}", Status: DeliveryPending, NextAttemptDate: now, CreatedDate: created})
+	}
+
+	result, err := s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Delivered)
+	assert.Equal(t, 2, received)
+	d, _ := repo.Deliveries.Get("d3")
+	assert.Equal(t, DeliveryPending, d.Status)
+
+	result, err = s.Send()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Delivered)
+	assert.Equal(t, 3, received)
+}
+
+func TestSenderInactiveSubscription(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.Subscriptions.Create(Subscription{ID: "s", URL: "http://127.0.0.1:1", Active: false})
+	repo.Deliveries.Create(Delivery{ID: "d", SubscriptionID: "s", Status: DeliveryPending, NextAttemptDate: time.Now(), CreatedDate: time.Now()})
+
+	d, err := NewSender(repo).Deliver(Delivery{ID: "d", SubscriptionID: "s"})
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryFailed, d.Status)
+	assert.Equal(t, "subscription inactive", d.LastError)
+}
+
+func TestSenderPrivateTargets(t *testing.T) {
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		http.Redirect(w, r, "/redirected", http.StatusFound)
+	}))
+	defer receiver.Close()
+
+	repo := NewMemoryRepository()
+	repo.Subscriptions.Create(Subscription{ID: "s", URL: receiver.URL, Active: true, SigningSecret: "secret"})
+	repo.Deliveries.Create(Delivery{ID: "d", SubscriptionID: "s", Status: DeliveryPending, NextAttemptDate: time.Now(), CreatedDate: time.Now()})
+
+	d, err := NewSender(repo).Deliver(Delivery{ID: "d", SubscriptionID: "s", Payload: "{}"})
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryPending, d.Status)
+	assert.Contains(t, d.LastError, errPrivateTarget.Error())
+	assert.Equal(t, 0, received)
+
+	//the redirects are not followed
+	t.Setenv("FMT_WEBHOOK_PRIVATE_TARGETS", "true")
+	d, err = NewSender(repo).Deliver(Delivery{ID: "d", SubscriptionID: "s", Payload: "{}"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusFound, d.ResponseStatus)
+	assert.Equal(t, 1, received)
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.Nil(t, validateURL("https://93.184.216.34/webhooks"))
+	for _, target := range []string{
+		"ftp://93.184.216.34",
+		"/webhooks",
+		"http://127.0.0.1:8080",
+		"http://localhost",
+		"http://10.0.0.1",
+		"http://192.168.0.10",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]",
+		"http://0.0.0.0",
+	} {
+		assert.NotNil(t, validateURL(target), target)
+	}
+
+	t.Setenv("FMT_WEBHOOK_PRIVATE_TARGETS", "true")
+	assert.Nil(t, validateURL("http://127.0.0.1:8080"))
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, defaultBackoff(1))
+	assert.Equal(t, 4*time.Minute, defaultBackoff(3))
+	assert.Equal(t, maxBackoff, defaultBackoff(20))
+}
@@ -0,0 +1,277 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/outbox"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/google/uuid"
+)
+
+//Subscription represents a partner endpoint receiving the changes of the trips and events.
+//Subscriptions with a trip receive the changes of that trip and of the global events, the others receive every change.
+type Subscription struct {
+	ID            string    `json:"id" db:"id" lock:"true"`
+	TripID        string    `json:"trip_id" db:"trip_id" lock:"true"`
+	URL           string    `json:"url" db:"url"`
+	EventTypes    string    `json:"event_types" db:"event_types"`
+	Active        bool      `json:"active" db:"active"`
+	SigningSecret string    `json:"-" db:"secret" lock:"true"`
+	Secret        string    `json:"secret,omitempty"`
+	CreatedBy     string    `json:"created_by" db:"created_by" lock:"true"`
+	CreatedDate   time.Time `json:"created_date" db:"created_date" lock:"true"`
+	UpdatedBy     string    `json:"updated_by" db:"updated_by"`
+	UpdatedDate   time.Time `json:"updated_date" db:"updated_date"`
+}
+
+//Get returns the subscription, the secret is only returned when the subscription is created or the secret is changed
+func (s *Subscription) Get(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	subscription, err := ownSubscription(repo, tokenUser, request.PathParameters["id"])
+	if err != nil {
+		return subscriptionError(err)
+	}
+
+	return common.APIResponse(subscription, http.StatusOK)
+}
+
+//GetAll returns the subscriptions created by the user, admins see every subscription
+func (s *Subscription) GetAll(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	params := map[string]string{}
+	for k, v := range request.QueryStringParameters {
+		params[k] = v
+	}
+	if !tokenUser.IsAdmin() {
+		params["created_by"] = tokenUser.UserID
+	}
+
+	result, err := repo.Subscriptions.List(params)
+	if db.IsFilterError(err) {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(result, http.StatusOK)
+}
+
+//SaveNew creates a new subscription, admins can subscribe to every change and the trip owners to the changes of their trips
+func (s *Subscription) SaveNew(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	err = json.Unmarshal([]byte(request.Body), s)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+
+	if s.TripID == "" && !tokenUser.IsAdmin() {
+		return common.APIError(http.StatusForbidden, errors.New("only admin users can subscribe to every trip, inform the trip_id"))
+	}
+	if s.TripID != "" && !tokenUser.IsAdmin() {
+		role, err := repo.Trips.Participants.Role(s.TripID, tokenUser.UserID)
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+		if role != trips.ParticipantOwnerRole {
+			return common.APIError(http.StatusForbidden, errors.New("only the trip owner can subscribe to the trip"))
+		}
+	}
+	err = validateURL(s.URL)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	err = validateEventTypes(s.EventTypes)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+	if s.Secret == "" {
+		s.Secret, err = newSecret()
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+	}
+
+	s.ID = uuid.New().String()
+	s.Active = true
+	s.SigningSecret = s.Secret
+	s.CreatedBy = tokenUser.UserID
+	s.CreatedDate = time.Now()
+	s.UpdatedBy = tokenUser.UserID
+	s.UpdatedDate = time.Now()
+
+	subscription := *s
+	subscription.Secret = ""
+	err = repo.Subscriptions.Create(subscription, outbox.NewEvent(EventSubscriptionCreated, s.ID, tokenUser.UserID, subscription))
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(s, http.StatusCreated)
+}
+
+//Update change the subscription url, event types, active flag or secret
+func (s *Subscription) Update(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	subscription, err := ownSubscription(repo, tokenUser, request.PathParameters["id"])
+	if err != nil {
+		return subscriptionError(err)
+	}
+
+	jsonMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(request.Body), &jsonMap)
+	if err != nil {
+		return common.APIError(http.StatusBadRequest, err)
+	}
+
+	if v, ok := jsonMap["url"]; ok {
+		u, _ := v.(string)
+		err = validateURL(u)
+		if err != nil {
+			return common.APIError(http.StatusBadRequest, err)
+		}
+	}
+	if v, ok := jsonMap["event_types"]; ok {
+		types, isString := v.(string)
+		if !isString {
+			return common.APIError(http.StatusBadRequest, errors.New("event_types must be a comma separated list"))
+		}
+		err = validateEventTypes(types)
+		if err != nil {
+			return common.APIError(http.StatusBadRequest, err)
+		}
+	}
+	secret, rotate := jsonMap["secret"].(string)
+	if rotate && secret == "" {
+		secret, err = newSecret()
+		if err != nil {
+			return common.APIError(http.StatusInternalServerError, err)
+		}
+		jsonMap["secret"] = secret
+	}
+
+	jsonMap["updated_by"] = tokenUser.UserID
+	jsonMap["updated_date"] = time.Now()
+
+	changes := outbox.NewEvent(EventSubscriptionUpdated, subscription.ID, tokenUser.UserID, jsonMap)
+	delete(changes.Data.(map[string]interface{}), "secret")
+	err = repo.Subscriptions.Update(subscription.ID, jsonMap, changes)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	result, err := repo.Subscriptions.Get(subscription.ID)
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+	if rotate {
+		result.Secret = secret
+	}
+
+	return common.APIResponse(result, http.StatusOK)
+}
+
+//Delete removes the subscription with its deliveries
+func (s *Subscription) Delete(request events.APIGatewayProxyRequest, repo Repository) (events.APIGatewayProxyResponse, error) {
+	tokenUser, err := common.GetTokenUser(request)
+	if err != nil {
+		return common.APIError(http.StatusUnauthorized, err)
+	}
+
+	subscription, err := ownSubscription(repo, tokenUser, request.PathParameters["id"])
+	if err != nil {
+		return subscriptionError(err)
+	}
+
+	err = repo.Subscriptions.Delete(subscription.ID, outbox.NewEvent(EventSubscriptionDeleted, subscription.ID, tokenUser.UserID, nil))
+	if err != nil {
+		return common.APIError(http.StatusInternalServerError, err)
+	}
+
+	return common.APIResponse(nil, http.StatusOK)
+}
+
+//errSubscriptionForbidden is returned when the user isn't an admin nor the subscription creator
+var errSubscriptionForbidden = errors.New("only the subscription creator can access it")
+
+//ownSubscription loads the subscription checking the user can manage it
+func ownSubscription(repo Repository, user *common.TokenUser, id string) (Subscription, error) {
+	subscription, err := repo.Subscriptions.Get(id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if !user.IsAdmin() && subscription.CreatedBy != user.UserID {
+		return Subscription{}, errSubscriptionForbidden
+	}
+	return subscription, nil
+}
+
+//subscriptionError returns the api response for the errors loading a subscription
+func subscriptionError(err error) (events.APIGatewayProxyResponse, error) {
+	switch err {
+	case db.ErrNotFound:
+		return common.APIError(http.StatusNotFound, err)
+	case errSubscriptionForbidden:
+		return common.APIError(http.StatusForbidden, err)
+	}
+	return common.APIError(http.StatusInternalServerError, err)
+}
+
+//validateURL checks the subscription url is an absolute http or https url of a public address
+func validateURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid url, it must be an absolute http or https url")
+	}
+	return validateHost(u.Hostname())
+}
+
+//validateEventTypes checks the comma separated filters, like "trip.*,event.updated", an empty filter matches every event
+func validateEventTypes(types string) error {
+	for _, t := range strings.Split(types, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || t == "*" {
+			continue
+		}
+		parts := strings.SplitN(t, ".", 2)
+		if len(parts) != 2 || parts[1] == "" || (!tripAggregates[parts[0]] && !globalAggregates[parts[0]]) {
+			return errors.New("invalid event type " + t)
+		}
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+//errPrivateTarget is returned when the webhook url reaches an address out of the public internet
+var errPrivateTarget = errors.New("invalid url, it must target a public address")
+
+//privateTargetsAllowed returns true when FMT_WEBHOOK_PRIVATE_TARGETS=true allows the private, loopback and
+//link-local addresses, used by the local environments and the tests receiving the webhooks on the same machine
+func privateTargetsAllowed() bool {
+	return os.Getenv("FMT_WEBHOOK_PRIVATE_TARGETS") == "true"
+}
+
+//publicIP returns false for the private, loopback, link-local, multicast and unspecified addresses, like the
+//instance metadata service at 169.254.169.254
+func publicIP(ip net.IP) bool {
+	return !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+//validateHost resolves the url host and checks every address, the check is repeated when each delivery connects
+//because the name may resolve to other addresses later
+func validateHost(host string) error {
+	if privateTargetsAllowed() {
+		return nil
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(context.Background(), host)
+		if err != nil || len(addrs) == 0 {
+			return errors.New("invalid url, the host can't be resolved")
+		}
+		ips = ips[:0]
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return errPrivateTarget
+		}
+	}
+	return nil
+}
+
+//dialControl refuses the connections to the resolved addresses out of the public internet
+func dialControl(network, address string, c syscall.RawConn) error {
+	if privateTargetsAllowed() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return errPrivateTarget
+	}
+	return nil
+}
+
+//newClient returns the http client of the Sender, it connects only to public addresses without proxies and
+//doesn't follow redirects, a redirect response is a failed delivery
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}).DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
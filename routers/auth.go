@@ -0,0 +1,81 @@
+package routers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/resources/users"
+)
+
+//Auth routes the requests of the auth function, providerErr is returned to every request when the identity provider
+//could not be configured
+func Auth(provider auth.IdentityProvider, providerErr error, userRepository users.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if providerErr != nil {
+			return common.APIError(http.StatusInternalServerError, errors.New("identity provider not configured: "+providerErr.Error()))
+		}
+
+		//the auth endpoints are used before the user has an access token
+		db.SetAuditContext("", req.RequestContext.RequestID)
+
+		authentication := auth.Auth{}
+		switch req.Resource {
+		case "/auth/login":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.Login(req, provider)
+			}
+		case "/auth/refresh":
+			switch req.HTTPMethod {
+			case "GET":
+				return authentication.Refresh(req, provider)
+			}
+		case "/auth/signup":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.SignUp(req, provider, userRepository)
+			}
+		case "/auth/confirm":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.Confirm(req, provider, userRepository)
+			}
+		case "/auth/confirm/resend":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.ResendConfirmation(req, provider)
+			}
+		case "/auth/forgot-password":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.ForgotPassword(req, provider)
+			}
+		case "/auth/reset-password":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.ResetPassword(req, provider)
+			}
+		case "/auth/change-password":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.ChangePassword(req, provider)
+			}
+		case "/auth/logout":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.Logout(req, provider)
+			}
+		case "/auth/register":
+			switch req.HTTPMethod {
+			case "POST":
+				return authentication.Register(req, provider, userRepository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
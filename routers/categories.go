@@ -0,0 +1,48 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/categories"
+)
+
+//Categories routes the requests of the categories function
+func Categories(repository categories.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		category := categories.Category{}
+		switch req.Resource {
+		case "/categories":
+			switch req.HTTPMethod {
+			case "GET":
+				return category.GetAll(req, repository)
+			case "POST":
+				return category.SaveNew(req, repository)
+			}
+		case "/categories/{id}":
+			switch req.HTTPMethod {
+			case "GET":
+				return category.Get(req, repository)
+			case "DELETE":
+				return category.Delete(req, repository)
+			case "PATCH":
+				return category.Update(req, repository)
+			}
+		case "/categories/{id}/history":
+			switch req.HTTPMethod {
+			case "GET":
+				return category.History(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
@@ -0,0 +1,399 @@
+package routers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/openapi"
+	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/resources/categories"
+	fmt "github.com/feedmytrip/api/resources/events"
+	"github.com/feedmytrip/api/resources/highlights"
+	"github.com/feedmytrip/api/resources/locations"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/users"
+	"github.com/feedmytrip/api/resources/webhooks"
+)
+
+//authorizer is the Cognito authorizer of the SAM template, used as the security scheme of the document
+const authorizer = "FMTApiCognitoAuthorizer"
+
+//strictParameter rejects the itinerary events placed out of the schedules of their global events
+var strictParameter = &openapi.Parameter{
+	Name:        "strict",
+	In:          "query",
+	Description: "Rejects with 409 the placements out of the global event schedules instead of returning the warnings",
+	Schema:      &openapi.Schema{Type: "boolean"},
+}
+
+//checkConflictsParameter rejects the itinerary events overlapping others or out of the itinerary days
+var checkConflictsParameter = &openapi.Parameter{
+	Name:        "check_conflicts",
+	In:          "query",
+	Description: "Rejects with 409 the itinerary events overlapping others or out of the itinerary days",
+	Schema:      &openapi.Schema{Type: "boolean"},
+}
+
+var (
+	documentOnce sync.Once
+	document     *openapi.Document
+)
+
+//Document returns the OpenAPI document of the function routes, built on the first call
+func Document() *openapi.Document {
+	documentOnce.Do(func() {
+		document = newSpec().doc
+	})
+	return document
+}
+
+//spec builds the OpenAPI document and keeps the route of every operation added
+type spec struct {
+	doc    *openapi.Document
+	routes []Route
+}
+
+//newSpec documents the routes of every function, the schemas come from the json, db and lock tags of the records
+func newSpec() *spec {
+	s := &spec{doc: openapi.NewDocument(openapi.Info{
+		Title:       "Feed My Trip API",
+		Description: "Trips, itineraries and the global events of Feed My Trip",
+		Version:     "beta",
+	})}
+	s.doc.Security = []map[string][]string{{authorizer: {}}}
+	s.doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		authorizer: {Type: "apiKey", Name: "Authorization", In: "header", Description: "The token returned by the login"},
+	}
+	s.doc.Components.Schemas["Error"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"error":   {Type: "string"},
+			"details": {Description: "The attributes of the error, like the policy rule that denied the request"},
+		},
+	}
+	s.doc.Components.Responses = map[string]*openapi.Response{
+		"Error": {Description: "The request failed", Content: openapi.JSON(openapi.Ref("Error"))},
+	}
+
+	s.authRoutes()
+	s.userRoutes()
+	s.tripRoutes()
+	s.eventRoutes()
+	s.highlightRoutes()
+	s.categoryRoutes()
+	s.locationRoutes()
+	s.webhookRoutes()
+	s.action("OpenAPIFunction", "GET", "/openapi.json", "openapi", "Returns this OpenAPI document", true, nil, http.StatusOK, &openapi.Schema{Type: "object"})
+
+	for _, tag := range []string{"auth", "users", "trips", "events", "highlights", "categories", "locations", "webhooks", "openapi"} {
+		s.doc.Tags = append(s.doc.Tags, openapi.Tag{Name: tag})
+	}
+	return s
+}
+
+func (s *spec) authRoutes() {
+	username := struct {
+		Username string `json:"username"`
+	}{}
+	login := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{}
+	confirmation := struct {
+		Username string `json:"username"`
+		Code     string `json:"code"`
+	}{}
+	reset := struct {
+		Username string `json:"username"`
+		Code     string `json:"code"`
+		Password string `json:"password"`
+	}{}
+	change := struct {
+		PreviousPassword string `json:"previous_password"`
+		ProposedPassword string `json:"proposed_password"`
+	}{}
+	logout := struct {
+		RefreshToken string `json:"refresh_token"`
+		AllDevices   bool   `json:"all_devices"`
+	}{}
+
+	s.action("AuthFunction", "POST", "/auth/register", "auth", "Creates a confirmed user, only for admins", false, s.doc.Schema(auth.Credentials{}), http.StatusCreated, s.doc.Schema(auth.DBUser{}))
+	s.action("AuthFunction", "POST", "/auth/login", "auth", "Returns the tokens of the user", true, s.doc.Schema(login), http.StatusOK, s.doc.Schema(auth.UserResponse{}))
+	op := s.action("AuthFunction", "GET", "/auth/refresh", "auth", "Returns new tokens of the user", true, nil, http.StatusOK, s.doc.Schema(auth.UserResponse{}))
+	op.Description = "The refresh token of the login is sent in the Authorization header"
+	s.action("AuthFunction", "POST", "/auth/signup", "auth", "Creates an unconfirmed user and sends the confirmation code", true, s.doc.Schema(auth.Credentials{}), http.StatusCreated, s.doc.Schema(auth.DBUser{}))
+	s.action("AuthFunction", "POST", "/auth/confirm", "auth", "Confirms the user with the code sent by email", true, s.doc.Schema(confirmation), http.StatusOK, s.doc.Schema(users.User{}))
+	s.action("AuthFunction", "POST", "/auth/confirm/resend", "auth", "Sends a new confirmation code", true, s.doc.Schema(username), http.StatusOK, nil)
+	s.action("AuthFunction", "POST", "/auth/forgot-password", "auth", "Sends the code to reset the password", true, s.doc.Schema(username), http.StatusOK, nil)
+	s.action("AuthFunction", "POST", "/auth/reset-password", "auth", "Changes the password with the code sent by email", true, s.doc.Schema(reset), http.StatusOK, nil)
+	s.action("AuthFunction", "POST", "/auth/change-password", "auth", "Changes the password of the user", false, s.doc.Schema(change), http.StatusOK, nil)
+	s.action("AuthFunction", "POST", "/auth/logout", "auth", "Revokes the refresh token of the user", false, s.doc.Schema(logout), http.StatusOK, nil)
+}
+
+func (s *spec) userRoutes() {
+	s.list("UsersFunction", "/users", "users", "Lists the users", users.User{}, false)
+	s.update("UsersFunction", "/users/{id}", "users", "Updates the user", users.User{}, false)
+	s.remove("UsersFunction", "/users/{id}", "users", "Removes the user")
+}
+
+func (s *spec) tripRoutes() {
+	participant := struct {
+		UserID string `json:"user_id"`
+		Role   string `json:"role"`
+	}{}
+	invite := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+	answer := struct {
+		Token string `json:"token"`
+	}{}
+	beginOffset := struct {
+		BeginOffset float64 `json:"begin_offset"`
+	}{}
+	reorder := struct {
+		Days []int `json:"days"`
+	}{}
+	day := struct {
+		Day int `json:"day"`
+	}{}
+	hours := struct {
+		DayStart float64 `json:"day_start"`
+		DayEnd   float64 `json:"day_end"`
+	}{}
+	swap := struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}{}
+
+	s.list("TripsFunction", "/trips", "trips", "Lists the trips of the user", trips.Trip{}, true)
+	s.create("TripsFunction", "/trips", "trips", "Creates a trip", nil, trips.Trip{}, true)
+	s.get("TripsFunction", "/trips/{id}", "trips", "Returns the trip", trips.Trip{}, true)
+	s.update("TripsFunction", "/trips/{id}", "trips", "Updates the trip", trips.Trip{}, true)
+	s.remove("TripsFunction", "/trips/{id}", "trips", "Removes the trip, it can be restored until it's purged")
+	s.history("TripsFunction", "/trips/{id}/history", "trips", "Returns the changes of the trip")
+	s.restore("TripsFunction", "/trips/{id}/restore", "trips", "Restores the removed trip", trips.Trip{})
+
+	s.list("TripsFunction", "/trips/{id}/participants", "trips", "Lists the participants of the trip", trips.Participant{}, false)
+	s.create("TripsFunction", "/trips/{id}/participants", "trips", "Adds a participant to the trip", s.doc.Schema(participant), trips.Participant{}, false)
+	s.update("TripsFunction", "/trips/{id}/participants/{participant_id}", "trips", "Changes the role of the participant", trips.Participant{}, false)
+	s.remove("TripsFunction", "/trips/{id}/participants/{participant_id}", "trips", "Removes the participant from the trip")
+
+	s.list("TripsFunction", "/trips/{id}/invites", "trips", "Lists the invites of the trip", trips.Invite{}, false)
+	s.create("TripsFunction", "/trips/{id}/invites", "trips", "Invites an email to the trip, the token is only returned here", s.doc.Schema(invite), trips.Invite{}, false)
+	s.remove("TripsFunction", "/trips/{id}/invites/{invite_id}", "trips", "Revokes the invite")
+	s.action("TripsFunction", "POST", "/trips/{id}/invites/{invite_id}/accept", "trips", "Accepts the invite with its token", false, s.doc.Schema(answer), http.StatusCreated, s.doc.Schema(trips.Participant{}))
+	s.action("TripsFunction", "POST", "/trips/{id}/invites/{invite_id}/decline", "trips", "Declines the invite with its token", false, s.doc.Schema(answer), http.StatusOK, s.doc.Schema(trips.Invite{}))
+
+	s.list("TripsFunction", "/trips/{id}/itineraries", "trips", "Lists the itineraries of the trip", trips.Itinerary{}, true)
+	s.create("TripsFunction", "/trips/{id}/itineraries", "trips", "Creates an itinerary", nil, trips.Itinerary{}, true)
+	s.get("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}", "trips", "Returns the itinerary", trips.Itinerary{}, true)
+	s.update("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}", "trips", "Updates the itinerary", trips.Itinerary{}, true)
+	s.remove("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}", "trips", "Removes the itinerary, it can be restored until it's purged")
+	s.history("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/history", "trips", "Returns the changes of the itinerary")
+	s.restore("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/restore", "trips", "Restores the removed itinerary", trips.Itinerary{})
+	s.action("TripsFunction", "GET", "/trips/{id}/itineraries/{itinerary_id}/conflicts", "trips", "Returns the overlapping, out of range and unscheduled events of the itinerary", false, nil, http.StatusOK, s.doc.Schema(trips.ItineraryConflicts{}))
+	op := s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/add/{global_event_id}", "trips", "Adds a global event to the itinerary", false, s.doc.Schema(beginOffset), http.StatusCreated, s.doc.Schema(trips.ItineraryEvent{}))
+	op.RequestBody.Required = false
+	op.Parameters = append(op.Parameters, strictParameter)
+	s.list("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/events", "trips", "Lists the events of the itinerary", trips.ItineraryEvent{}, false)
+	op = s.create("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/events", "trips", "Creates an event in the itinerary", nil, trips.ItineraryEvent{}, false)
+	op.Parameters = append(op.Parameters, checkConflictsParameter)
+	s.get("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/events/{event_id}", "trips", "Returns the itinerary event", trips.ItineraryEvent{}, true)
+	op = s.update("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/events/{event_id}", "trips", "Updates the itinerary event", trips.ItineraryEvent{}, true)
+	op.Parameters = append(op.Parameters, strictParameter, checkConflictsParameter)
+	s.remove("TripsFunction", "/trips/{id}/itineraries/{itinerary_id}/events/{event_id}", "trips", "Removes the itinerary event")
+	s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/append/{append_itinerary_id}", "trips", "Appends the days and events of another itinerary", false, nil, http.StatusOK, nil)
+	s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/swap", "trips", "Swaps the events of two days of the itinerary", false, s.doc.Schema(swap), http.StatusOK, nil)
+	s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/reorder-days", "trips", "Moves the events of the itinerary days to a new order of the days", false, s.doc.Schema(reorder), http.StatusOK, s.doc.Schema(trips.Itinerary{}))
+	s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/days", "trips", "Inserts an empty day in the itinerary", false, s.doc.Schema(day), http.StatusOK, s.doc.Schema(trips.Itinerary{}))
+	s.action("TripsFunction", "DELETE", "/trips/{id}/itineraries/{itinerary_id}/days/{day}", "trips", "Removes a day of the itinerary, its events become unscheduled", false, nil, http.StatusOK, s.doc.Schema(trips.Itinerary{}))
+	op = s.action("TripsFunction", "POST", "/trips/{id}/itineraries/{itinerary_id}/auto-schedule", "trips", "Places the unscheduled events in the free slots of the itinerary days", false, s.doc.Schema(hours), http.StatusOK, s.doc.Schema(trips.AutoSchedule{}))
+	op.RequestBody.Required = false
+	op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "apply", In: "query", Description: "Saves the placements instead of returning only the preview", Schema: &openapi.Schema{Type: "boolean"}})
+}
+
+func (s *spec) eventRoutes() {
+	s.list("EventsFunction", "/events", "events", "Lists the global events", fmt.Event{}, true)
+	s.create("EventsFunction", "/events", "events", "Creates a global event", nil, fmt.Event{}, true)
+	s.get("EventsFunction", "/events/{id}", "events", "Returns the global event", fmt.Event{}, true)
+	s.update("EventsFunction", "/events/{id}", "events", "Updates the global event", fmt.Event{}, true)
+	s.remove("EventsFunction", "/events/{id}", "events", "Removes the global event, it can be restored until it's purged")
+	s.history("EventsFunction", "/events/{id}/history", "events", "Returns the changes of the global event")
+	s.restore("EventsFunction", "/events/{id}/restore", "events", "Restores the removed global event", fmt.Event{})
+	op := s.action("EventsFunction", "GET", "/events/{id}/availability", "events", "Returns the open windows of the event by its schedules", false, nil, http.StatusOK, s.doc.Schema(fmt.AvailabilityResult{}))
+	op.Parameters = append(op.Parameters,
+		&openapi.Parameter{Name: "from", In: "query", Description: "Start of the range, a RFC 3339 time or a date, now by default", Schema: &openapi.Schema{Type: "string"}},
+		&openapi.Parameter{Name: "to", In: "query", Description: "End of the range, a week after from by default and at most 366 days after it", Schema: &openapi.Schema{Type: "string"}},
+		&openapi.Parameter{Name: "results", In: "query", Description: "Maximum number of windows", Schema: &openapi.Schema{Type: "integer", Format: "int32"}},
+	)
+
+	s.create("EventsFunction", "/events/{id}/schedules", "events", "Creates a schedule of the event", nil, fmt.Schedule{}, false)
+	s.list("EventsFunction", "/events/{id}/schedules", "events", "Lists the schedules of the event", fmt.Schedule{}, false)
+	s.update("EventsFunction", "/events/{id}/schedules/{schedule_id}", "events", "Updates the schedule", fmt.Schedule{}, false)
+	s.remove("EventsFunction", "/events/{id}/schedules/{schedule_id}", "events", "Removes the schedule")
+}
+
+func (s *spec) highlightRoutes() {
+	s.list("HighlightsFunction", "/highlights", "highlights", "Lists the highlights", highlights.Highlight{}, false)
+	s.create("HighlightsFunction", "/highlights", "highlights", "Creates a highlight", nil, highlights.Highlight{}, false)
+	s.get("HighlightsFunction", "/highlights/{id}", "highlights", "Returns the highlight", highlights.Highlight{}, false)
+	s.update("HighlightsFunction", "/highlights/{id}", "highlights", "Updates the highlight", highlights.Highlight{}, false)
+	s.remove("HighlightsFunction", "/highlights/{id}", "highlights", "Removes the highlight")
+
+	s.create("HighlightsFunction", "/highlights/{id}/images", "highlights", "Adds an image to the highlight", nil, highlights.HighlightImage{}, false)
+	s.list("HighlightsFunction", "/highlights/{id}/images", "highlights", "Lists the images of the highlight", highlights.HighlightImage{}, false)
+	s.remove("HighlightsFunction", "/highlights/{id}/images/{image_id}", "highlights", "Removes the image")
+}
+
+func (s *spec) categoryRoutes() {
+	s.list("CategoriesFunction", "/categories", "categories", "Lists the categories", categories.Category{}, false)
+	s.create("CategoriesFunction", "/categories", "categories", "Creates a category", nil, categories.Category{}, true)
+	s.get("CategoriesFunction", "/categories/{id}", "categories", "Returns the category", categories.Category{}, true)
+	s.update("CategoriesFunction", "/categories/{id}", "categories", "Updates the category", categories.Category{}, true)
+	s.remove("CategoriesFunction", "/categories/{id}", "categories", "Removes the category")
+	s.history("CategoriesFunction", "/categories/{id}/history", "categories", "Returns the changes of the category")
+}
+
+func (s *spec) locationRoutes() {
+	s.create("LocationsFunction", "/locations", "locations", "Creates a location", nil, locations.Location{}, false)
+	s.list("LocationsFunction", "/locations", "locations", "Lists the locations", locations.Location{}, false)
+	s.update("LocationsFunction", "/locations/{id}", "locations", "Updates the location", locations.Location{}, false)
+	s.remove("LocationsFunction", "/locations/{id}", "locations", "Removes the location")
+}
+
+func (s *spec) webhookRoutes() {
+	subscription := struct {
+		TripID     string `json:"trip_id"`
+		URL        string `json:"url"`
+		EventTypes string `json:"event_types"`
+		Active     bool   `json:"active"`
+	}{}
+
+	s.list("WebhooksFunction", "/webhooks", "webhooks", "Lists the webhook subscriptions of the user", webhooks.Subscription{}, false)
+	s.create("WebhooksFunction", "/webhooks", "webhooks", "Subscribes an endpoint, the signing secret is only returned here", s.doc.Schema(subscription), webhooks.Subscription{}, false)
+	s.get("WebhooksFunction", "/webhooks/{id}", "webhooks", "Returns the subscription", webhooks.Subscription{}, false)
+	s.update("WebhooksFunction", "/webhooks/{id}", "webhooks", "Updates the subscription", webhooks.Subscription{}, false)
+	s.remove("WebhooksFunction", "/webhooks/{id}", "webhooks", "Removes the subscription")
+	s.list("WebhooksFunction", "/webhooks/{id}/deliveries", "webhooks", "Lists the deliveries of the subscription", webhooks.Delivery{}, false)
+	s.action("WebhooksFunction", "POST", "/webhooks/{id}/deliveries/{delivery_id}/redeliver", "webhooks", "Sends the payload of the delivery again", false, nil, http.StatusCreated, s.doc.Schema(webhooks.Delivery{}))
+}
+
+//add adds the operation of the function route, the public operations have no security requirement
+func (s *spec) add(function, method, path, tag, summary string, public bool, op *openapi.Operation) *openapi.Operation {
+	op.Tags = []string{tag}
+	op.Summary = summary
+	op.OperationID = operationID(method, path)
+	op.Responses["default"] = &openapi.Response{Ref: "#/components/responses/Error"}
+	route := Route{Function: function, Path: path, Method: method, Authorizer: authorizer}
+	if public {
+		op.Security = &[]map[string][]string{}
+		route.Authorizer = ""
+	}
+	s.doc.AddOperation(method, path, op)
+	s.routes = append(s.routes, route)
+	return op
+}
+
+func (s *spec) list(function, path, tag, summary string, object interface{}, softDelete bool) {
+	s.add(function, "GET", path, tag, summary, false, &openapi.Operation{
+		Parameters: s.doc.ListParameters(object, softDelete),
+		Responses:  responses(http.StatusOK, s.doc.ListSchema(object), false),
+	})
+}
+
+func (s *spec) get(function, path, tag, summary string, object interface{}, versioned bool) {
+	op := &openapi.Operation{Responses: responses(http.StatusOK, s.doc.Schema(object), versioned)}
+	if versioned {
+		op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "If-None-Match", In: "header", Description: "The ETag of the cached record", Schema: &openapi.Schema{Type: "string"}})
+		op.Responses[strconv.Itoa(http.StatusNotModified)] = &openapi.Response{Description: "The record didn't change"}
+	}
+	s.add(function, "GET", path, tag, summary, false, op)
+}
+
+//create documents the POST of a new record, the body is the record schema when it's nil
+func (s *spec) create(function, path, tag, summary string, body *openapi.Schema, object interface{}, versioned bool) *openapi.Operation {
+	if body == nil {
+		body = s.doc.Schema(object)
+	}
+	return s.add(function, "POST", path, tag, summary, false, &openapi.Operation{
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(body)},
+		Responses:   responses(http.StatusCreated, s.doc.Schema(object), versioned),
+	})
+}
+
+func (s *spec) update(function, path, tag, summary string, object interface{}, versioned bool) *openapi.Operation {
+	op := &openapi.Operation{
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(s.doc.UpdateSchema(object))},
+		Responses:   responses(http.StatusOK, s.doc.Schema(object), versioned),
+	}
+	if versioned {
+		op.Parameters = append(op.Parameters, &openapi.Parameter{Name: "If-Match", In: "header", Description: "The ETag of the record changed, the update fails when it's stale", Schema: &openapi.Schema{Type: "string"}})
+		op.Responses[strconv.Itoa(http.StatusPreconditionFailed)] = &openapi.Response{Description: "The record changed after the ETag of If-Match", Content: openapi.JSON(openapi.Ref("Error"))}
+	}
+	return s.add(function, "PATCH", path, tag, summary, false, op)
+}
+
+func (s *spec) remove(function, path, tag, summary string) {
+	s.add(function, "DELETE", path, tag, summary, false, &openapi.Operation{
+		Responses: responses(http.StatusOK, &openapi.Schema{Type: "object"}, false),
+	})
+}
+
+func (s *spec) history(function, path, tag, summary string) {
+	entries := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{
+		"data": {Type: "array", Items: s.doc.Schema(db.AuditEntry{})},
+	}}
+	s.add(function, "GET", path, tag, summary, false, &openapi.Operation{
+		Responses: responses(http.StatusOK, entries, false),
+	})
+}
+
+func (s *spec) restore(function, path, tag, summary string, object interface{}) {
+	s.add(function, "POST", path, tag, summary, false, &openapi.Operation{
+		Responses: responses(http.StatusOK, s.doc.Schema(object), true),
+	})
+}
+
+//action documents the routes that aren't a change of a single record, the response is an empty object when it's nil
+func (s *spec) action(function, method, path, tag, summary string, public bool, body *openapi.Schema, status int, response *openapi.Schema) *openapi.Operation {
+	if response == nil {
+		response = &openapi.Schema{Type: "object"}
+	}
+	op := &openapi.Operation{Responses: responses(status, response, false)}
+	if body != nil {
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(body)}
+	}
+	return s.add(function, method, path, tag, summary, public, op)
+}
+
+//responses returns the success response of the operation, versioned adds the ETag header with the record version
+func responses(status int, schema *openapi.Schema, versioned bool) map[string]*openapi.Response {
+	response := &openapi.Response{Description: http.StatusText(status), Content: openapi.JSON(schema)}
+	if versioned {
+		response.Headers = map[string]*openapi.Header{
+			"ETag": {Description: "The version of the record", Schema: &openapi.Schema{Type: "string"}},
+		}
+	}
+	return map[string]*openapi.Response{strconv.Itoa(status): response}
+}
+
+//operationID names the operation by the method and the path, like getTripsByIdItineraries
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") {
+			id += "By"
+		}
+		words := strings.FieldsFunc(segment, func(r rune) bool {
+			return strings.ContainsRune("{}_-.", r)
+		})
+		for _, word := range words {
+			id += strings.Title(word)
+		}
+	}
+	return id
+}
@@ -0,0 +1,180 @@
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common/apitest"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func sortRoutes(routes []Route) []Route {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+func TestDocumentTemplateRoutes(t *testing.T) {
+	f, err := os.Open("../template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	templateRoutes, err := ParseTemplate(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//every Api event of the template is documented once, with its function and authorizer
+	s := newSpec()
+	assert.Equal(t, sortRoutes(templateRoutes), sortRoutes(s.routes))
+	assert.Len(t, s.doc.Operations(), len(templateRoutes))
+}
+
+func TestDocumentOperationsRouted(t *testing.T) {
+	store := db.NewMemoryStore()
+	provider := auth.NewLocalProvider(store, auth.LocalConfig{Key: apitest.Key(), KeyID: apitest.KeyID, Issuer: apitest.Issuer, Audience: apitest.Audience})
+	functions := Functions(store, provider, nil)
+	token := apitest.Token("test_admin", "Admin")
+
+	for _, route := range newSpec().routes {
+		router, ok := functions[route.Function]
+		if !assert.True(t, ok, route.Function) {
+			continue
+		}
+		params := map[string]string{}
+		for _, segment := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(segment, "{") {
+				params[strings.Trim(segment, "{}")] = "unknown"
+			}
+		}
+		response, err := router(events.APIGatewayProxyRequest{
+			Resource:       route.Path,
+			HTTPMethod:     route.Method,
+			PathParameters: params,
+			Headers:        map[string]string{"Authorization": token},
+		})
+		assert.Nil(t, err)
+		assert.NotEqual(t, http.StatusMethodNotAllowed, response.StatusCode, route.Method+" "+route.Path)
+	}
+}
+
+func TestDocumentReferences(t *testing.T) {
+	doc := Document()
+	body, err := json.Marshal(doc)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var refs func(value interface{})
+	refs = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				if ref, ok := item.(string); ok && key == "$ref" {
+					switch {
+					case strings.HasPrefix(ref, "#/components/schemas/"):
+						assert.Contains(t, doc.Components.Schemas, strings.TrimPrefix(ref, "#/components/schemas/"))
+					case strings.HasPrefix(ref, "#/components/responses/"):
+						assert.Contains(t, doc.Components.Responses, strings.TrimPrefix(ref, "#/components/responses/"))
+					default:
+						t.Errorf("unexpected reference %s", ref)
+					}
+					continue
+				}
+				refs(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				refs(item)
+			}
+		}
+	}
+	var value interface{}
+	json.Unmarshal(body, &value)
+	refs(value)
+
+	ids := map[string]bool{}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			assert.False(t, ids[op.OperationID], op.OperationID)
+			ids[op.OperationID] = true
+		}
+	}
+}
+
+func TestDocumentSchemas(t *testing.T) {
+	doc := Document()
+
+	trip := doc.Components.Schemas["Trip"]
+	if assert.NotNil(t, trip) {
+		assert.True(t, trip.Properties["scope"].ReadOnly)
+		assert.True(t, trip.Properties["version"].ReadOnly)
+		assert.True(t, trip.Properties["created_by"].ReadOnly)
+		assert.True(t, trip.Properties["deleted_at"].Nullable)
+		assert.False(t, trip.Properties["title"].ReadOnly)
+		assert.False(t, trip.Properties["active"].ReadOnly)
+		//the translations loaded by the joins aren't changed by the requests
+		assert.True(t, trip.Properties["country"].ReadOnly)
+	}
+
+	update := doc.Components.Schemas["TripUpdate"]
+	if assert.NotNil(t, update) {
+		assert.Contains(t, update.Properties, "title.pt")
+		assert.Contains(t, update.Properties, "active")
+		assert.NotContains(t, update.Properties, "version")
+		assert.NotContains(t, update.Properties, "country")
+	}
+
+	list := doc.Components.Schemas["TripList"]
+	if assert.NotNil(t, list) {
+		assert.Equal(t, "#/components/schemas/Trip", list.Properties["data"].Items.Ref)
+		assert.Equal(t, "#/components/schemas/ListMetadata", list.Properties["metadata"].Ref)
+	}
+
+	op := doc.Paths["/trips"]["get"]
+	names := []string{}
+	for _, param := range op.Parameters {
+		names = append(names, param.Name)
+	}
+	assert.Subset(t, names, []string{"page", "results", "cursor", "sort", "order", "count", "include_deleted", "created_date"})
+
+	op = doc.Paths["/trips/{id}"]["patch"]
+	assert.Equal(t, "If-Match", op.Parameters[1].Name)
+	assert.Contains(t, op.Responses, "412")
+	assert.Contains(t, op.Responses["200"].Headers, "ETag")
+	assert.Nil(t, op.Security)
+
+	op = doc.Paths["/openapi.json"]["get"]
+	if assert.NotNil(t, op.Security) {
+		assert.Empty(t, *op.Security)
+	}
+}
+
+func TestOpenAPIRouter(t *testing.T) {
+	router := OpenAPI()
+	response, err := router(events.APIGatewayProxyRequest{Resource: "/openapi.json", HTTPMethod: "GET"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	doc := struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}{}
+	assert.Nil(t, json.Unmarshal([]byte(response.Body), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/trips/{id}/itineraries/{itinerary_id}")
+
+	response, _ = router(events.APIGatewayProxyRequest{Resource: "/openapi.json", HTTPMethod: "POST"})
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
@@ -0,0 +1,78 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	fmt "github.com/feedmytrip/api/resources/events"
+)
+
+//Events routes the requests of the events function
+func Events(repository fmt.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		switch req.Resource {
+		case "/events":
+			event := fmt.Event{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.GetAll(req, repository)
+			case "POST":
+				return event.SaveNew(req, repository)
+			}
+		case "/events/{id}":
+			event := fmt.Event{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.Get(req, repository)
+			case "DELETE":
+				return event.Delete(req, repository)
+			case "PATCH":
+				return event.Update(req, repository)
+			}
+		case "/events/{id}/history":
+			event := fmt.Event{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.History(req, repository)
+			}
+		case "/events/{id}/restore":
+			event := fmt.Event{}
+			switch req.HTTPMethod {
+			case "POST":
+				return event.Restore(req, repository)
+			}
+		case "/events/{id}/availability":
+			event := fmt.Event{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.Availability(req, repository)
+			}
+		case "/events/{id}/schedules":
+			schedule := fmt.Schedule{}
+			switch req.HTTPMethod {
+			case "POST":
+				return schedule.SaveNew(req, repository)
+			case "GET":
+				return schedule.GetAll(req, repository)
+			}
+		case "/events/{id}/schedules/{schedule_id}":
+			schedule := fmt.Schedule{}
+			switch req.HTTPMethod {
+			case "PATCH":
+				return schedule.Update(req, repository)
+			case "DELETE":
+				return schedule.Delete(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
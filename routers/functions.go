@@ -0,0 +1,28 @@
+package routers
+
+import (
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/auth"
+	"github.com/feedmytrip/api/resources/categories"
+	"github.com/feedmytrip/api/resources/events"
+	"github.com/feedmytrip/api/resources/highlights"
+	"github.com/feedmytrip/api/resources/locations"
+	"github.com/feedmytrip/api/resources/trips"
+	"github.com/feedmytrip/api/resources/users"
+	"github.com/feedmytrip/api/resources/webhooks"
+)
+
+//Functions returns the routers by the function names of the SAM template, all of them using the same store
+func Functions(store db.Store, provider auth.IdentityProvider, providerErr error) map[string]Router {
+	return map[string]Router{
+		"AuthFunction":       Auth(provider, providerErr, users.NewRepository(store)),
+		"UsersFunction":      Users(users.NewRepository(store)),
+		"TripsFunction":      Trips(trips.NewRepository(store)),
+		"EventsFunction":     Events(events.NewRepository(store)),
+		"HighlightsFunction": Highlights(highlights.NewRepository(store)),
+		"CategoriesFunction": Categories(categories.NewRepository(store)),
+		"LocationsFunction":  Locations(locations.NewRepository(store)),
+		"WebhooksFunction":   Webhooks(webhooks.NewRepository(store)),
+		"OpenAPIFunction":    OpenAPI(),
+	}
+}
@@ -0,0 +1,56 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/highlights"
+)
+
+//Highlights routes the requests of the highlights function
+func Highlights(repository highlights.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		highlight := highlights.Highlight{}
+		images := highlights.HighlightImage{}
+		switch req.Resource {
+		case "/highlights":
+			switch req.HTTPMethod {
+			case "POST":
+				return highlight.SaveNew(req, repository)
+			case "GET":
+				return highlight.GetAll(req, repository)
+			}
+		case "/highlights/{id}":
+			switch req.HTTPMethod {
+			case "GET":
+				return highlight.Get(req, repository)
+			case "DELETE":
+				return highlight.Delete(req, repository)
+			case "PATCH":
+				return highlight.Update(req, repository)
+			}
+		case "/highlights/{id}/images":
+			switch req.HTTPMethod {
+			case "POST":
+				return images.SaveNew(req, repository)
+			case "GET":
+				return images.GetAll(req, repository)
+			}
+		case "/highlights/{id}/images/{image_id}":
+			switch req.HTTPMethod {
+			case "DELETE":
+				return images.Delete(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
@@ -0,0 +1,41 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/locations"
+)
+
+//Locations routes the requests of the locations function
+func Locations(repository locations.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		location := locations.Location{}
+		switch req.Resource {
+		case "/locations":
+			switch req.HTTPMethod {
+			case "POST":
+				return location.SaveNew(req, repository)
+			case "GET":
+				return location.GetAll(req, repository)
+			}
+		case "/locations/{id}":
+			switch req.HTTPMethod {
+			case "DELETE":
+				return location.Delete(req, repository)
+			case "PATCH":
+				return location.Update(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
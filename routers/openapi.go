@@ -0,0 +1,22 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+)
+
+//OpenAPI routes the requests of the openapi function, the document is public
+func OpenAPI() Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		switch req.Resource {
+		case "/openapi.json":
+			switch req.HTTPMethod {
+			case "GET":
+				return common.APIResponse(Document(), http.StatusOK)
+			}
+		}
+		return methodNotAllowed()
+	}
+}
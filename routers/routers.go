@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+//Router handles the API Gateway requests of one lambda function
+type Router func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+//methodNotAllowed is the response of the resources and methods not routed by the function
+func methodNotAllowed() (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusMethodNotAllowed,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin":      "*",
+			"Access-Control-Allow-Credentials": "true",
+		},
+	}, nil
+}
@@ -0,0 +1,73 @@
+package routers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+//Route is an Api event of a function declared in the SAM template
+type Route struct {
+	Function string
+	Path     string
+	Method   string
+	//Authorizer is empty for the routes without authentication
+	Authorizer string
+}
+
+//ParseTemplate reads the Api events of the functions in the SAM template. Only the keys used by the
+//routes are read, so the template tags like !Ref don't need a yaml parser.
+func ParseTemplate(r io.Reader) ([]Route, error) {
+	routes := []Route{}
+	resource, isFunction, inEvents := "", false, false
+	current := Route{}
+	flush := func() {
+		if current.Path != "" && current.Method != "" {
+			routes = append(routes, current)
+		}
+		current = Route{Function: resource}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value := text, ""
+		if i := strings.Index(text, ":"); i >= 0 {
+			key, value = text[:i], strings.Trim(strings.TrimSpace(text[i+1:]), `"'`)
+		}
+
+		switch {
+		case indent == 2:
+			flush()
+			resource, isFunction, inEvents = key, false, false
+			current.Function = resource
+		case indent == 4 && key == "Type":
+			isFunction = value == "AWS::Serverless::Function"
+		case indent == 6:
+			inEvents = key == "Events"
+		case !isFunction || !inEvents:
+		case indent == 8:
+			flush()
+		case key == "Path":
+			current.Path = value
+		case key == "Method":
+			current.Method = strings.ToUpper(value)
+		case key == "Authorizer":
+			current.Authorizer = value
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("the template has no Api events")
+	}
+	return routes, nil
+}
@@ -0,0 +1,194 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/trips"
+)
+
+//Trips routes the requests of the trips function
+func Trips(repository trips.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		switch req.Resource {
+		case "/trips":
+			trip := trips.Trip{}
+			switch req.HTTPMethod {
+			case "GET":
+				return trip.GetAll(req, repository)
+			case "POST":
+				return trip.SaveNew(req, repository)
+			}
+		case "/trips/{id}":
+			trip := trips.Trip{}
+			switch req.HTTPMethod {
+			case "GET":
+				return trip.Get(req, repository)
+			case "PATCH":
+				return trip.Update(req, repository)
+			case "DELETE":
+				return trip.Delete(req, repository)
+			}
+		case "/trips/{id}/history":
+			trip := trips.Trip{}
+			switch req.HTTPMethod {
+			case "GET":
+				return trip.History(req, repository)
+			}
+		case "/trips/{id}/restore":
+			trip := trips.Trip{}
+			switch req.HTTPMethod {
+			case "POST":
+				return trip.Restore(req, repository)
+			}
+		case "/trips/{id}/participants":
+			participant := trips.Participant{}
+			switch req.HTTPMethod {
+			case "GET":
+				return participant.GetAll(req, repository)
+			case "POST":
+				return participant.SaveNew(req, repository)
+			}
+		case "/trips/{id}/participants/{participant_id}":
+			participant := trips.Participant{}
+			switch req.HTTPMethod {
+			case "PATCH":
+				return participant.Update(req, repository)
+			case "DELETE":
+				return participant.Delete(req, repository)
+			}
+		case "/trips/{id}/invites":
+			invite := trips.Invite{}
+			switch req.HTTPMethod {
+			case "GET":
+				return invite.GetAll(req, repository)
+			case "POST":
+				return invite.SaveNew(req, repository)
+			}
+		case "/trips/{id}/invites/{invite_id}":
+			invite := trips.Invite{}
+			switch req.HTTPMethod {
+			case "DELETE":
+				return invite.Delete(req, repository)
+			}
+		case "/trips/{id}/invites/{invite_id}/accept":
+			invite := trips.Invite{}
+			switch req.HTTPMethod {
+			case "POST":
+				return invite.Accept(req, repository)
+			}
+		case "/trips/{id}/invites/{invite_id}/decline":
+			invite := trips.Invite{}
+			switch req.HTTPMethod {
+			case "POST":
+				return invite.Decline(req, repository)
+			}
+		case "/trips/{id}/itineraries":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "GET":
+				return itinerary.GetAll(req, repository)
+			case "POST":
+				return itinerary.SaveNew(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "GET":
+				return itinerary.Get(req, repository)
+			case "PATCH":
+				return itinerary.Update(req, repository)
+			case "DELETE":
+				return itinerary.Delete(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/history":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "GET":
+				return itinerary.History(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/restore":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.Restore(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/conflicts":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "GET":
+				return itinerary.Conflicts(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/auto-schedule":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.AutoSchedule(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/events":
+			event := trips.ItineraryEvent{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.GetAll(req, repository)
+			case "POST":
+				return event.SaveNew(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/events/{event_id}":
+			event := trips.ItineraryEvent{}
+			switch req.HTTPMethod {
+			case "GET":
+				return event.Get(req, repository)
+			case "PATCH":
+				return event.Update(req, repository)
+			case "DELETE":
+				return event.Delete(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/add/{global_event_id}":
+			event := trips.ItineraryEvent{}
+			switch req.HTTPMethod {
+			case "POST":
+				return event.Add(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/append/{append_itinerary_id}":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.Append(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/swap":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.SwapDay(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/reorder-days":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.ReorderDays(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/days":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "POST":
+				return itinerary.InsertDay(req, repository)
+			}
+		case "/trips/{id}/itineraries/{itinerary_id}/days/{day}":
+			itinerary := trips.Itinerary{}
+			switch req.HTTPMethod {
+			case "DELETE":
+				return itinerary.DeleteDay(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
@@ -0,0 +1,39 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/users"
+)
+
+//Users routes the requests of the users function
+func Users(repository users.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		user := users.User{}
+		switch req.Resource {
+		case "/users":
+			switch req.HTTPMethod {
+			case "GET":
+				return user.GetAll(req, repository)
+			}
+		case "/users/{id}":
+			switch req.HTTPMethod {
+			case "PATCH":
+				return user.Update(req, repository)
+			case "DELETE":
+				return user.Delete(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}
@@ -0,0 +1,56 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/feedmytrip/api/common"
+	"github.com/feedmytrip/api/db"
+	"github.com/feedmytrip/api/resources/webhooks"
+)
+
+//Webhooks routes the requests of the webhooks function
+func Webhooks(repository webhooks.Repository) Router {
+	return func(req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tokenUser, err := common.GetTokenUser(req)
+		if err != nil {
+			return common.APIError(http.StatusUnauthorized, err)
+		}
+		db.SetAuditContext(tokenUser.UserID, req.RequestContext.RequestID)
+
+		switch req.Resource {
+		case "/webhooks":
+			subscription := webhooks.Subscription{}
+			switch req.HTTPMethod {
+			case "GET":
+				return subscription.GetAll(req, repository)
+			case "POST":
+				return subscription.SaveNew(req, repository)
+			}
+		case "/webhooks/{id}":
+			subscription := webhooks.Subscription{}
+			switch req.HTTPMethod {
+			case "GET":
+				return subscription.Get(req, repository)
+			case "PATCH":
+				return subscription.Update(req, repository)
+			case "DELETE":
+				return subscription.Delete(req, repository)
+			}
+		case "/webhooks/{id}/deliveries":
+			delivery := webhooks.Delivery{}
+			switch req.HTTPMethod {
+			case "GET":
+				return delivery.GetAll(req, repository)
+			}
+		case "/webhooks/{id}/deliveries/{delivery_id}/redeliver":
+			delivery := webhooks.Delivery{}
+			switch req.HTTPMethod {
+			case "POST":
+				return delivery.Redeliver(req, repository)
+			}
+		}
+
+		return methodNotAllowed()
+	}
+}